@@ -12,6 +12,8 @@ import (
 const version = "1.0.112"
 
 func main() {
+	internal.Version = version
+
 	if len(os.Args) < 2 {
 		printHelp()
 		os.Exit(0)
@@ -90,6 +92,36 @@ func main() {
 		return
 	}
 
+	if strings.HasPrefix(command, "logs:") {
+		subcommand := strings.TrimPrefix(command, "logs:")
+		commands.Logs(append([]string{subcommand}, os.Args[2:]...))
+		return
+	}
+
+	if strings.HasPrefix(command, "metrics:") {
+		subcommand := strings.TrimPrefix(command, "metrics:")
+		commands.Metrics(append([]string{subcommand}, os.Args[2:]...))
+		return
+	}
+
+	if strings.HasPrefix(command, "cron:") {
+		subcommand := strings.TrimPrefix(command, "cron:")
+		commands.Cron(append([]string{subcommand}, os.Args[2:]...))
+		return
+	}
+
+	if strings.HasPrefix(command, "notifications:") {
+		subcommand := strings.TrimPrefix(command, "notifications:")
+		commands.Notifications(append([]string{subcommand}, os.Args[2:]...))
+		return
+	}
+
+	if strings.HasPrefix(command, "ports:") {
+		subcommand := strings.TrimPrefix(command, "ports:")
+		commands.Ports(append([]string{subcommand}, os.Args[2:]...))
+		return
+	}
+
 	if strings.HasPrefix(command, "remote:") {
 		subcommand := strings.TrimPrefix(command, "remote:")
 		commands.Remote(append([]string{subcommand}, os.Args[2:]...))
@@ -124,6 +156,8 @@ func main() {
 		commands.Services(os.Args[2:])
 	case "ps":
 		commands.Processes(os.Args[2:])
+	case "ports":
+		commands.Ports(os.Args[2:])
 	case "au", "update", "auto-update":
 		commands.AutoUpdate(os.Args[2:])
 	case "uninstall":
@@ -179,7 +213,7 @@ CLIENT COMMANDS (run from local machine):
   remote        Manage git remotes (add, list, remove, setup)
   apps          List applications on remote server
   config         Manage environment variables (use -a with git remote)
-  run            Run arbitrary commands (use -a)
+  run            Run commands in a one-off container (use -a)
   logs           View application logs (use -a)
   status         Check services status (use -a)
   restart        Restart services (use -a)
@@ -189,17 +223,22 @@ CLIENT COMMANDS (run from local machine):
   plugins        Manage plugins
   services       Manage services
   ps             Process management (list, restart, stop)
+  ports          List host ports allocated by port_strategy: auto
+  cron           Scheduled jobs from gokku.yml (cron:list, cron:run, cron:history)
+  notifications  Deploy event notifications (notifications:list, notifications:test)
   uninstall      Remove Gokku installation
   version        Show version
   help           Show this help
 
 SERVER COMMANDS (run directly on server):
   config         Manage environment variables locally (use -a with app name)
-  run            Run arbitrary commands locally
-  logs           View application logs locally
+  run            Run commands in a one-off container locally
+  logs           View application logs locally (logs:forward ships them to apps[].logging sinks)
   status         Check services status locally
   restart        Restart services locally
   rollback       Rollback to previous release locally
+  metrics        Prometheus metrics endpoint (metrics:serve)
+  notifications  Retry queued notifications (notifications:flush, run it from cron)
 
 Remote Management:
   gokku remote add <app_name> <user@host>                      Add a git remote
@@ -213,9 +252,9 @@ Client Commands (always use -a with git remote):
   gokku config list -a <git-remote>
   gokku config unset KEY -a <git-remote>
 
-  gokku run <command> -a <git-remote>
+  gokku run [--detached] [--size <size>] <command> -a <git-remote>
 
-  gokku logs -a <git-remote> [-f]
+  gokku logs -a <git-remote> [-f] [--tail <n>] [--grep <regexp>] [--process <type>]
   gokku status -a <git-remote>
   gokku restart -a <git-remote>
 
@@ -226,9 +265,17 @@ Client Commands (always use -a with git remote):
   gokku ps:restart -a <git-remote>
   gokku ps:stop -a <git-remote>
 
+  gokku ports:list --remote <git-remote>
+
+  gokku cron:list -a <app> --remote <git-remote>
+  gokku cron:run <job> -a <app> --remote <git-remote>
+
+  gokku notifications:list -a <app> --remote <git-remote>
+  gokku notifications:test -a <app> --remote <git-remote>
+
 Server Commands (run on server only, use -a with app name):
   gokku run <command>                                (run locally)
-  gokku logs <app> <env> [-f]                        (view logs locally)
+  gokku logs -a <app> [-f] [--tail <n>]              (view logs locally)
   gokku status [app]                                 (check status locally)
   gokku restart <app>                                (restart locally)
   gokku rollback <app> <env> [release-id]            (rollback locally)
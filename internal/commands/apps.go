@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"gokku/internal"
+	"gokku/internal/containers"
 	"gokku/internal/services"
 	"gokku/tui"
 )
@@ -302,6 +303,12 @@ func destroyApp(args []string) {
 			return
 		}
 
+		// Plugins clean up while the app's data still exists
+		if err := runAppHook(nil, internal.HookAppDestroy, appName); err != nil {
+			fmt.Printf("Failed to destroy app: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Remove app directory and repository directly
 		destroyCmd := exec.Command("bash", "-c", fmt.Sprintf(`
 			set -e
@@ -320,6 +327,18 @@ func destroyApp(args []string) {
 			os.Exit(1)
 		}
 
+		if err := containers.NewPortLedger("/opt/gokku").ReleaseApp(appName); err != nil {
+			fmt.Printf("Warning: failed to release ports: %v\n", err)
+		}
+
+		if err := internal.RemoveCronJobs(appName); err != nil {
+			fmt.Printf("Warning: failed to remove cron jobs: %v\n", err)
+		}
+
+		if err := internal.RemoveNetwork(internal.AppNetworkName(appName)); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+
 		fmt.Println("✓ App destroyed successfully!")
 	} else {
 		// Client mode - require remote flag
@@ -349,12 +368,16 @@ func destroyApp(args []string) {
 		// Remove app directory and repository via SSH
 		destroyCmd := exec.Command("ssh", remoteInfo.Host, fmt.Sprintf(`
 			set -e
+			gokku plugins:hooks run app-destroy -a %s
 			echo "Removing app directory..."
 			sudo rm -rf /opt/gokku/apps/%s
 			echo "Removing repository..."
 			sudo rm -rf /opt/gokku/repos/%s.git
+			gokku ports release %s >/dev/null 2>&1 || true
+			gokku cron:uninstall -a %s >/dev/null 2>&1 || true
+			docker network rm %s >/dev/null 2>&1 || true
 			echo "App destroyed successfully"
-		`, appName, appName))
+		`, appName, appName, appName, appName, appName, internal.AppNetworkName(appName)))
 
 		destroyCmd.Stdout = os.Stdout
 		destroyCmd.Stderr = os.Stderr
@@ -742,10 +765,29 @@ echo "-----> Done"
 		}
 	}
 
+	if !isRemote {
+		remoteInfo = nil
+	}
+
+	if err := runAppHook(remoteInfo, internal.HookAppCreate, appName); err != nil {
+		return err
+	}
+
 	fmt.Println("✓ Complete setup finished")
 	return nil
 }
 
+// runAppHook runs the plugin hooks for an app lifecycle event, on the
+// server over SSH when remoteInfo is set
+func runAppHook(remoteInfo *internal.RemoteInfo, event, appName string) error {
+	if remoteInfo != nil {
+		cmd := fmt.Sprintf("gokku plugins:hooks run %s -a %s", event, internal.ShellQuote(appName))
+		return internal.ExecuteRemoteCommand(remoteInfo, cmd)
+	}
+
+	return internal.RunHooks(internal.HookPayload{Event: event, App: appName})
+}
+
 // getDeployUser gets the deploy user from SSH connection
 func getDeployUser(host string) (string, error) {
 	cmd := exec.Command("ssh", host, "whoami")
@@ -0,0 +1,236 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokku/internal"
+	"gokku/internal/services"
+	"gokku/tui"
+)
+
+// backupPolicyFlags change a service's backup policy instead of backing it up
+var backupPolicyFlags = []string{"--schedule", "--keep", "--target", "--endpoint"}
+
+// handleServicesBackup backs a service up now, or changes its backup policy
+func handleServicesBackup(args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printBackupUsage()
+		os.Exit(1)
+	}
+
+	if remoteInfo != nil {
+		forwardServicesCommand(remoteInfo, "backup", args)
+		return
+	}
+
+	serviceName := args[0]
+	bm := services.NewBackupManager("")
+
+	for _, flag := range backupPolicyFlags {
+		if slices.Contains(args[1:], flag) {
+			setBackupPolicy(bm, serviceName, args[1:])
+			return
+		}
+	}
+
+	fmt.Printf("-----> Backing up %s\n", serviceName)
+
+	backup, err := bm.Backup(serviceName, os.Stdout)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Backup %s stored at %s (%s)\n", backup.ID, backup.Location, formatBackupSize(backup.Size))
+}
+
+// setBackupPolicy applies the policy flags on top of the service's policy
+func setBackupPolicy(bm *services.BackupManager, serviceName string, args []string) {
+	policy, err := bm.Policy(serviceName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if slices.Contains(args, "--schedule") {
+		policy.Schedule = internal.ExtractFlagValue(args, "--schedule")
+
+		if policy.Schedule == "off" {
+			policy.Schedule = ""
+		}
+	}
+
+	if slices.Contains(args, "--keep") {
+		keep, err := strconv.Atoi(internal.ExtractFlagValue(args, "--keep"))
+		if err != nil {
+			fmt.Println("Error: --keep must be a number")
+			os.Exit(1)
+		}
+
+		policy.Keep = keep
+	}
+
+	if slices.Contains(args, "--target") {
+		policy.Target = internal.ExtractFlagValue(args, "--target")
+
+		if policy.Target == "local" {
+			policy.Target = ""
+			policy.Endpoint = ""
+		}
+	}
+
+	if slices.Contains(args, "--endpoint") {
+		policy.Endpoint = internal.ExtractFlagValue(args, "--endpoint")
+	}
+
+	if err := bm.SetPolicy(serviceName, policy); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Backup policy of %s updated\n", serviceName)
+	printBackupPolicy(policy)
+}
+
+// handleServicesBackups lists a service's backups and its backup policy
+func handleServicesBackups(args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 1 {
+		fmt.Println("Usage: gokku services:backups <service> [--remote]")
+		os.Exit(1)
+	}
+
+	if remoteInfo != nil {
+		forwardServicesCommand(remoteInfo, "backups", args)
+		return
+	}
+
+	serviceName := args[0]
+	bm := services.NewBackupManager("")
+
+	policy, err := bm.Policy(serviceName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("===== Backups of %s\n", serviceName)
+	printBackupPolicy(policy)
+
+	backups, err := bm.List(serviceName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(backups) == 0 {
+		fmt.Println("No backups yet")
+		return
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"ID", "CREATED", "SIZE", "LOCATION"})
+
+	for _, backup := range backups {
+		table.AppendRow([]string{
+			backup.ID,
+			backup.CreatedAt.Local().Format(time.RFC3339),
+			formatBackupSize(backup.Size),
+			backup.Location,
+		})
+	}
+
+	fmt.Print(table.Render())
+}
+
+// handleServicesRestore loads a backup back into its service
+func handleServicesRestore(args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 2 {
+		fmt.Println("Usage: gokku services:restore <service> <backup-id> [--remote]")
+		fmt.Println("")
+		fmt.Println("List backup ids with: gokku services:backups <service>")
+		os.Exit(1)
+	}
+
+	if remoteInfo != nil {
+		forwardServicesCommand(remoteInfo, "restore", args)
+		return
+	}
+
+	serviceName, id := args[0], args[1]
+
+	fmt.Printf("-----> Restoring %s from backup %s\n", serviceName, id)
+
+	if err := services.NewBackupManager("").Restore(serviceName, id, os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ %s restored from backup %s\n", serviceName, id)
+}
+
+func printBackupPolicy(policy services.BackupPolicy) {
+	schedule := policy.Schedule
+	if schedule == "" {
+		schedule = "manual"
+	}
+
+	keep := "all"
+	if policy.Keep > 0 {
+		keep = strconv.Itoa(policy.Keep)
+	}
+
+	target := "local"
+	if policy.Target != "" {
+		target = policy.Target
+	}
+
+	if policy.Endpoint != "" {
+		target += " (" + policy.Endpoint + ")"
+	}
+
+	fmt.Printf("Schedule: %s\n", schedule)
+	fmt.Printf("Keep:     %s\n", keep)
+	fmt.Printf("Target:   %s\n", target)
+}
+
+func printBackupUsage() {
+	fmt.Println("Usage: gokku services:backup <service> [options] [--remote]")
+	fmt.Println("")
+	fmt.Println("Without options the service is backed up now. Options change its backup policy:")
+	fmt.Println("  --schedule <cron|off>     Back up on a cron schedule, e.g. \"0 3 * * *\" or @daily")
+	fmt.Println("  --keep <n>                Keep the newest n backups, 0 keeps all")
+	fmt.Println("  --target <s3://bucket/prefix|local>  Where backups are stored")
+	fmt.Println("  --endpoint <url>          S3-compatible endpoint, e.g. MinIO or R2")
+	fmt.Println("")
+	fmt.Println("Examples:")
+	fmt.Println("  gokku services:backup postgres-api")
+	fmt.Println("  gokku services:backup postgres-api --schedule @daily --keep 7")
+	fmt.Println("  gokku services:backup postgres-api --target s3://backups/gokku --endpoint https://minio.example.com")
+	fmt.Println("  gokku services:backups postgres-api")
+	fmt.Println("  gokku services:restore postgres-api 20240501-030000")
+}
+
+func formatBackupSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value, suffix := float64(size)/unit, "KB"
+
+	for _, next := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+
+		value, suffix = value/unit, next
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
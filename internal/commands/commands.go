@@ -54,6 +54,26 @@ func Plugins(args []string) {
 	internal.TryCatch(func() { usePlugins(args) })
 }
 
+func Logs(args []string) {
+	internal.TryCatch(func() { useLogs(args) })
+}
+
+func Metrics(args []string) {
+	internal.TryCatch(func() { useMetrics(args) })
+}
+
+func Cron(args []string) {
+	internal.TryCatch(func() { useCron(args) })
+}
+
+func Notifications(args []string) {
+	internal.TryCatch(func() { useNotifications(args) })
+}
+
+func Ports(args []string) {
+	internal.TryCatch(func() { usePorts(args) })
+}
+
 func Services(args []string) {
 	internal.TryCatch(func() { useServices(args) })
 }
@@ -7,11 +7,12 @@ import (
 
 	"gokku/internal"
 	"gokku/internal/services"
+	"gokku/tui"
 )
 
 func useConfigWithContext(ctx *internal.ExecutionContext, args []string) {
 	if len(args) < 1 {
-		fmt.Println("Usage: gokku config <set|get|list|unset> [KEY[=VALUE]] [options]")
+		fmt.Println("Usage: gokku config <set|get|list|unset|effective> [KEY[=VALUE]] [options]")
 		fmt.Println("")
 		fmt.Println("Options:")
 		fmt.Println("  -a, --app <app>           App name")
@@ -20,6 +21,7 @@ func useConfigWithContext(ctx *internal.ExecutionContext, args []string) {
 		fmt.Println("  # Client mode (from local machine)")
 		fmt.Println("  gokku config set PORT=8080 -a api-production")
 		fmt.Println("  gokku config list -a api-production")
+		fmt.Println("  gokku config effective -a api-production")
 		fmt.Println("")
 		fmt.Println("  # Server mode (on server)")
 		fmt.Println("  gokku config set PORT=8080 -a api")
@@ -77,6 +79,17 @@ func executeAsServerMode(ctx *internal.ExecutionContext, subcommand string, args
 		for _, arg := range args {
 			fmt.Println(arg)
 		}
+
+		// Values may be credentials, so only the keys are sent
+		keys := make([]string, 0, len(args))
+
+		for _, arg := range args {
+			key, _, _ := strings.Cut(arg, "=")
+			keys = append(keys, key)
+		}
+
+		internal.Notify(appName, internal.EventConfigChanged, "", "config set "+strings.Join(keys, ", "))
+		runConfigHook(appName, "set", keys)
 	case "get":
 		if len(args) < 1 {
 			fmt.Println("Error: KEY is required for config get")
@@ -125,6 +138,37 @@ func executeAsServerMode(ctx *internal.ExecutionContext, subcommand string, args
 		for _, key := range keys {
 			fmt.Printf("%s=%s\n", key, envVars[key])
 		}
+	case "effective":
+		raw := false
+
+		for _, arg := range args {
+			if arg == "--raw" {
+				raw = true
+			}
+		}
+
+		envVars, err := configService.EffectiveEnvVars(appName, !raw)
+
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Printf("Run 'gokku config effective --raw -a %s' to see unresolved values\n", appName)
+			os.Exit(1)
+		}
+
+		if len(envVars) == 0 {
+			fmt.Println("No environment variables set")
+			return
+		}
+
+		table := tui.NewTable(tui.ASCII)
+		table.AppendHeaders([]string{"KEY", "VALUE", "SOURCE"})
+		table.AppendSeparator()
+
+		for _, envVar := range envVars {
+			table.AppendRow([]string{envVar.Key, envVar.Value, envVar.Source})
+		}
+
+		fmt.Print(table.Render())
 	case "unset":
 		if len(args) < 1 {
 			fmt.Println("Usage: gokku config unset KEY [KEY2...] -a <app>")
@@ -139,6 +183,9 @@ func executeAsServerMode(ctx *internal.ExecutionContext, subcommand string, args
 		for _, key := range args {
 			fmt.Printf("Unset %s\n", key)
 		}
+
+		internal.Notify(appName, internal.EventConfigChanged, "", "config unset "+strings.Join(args, ", "))
+		runConfigHook(appName, "unset", args)
 	default:
 		fmt.Printf("Unknown subcommand: %s\n", subcommand)
 		os.Exit(1)
@@ -157,6 +204,22 @@ func executeAsServerMode(ctx *internal.ExecutionContext, subcommand string, args
 	}
 }
 
+// runConfigHook runs the config-change plugin hooks. Like notifications they
+// only get the changed keys, never the values.
+func runConfigHook(appName, action string, keys []string) {
+	err := internal.RunHooks(internal.HookPayload{
+		Event:  internal.HookConfigChange,
+		App:    appName,
+		Action: action,
+		Keys:   keys,
+	})
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func executeAsClientMode(ctx *internal.ExecutionContext, subcommand string, args []string) {
 	var cmd string
 
@@ -190,6 +253,8 @@ func executeAsClientMode(ctx *internal.ExecutionContext, subcommand string, args
 		cmd = fmt.Sprintf("gokku config get %s --app %s", key, ctx.GetAppName())
 	case "list":
 		cmd = fmt.Sprintf("gokku config list --app %s", ctx.GetAppName())
+	case "effective":
+		cmd = fmt.Sprintf("gokku config effective %s --app %s", strings.Join(args, " "), ctx.GetAppName())
 	case "unset":
 		if len(args) < 1 {
 			fmt.Println("Usage: gokku config unset KEY [KEY2...] -a <app>")
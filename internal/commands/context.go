@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -17,100 +18,75 @@ func useLogsWithContext(ctx *internal.ExecutionContext, args []string) {
 	// Extract remaining args (without -a flag)
 	_, remainingArgs := internal.ExtractAppFlag(args)
 
-	// Check for -f flag
-	follow := false
+	opts, err := internal.ParseLogOptions(remainingArgs)
 
-	for _, arg := range remainingArgs {
-		if arg == "-f" {
-			follow = true
-			break
-		}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		printLogsUsage()
+		os.Exit(1)
 	}
 
 	serviceName := ctx.GetAppName()
-	followFlag := ""
+	opts.Color = !opts.JSON && internal.IsTerminal(os.Stdout)
 
-	if follow {
-		followFlag = "-f"
+	if !opts.JSON {
+		ctx.PrintConnectionInfo()
 	}
 
-	ctx.PrintConnectionInfo()
-
 	if ctx.ServerExecution {
-		useLogsServerMode(serviceName, follow)
+		useLogsServerMode(serviceName, opts)
 	} else {
-		useLogsClientMode(ctx, serviceName, followFlag)
+		useLogsClientMode(ctx, serviceName, opts)
 	}
 }
 
-func useLogsServerMode(serviceName string, follow bool) {
-	checkCmd := exec.Command("docker", "ps", "-a", "--format", "{{.Names}}")
-	output, err := checkCmd.Output()
+func printLogsUsage() {
+	fmt.Println("Usage: gokku logs -a <app> [options]")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -f, --follow            Keep streaming new lines")
+	fmt.Println("  -n, --tail <n|all>      Lines per container (default 500)")
+	fmt.Println("  --since <time>          Only lines since a timestamp or duration, e.g. 10m")
+	fmt.Println("  --until <time>          Only lines before a timestamp or duration")
+	fmt.Println("  --grep <regexp>         Only lines matching the regular expression")
+	fmt.Println("  -p, --process <type>    Only containers running this process type")
+	fmt.Println("  --release <release>     Only containers from this release")
+	fmt.Println("  --json                  Print one JSON object per line")
+}
+
+func useLogsServerMode(serviceName string, opts internal.LogOptions) {
+	containers, err := internal.ListContainers(true)
 
 	if err != nil {
 		fmt.Printf("Error checking containers: %v\n", err)
 		os.Exit(1)
 	}
 
-	containerExists := false
-
-	for _, name := range strings.Split(string(output), "\n") {
-		if strings.TrimSpace(name) == serviceName {
-			containerExists = true
-			break
-		}
-	}
+	selected := internal.SelectLogContainers(containers, serviceName, opts)
 
-	if !containerExists {
-		fmt.Printf("Container '%s' not found\n", serviceName)
+	if len(selected) == 0 {
+		fmt.Printf("No containers found for '%s'\n", serviceName)
 		os.Exit(1)
 	}
 
-	var cmd *exec.Cmd
-
-	if follow {
-		cmd = exec.Command("docker", "logs", "-f", "--tail", "500", serviceName)
-	} else {
-		cmd = exec.Command("docker", "logs", "--tail", "500", serviceName)
-	}
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	// Run the command and check for errors
-	if err := cmd.Run(); err != nil {
-		// Check if it's a signal interruption (Ctrl+C), which is normal
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			// Exit code 130 = SIGINT (Ctrl+C)
-			// Exit code 143 = SIGTERM
-			if exitErr.ExitCode() == 130 || exitErr.ExitCode() == 143 {
-				os.Exit(0)
-			}
-		}
-
+	if err := internal.StreamLogs(serviceName, selected, opts, os.Stdout); err != nil {
 		fmt.Printf("Error executing docker logs: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func useLogsClientMode(ctx *internal.ExecutionContext, serviceName, followFlag string) {
-	sshCmd := fmt.Sprintf(`
-  if docker ps | grep -q %s; then
-    docker logs %s %s
-  else
-    echo "Container '%s' not found"
-    exit 1
-  fi
-`, serviceName, serviceName, followFlag, serviceName)
-
-	cmd := exec.Command("ssh", "-t", ctx.Host, sshCmd)
+func useLogsClientMode(ctx *internal.ExecutionContext, serviceName string, opts internal.LogOptions) {
+	remoteCmd := strings.TrimSpace(fmt.Sprintf("gokku logs -a %s %s", internal.ShellQuote(serviceName), opts.Flags()))
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	// A TTY on the server makes gokku colour the prefixes there too
+	tty := opts.Color && internal.IsTerminal(os.Stdin)
 
-	cmd.Run()
+	if err := ctx.ExecuteInteractiveCommand(remoteCmd, tty); err != nil {
+		// Exit code 130 = SIGINT (Ctrl+C), 143 = SIGTERM
+		if code := exitCode(err); code != 130 && code != 143 {
+			os.Exit(code)
+		}
+	}
 }
 
 func useStatusWithContext(ctx *internal.ExecutionContext, args []string) {
@@ -134,15 +110,18 @@ func useStatusWithContext(ctx *internal.ExecutionContext, args []string) {
 	// Print connection info for remote execution
 	ctx.PrintConnectionInfo()
 
-	// Build docker status command
+	// Build docker status command, matching the gokku.app label exactly and
+	// falling back to the exact name for containers created before labels
 	dockerCmd := fmt.Sprintf(`
-		if docker ps | grep -q %s; then
-			docker ps --format "table {{.Names}}\t{{.Status}}\t{{.Ports}}" --filter "name=%s"
+		if docker ps -q --filter "label=%[1]s=%[2]s" | grep -q .; then
+			docker ps --format "table {{.Names}}\t{{.Status}}\t{{.Ports}}" --filter "label=%[1]s=%[2]s"
+		elif docker ps -q --filter "name=^%[2]s$" | grep -q .; then
+			docker ps --format "table {{.Names}}\t{{.Status}}\t{{.Ports}}" --filter "name=^%[2]s$"
 		else
-			echo "Service or container '%s' not found"
+			echo "Service or container '%[2]s' not found"
 			exit 1
 		fi
-	`, serviceName, serviceName, serviceName)
+	`, internal.LabelApp, serviceName)
 
 	// Execute command
 	if err := ctx.ExecuteCommand(dockerCmd); err != nil {
@@ -230,6 +209,20 @@ func useRollbackWithContext(ctx *internal.ExecutionContext, args []string) {
 		fmt.Printf("Rollback failed: %v\n", err)
 		os.Exit(1)
 	}
+
+	message := "rolled back to release " + releaseID
+
+	if ctx.ServerExecution {
+		internal.Notify(ctx.GetAppName(), internal.EventRollback, releaseID, message)
+		return
+	}
+
+	notifyCmd := fmt.Sprintf("gokku notifications:send %s -a %s --release %s --message %s",
+		internal.EventRollback, ctx.GetAppName(), internal.ShellQuote(releaseID), internal.ShellQuote(message))
+
+	if err := ctx.ExecuteCommand(notifyCmd); err != nil {
+		fmt.Printf("Warning: Failed to send rollback notification: %v\n", err)
+	}
 }
 
 func useRunWithContext(ctx *internal.ExecutionContext, args []string) {
@@ -239,24 +232,145 @@ func useRunWithContext(ctx *internal.ExecutionContext, args []string) {
 
 	_, remainingArgs := internal.ExtractAppFlag(args)
 
-	if len(remainingArgs) < 1 {
-		fmt.Println("Error: command is required")
-		fmt.Println("Usage: gokku run <command> -a <app>")
+	opts, command, err := parseRunFlags(remainingArgs)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		printRunUsage()
 		os.Exit(1)
 	}
 
-	command := strings.Join(remainingArgs, " ")
+	if len(command) < 1 {
+		fmt.Println("Error: command is required")
+		printRunUsage()
+		os.Exit(1)
+	}
 
-	containerName := ctx.GetAppName()
-	dockerCommand := fmt.Sprintf("docker exec -it %s %s", containerName, command)
+	appName := ctx.GetAppName()
+	tty := !opts.detached && internal.IsTerminal(os.Stdin) && internal.IsTerminal(os.Stdout)
 
 	ctx.PrintConnectionInfo()
-	fmt.Printf("$ %s\n\n", command)
 
-	// Execute command
-	if err := ctx.ExecuteCommand(dockerCommand); err != nil {
+	if !ctx.ServerExecution {
+		remoteCmd := fmt.Sprintf("gokku run %s-a %s %s", opts.flags(), appName, strings.Join(command, " "))
+
+		if err := ctx.ExecuteInteractiveCommand(remoteCmd, tty); err != nil {
+			os.Exit(exitCode(err))
+		}
+
+		return
+	}
+
+	app, err := internal.LoadAppConfig(appName)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := internal.NewOneOffConfig(appName, app, strings.Join(command, " "))
+	config.Detached = opts.detached
+	config.TTY = tty
+
+	if opts.size != "" {
+		size, _ := internal.ParseRunSize(opts.size)
+		config.Size = &size
+	}
+
+	fmt.Printf("$ %s\n\n", strings.Join(command, " "))
+
+	code, err := internal.RunOneOff(config, internal.AppBaseEnv(app))
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	if config.Detached && code == 0 {
+		fmt.Printf("✓ Started one-off container %s\n", config.Name)
+		fmt.Printf("  View output with: docker logs -f %s\n", config.Name)
+	}
+
+	os.Exit(code)
+}
+
+// runOptions holds the gokku run flags
+type runOptions struct {
+	detached bool
+	size     string
+}
+
+// flags renders the options back into command line flags for remote execution
+func (o runOptions) flags() string {
+	flags := ""
+
+	if o.detached {
+		flags += "--detached "
+	}
+
+	if o.size != "" {
+		flags += fmt.Sprintf("--size %s ", o.size)
+	}
+
+	return flags
+}
+
+// parseRunFlags reads gokku run flags up to the first argument of the command
+func parseRunFlags(args []string) (runOptions, []string, error) {
+	var opts runOptions
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--detached" || arg == "-d":
+			opts.detached = true
+		case arg == "--rm":
+			opts.detached = false
+		case arg == "--size" && i+1 < len(args):
+			opts.size = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--size="):
+			opts.size = strings.TrimPrefix(arg, "--size=")
+		case arg == "--":
+			return opts, args[i+1:], opts.validate()
+		default:
+			return opts, args[i:], opts.validate()
+		}
+	}
+
+	return opts, nil, opts.validate()
+}
+
+func (o runOptions) validate() error {
+	if o.size == "" {
+		return nil
+	}
+
+	_, err := internal.ParseRunSize(o.size)
+	return err
+}
+
+func printRunUsage() {
+	fmt.Println("Usage: gokku run [--rm|--detached] [--size <size>] <command> -a <app>")
+	fmt.Println("")
+	fmt.Println("Runs the command in a new one-off container from the current release.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --rm                Remove the container when the command exits (default)")
+	fmt.Println("  -d, --detached      Run in the background and keep the container for its logs")
+	fmt.Println("  --size <size>       small, medium, large, xlarge or <memory>[:<cpus>] like 1g:2")
+}
+
+// exitCode returns the exit code of a failed command, or 1
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+
+	return 1
 }
 
 func executeRestartServerMode(ctx *internal.ExecutionContext, appName string) {
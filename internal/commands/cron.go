@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gokku/internal"
+	"gokku/tui"
+)
+
+// cronSubcommands are handled by gokku itself; other cron: commands go to the cron plugin
+var cronSubcommands = map[string]bool{
+	"list": true, "ls": true, "run": true, "history": true, "install": true, "uninstall": true,
+}
+
+func useCron(args []string) {
+	remoteInfo, remainingArgs, err := internal.GetRemoteInfoOrDefault(args)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(remainingArgs) < 1 {
+		printCronUsage()
+		os.Exit(1)
+	}
+
+	subcommand := remainingArgs[0]
+
+	if remoteInfo != nil {
+		cmd := strings.TrimSpace(fmt.Sprintf("gokku cron:%s %s", subcommand, strings.Join(remainingArgs[1:], " ")))
+
+		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+			os.Exit(exitCode(err))
+		}
+
+		return
+	}
+
+	if !cronSubcommands[subcommand] {
+		if IsPluginInstalled("cron") {
+			usePlugins(append([]string{"cron:" + subcommand}, remainingArgs[1:]...))
+			return
+		}
+
+		printCronUsage()
+		os.Exit(1)
+	}
+
+	appName, subArgs := internal.ExtractAppFlag(remainingArgs[1:])
+	runner := internal.NewCronRunner("/opt/gokku")
+
+	switch subcommand {
+	case "list", "ls":
+		listCronJobs(runner, appName)
+	case "run":
+		runCronJob(runner, appName, subArgs)
+	case "history":
+		showCronHistory(runner, appName, subArgs)
+	case "install":
+		installCronJobs(appName)
+	case "uninstall":
+		uninstallCronJobs(appName)
+	}
+}
+
+func printCronUsage() {
+	fmt.Println("Usage: gokku cron:<command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  list, ls [-a <app>]           List scheduled jobs from gokku.yml")
+	fmt.Println("  run <job> -a <app>            Run a job now")
+	fmt.Println("  history <job> -a <app>        Show recent runs of a job")
+	fmt.Println("  install -a <app>              Write the app's jobs to the crontab (done on deploy)")
+	fmt.Println("  uninstall -a <app>            Remove the app's jobs from the crontab")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --remote                      Execute on remote server")
+}
+
+func listCronJobs(runner *internal.CronRunner, appName string) {
+	apps := []string{appName}
+
+	if appName == "" {
+		apps = listAppNames("/opt/gokku")
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"App", "Job", "Schedule", "Command", "Timeout", "Last Run", "Status"})
+
+	count := 0
+
+	for _, name := range apps {
+		app, err := internal.LoadAppConfig(name)
+
+		if err != nil {
+			if appName != "" {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			continue
+		}
+
+		for _, job := range internal.CronJobs(app) {
+			lastRun, status := "-", "-"
+
+			if run, err := runner.LastRun(name, job.Name); err == nil && run != nil {
+				lastRun = run.StartedAt
+				status = run.Status
+			}
+
+			table.AppendRow([]string{
+				name,
+				job.Name,
+				job.Schedule,
+				job.Command,
+				job.TimeoutDuration().String(),
+				lastRun,
+				status,
+			})
+
+			count++
+		}
+	}
+
+	if count == 0 {
+		fmt.Println("No cron jobs defined")
+		return
+	}
+
+	fmt.Print(table.Render())
+}
+
+func runCronJob(runner *internal.CronRunner, appName string, args []string) {
+	scheduled := false
+	var jobName string
+
+	for _, arg := range args {
+		if arg == "--scheduled" {
+			scheduled = true
+		} else if jobName == "" {
+			jobName = arg
+		}
+	}
+
+	if appName == "" || jobName == "" {
+		fmt.Println("Usage: gokku cron:run <job> -a <app>")
+		os.Exit(1)
+	}
+
+	app, err := internal.LoadAppConfig(appName)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	job, err := internal.FindCronJob(app, jobName)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	trigger := internal.CronTriggerManual
+	var out io.Writer = os.Stdout
+
+	if scheduled {
+		trigger = internal.CronTriggerSchedule
+		out = io.Discard
+	} else {
+		fmt.Printf("-----> Running %s: %s\n", job.Name, job.Command)
+	}
+
+	run, err := runner.Run(appName, app, job, trigger, out)
+
+	if errors.Is(err, internal.ErrCronJobRunning) {
+		fmt.Printf("Skipped %s: %v\n", job.Name, internal.ErrCronJobRunning)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if run.Status != internal.CronStatusSucceeded {
+		fmt.Printf("-----> %s %s (exit code %d) after %s\n", job.Name, run.Status, run.ExitCode, run.Duration)
+		os.Exit(run.ExitCode)
+	}
+
+	fmt.Printf("✓ %s succeeded in %s\n", job.Name, run.Duration)
+}
+
+func showCronHistory(runner *internal.CronRunner, appName string, args []string) {
+	if appName == "" || len(args) < 1 {
+		fmt.Println("Usage: gokku cron:history <job> -a <app>")
+		os.Exit(1)
+	}
+
+	runs, err := runner.History(appName, args[0])
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded for %s\n", args[0])
+		return
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"Started At", "Trigger", "Status", "Exit Code", "Duration", "Log"})
+
+	for _, run := range runs {
+		table.AppendRow([]string{
+			run.StartedAt,
+			run.Trigger,
+			run.Status,
+			strconv.Itoa(run.ExitCode),
+			run.Duration,
+			run.LogFile,
+		})
+	}
+
+	fmt.Print(table.Render())
+}
+
+func installCronJobs(appName string) {
+	if appName == "" {
+		fmt.Println("Usage: gokku cron:install -a <app>")
+		os.Exit(1)
+	}
+
+	app, err := internal.LoadAppConfig(appName)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := internal.InstallCronJobs(appName, app); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Scheduled %d cron jobs for %s\n", len(app.Cron), appName)
+}
+
+func uninstallCronJobs(appName string) {
+	if appName == "" {
+		fmt.Println("Usage: gokku cron:uninstall -a <app>")
+		os.Exit(1)
+	}
+
+	if err := internal.RemoveCronJobs(appName); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Removed cron jobs for %s\n", appName)
+}
+
+// listAppNames returns the apps that have a directory under baseDir/apps
+func listAppNames(baseDir string) []string {
+	entries, err := os.ReadDir(filepath.Join(baseDir, "apps"))
+
+	if err != nil {
+		return nil
+	}
+
+	var apps []string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			apps = append(apps, entry.Name())
+		}
+	}
+
+	sort.Strings(apps)
+
+	return apps
+}
@@ -11,8 +11,6 @@ import (
 
 	"gokku/internal"
 	"gokku/internal/lang"
-
-	"gopkg.in/yaml.v3"
 )
 
 func useDeploy(args []string) {
@@ -100,6 +98,15 @@ func useDeploy(args []string) {
 		os.Exit(1)
 	}
 
+	// Validate gokku.yml before pushing so typos fail fast
+	if _, err := os.Stat("gokku.yml"); err == nil {
+		if err := internal.ValidateConfigFile("gokku.yml"); err != nil {
+			fmt.Printf("Error: gokku.yml is invalid:\n%v\n", err)
+			fmt.Println("\nRun 'gokku tool validate-config' for details")
+			os.Exit(1)
+		}
+	}
+
 	// Get remote info for auto-setup
 	remoteInfo, err := internal.GetRemoteInfo(remoteName)
 
@@ -142,7 +149,7 @@ func useDeploy(args []string) {
 }
 
 // executeDirectDeployment performs deployment directly without git push
-func executeDirectDeployment(appName string) error {
+func executeDirectDeployment(appName string) (err error) {
 	baseDir := "/opt/gokku"
 	appDir := filepath.Join(baseDir, "apps", appName)
 	reposDir := filepath.Join(baseDir, "repos", appName+".git")
@@ -165,9 +172,27 @@ func executeDirectDeployment(appName string) error {
 	}
 
 	// Create release directory
-	releaseTag := time.Now().Format("20060102-150405")
+	startedAt := time.Now()
+	releaseTag := startedAt.Format("20060102-150405")
 	releaseDir := filepath.Join(appDir, "releases", releaseTag)
 
+	// Record the outcome for gokku metrics:serve and notify the app's targets
+	defer func() {
+		meta, recordErr := internal.RecordDeploy(baseDir, appName, releaseTag, startedAt, err)
+
+		if recordErr != nil {
+			fmt.Printf("Warning: Failed to record deploy: %v\n", recordErr)
+		}
+
+		if err != nil {
+			internal.Notify(appName, internal.EventDeployFailed, releaseTag, fmt.Sprintf("deploy failed: %v", err))
+		} else {
+			internal.Notify(appName, internal.EventDeploySucceeded, releaseTag, fmt.Sprintf("deploy succeeded in %s", time.Duration(meta.Duration*float64(time.Second)).Round(time.Second)))
+		}
+	}()
+
+	internal.Notify(appName, internal.EventDeployStarted, releaseTag, "deploy started")
+
 	fmt.Printf("-----> Creating release: %s\n", releaseTag)
 
 	if err := os.MkdirAll(releaseDir, 0755); err != nil {
@@ -179,10 +204,22 @@ func executeDirectDeployment(appName string) error {
 		return fmt.Errorf("failed to extract code: %v", err)
 	}
 
+	// Record the deployed commit for the gokku.sha label
+	if err := writeRevision(reposDir, releaseDir); err != nil {
+		fmt.Printf("Warning: Failed to record release revision: %v\n", err)
+	}
+
 	// Copy gokku.yml to app directory if it doesn't exist
 	appConfigPath := filepath.Join(appDir, "gokku.yml")
 	releaseConfigPath := filepath.Join(releaseDir, "gokku.yml")
 
+	// Validate gokku.yml before building
+	if _, err := os.Stat(releaseConfigPath); err == nil {
+		if err := internal.ValidateConfigFile(releaseConfigPath); err != nil {
+			return fmt.Errorf("invalid gokku.yml:\n%v", err)
+		}
+	}
+
 	// Check if this is the first deployment and handle initial setup
 	if !appExists {
 		if _, err := os.Stat(releaseConfigPath); err == nil {
@@ -190,6 +227,10 @@ func executeDirectDeployment(appName string) error {
 			if err := initialSetup(appName, releaseConfigPath, releaseDir); err != nil {
 				return fmt.Errorf("failed to setup initial configuration: %v", err)
 			}
+
+			if err := internal.RunHooks(internal.HookPayload{Event: internal.HookAppCreate, App: appName}); err != nil {
+				return err
+			}
 		} else {
 			return fmt.Errorf("gokku.yml not found in release directory - cannot setup app without configuration")
 		}
@@ -243,6 +284,10 @@ func executeDirectDeployment(appName string) error {
 		return fmt.Errorf("failed to create current symlink: %v", err)
 	}
 
+	if err := runDeployHook(internal.HookPreBuild, appName, releaseTag, releaseDir); err != nil {
+		return err
+	}
+
 	// Build application using language handler
 	fmt.Println("-----> Building application...")
 
@@ -261,7 +306,7 @@ func executeDirectDeployment(appName string) error {
 			cmd := exec.Command("docker", "build", "--progress=plain", "--no-cache", "-t", imageTag, releaseDir)
 
 			// Add Gokku labels to image
-			for _, label := range internal.GetGokkuLabels() {
+			for _, label := range internal.NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 				cmd.Args = append(cmd.Args, "--label", label)
 			}
 
@@ -294,6 +339,23 @@ func executeDirectDeployment(appName string) error {
 
 	fmt.Println("-----> Build complete!")
 
+	if err := runDeployHook(internal.HookPostBuild, appName, releaseTag, releaseDir); err != nil {
+		return err
+	}
+
+	if err := runDeployHook(internal.HookPreRelease, appName, releaseTag, releaseDir); err != nil {
+		return err
+	}
+
+	// Keep the image under the release name so a crash loop can roll back to it
+	if err := internal.TagReleaseImage(appName, releaseTag); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := runDeployHook(internal.HookPreDeploy, appName, releaseTag, releaseDir); err != nil {
+		return err
+	}
+
 	// Deploy application using language handler
 	if err := lang.Deploy(appName, app, releaseDir); err != nil {
 		return fmt.Errorf("deploy failed: %v", err)
@@ -309,9 +371,136 @@ func executeDirectDeployment(appName string) error {
 		return fmt.Errorf("post-deploy commands failed: %v", err)
 	}
 
+	if err := watchRelease(appName, app, releaseTag); err != nil {
+		return err
+	}
+
+	if err := runDeployHook(internal.HookPostDeploy, appName, releaseTag, releaseDir); err != nil {
+		return err
+	}
+
+	// Schedule the cron jobs shipped with this release
+	if len(app.Cron) > 0 {
+		fmt.Printf("-----> Scheduling %d cron jobs\n", len(app.Cron))
+	}
+
+	if err := internal.InstallCronJobs(appName, app); err != nil {
+		fmt.Printf("Warning: Failed to schedule cron jobs: %v\n", err)
+	}
+
+	keepImages := internal.DefaultKeepImages
+
+	if app.Deployment != nil && app.Deployment.KeepImages > 0 {
+		keepImages = app.Deployment.KeepImages
+	}
+
+	internal.PruneReleaseImages(appName, keepImages)
+
 	return nil
 }
 
+// runDeployHook runs the plugin hooks for a deploy step of a release
+func runDeployHook(event, appName, release, releaseDir string) error {
+	return internal.RunHooks(internal.HookPayload{
+		Event:      event,
+		App:        appName,
+		Release:    release,
+		ReleaseDir: releaseDir,
+	})
+}
+
+// watchRelease watches the new release for crash loops and, when one is
+// detected, redeploys the previous release and fails the deploy
+func watchRelease(appName string, app *internal.App, release string) error {
+	watch := app.Deployment.WatchConfig()
+	window, err := watch.WindowDuration()
+
+	if err != nil {
+		return fmt.Errorf("deployment.watch: %v", err)
+	}
+
+	if window == 0 {
+		return nil
+	}
+
+	fmt.Printf("-----> Watching %s for crash loops (%s)...\n", appName, window)
+
+	err = internal.NewReleaseWatcher(os.Stdout).Watch(appName, watch)
+
+	if err == nil {
+		fmt.Println("-----> No crash loop detected")
+		return nil
+	}
+
+	fmt.Printf("-----> Crash loop detected: %v\n", err)
+	internal.Notify(appName, internal.EventCrashLoop, release, err.Error())
+
+	if !watch.RollbackEnabled() {
+		return fmt.Errorf("release %s is crash-looping: %v", release, err)
+	}
+
+	previous, prevErr := internal.PreviousRelease("/opt/gokku", appName, release)
+
+	if prevErr == nil {
+		prevErr = rollbackToRelease(appName, previous)
+	}
+
+	if prevErr != nil {
+		return fmt.Errorf("release %s is crash-looping (%v) and could not be rolled back: %v", release, err, prevErr)
+	}
+
+	internal.Notify(appName, internal.EventRollback, previous, fmt.Sprintf("rolled back from %s after a crash loop", release))
+
+	return fmt.Errorf("release %s is crash-looping (%v), rolled back to %s", release, err, previous)
+}
+
+// rollbackToRelease redeploys the image and config of an earlier release
+func rollbackToRelease(appName, release string) error {
+	appDir := filepath.Join("/opt/gokku", "apps", appName)
+	releaseDir := filepath.Join(appDir, "releases", release)
+
+	fmt.Printf("-----> Rolling back to release %s\n", release)
+
+	app, err := internal.LoadAppConfigFromFile(filepath.Join(releaseDir, "gokku.yml"), appName)
+
+	if err != nil {
+		return fmt.Errorf("failed to load config of release %s: %v", release, err)
+	}
+
+	if err := internal.RestoreReleaseImage(appName, release); err != nil {
+		return err
+	}
+
+	currentLink := filepath.Join(appDir, "current")
+
+	if err := os.Remove(currentLink); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove current symlink: %v", err)
+	}
+
+	if err := os.Symlink(releaseDir, currentLink); err != nil {
+		return fmt.Errorf("failed to create current symlink: %v", err)
+	}
+
+	handler, err := lang.NewLang(app, releaseDir)
+
+	if err != nil {
+		return fmt.Errorf("failed to create language handler: %v", err)
+	}
+
+	return handler.Deploy(appName, app, releaseDir)
+}
+
+// writeRevision writes the HEAD commit of the repository to the release's REVISION file
+func writeRevision(repoDir, releaseDir string) error {
+	output, err := exec.Command("git", "--git-dir", repoDir, "rev-parse", "HEAD").Output()
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(releaseDir, internal.RevisionFile), output, 0644)
+}
+
 // hasCommits checks if a git repository has any commits
 func hasCommits(repoDir string) bool {
 	checkCmd := exec.Command("git", "--git-dir", repoDir, "rev-parse", "--short", "HEAD")
@@ -344,9 +533,9 @@ func extractCodeFromRepo(appName string, repoDir, releaseDir string) error {
 	}
 
 	// Parse config directly from the extracted content
-	var serverConfig internal.ServerConfig
+	serverConfig, err := internal.ParseServerConfig([]byte(gokkuYmlContent))
 
-	if err := yaml.Unmarshal([]byte(gokkuYmlContent), &serverConfig); err != nil {
+	if err != nil {
 		fmt.Printf("-----> Error parsing app config: %v, extracting full repository...\n", err)
 		output, err := gitc.ExecuteCommand("--git-dir", repoDir, "--work-tree", releaseDir, "checkout", "-f", "HEAD")
 
@@ -505,33 +694,19 @@ func copyFile(src, dst string) error {
 	return os.Chmod(dst, sourceInfo.Mode())
 }
 
-// updateEnvironmentFile updates environment file from gokku.yml if needed
+// updateEnvironmentFile makes sure the app environment file exists.
+// Env from gokku.yml is not copied into it; it is merged at container create time
+// with config:set values taking precedence (see internal.MergeAppEnv).
 func updateEnvironmentFile(envFile, appName string) error {
-	// Load server config to get default env vars
-	app, err := internal.LoadAppConfig(appName)
-
-	if err != nil {
-		return fmt.Errorf("failed to load server config: %v", err)
+	if _, err := os.Stat(envFile); err == nil {
+		return nil
 	}
 
-	// Load existing env vars
-	envVars := internal.LoadEnvFile(envFile)
-
-	// Add default env vars from config if not already set
-	if app.Environments != nil {
-		for _, env := range app.Environments {
-			if env.DefaultEnvVars != nil {
-				for key, value := range env.DefaultEnvVars {
-					if _, exists := envVars[key]; !exists {
-						envVars[key] = value
-					}
-				}
-			}
-		}
+	if err := os.MkdirAll(filepath.Dir(envFile), 0755); err != nil {
+		return fmt.Errorf("failed to create shared directory for %s: %v", appName, err)
 	}
 
-	// Save updated env vars
-	return internal.SaveEnvFile(envFile, envVars)
+	return internal.SaveEnvFile(envFile, map[string]string{})
 }
 
 // executePostDeployCommands runs post-deploy commands if configured
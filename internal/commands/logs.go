@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gokku/internal"
+)
+
+func useLogs(args []string) {
+	if len(args) < 1 {
+		printLogsCommandsUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "forward":
+		forwardLogs(args[1:])
+	default:
+		printLogsCommandsUsage()
+		os.Exit(1)
+	}
+}
+
+func printLogsCommandsUsage() {
+	fmt.Println("Usage: gokku logs:<command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  forward [-a <app>]    Ship container logs to the sinks in apps[].logging.forward")
+	fmt.Println("")
+	fmt.Println("View logs with: gokku logs -a <app>")
+}
+
+// forwardLogs runs the log forwarder in the foreground until interrupted
+func forwardLogs(args []string) {
+	if !internal.IsServerMode() {
+		fmt.Println("Error: logs:forward runs on the server only")
+		os.Exit(1)
+	}
+
+	appName, _ := internal.ExtractAppFlag(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if appName != "" {
+		fmt.Printf("-----> Forwarding logs of %s\n", appName)
+	} else {
+		fmt.Println("-----> Forwarding logs of all apps")
+	}
+
+	forwarder := internal.NewLogForwarder("/opt/gokku", appName, os.Stdout)
+
+	if err := forwarder.Run(ctx); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✓ Log forwarder stopped")
+}
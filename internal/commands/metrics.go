@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gokku/internal"
+	"gokku/internal/services"
+)
+
+func useMetrics(args []string) {
+	if len(args) < 1 || args[0] != "serve" {
+		printMetricsUsage()
+		os.Exit(1)
+	}
+
+	address := services.DefaultMetricsAddress
+	rest := args[1:]
+
+	for i := 0; i < len(rest); i++ {
+		switch {
+		case rest[i] == "--listen" && i+1 < len(rest):
+			address = rest[i+1]
+			i++
+		case strings.HasPrefix(rest[i], "--listen="):
+			address = strings.TrimPrefix(rest[i], "--listen=")
+		default:
+			printMetricsUsage()
+			os.Exit(1)
+		}
+	}
+
+	serveMetrics(address)
+}
+
+func printMetricsUsage() {
+	fmt.Println("Usage: gokku metrics:serve [--listen <address>]")
+	fmt.Println("")
+	fmt.Println("Serves Prometheus metrics for containers, deploys and services on /metrics.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Printf("  --listen <address>    Address to listen on (default %s)\n", services.DefaultMetricsAddress)
+}
+
+// serveMetrics runs the metrics endpoint in the foreground until interrupted
+func serveMetrics(address string) {
+	if !internal.IsServerMode() {
+		fmt.Println("Error: metrics:serve runs on the server only")
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", services.NewMetricsCollector("/opt/gokku").Handler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintln(w, "gokku metrics are served on /metrics")
+	})
+
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("-----> Serving metrics on http://%s/metrics\n", address)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✓ Metrics server stopped")
+}
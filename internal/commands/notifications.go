@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gokku/internal"
+	"gokku/tui"
+)
+
+func useNotifications(args []string) {
+	remoteInfo, remainingArgs, err := internal.GetRemoteInfoOrDefault(args)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(remainingArgs) < 1 {
+		printNotificationsUsage()
+		os.Exit(1)
+	}
+
+	subcommand := remainingArgs[0]
+
+	if remoteInfo != nil {
+		quoted := make([]string, 0, len(remainingArgs)-1)
+
+		for _, arg := range remainingArgs[1:] {
+			quoted = append(quoted, internal.ShellQuote(arg))
+		}
+
+		cmd := strings.TrimSpace(fmt.Sprintf("gokku notifications:%s %s", subcommand, strings.Join(quoted, " ")))
+
+		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+			os.Exit(exitCode(err))
+		}
+
+		return
+	}
+
+	appName, subArgs := internal.ExtractAppFlag(remainingArgs[1:])
+	notifier := internal.NewNotifier("/opt/gokku", os.Stdout)
+
+	switch subcommand {
+	case "list", "ls":
+		listNotifications(notifier, appName)
+	case "test":
+		testNotifications(notifier, appName)
+	case "send":
+		sendNotification(notifier, appName, subArgs)
+	case "flush":
+		flushNotifications(notifier)
+	default:
+		printNotificationsUsage()
+		os.Exit(1)
+	}
+}
+
+func printNotificationsUsage() {
+	fmt.Println("Usage: gokku notifications:<command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  list, ls [-a <app>]                  List notification targets and queued deliveries")
+	fmt.Println("  test -a <app>                        Send a test event to every target of the app")
+	fmt.Println("  send <event> -a <app> [options]      Send an event, e.g. from a deploy script")
+	fmt.Println("  flush                                Retry queued deliveries that are due")
+	fmt.Println("")
+	fmt.Println("Send options:")
+	fmt.Println("  --release <release>                  Release the event refers to")
+	fmt.Println("  --message <text>                     Event message")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --remote                             Execute on remote server")
+	fmt.Println("")
+	fmt.Printf("Events: %s\n", strings.Join(internal.NotificationEvents, ", "))
+}
+
+func listNotifications(notifier *internal.Notifier, appName string) {
+	apps := []string{appName}
+
+	if appName == "" {
+		apps = listAppNames("/opt/gokku")
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"App", "Type", "Destination", "Events", "Signed"})
+
+	count := 0
+
+	for _, name := range apps {
+		app, err := internal.LoadAppConfig(name)
+
+		if err != nil {
+			if appName != "" {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			continue
+		}
+
+		for _, target := range app.Notifications {
+			table.AppendRow(notificationTargetRow(name, target))
+			count++
+		}
+	}
+
+	serverTargets, err := notifier.ServerTargets()
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, target := range serverTargets {
+		table.AppendRow(notificationTargetRow("(all)", target))
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("No notification targets defined")
+	} else {
+		fmt.Print(table.Render())
+	}
+
+	queued, _ := notifier.Queued()
+	failed, _ := notifier.Failed()
+
+	if len(queued) == 0 && len(failed) == 0 {
+		return
+	}
+
+	fmt.Println("")
+
+	table = tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"Delivery", "App", "Event", "Destination", "Attempts", "Next Attempt", "Last Error"})
+
+	for _, delivery := range queued {
+		table.AppendRow(notificationDeliveryRow(delivery, delivery.NextAttempt))
+	}
+
+	for _, delivery := range failed {
+		table.AppendRow(notificationDeliveryRow(delivery, "gave up"))
+	}
+
+	fmt.Print(table.Render())
+}
+
+func notificationTargetRow(appName string, target internal.NotificationTarget) []string {
+	events := "all"
+
+	if len(target.Events) > 0 {
+		events = strings.Join(target.Events, ", ")
+	}
+
+	signed := "no"
+
+	if target.Secret != "" {
+		signed = "yes"
+	}
+
+	return []string{appName, target.Type, target.Destination(), events, signed}
+}
+
+func notificationDeliveryRow(delivery internal.NotificationDelivery, next string) []string {
+	return []string{
+		delivery.ID,
+		delivery.Event.App,
+		delivery.Event.Event,
+		delivery.Target.Destination(),
+		strconv.Itoa(delivery.Attempts),
+		next,
+		delivery.LastError,
+	}
+}
+
+func testNotifications(notifier *internal.Notifier, appName string) {
+	if appName == "" {
+		fmt.Println("Usage: gokku notifications:test -a <app>")
+		os.Exit(1)
+	}
+
+	targets, results, err := notifier.Test(appName)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(targets) == 0 {
+		fmt.Printf("No notification targets defined for %s\n", appName)
+		return
+	}
+
+	failed := false
+
+	for i, target := range targets {
+		if results[i] != nil {
+			fmt.Printf("✗ %s %s: %v\n", target.Type, target.Destination(), results[i])
+			failed = true
+			continue
+		}
+
+		fmt.Printf("✓ %s %s\n", target.Type, target.Destination())
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func sendNotification(notifier *internal.Notifier, appName string, args []string) {
+	var event, release, message string
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--release" && i+1 < len(args):
+			release = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--release="):
+			release = strings.TrimPrefix(args[i], "--release=")
+		case args[i] == "--message" && i+1 < len(args):
+			message = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--message="):
+			message = strings.TrimPrefix(args[i], "--message=")
+		case event == "" && !strings.HasPrefix(args[i], "-"):
+			event = args[i]
+		default:
+			fmt.Printf("Error: unknown option '%s'\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	if appName == "" || event == "" {
+		fmt.Println("Usage: gokku notifications:send <event> -a <app> [--release <release>] [--message <text>]")
+		os.Exit(1)
+	}
+
+	found := false
+
+	for _, name := range internal.NotificationEvents {
+		if name == event {
+			found = true
+		}
+	}
+
+	if !found {
+		fmt.Printf("Error: unknown event '%s' (expected one of: %s)\n", event, strings.Join(internal.NotificationEvents, ", "))
+		os.Exit(1)
+	}
+
+	if message == "" {
+		message = event
+	}
+
+	e := internal.NewNotificationEvent(event, appName, message)
+	e.Release = release
+
+	if err := notifier.Notify(e); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func flushNotifications(notifier *internal.Notifier) {
+	result, err := notifier.Flush()
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Delivered %d, pending %d, gave up on %d\n", result.Delivered, result.Pending, result.Failed)
+}
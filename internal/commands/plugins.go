@@ -5,10 +5,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"gokku/internal"
 	"gokku/internal/plugins"
+	"gokku/pluginsdk"
 	"gokku/tui"
 )
 
@@ -18,6 +22,16 @@ func usePlugins(args []string) {
 		return
 	}
 
+	// Scaffolding and testing work on the local machine and need no server
+	switch args[0] {
+	case "new", "plugins:new":
+		newPlugin(args[1:])
+		return
+	case "test", "plugins:test":
+		testPlugin(args[1:])
+		return
+	}
+
 	// Extract --remote flag first (if present)
 	remoteInfo, remainingArgs, err := internal.GetRemoteInfoOrDefault(args)
 
@@ -67,8 +81,12 @@ func usePlugins(args []string) {
 		addPlugin(remainingArgs[1:], remoteInfo)
 	case "update":
 		updatePlugin(remainingArgs[1:], remoteInfo)
+	case "rollback":
+		rollbackPlugin(remainingArgs[1:], remoteInfo)
 	case "remove":
 		removePlugin(remainingArgs[1:], remoteInfo)
+	case "hooks":
+		pluginHooks(remainingArgs[1:], remoteInfo)
 	default:
 		if remoteInfo != nil {
 			// Execute plugin command remotely
@@ -98,9 +116,9 @@ func listPlugins(args []string, remoteInfo *internal.RemoteInfo) {
 	}
 
 	// Server mode: execute locally
-	pm := plugins.NewPluginManager()
+	pm := plugins.NewPluginManager("")
 
-	pluginList, err := pm.ListPlugins()
+	pluginList, err := pm.ListPluginInfo()
 	if err != nil {
 		fmt.Printf("Error listing plugins: %v\n", err)
 		os.Exit(1)
@@ -114,17 +132,184 @@ func listPlugins(args []string, remoteInfo *internal.RemoteInfo) {
 	fmt.Println("Installed plugins:")
 
 	table := tui.NewTable(tui.ASCII)
-	table.AppendHeaders([]string{"NAME"})
+	table.AppendHeaders([]string{"NAME", "VERSION", "REF", "COMMIT", "DESCRIPTION"})
 	table.AppendSeparator()
 
 	for _, plugin := range pluginList {
-		table.AppendRow([]string{plugin}, true)
+		version := plugin.Version
+		if version == "" {
+			version = "-"
+		}
+
+		ref, commit := plugin.Ref, plugin.Commit
+		if plugin.Linked {
+			ref = "linked"
+		} else if ref == "" {
+			ref = "-"
+		}
+
+		if len(commit) > 7 {
+			commit = commit[:7]
+		} else if commit == "" {
+			commit = "-"
+		}
+
+		table.AppendRow([]string{plugin.Name, version, ref, commit, plugin.Description}, true)
 	}
 
 	fmt.Print(table.Render())
 	_ = args // unused for now
 }
 
+// addLocalPlugin links a plugin directory on this server into the plugins directory
+func addLocalPlugin(pm *plugins.PluginManager, pluginName, ref, source string) {
+	if ref != "" {
+		fmt.Println("Error: local plugins are linked as they are, a ref cannot be pinned")
+		os.Exit(1)
+	}
+
+	path, err := plugins.LocalPluginPath(source)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if pluginName == "" {
+		pluginName = pm.LocalPluginName(path)
+	}
+
+	if err := plugins.ValidatePluginName(pluginName); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Linking local plugin '%s' from %s...\n", pluginName, path)
+
+	if _, err := pm.InstallLocalPlugin(path, pluginName); err != nil {
+		fmt.Printf("Error installing local plugin: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Plugin '%s' linked successfully, edits to %s apply immediately\n", pluginName, path)
+	fmt.Printf("Create a service with:\n")
+	fmt.Printf("  gokku services:create %s --name <service-name>\n", pluginName)
+}
+
+// newPlugin scaffolds a plugin in a new directory
+func newPlugin(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: gokku plugins:new <name> [--dir <path>]")
+		fmt.Println("")
+		fmt.Println("Creates gokku-<name>/ with a manifest, install and uninstall scripts,")
+		fmt.Println("the help, info and logs commands and a tests/lifecycle harness")
+		os.Exit(1)
+	}
+
+	name := args[0]
+
+	dir := internal.ExtractFlagValue(args[1:], "--dir")
+	if dir == "" {
+		dir = "gokku-" + name
+	}
+
+	files, err := plugins.ScaffoldPlugin(dir, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("-----> Created plugin '%s' in %s\n", name, dir)
+
+	for _, file := range files {
+		fmt.Printf("       %s\n", file)
+	}
+
+	fmt.Println("")
+	fmt.Println("Next steps:")
+	fmt.Printf("  1. Edit %s/install with the image of your service\n", dir)
+	fmt.Printf("  2. Link it on the server: gokku plugins:add ./%s\n", filepath.Base(dir))
+	fmt.Printf("  3. Try it: %s/tests/lifecycle\n", dir)
+}
+
+// testPlugin runs a plugin against a temporary base directory with a fake docker
+func testPlugin(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: gokku plugins:test <plugin|path> [--keep]")
+		fmt.Println("")
+		fmt.Println("Installs a service from the plugin, runs its info command, uninstalls it")
+		fmt.Println("and runs the scripts in its tests/ directory, with docker replaced by a")
+		fmt.Println("shim that records calls. Nothing touches /opt/gokku or real containers.")
+		os.Exit(1)
+	}
+
+	pluginDir := args[0]
+
+	if plugins.IsLocalPluginSource(pluginDir) {
+		path, err := plugins.LocalPluginPath(pluginDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		pluginDir = path
+	} else if _, err := os.Stat(pluginDir); err != nil {
+		// Not a directory here: test the installed plugin of that name
+		pluginDir = filepath.Join(plugins.NewPluginManager("").GetPluginsDir(), pluginDir)
+	}
+
+	harness, err := plugins.NewPluginTestHarness(pluginDir, os.Stdout)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	keep := slices.Contains(args[1:], "--keep")
+	if !keep {
+		defer harness.Cleanup()
+	}
+
+	fmt.Printf("-----> Testing plugin in %s\n", harness.BaseDir())
+
+	report, err := harness.Run()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		harness.Cleanup()
+		os.Exit(1)
+	}
+
+	fmt.Println("")
+	fmt.Printf("-----> Results for plugin '%s'\n", report.Plugin)
+
+	for _, step := range report.Steps {
+		if step.Err != nil {
+			fmt.Printf("✗ %s: %v\n", step.Name, step.Err)
+		} else {
+			fmt.Printf("✓ %s (%s)\n", step.Name, step.Duration.Round(time.Millisecond))
+		}
+	}
+
+	if len(report.DockerCalls) > 0 {
+		fmt.Println("")
+		fmt.Println("Docker calls:")
+
+		for _, call := range report.DockerCalls {
+			fmt.Printf("       docker %s\n", call)
+		}
+	}
+
+	if keep {
+		fmt.Printf("\nKept test base directory: %s\n", harness.BaseDir())
+	}
+
+	if !report.Passed() {
+		if !keep {
+			harness.Cleanup()
+		}
+
+		os.Exit(1)
+	}
+}
+
 // adds a new plugin from official repository or Git URL
 func addPlugin(args []string, remoteInfo *internal.RemoteInfo) {
 	// Use args directly if remoteInfo is already set (from parent)
@@ -132,19 +317,24 @@ func addPlugin(args []string, remoteInfo *internal.RemoteInfo) {
 	cleanArgs := args
 
 	if len(cleanArgs) < 1 {
-		fmt.Println("Usage: gokku plugins:add <plugin-name> [<git-url>] [--remote]")
+		fmt.Println("Usage: gokku plugins:add <plugin-name>[@<ref>] [<git-url>|<path>] [--remote]")
+		fmt.Println("       gokku plugins:add <path> [--remote]")
 		fmt.Println("")
 		fmt.Println("Examples:")
 		fmt.Println("  gokku plugins:add nginx                              # Official plugin")
+		fmt.Println("  gokku plugins:add postgres@v1.2.0                    # Pinned to a tag, branch or commit")
 		fmt.Println("  gokku plugins:add myplugin https://github.com/user/gokku-myplugin  # Community plugin")
+		fmt.Println("  gokku plugins:add myplugin git@git.example.com:ops/gokku-myplugin.git  # Any git server")
+		fmt.Println("  gokku plugins:add ./gokku-myplugin                   # Link a local directory (dev mode)")
 		fmt.Println("  gokku plugins:add nginx --remote                    # Install on remote server")
 		fmt.Println("")
 		fmt.Println("Official plugins are automatically fetched from gokku-vm organization")
 		fmt.Println("Community plugins require a git URL")
+		fmt.Println("Local paths are resolved on the server and linked, so edits apply immediately")
 		os.Exit(1)
 	}
 
-	pluginName := cleanArgs[0]
+	pluginName, ref := plugins.ParsePluginRef(cleanArgs[0])
 	var gitURL string
 	if len(cleanArgs) > 1 {
 		// Check if next arg is a flag, not a URL
@@ -154,11 +344,16 @@ func addPlugin(args []string, remoteInfo *internal.RemoteInfo) {
 		}
 	}
 
+	// gokku plugins:add ./path names the plugin after its manifest
+	if plugins.IsLocalPluginSource(cleanArgs[0]) {
+		pluginName, ref, gitURL = "", "", cleanArgs[0]
+	}
+
 	// If remote mode, execute remotely
 	if remoteInfo != nil {
-		cmdParts := []string{"gokku plugins:add", pluginName}
-		if gitURL != "" {
-			cmdParts = append(cmdParts, gitURL)
+		cmdParts := []string{"gokku plugins:add", internal.ShellQuote(cleanArgs[0])}
+		if gitURL != "" && gitURL != cleanArgs[0] {
+			cmdParts = append(cmdParts, internal.ShellQuote(gitURL))
 		}
 		cmd := strings.Join(cmdParts, " ")
 		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
@@ -168,7 +363,13 @@ func addPlugin(args []string, remoteInfo *internal.RemoteInfo) {
 		return
 	}
 
-	pm := plugins.NewPluginManager()
+	pm := plugins.NewPluginManager("")
+
+	// Local directories are linked, not cloned
+	if plugins.IsLocalPluginSource(gitURL) {
+		addLocalPlugin(pm, pluginName, ref, gitURL)
+		return
+	}
 
 	// Check if it's a community plugin (git URL provided)
 	if gitURL != "" {
@@ -179,12 +380,13 @@ func addPlugin(args []string, remoteInfo *internal.RemoteInfo) {
 
 		fmt.Printf("Installing community plugin '%s' from %s...\n", pluginName, gitURL)
 
-		if err := pm.InstallPluginFromGit(gitURL, pluginName); err != nil {
+		entry, err := pm.InstallPluginFromGit(gitURL, pluginName, ref)
+		if err != nil {
 			fmt.Printf("Error installing community plugin: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Plugin '%s' installed successfully\n", pluginName)
+		fmt.Printf("Plugin '%s' installed successfully (%s)\n", pluginName, describeLockEntry(entry))
 		fmt.Printf("Plugin is now available. Create a service with:\n")
 		fmt.Printf("  gokku services:create %s --name <service-name>\n", pluginName)
 		return
@@ -203,12 +405,13 @@ func addPlugin(args []string, remoteInfo *internal.RemoteInfo) {
 	if isOfficial {
 		fmt.Printf("Installing official plugin '%s'...\n", pluginName)
 
-		if err := pm.InstallOfficialPlugin(pluginName); err != nil {
+		entry, err := pm.InstallOfficialPlugin(pluginName, ref)
+		if err != nil {
 			fmt.Printf("Error installing official plugin: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Plugin '%s' installed successfully\n", pluginName)
+		fmt.Printf("Plugin '%s' installed successfully (%s)\n", pluginName, describeLockEntry(entry))
 		fmt.Printf("Plugin is now available. Create a service with:\n")
 		fmt.Printf("  gokku services:create %s --name <service-name>\n", pluginName)
 		return
@@ -239,19 +442,22 @@ func updatePlugin(args []string, remoteInfo *internal.RemoteInfo) {
 	}
 
 	if len(cleanArgs) < 1 {
-		fmt.Println("Usage: gokku plugins:update <plugin-name> [--remote]")
+		fmt.Println("Usage: gokku plugins:update <plugin-name>[@<ref>] [--remote]")
+		fmt.Println("")
+		fmt.Println("Without a ref the plugin follows the ref it is pinned to in plugins.lock")
 		fmt.Println("")
 		fmt.Println("Examples:")
 		fmt.Println("  gokku plugins:update redis")
+		fmt.Println("  gokku plugins:update redis@v2.0.0")
 		fmt.Println("  gokku plugins:update redis --remote")
 		os.Exit(1)
 	}
 
-	pluginName := cleanArgs[0]
+	pluginName, ref := plugins.ParsePluginRef(cleanArgs[0])
 
 	// If remote mode, execute remotely
 	if remoteInfo != nil {
-		cmd := fmt.Sprintf("gokku plugins:update %s", pluginName)
+		cmd := fmt.Sprintf("gokku plugins:update %s", cleanArgs[0])
 		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
 			fmt.Printf("Error updating plugin: %v\n", err)
 			os.Exit(1)
@@ -260,7 +466,7 @@ func updatePlugin(args []string, remoteInfo *internal.RemoteInfo) {
 	}
 
 	// Server mode: execute locally
-	pm := plugins.NewPluginManager()
+	pm := plugins.NewPluginManager("")
 
 	if !pm.PluginExists(pluginName) {
 		fmt.Printf("Plugin '%s' not found\n", pluginName)
@@ -270,12 +476,202 @@ func updatePlugin(args []string, remoteInfo *internal.RemoteInfo) {
 
 	fmt.Printf("Updating plugin '%s'...\n", pluginName)
 
-	if err := pm.UpdatePlugin(pluginName); err != nil {
+	entry, err := pm.UpdatePlugin(pluginName, ref)
+	if err != nil {
 		fmt.Printf("Error updating plugin: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Plugin '%s' updated successfully\n", pluginName)
+	if entry.Previous == nil {
+		fmt.Printf("Plugin '%s' is up to date (%s)\n", pluginName, describeLockEntry(entry))
+		return
+	}
+
+	fmt.Printf("Plugin '%s' updated successfully (%s -> %s)\n", pluginName, describeLockEntry(*entry.Previous), describeLockEntry(entry))
+	fmt.Printf("Undo with: gokku plugins:rollback %s\n", pluginName)
+}
+
+// swaps a plugin back to the version its last update replaced
+func rollbackPlugin(args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 1 {
+		fmt.Println("Usage: gokku plugins:rollback <plugin-name> [--remote]")
+		os.Exit(1)
+	}
+
+	pluginName := args[0]
+
+	if remoteInfo != nil {
+		cmd := fmt.Sprintf("gokku plugins:rollback %s", pluginName)
+		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+			fmt.Printf("Error rolling back plugin: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	pm := plugins.NewPluginManager("")
+
+	fmt.Printf("Rolling back plugin '%s'...\n", pluginName)
+
+	entry, err := pm.RollbackPlugin(pluginName)
+	if err != nil {
+		fmt.Printf("Error rolling back plugin: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Plugin '%s' rolled back to %s\n", pluginName, describeLockEntry(entry))
+}
+
+// pluginHooks lists the installed plugin hooks, or runs those of an event
+func pluginHooks(args []string, remoteInfo *internal.RemoteInfo) {
+	if remoteInfo != nil {
+		quoted := make([]string, 0, len(args))
+
+		for _, arg := range args {
+			quoted = append(quoted, internal.ShellQuote(arg))
+		}
+
+		cmd := strings.TrimSpace("gokku plugins:hooks " + strings.Join(quoted, " "))
+		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	dispatcher := internal.NewHookDispatcher("", os.Stdout)
+
+	if len(args) > 0 && args[0] == "run" {
+		if len(args) < 2 || !slices.Contains(internal.HookEvents, args[1]) {
+			fmt.Println("Usage: gokku plugins:hooks run <event> [-a <app>] [--service <service>] [--release <release>]")
+			fmt.Printf("Events: %s\n", strings.Join(internal.HookEvents, ", "))
+			os.Exit(1)
+		}
+
+		payload := internal.HookPayload{
+			Event:   args[1],
+			App:     internal.ExtractAppName(args[2:]),
+			Service: internal.ExtractFlagValue(args[2:], "--service"),
+			Release: internal.ExtractFlagValue(args[2:], "--release"),
+		}
+
+		if err := dispatcher.Dispatch(payload); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	events := internal.HookEvents
+	if len(args) > 0 {
+		events = args[:1]
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"EVENT", "PLUGIN", "TIMEOUT", "ON FAILURE"})
+	table.AppendSeparator()
+
+	found := 0
+
+	for _, event := range events {
+		hooks, err := dispatcher.Hooks(event)
+		if err != nil {
+			fmt.Printf("Error listing hooks: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, hook := range hooks {
+			onFailure := internal.HookOnFailureIgnore
+			if hook.Block {
+				onFailure = internal.HookOnFailureBlock
+			}
+
+			table.AppendRow([]string{hook.Event, hook.Plugin, hook.Timeout.String(), onFailure}, true)
+			found++
+		}
+	}
+
+	if found == 0 {
+		fmt.Println("No plugin hooks installed")
+		return
+	}
+
+	fmt.Print(table.Render())
+}
+
+// runPluginRPCCommand runs a command of a jsonrpc plugin and renders its
+// result. It returns false when the plugin has no such RPC command, so the
+// caller falls back to its scripts.
+func runPluginRPCCommand(pluginDir, command string, args []string) bool {
+	params := pluginsdk.CommandParams{Command: command, Args: args}
+
+	result, handled, err := internal.CallPlugin(pluginDir, pluginsdk.MethodCommand, params)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !handled {
+		return false
+	}
+
+	renderPluginResult(result)
+
+	return true
+}
+
+// renderPluginResult prints the structured result of a plugin command
+func renderPluginResult(result *pluginsdk.Result) {
+	if result.Output != "" {
+		fmt.Println(strings.TrimRight(result.Output, "\n"))
+	}
+
+	if result.Status != "" {
+		fmt.Printf("Status: %s\n", result.Status)
+	}
+
+	if result.Table != nil && len(result.Table.Rows) > 0 {
+		table := tui.NewTable(tui.ASCII)
+		table.AppendHeaders(result.Table.Headers)
+		table.AppendSeparator()
+
+		for _, row := range result.Table.Rows {
+			table.AppendRow(row)
+		}
+
+		fmt.Print(table.Render())
+	}
+
+	keys := make([]string, 0, len(result.Env))
+	for key := range result.Env {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s=%s\n", key, result.Env[key])
+	}
+}
+
+// describeLockEntry renders a pinned plugin as "v1.2.0, abc1234"
+func describeLockEntry(entry plugins.PluginLockEntry) string {
+	var parts []string
+
+	if entry.Version != "" {
+		parts = append(parts, entry.Version)
+	} else if entry.Ref != "" {
+		parts = append(parts, entry.Ref)
+	}
+
+	if len(entry.Commit) >= 7 {
+		parts = append(parts, entry.Commit[:7])
+	}
+
+	if len(parts) == 0 {
+		return "unpinned"
+	}
+
+	return strings.Join(parts, ", ")
 }
 
 // removes a plugin
@@ -314,7 +710,7 @@ func removePlugin(args []string, remoteInfo *internal.RemoteInfo) {
 	}
 
 	// Server mode: execute locally
-	pm := plugins.NewPluginManager()
+	pm := plugins.NewPluginManager("")
 
 	if !pm.PluginExists(pluginName) {
 		fmt.Printf("Plugin '%s' not found\n", pluginName)
@@ -346,7 +742,7 @@ func wildcardPlugin(args []string) {
 	pluginName := parts[0]
 	command := parts[1]
 
-	pm := plugins.NewPluginManager()
+	pm := plugins.NewPluginManager("")
 
 	// Check if plugin exists
 	if !pm.PluginExists(pluginName) {
@@ -359,6 +755,10 @@ func wildcardPlugin(args []string) {
 		// Get the plugin directory from PluginManager
 		pluginDir := filepath.Join(pm.GetPluginsDir(), pluginName)
 
+		if runPluginRPCCommand(pluginDir, command, args[1:]) {
+			return
+		}
+
 		var commandPath string
 
 		if pm.BinExists(pluginName, command) {
@@ -393,7 +793,7 @@ func wildcardPlugin(args []string) {
 }
 
 func IsPluginInstalled(pluginName string) bool {
-	pm := plugins.NewPluginManager()
+	pm := plugins.NewPluginManager("")
 	return pm.PluginExists(pluginName)
 }
 
@@ -404,8 +804,15 @@ func showHelpPlugins() {
 	fmt.Println("  gokku plugins:list                    List all installed plugins")
 	fmt.Println("  gokku plugins:add <name>              Add official plugin")
 	fmt.Println("  gokku plugins:add <name> <git-url>    Add community plugin")
-	fmt.Println("  gokku plugins:update <plugin>         Update plugin from source")
+	fmt.Println("  gokku plugins:add <name>@<ref>        Add plugin pinned to a tag, branch or commit")
+	fmt.Println("  gokku plugins:add <path>              Link a local plugin directory (dev mode)")
+	fmt.Println("  gokku plugins:new <name>              Scaffold a new plugin in gokku-<name>/")
+	fmt.Println("  gokku plugins:test <plugin|path>      Test a plugin against a fake gokku and docker")
+	fmt.Println("  gokku plugins:update <plugin>[@<ref>] Update plugin from source")
+	fmt.Println("  gokku plugins:rollback <plugin>       Return to the version before the last update")
 	fmt.Println("  gokku plugins:remove <plugin>         Remove plugin")
+	fmt.Println("  gokku plugins:hooks [event]           List the lifecycle hooks plugins install")
+	fmt.Println("  gokku plugins:hooks run <event> -a <app>  Run the hooks of an event")
 	fmt.Println("")
 	fmt.Println("Plugin commands:")
 	fmt.Println("  gokku <plugin>:<command> <service>   Execute plugin command")
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"gokku/internal"
+	"gokku/internal/containers"
+	"gokku/tui"
+)
+
+func usePorts(args []string) {
+	remoteInfo, remainingArgs, err := internal.GetRemoteInfoOrDefault(args)
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(remainingArgs) < 1 {
+		printPortsUsage()
+		os.Exit(1)
+	}
+
+	subcommand := remainingArgs[0]
+
+	if remoteInfo != nil {
+		cmd := fmt.Sprintf("gokku ports %s", subcommand)
+
+		for _, arg := range remainingArgs[1:] {
+			cmd += " " + arg
+		}
+
+		if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	ledger := containers.NewPortLedger("/opt/gokku")
+
+	switch subcommand {
+	case "list", "ls":
+		listPorts(ledger, remainingArgs[1:])
+	case "release":
+		releasePorts(ledger, remainingArgs[1:])
+	default:
+		printPortsUsage()
+		os.Exit(1)
+	}
+}
+
+func printPortsUsage() {
+	fmt.Println("Usage: gokku ports <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  list, ls [app]                List host ports allocated by port_strategy: auto")
+	fmt.Println("  release <app> [process]       Release the ports allocated to an app")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --remote                      Execute on remote server")
+}
+
+func listPorts(ledger *containers.PortLedger, args []string) {
+	allocations, err := ledger.List()
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	appName := ""
+
+	if len(args) > 0 {
+		appName = args[0]
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"Port", "App", "Process", "Allocated At"})
+
+	count := 0
+
+	for _, allocation := range allocations {
+		if appName != "" && allocation.App != appName {
+			continue
+		}
+
+		table.AppendRow([]string{
+			strconv.Itoa(allocation.Port),
+			allocation.App,
+			allocation.Process,
+			allocation.AllocatedAt,
+		})
+
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("No ports allocated")
+		return
+	}
+
+	fmt.Print(table.Render())
+}
+
+func releasePorts(ledger *containers.PortLedger, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: gokku ports release <app> [process]")
+		os.Exit(1)
+	}
+
+	appName := args[0]
+
+	var err error
+
+	if len(args) >= 2 {
+		err = ledger.Release(appName, args[1])
+	} else {
+		err = ledger.ReleaseApp(appName)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Released ports for %s\n", appName)
+}
@@ -110,6 +110,15 @@ func useProcesses(args []string) {
 			return
 		}
 		handlePSRestart(subcommandArgs)
+	case "reconcile":
+		if remoteInfo != nil {
+			cmd := strings.TrimSpace(fmt.Sprintf("gokku ps:reconcile %s", strings.Join(subcommandArgs, " ")))
+			if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+		handlePSReconcile(subcommandArgs)
 	case "stop":
 		if remoteInfo != nil {
 			cmdArgs := []string{subcommand}
@@ -219,6 +228,8 @@ func handlePSList(args []string) {
 
 	// Server mode - use ContainerService
 	baseDir := "/opt/gokku"
+	reconcileRegistry(baseDir)
+
 	containerService := services.NewContainerService(baseDir)
 
 	filter := services.ContainerFilter{
@@ -257,6 +268,8 @@ func handlePSList(args []string) {
 // listAllContainers lists all running containers with gokku format
 func listAllContainers() {
 	baseDir := "/opt/gokku"
+	reconcileRegistry(baseDir)
+
 	containerService := services.NewContainerService(baseDir)
 
 	filter := services.ContainerFilter{
@@ -511,6 +524,66 @@ func handlePSStop(args []string) {
 	fmt.Printf("Stop complete for app '%s'\n", appName)
 }
 
+// reconcileRegistry syncs the container registry with Docker before listing,
+// so ps output stays accurate after reboots or crashes. Containers are never
+// removed here, and failures are ignored.
+func reconcileRegistry(baseDir string) {
+	services.NewReconciler(baseDir).Reconcile(services.ReconcileOptions{})
+}
+
+// handlePSReconcile handles the ps:reconcile command
+func handlePSReconcile(args []string) {
+	opts := services.ReconcileOptions{}
+
+	for _, arg := range args {
+		switch arg {
+		case "--dry-run":
+			opts.DryRun = true
+		case "--prune":
+			opts.Prune = true
+		}
+	}
+
+	actions, err := services.NewReconciler("/opt/gokku").Reconcile(opts)
+
+	if err != nil {
+		fmt.Printf("Error reconciling containers: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(actions) == 0 {
+		fmt.Println("Registry is in sync with Docker")
+		return
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"ACTION", "CONTAINER", "APP", "PROCESS", "CHANGE"})
+
+	for _, action := range actions {
+		process := ""
+
+		if action.ProcessType != "" {
+			process = fmt.Sprintf("%s.%d", action.ProcessType, action.Number)
+		}
+
+		change := action.To
+
+		if action.From != "" && action.To != "" {
+			change = fmt.Sprintf("%s → %s", action.From, action.To)
+		} else if action.From != "" {
+			change = action.From
+		}
+
+		table.AppendRow([]string{action.Kind, action.Container, action.AppName, process, change})
+	}
+
+	fmt.Print(table.Render())
+
+	if opts.DryRun {
+		fmt.Println("Dry run, no changes applied")
+	}
+}
+
 // showPSHelp shows help for ps commands
 func showPSHelp() {
 	isServerMode := internal.IsServerMode()
@@ -523,6 +596,7 @@ func showPSHelp() {
 		fmt.Println("  gokku ps:list [<app>]                       List running processes (all if no app)")
 		fmt.Println("  gokku ps:restart <app>                     Restart all processes")
 		fmt.Println("  gokku ps:stop [<process>] <app>            Stop processes")
+		fmt.Println("  gokku ps:reconcile [--dry-run] [--prune]   Sync the process registry with Docker")
 		fmt.Println("")
 		fmt.Println("Examples:")
 		fmt.Println("  gokku ps")
@@ -539,6 +613,7 @@ func showPSHelp() {
 		fmt.Println("  gokku ps:list -a <app>                         List running processes")
 		fmt.Println("  gokku ps:restart -a <app>                     Restart all processes")
 		fmt.Println("  gokku ps:stop [<process>] -a <app>             Stop processes")
+		fmt.Println("  gokku ps:reconcile --remote <remote>           Sync the process registry with Docker")
 		fmt.Println("")
 		fmt.Println("Examples:")
 		fmt.Println("  gokku ps:report -a api-production")
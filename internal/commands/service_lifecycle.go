@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gokku/internal"
+	"gokku/internal/services"
+	"gokku/tui"
+)
+
+// handleServicesLifecycle starts, stops or restarts a service
+func handleServicesLifecycle(action string, args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 1 {
+		fmt.Printf("Usage: gokku services:%s <service> [--remote]\n", action)
+		os.Exit(1)
+	}
+
+	if remoteInfo != nil {
+		forwardServicesCommand(remoteInfo, action, args)
+		return
+	}
+
+	serviceName := args[0]
+	sm := services.NewServiceManager("")
+
+	run, verb := sm.RestartService, "Restarting"
+
+	switch action {
+	case "start":
+		run, verb = sm.StartService, "Starting"
+	case "stop":
+		run, verb = sm.StopService, "Stopping"
+	}
+
+	fmt.Printf("-----> %s %s\n", verb, serviceName)
+
+	if err := run(serviceName, os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printServiceState(sm, serviceName)
+}
+
+// handleServicesStatus shows a service's containers and their state
+func handleServicesStatus(args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 1 {
+		fmt.Println("Usage: gokku services:status <service> [--remote]")
+		os.Exit(1)
+	}
+
+	if remoteInfo != nil {
+		forwardServicesCommand(remoteInfo, "status", args)
+		return
+	}
+
+	status, err := services.NewServiceManager("").ServiceStatus(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	version := status.Version
+	if version == "" {
+		version = "default"
+	}
+
+	state := "stopped"
+	if status.Running {
+		state = "running"
+	}
+
+	fmt.Printf("===== %s\n", status.Service)
+	fmt.Printf("Plugin:   %s\n", status.Plugin)
+	fmt.Printf("Version:  %s\n", version)
+	fmt.Printf("Status:   %s\n", state)
+
+	if len(status.LinkedApps) > 0 {
+		fmt.Printf("Linked:   %s\n", strings.Join(status.LinkedApps, ", "))
+	}
+
+	if len(status.Networks) > 0 {
+		fmt.Printf("Networks: %s\n", strings.Join(status.Networks, ", "))
+	}
+
+	if len(status.Containers) == 0 {
+		fmt.Println("No containers found")
+		return
+	}
+
+	table := tui.NewTable(tui.ASCII)
+	table.AppendHeaders([]string{"NAME", "IMAGE", "STATE", "STATUS"})
+
+	for _, c := range status.Containers {
+		table.AppendRow([]string{c.Name, c.Image, c.State, c.Status})
+	}
+
+	fmt.Print(table.Render())
+}
+
+// handleServicesUpgrade backs a service up and upgrades it to a version,
+// by default the one its plugin manifest names
+func handleServicesUpgrade(args []string, remoteInfo *internal.RemoteInfo) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: gokku services:upgrade <service> [<version>] [--no-backup] [--remote]")
+		fmt.Println("")
+		fmt.Println("The service is backed up first unless --no-backup is given, and its plugin")
+		fmt.Println("migrates the data to the new version. Without a version the service moves")
+		fmt.Println("to the default_version of its plugin's manifest.")
+		fmt.Println("")
+		fmt.Println("Examples:")
+		fmt.Println("  gokku services:upgrade postgres-api")
+		fmt.Println("  gokku services:upgrade postgres-api 16")
+		fmt.Println("  gokku services:upgrade redis-cache 7.2 --no-backup")
+		os.Exit(1)
+	}
+
+	if remoteInfo != nil {
+		forwardServicesCommand(remoteInfo, "upgrade", args)
+		return
+	}
+
+	serviceName, version := args[0], ""
+	sm := services.NewServiceManager("")
+
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		version = args[1]
+	} else {
+		defaultVersion, err := sm.DefaultUpgradeVersion(serviceName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		version = defaultVersion
+	}
+
+	fmt.Printf("-----> Upgrading %s to %s\n", serviceName, version)
+
+	if err := sm.UpgradeService(serviceName, version, slices.Contains(args[1:], "--no-backup"), os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ %s upgraded to %s\n", serviceName, version)
+	printServiceState(sm, serviceName)
+}
+
+// printServiceState prints whether the service's containers are running
+func printServiceState(sm *services.ServiceManager, serviceName string) {
+	status, err := sm.ServiceStatus(serviceName)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+
+	if status.Running {
+		fmt.Printf("✓ %s is running\n", serviceName)
+	} else {
+		fmt.Printf("✓ %s is stopped\n", serviceName)
+	}
+}
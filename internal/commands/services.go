@@ -63,6 +63,18 @@ func useServices(args []string) {
 		handleServicesInfo(remainingArgs[1:], remoteInfo)
 	case "logs":
 		handleServicesLogs(remainingArgs[1:], remoteInfo)
+	case "backup":
+		handleServicesBackup(remainingArgs[1:], remoteInfo)
+	case "backups":
+		handleServicesBackups(remainingArgs[1:], remoteInfo)
+	case "restore":
+		handleServicesRestore(remainingArgs[1:], remoteInfo)
+	case "start", "stop", "restart":
+		handleServicesLifecycle(subcommand, remainingArgs[1:], remoteInfo)
+	case "status":
+		handleServicesStatus(remainingArgs[1:], remoteInfo)
+	case "upgrade":
+		handleServicesUpgrade(remainingArgs[1:], remoteInfo)
 	default:
 		// Try to execute as service command
 		if remoteInfo != nil {
@@ -80,6 +92,21 @@ func useServices(args []string) {
 	}
 }
 
+// forwardServicesCommand runs a services subcommand on the remote with its args quoted
+func forwardServicesCommand(remoteInfo *internal.RemoteInfo, subcommand string, args []string) {
+	quoted := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		quoted = append(quoted, internal.ShellQuote(arg))
+	}
+
+	cmd := strings.TrimSpace(fmt.Sprintf("gokku services:%s %s", subcommand, strings.Join(quoted, " ")))
+
+	if err := internal.ExecuteRemoteCommand(remoteInfo, cmd); err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
 // handleServicesList lists all services
 func handleServicesList(args []string, remoteInfo *internal.RemoteInfo) {
 	if remoteInfo != nil {
@@ -95,7 +122,7 @@ func handleServicesList(args []string, remoteInfo *internal.RemoteInfo) {
 	}
 
 	// Server mode: execute locally
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
 
 	serviceList, err := sm.ListServices()
 
@@ -118,7 +145,7 @@ func handleServicesList(args []string, remoteInfo *internal.RemoteInfo) {
 		status := "stopped"
 
 		// Check actual container status from Docker
-		if internal.ContainerIsRunning(service.Name) {
+		if serviceStatus, err := sm.ServiceStatus(service.Name); err == nil && serviceStatus.Running {
 			status = "running"
 		}
 
@@ -179,7 +206,7 @@ func handleServicesCreate(args []string, remoteInfo *internal.RemoteInfo) {
 		version = parts[1]
 	}
 
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
 
 	if version != "" {
 		fmt.Printf("Creating service '%s' from plugin '%s:%s'...\n", serviceName, pluginName, version)
@@ -201,11 +228,15 @@ func handleServicesLink(args []string, remoteInfo *internal.RemoteInfo) {
 	cleanArgs := args
 
 	if len(cleanArgs) < 1 {
-		fmt.Println("Usage: gokku services:link <service> -a <app> [--remote]")
+		fmt.Println("Usage: gokku services:link <service> -a <app> [--alias <name>] [--remote]")
+		fmt.Println("")
+		fmt.Println("Options:")
+		fmt.Println("  --alias <name>    Prefix the service env vars, e.g. ANALYTICS_DATABASE_URL")
 		fmt.Println("")
 		fmt.Println("Examples:")
 		fmt.Println("  gokku services:link postgres-api -a api-production")
 		fmt.Println("  gokku services:link redis-cache -a api-production")
+		fmt.Println("  gokku services:link postgres-analytics -a api-production --alias analytics")
 		fmt.Println("  gokku services:link postgres-api -a api-production --remote")
 		os.Exit(1)
 	}
@@ -223,16 +254,27 @@ func handleServicesLink(args []string, remoteInfo *internal.RemoteInfo) {
 	serviceName := cleanArgs[0]
 	appName := internal.ExtractAppName(cleanArgs[1:])
 
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
+
+	alias := internal.ExtractFlagValue(cleanArgs[1:], "--alias")
 
 	fmt.Printf("Linking service '%s' to app '%s'...\n", serviceName, appName)
 
-	if err := sm.LinkService(serviceName, appName, "default"); err != nil {
+	names, err := sm.LinkService(serviceName, appName, "default", alias)
+	if err != nil {
 		fmt.Printf("Error linking service: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Service '%s' linked to '%s' successfully\n", serviceName, appName)
+	runServiceLinkHook(serviceName, appName, "link")
+
+	if len(names) == 0 {
+		fmt.Printf("Plugin of '%s' declares no environment variables\n", serviceName)
+		return
+	}
+
+	fmt.Printf("Added: %s\n", strings.Join(names, ", "))
 	fmt.Printf("Environment variables updated. Restart your app to use the service.\n")
 }
 
@@ -263,7 +305,7 @@ func handleServicesUnlink(args []string, remoteInfo *internal.RemoteInfo) {
 	serviceName := cleanArgs[0]
 	appName := internal.ExtractAppName(cleanArgs[1:])
 
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
 
 	fmt.Printf("Unlinking service '%s' from app '%s'...\n", serviceName, appName)
 
@@ -273,6 +315,22 @@ func handleServicesUnlink(args []string, remoteInfo *internal.RemoteInfo) {
 	}
 
 	fmt.Printf("Service '%s' unlinked from '%s' successfully\n", serviceName, appName)
+	runServiceLinkHook(serviceName, appName, "unlink")
+}
+
+// runServiceLinkHook runs the service-link plugin hooks for a link or unlink
+func runServiceLinkHook(serviceName, appName, action string) {
+	err := internal.RunHooks(internal.HookPayload{
+		Event:   internal.HookServiceLink,
+		App:     appName,
+		Service: serviceName,
+		Action:  action,
+	})
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 // handleServicesDestroy destroys a service
@@ -313,7 +371,7 @@ func handleServicesDestroy(args []string, remoteInfo *internal.RemoteInfo) {
 		return
 	}
 
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
 
 	fmt.Printf("Destroying service '%s'...\n", serviceName)
 
@@ -351,7 +409,7 @@ func handleServicesInfo(args []string, remoteInfo *internal.RemoteInfo) {
 
 	serviceName := cleanArgs[0]
 
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
 
 	// Check if service exists
 	service, err := sm.GetService(serviceName)
@@ -406,7 +464,7 @@ func handleServicesLogs(args []string, remoteInfo *internal.RemoteInfo) {
 
 	serviceName := cleanArgs[0]
 
-	sm := services.NewServiceManager()
+	sm := services.NewServiceManager("")
 
 	// Check if service exists
 	service, err := sm.GetService(serviceName)
@@ -456,6 +514,10 @@ func handleServiceCommand(args []string) {
 
 	serviceName := args[1]
 
+	if runPluginRPCCommand(filepath.Join("/opt/gokku/plugins", pluginName), command, args[1:]) {
+		return
+	}
+
 	// Execute plugin command
 	commandPath := filepath.Join("/opt/gokku/plugins", pluginName, "commands", command)
 
@@ -481,11 +543,19 @@ func showServicesHelp() {
 	fmt.Println("")
 	fmt.Println("  gokku services:list                    List all services")
 	fmt.Println("  gokku services:create <plugin> --name <service>  Create service from plugin")
-	fmt.Println("  gokku services:link <service> -a <app> Link service to app")
+	fmt.Println("  gokku services:link <service> -a <app> Link service to app (--alias <name> to prefix its vars)")
 	fmt.Println("  gokku services:unlink <service> -a <app>  Unlink service from app")
 	fmt.Println("  gokku services:destroy <service>       Destroy service")
 	fmt.Println("  gokku services:info <service>          Show service information")
 	fmt.Println("  gokku services:logs <service>          Show service logs")
+	fmt.Println("  gokku services:backup <service>        Back up service now (--schedule, --keep, --target set its policy)")
+	fmt.Println("  gokku services:backups <service>       List service backups")
+	fmt.Println("  gokku services:restore <service> <id>  Restore service from a backup")
+	fmt.Println("  gokku services:start <service>         Start service containers")
+	fmt.Println("  gokku services:stop <service>          Stop service containers")
+	fmt.Println("  gokku services:restart <service>       Restart service containers")
+	fmt.Println("  gokku services:status <service>        Show service containers and their state")
+	fmt.Println("  gokku services:upgrade <service> [<version>]  Back up and upgrade service to a version")
 	fmt.Println("")
 	fmt.Println("Service commands:")
 	fmt.Println("  gokku <plugin>:<command> <service>     Execute plugin command on service")
@@ -495,5 +565,6 @@ func showServicesHelp() {
 	fmt.Println("  gokku services:create postgres:14 --name postgres-api")
 	fmt.Println("  gokku services:create redis:7 --name redis-cache")
 	fmt.Println("  gokku services:link postgres-api -a api-production")
-	fmt.Println("  gokku postgres:backup postgres-api > backup.sql")
+	fmt.Println("  gokku services:backup postgres-api --schedule @daily --keep 7")
+	fmt.Println("  gokku services:upgrade postgres-api 16")
 }
@@ -12,8 +12,9 @@ func useTool(args []string) {
 	if len(args) == 0 {
 		fmt.Println("Usage: gokku tool <command> [args...]")
 		fmt.Println("Commands:")
-		fmt.Println("  parse-app-config <app-name>              Parse app configuration")
-		fmt.Println("  validate-config                           Validate gokku.yml")
+		fmt.Println("  parse-app-config <app-name> [path]        Print app configuration with defaults applied")
+		fmt.Println("  validate-config [path]                    Validate gokku.yml (default: ./gokku.yml)")
+		fmt.Println("  schema                                    Print the JSON Schema for gokku.yml")
 		os.Exit(1)
 	}
 
@@ -21,18 +22,35 @@ func useTool(args []string) {
 	switch command {
 	case "parse-app-config":
 		if len(args) < 2 {
-			fmt.Println("Usage: gokku internal parse-app-config <app-name>")
+			fmt.Println("Usage: gokku tool parse-app-config <app-name> [path]")
 			os.Exit(1)
 		}
-		handleParseAppConfig(args[1])
+
+		configPath := fmt.Sprintf("/opt/gokku/apps/%s/gokku.yml", args[1])
+
+		if len(args) >= 3 {
+			configPath = args[2]
+		}
+
+		handleParseAppConfig(args[1], configPath)
+	case "validate-config":
+		path := "gokku.yml"
+
+		if len(args) >= 2 {
+			path = args[1]
+		}
+
+		handleValidateConfig(path)
+	case "schema":
+		handleSchema()
 	default:
 		fmt.Printf("Unknown internal command: %s\n", command)
 		os.Exit(1)
 	}
 }
 
-func handleParseAppConfig(appName string) {
-	app, err := internal.LoadAppConfig(appName)
+func handleParseAppConfig(appName, configPath string) {
+	app, err := internal.LoadAppConfigFromFile(configPath, appName)
 
 	if err != nil {
 		fmt.Printf("ERROR: Failed to load config: %v\n", err)
@@ -49,3 +67,32 @@ func handleParseAppConfig(appName string) {
 
 	fmt.Println(string(jsonData))
 }
+
+func handleValidateConfig(path string) {
+	if err := internal.ValidateConfigFile(path); err != nil {
+		fmt.Printf("%s is invalid:\n", path)
+
+		if errs, ok := err.(internal.ValidationErrors); ok {
+			for _, e := range errs {
+				fmt.Printf("  - %s\n", e.Error())
+			}
+		} else {
+			fmt.Printf("  - %v\n", err)
+		}
+
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ %s is valid\n", path)
+}
+
+func handleSchema() {
+	data, err := internal.MarshalSchema()
+
+	if err != nil {
+		fmt.Printf("ERROR: Failed to generate schema: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(string(data))
+}
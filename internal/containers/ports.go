@@ -0,0 +1,225 @@
+package containers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// DefaultBasePort is the first port allocated when base_port is not set
+const DefaultBasePort = 8000
+
+// PortAllocation is a host port reserved for an app process
+type PortAllocation struct {
+	App         string `json:"app"`
+	Process     string `json:"process"`
+	Port        int    `json:"port"`
+	AllocatedAt string `json:"allocated_at"`
+}
+
+// PortLedger persists host port allocations under the gokku base directory.
+// Every read-modify-write is guarded by an exclusive lock on ports.lock so
+// concurrent deploys never hand out the same port.
+type PortLedger struct {
+	ledgerPath string
+	lockPath   string
+
+	// isPortFree reports whether nothing is listening on the port
+	isPortFree func(port int) bool
+}
+
+// NewPortLedger creates a new port ledger
+func NewPortLedger(baseDir string) *PortLedger {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &PortLedger{
+		ledgerPath: filepath.Join(baseDir, "ports.json"),
+		lockPath:   filepath.Join(baseDir, "ports.lock"),
+		isPortFree: isPortAvailable,
+	}
+}
+
+// Allocate returns the port reserved for app/process, reserving the lowest
+// free port from basePort when there is none yet
+func (l *PortLedger) Allocate(app, process string, basePort int) (int, error) {
+	if basePort <= 0 {
+		basePort = DefaultBasePort
+	}
+
+	var port int
+
+	err := l.update(func(allocations []PortAllocation) ([]PortAllocation, error) {
+		reserved := make(map[int]bool, len(allocations))
+
+		for _, allocation := range allocations {
+			if allocation.App == app && allocation.Process == process {
+				port = allocation.Port
+				return allocations, nil
+			}
+
+			reserved[allocation.Port] = true
+		}
+
+		for candidate := basePort; candidate <= 65535; candidate++ {
+			if reserved[candidate] || !l.isPortFree(candidate) {
+				continue
+			}
+
+			port = candidate
+
+			return append(allocations, PortAllocation{
+				App:         app,
+				Process:     process,
+				Port:        candidate,
+				AllocatedAt: time.Now().Format(time.RFC3339),
+			}), nil
+		}
+
+		return nil, fmt.Errorf("no available ports from %d", basePort)
+	})
+
+	return port, err
+}
+
+// Release frees the port reserved for app/process
+func (l *PortLedger) Release(app, process string) error {
+	return l.update(func(allocations []PortAllocation) ([]PortAllocation, error) {
+		var kept []PortAllocation
+
+		for _, allocation := range allocations {
+			if allocation.App != app || allocation.Process != process {
+				kept = append(kept, allocation)
+			}
+		}
+
+		return kept, nil
+	})
+}
+
+// ReleaseApp frees every port reserved for app
+func (l *PortLedger) ReleaseApp(app string) error {
+	return l.update(func(allocations []PortAllocation) ([]PortAllocation, error) {
+		var kept []PortAllocation
+
+		for _, allocation := range allocations {
+			if allocation.App != app {
+				kept = append(kept, allocation)
+			}
+		}
+
+		return kept, nil
+	})
+}
+
+// List returns all allocations sorted by port
+func (l *PortLedger) List() ([]PortAllocation, error) {
+	var result []PortAllocation
+
+	err := l.withLock(syscall.LOCK_SH, func() error {
+		allocations, err := l.load()
+		result = allocations
+		return err
+	})
+
+	return result, err
+}
+
+// update applies fn to the allocations while holding the exclusive lock
+func (l *PortLedger) update(fn func([]PortAllocation) ([]PortAllocation, error)) error {
+	return l.withLock(syscall.LOCK_EX, func() error {
+		allocations, err := l.load()
+
+		if err != nil {
+			return err
+		}
+
+		updated, err := fn(allocations)
+
+		if err != nil {
+			return err
+		}
+
+		return l.save(updated)
+	})
+}
+
+func (l *PortLedger) withLock(how int, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(l.lockPath), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+
+	lockFile, err := os.OpenFile(l.lockPath, os.O_CREATE|os.O_RDWR, 0644)
+
+	if err != nil {
+		return fmt.Errorf("failed to open port ledger lock: %w", err)
+	}
+
+	defer lockFile.Close()
+
+	if err := syscall.Flock(int(lockFile.Fd()), how); err != nil {
+		return fmt.Errorf("failed to lock port ledger: %w", err)
+	}
+
+	defer syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+func (l *PortLedger) load() ([]PortAllocation, error) {
+	data, err := os.ReadFile(l.ledgerPath)
+
+	if os.IsNotExist(err) {
+		return []PortAllocation{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read port ledger: %w", err)
+	}
+
+	var allocations []PortAllocation
+
+	if err := json.Unmarshal(data, &allocations); err != nil {
+		return nil, fmt.Errorf("failed to parse port ledger: %w", err)
+	}
+
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Port < allocations[j].Port
+	})
+
+	return allocations, nil
+}
+
+func (l *PortLedger) save(allocations []PortAllocation) error {
+	if allocations == nil {
+		allocations = []PortAllocation{}
+	}
+
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Port < allocations[j].Port
+	})
+
+	data, err := json.MarshalIndent(allocations, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal port ledger: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never see a partial ledger
+	tmpPath := l.ledgerPath + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write port ledger: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, l.ledgerPath); err != nil {
+		return fmt.Errorf("failed to write port ledger: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package containers
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type PortLedgerTestSuite struct {
+	suite.Suite
+	tempDir string
+	ledger  *PortLedger
+	busy    map[int]bool
+}
+
+func TestPortLedgerTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(PortLedgerTestSuite))
+}
+
+func (s *PortLedgerTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "gokku-test-*")
+	s.Require().NoError(err)
+
+	s.busy = make(map[int]bool)
+	s.ledger = NewPortLedger(s.tempDir)
+	s.ledger.isPortFree = func(port int) bool { return !s.busy[port] }
+}
+
+func (s *PortLedgerTestSuite) TearDownTest() {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+}
+
+func (s *PortLedgerTestSuite) TestAllocate_AssignsSequentialPorts() {
+	api, err := s.ledger.Allocate("api", "web", 8000)
+	Expect(err).To(BeNil())
+
+	worker, err := s.ledger.Allocate("worker", "web", 8000)
+	Expect(err).To(BeNil())
+
+	Expect(api).To(Equal(8000))
+	Expect(worker).To(Equal(8001))
+}
+
+func (s *PortLedgerTestSuite) TestAllocate_ReturnsExistingAllocation() {
+	first, _ := s.ledger.Allocate("api", "web", 8000)
+	second, err := s.ledger.Allocate("api", "web", 8000)
+
+	Expect(err).To(BeNil())
+	Expect(second).To(Equal(first))
+}
+
+func (s *PortLedgerTestSuite) TestAllocate_PersistsAcrossInstances() {
+	s.ledger.Allocate("api", "web", 8000)
+
+	other := NewPortLedger(s.tempDir)
+	other.isPortFree = s.ledger.isPortFree
+
+	port, err := other.Allocate("api", "web", 9000)
+
+	Expect(err).To(BeNil())
+	Expect(port).To(Equal(8000))
+}
+
+func (s *PortLedgerTestSuite) TestAllocate_SkipsPortsInUse() {
+	s.busy[8000] = true
+
+	port, err := s.ledger.Allocate("api", "web", 8000)
+
+	Expect(err).To(BeNil())
+	Expect(port).To(Equal(8001))
+}
+
+func (s *PortLedgerTestSuite) TestAllocate_WithDefaultBasePort() {
+	port, err := s.ledger.Allocate("api", "web", 0)
+
+	Expect(err).To(BeNil())
+	Expect(port).To(Equal(DefaultBasePort))
+}
+
+func (s *PortLedgerTestSuite) TestAllocate_IsSafeForConcurrentUse() {
+	var wg sync.WaitGroup
+	ports := make([]int, 10)
+
+	for i := range ports {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			// Each goroutine uses its own ledger, like separate gokku processes
+			ledger := NewPortLedger(s.tempDir)
+			ledger.isPortFree = func(int) bool { return true }
+
+			ports[i], _ = ledger.Allocate(fmt.Sprintf("app-%d", i), "web", 8000)
+		}(i)
+	}
+
+	wg.Wait()
+
+	Expect(ports).To(ConsistOf(8000, 8001, 8002, 8003, 8004, 8005, 8006, 8007, 8008, 8009))
+}
+
+func (s *PortLedgerTestSuite) TestRelease_FreesPortForReuse() {
+	s.ledger.Allocate("api", "web", 8000)
+	s.ledger.Allocate("worker", "web", 8000)
+
+	Expect(s.ledger.Release("api", "web")).To(BeNil())
+
+	port, err := s.ledger.Allocate("billing", "web", 8000)
+
+	Expect(err).To(BeNil())
+	Expect(port).To(Equal(8000))
+}
+
+func (s *PortLedgerTestSuite) TestReleaseApp_RemovesAllProcesses() {
+	s.ledger.Allocate("api", "web", 8000)
+	s.ledger.Allocate("api", "admin", 8000)
+	s.ledger.Allocate("worker", "web", 8000)
+
+	Expect(s.ledger.ReleaseApp("api")).To(BeNil())
+
+	allocations, err := s.ledger.List()
+
+	Expect(err).To(BeNil())
+	Expect(allocations).To(HaveLen(1))
+	Expect(allocations[0].App).To(Equal("worker"))
+}
+
+func (s *PortLedgerTestSuite) TestList_WhenLedgerIsEmpty() {
+	allocations, err := s.ledger.List()
+
+	Expect(err).To(BeNil())
+	Expect(allocations).To(BeEmpty())
+}
@@ -3,8 +3,8 @@ package containers
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"time"
@@ -34,6 +34,13 @@ func NewContainerRegistry() *ContainerRegistry {
 	}
 }
 
+// NewContainerRegistryAt creates a container registry rooted at an apps directory
+func NewContainerRegistryAt(basePath string) *ContainerRegistry {
+	return &ContainerRegistry{
+		basePath: basePath,
+	}
+}
+
 // SaveContainerInfo saves container information to disk
 func (cr *ContainerRegistry) SaveContainerInfo(info ContainerInfo) error {
 	appPath := filepath.Join(cr.basePath, info.AppName)
@@ -204,14 +211,23 @@ func (cr *ContainerRegistry) GetAllContainers(appName string) ([]ContainerInfo,
 	return allContainers, nil
 }
 
-// GetNextAvailablePort finds the next available port starting from 32768
+// GetNextAvailablePort finds the next available port starting from 32768,
+// skipping ports reserved in the port ledger even if their container is stopped
 func GetNextAvailablePort() (int, error) {
 	// Start from port 32768 (Docker's default range)
 	startPort := 32768
 	endPort := 65535
 
+	reserved := make(map[int]bool)
+
+	if allocations, err := NewPortLedger("").List(); err == nil {
+		for _, allocation := range allocations {
+			reserved[allocation.Port] = true
+		}
+	}
+
 	for port := startPort; port <= endPort; port++ {
-		if isPortAvailable(port) {
+		if !reserved[port] && isPortAvailable(port) {
 			return port, nil
 		}
 	}
@@ -219,30 +235,16 @@ func GetNextAvailablePort() (int, error) {
 	return 0, fmt.Errorf("no available ports found")
 }
 
-// isPortAvailable checks if a port is available
+// isPortAvailable checks if a port is available by trying to bind it
 func isPortAvailable(port int) bool {
-	portPattern := fmt.Sprintf(":%d ", port)
-
-	// Try netstat with grep first
-	netstatCmd := exec.Command("sh", "-c", fmt.Sprintf("netstat -ln 2>/dev/null | grep -q '%s'", portPattern))
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 
-	err := netstatCmd.Run()
-
-	if err == nil {
-		// grep found the port, so it's in use
+	if err != nil {
 		return false
 	}
 
-	// Try ss with grep as fallback
-	ssCmd := exec.Command("sh", "-c", fmt.Sprintf("ss -ln 2>/dev/null | grep -q '%s'", portPattern))
-	err = ssCmd.Run()
-
-	if err == nil {
-		// grep found the port, so it's in use
-		return false
-	}
+	listener.Close()
 
-	// If both commands fail or port is not found, consider it available
 	return true
 }
 
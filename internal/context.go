@@ -98,6 +98,20 @@ func (ctx *ExecutionContext) ExecuteCommand(command string) error {
 	}
 }
 
+// ExecuteInteractiveCommand executes a command, allocating a TTY on the
+// remote side when tty is set so interactive programs work over SSH
+func (ctx *ExecutionContext) ExecuteInteractiveCommand(command string, tty bool) error {
+	if ctx.ServerExecution || !tty {
+		return ctx.ExecuteCommand(command)
+	}
+
+	cmd := exec.Command("ssh", "-t", ctx.Host, command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
 // ExecuteCommandWithOutput executes a command and returns output
 func (ctx *ExecutionContext) ExecuteCommandWithOutput(command string) (string, error) {
 	if ctx.ServerExecution {
@@ -0,0 +1,493 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultCronTimeout bounds a job run when no timeout is configured
+	DefaultCronTimeout = time.Hour
+
+	// CronHistoryLimit is how many runs are kept per job
+	CronHistoryLimit = 20
+
+	// CronProcessType is the gokku.process of containers started by cron jobs
+	CronProcessType = "cron"
+
+	// CronTimeoutExitCode is reported for runs killed by their timeout, like timeout(1)
+	CronTimeoutExitCode = 124
+)
+
+// Cron run triggers
+const (
+	CronTriggerSchedule = "schedule"
+	CronTriggerManual   = "manual"
+)
+
+// Cron run statuses
+const (
+	CronStatusSucceeded = "succeeded"
+	CronStatusFailed    = "failed"
+	CronStatusTimeout   = "timeout"
+	CronStatusSkipped   = "skipped"
+)
+
+// ErrCronJobRunning is returned when a job is started while its previous run is still going
+var ErrCronJobRunning = errors.New("previous run is still in progress")
+
+var cronMacros = map[string]bool{
+	"@yearly": true, "@annually": true, "@monthly": true, "@weekly": true,
+	"@daily": true, "@midnight": true, "@hourly": true,
+}
+
+// cronFieldRanges are the allowed values of the five schedule fields
+var cronFieldRanges = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// CronJobName returns the job's name, defaulting to job-<n> by position
+func CronJobName(job CronJob, index int) string {
+	if job.Name != "" {
+		return job.Name
+	}
+
+	return fmt.Sprintf("job-%d", index+1)
+}
+
+// CronJobs returns the app's jobs with their names filled in
+func CronJobs(app *App) []CronJob {
+	jobs := make([]CronJob, len(app.Cron))
+
+	for i, job := range app.Cron {
+		job.Name = CronJobName(job, i)
+		jobs[i] = job
+	}
+
+	return jobs
+}
+
+// FindCronJob looks up an app's job by name
+func FindCronJob(app *App, name string) (CronJob, error) {
+	for _, job := range CronJobs(app) {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+
+	return CronJob{}, fmt.Errorf("cron job '%s' not found", name)
+}
+
+// TimeoutDuration returns the job's timeout, or DefaultCronTimeout
+func (j CronJob) TimeoutDuration() time.Duration {
+	if timeout, err := time.ParseDuration(j.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+
+	return DefaultCronTimeout
+}
+
+// ValidateCronSchedule checks a five field cron expression or a macro like @daily
+func ValidateCronSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@") {
+		if !cronMacros[schedule] {
+			return fmt.Errorf("unknown schedule '%s' (expected one of: @hourly, @daily, @weekly, @monthly, @yearly)", schedule)
+		}
+
+		return nil
+	}
+
+	fields := strings.Fields(schedule)
+
+	if len(fields) != 5 {
+		return fmt.Errorf("invalid schedule '%s' (expected 5 fields: minute hour day-of-month month day-of-week)", schedule)
+	}
+
+	for i, field := range fields {
+		r := cronFieldRanges[i]
+
+		if err := validateCronField(field, r.min, r.max); err != nil {
+			return fmt.Errorf("invalid %s '%s' in schedule '%s': %v", r.name, field, schedule, err)
+		}
+	}
+
+	return nil
+}
+
+// validateCronField checks a comma separated list of *, n, a-b with optional /step
+func validateCronField(field string, min, max int) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step, hasStep := strings.Cut(part, "/")
+
+		if hasStep {
+			if n, err := strconv.Atoi(step); err != nil || n < 1 {
+				return fmt.Errorf("invalid step '%s'", step)
+			}
+		}
+
+		if rangePart == "*" {
+			continue
+		}
+
+		low, high, isRange := strings.Cut(rangePart, "-")
+
+		if !isRange {
+			high = low
+		}
+
+		lowValue, err := strconv.Atoi(low)
+
+		if err != nil {
+			return fmt.Errorf("invalid value '%s'", low)
+		}
+
+		highValue, err := strconv.Atoi(high)
+
+		if err != nil {
+			return fmt.Errorf("invalid value '%s'", high)
+		}
+
+		if lowValue < min || highValue > max || lowValue > highValue {
+			return fmt.Errorf("out of range %d-%d", min, max)
+		}
+	}
+
+	return nil
+}
+
+// RenderCrontab replaces the app's block of entries in a crontab. Each entry
+// calls gokku cron:run, which handles overlap, timeouts and history.
+func RenderCrontab(existing, appName string, jobs []CronJob, gokkuBin string) string {
+	var entries []string
+
+	for _, job := range jobs {
+		entries = append(entries, fmt.Sprintf("%s %s cron:run %s -a %s --scheduled >/dev/null 2>&1",
+			job.Schedule, gokkuBin, job.Name, appName))
+	}
+
+	return ReplaceCrontabBlock(existing, "cron "+appName, entries)
+}
+
+// ReplaceCrontabBlock replaces the entries between the "# BEGIN gokku <block>"
+// and "# END gokku <block>" markers of a crontab. Without entries the block
+// is removed.
+func ReplaceCrontabBlock(existing, block string, entries []string) string {
+	begin := fmt.Sprintf("# BEGIN gokku %s", block)
+	end := fmt.Sprintf("# END gokku %s", block)
+
+	var lines []string
+	inBlock := false
+
+	for _, line := range strings.Split(strings.TrimRight(existing, "\n"), "\n") {
+		switch {
+		case line == begin:
+			inBlock = true
+		case line == end:
+			inBlock = false
+		case !inBlock && line != "":
+			lines = append(lines, line)
+		}
+	}
+
+	if len(entries) > 0 {
+		lines = append(lines, begin)
+		lines = append(lines, entries...)
+		lines = append(lines, end)
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// InstallCronJobs writes the app's jobs to the crontab of the current user
+func InstallCronJobs(appName string, app *App) error {
+	jobs := CronJobs(app)
+
+	return InstallCrontab(len(jobs) > 0, func(existing, gokkuBin string) string {
+		return RenderCrontab(existing, appName, jobs, gokkuBin)
+	})
+}
+
+// InstallCrontab rewrites the crontab of the current user with render, which
+// gets the current crontab and the path of the gokku binary. A missing
+// crontab command is only an error when something needs scheduling.
+func InstallCrontab(scheduling bool, render func(existing, gokkuBin string) string) error {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		if !scheduling {
+			return nil
+		}
+
+		return fmt.Errorf("crontab not found, install cron to schedule jobs")
+	}
+
+	gokkuBin, err := os.Executable()
+
+	if err != nil {
+		gokkuBin = "gokku"
+	}
+
+	// crontab -l fails when the user has no crontab yet
+	existing, _ := exec.Command("crontab", "-l").Output()
+
+	rendered := render(string(existing), gokkuBin)
+
+	if rendered == string(existing) {
+		return nil
+	}
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(rendered)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to install crontab: %v, output: %s", err, string(output))
+	}
+
+	return nil
+}
+
+// RemoveCronJobs removes the app's jobs from the crontab
+func RemoveCronJobs(appName string) error {
+	return InstallCronJobs(appName, &App{})
+}
+
+// CronRun is one recorded run of a cron job
+type CronRun struct {
+	Job        string `json:"job"`
+	Trigger    string `json:"trigger"`
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	Container  string `json:"container,omitempty"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	Duration   string `json:"duration"`
+	LogFile    string `json:"log_file,omitempty"`
+}
+
+// CronRunner runs an app's cron jobs in one-off containers, one run per job
+// at a time, and keeps their history under apps/<app>/cron
+type CronRunner struct {
+	baseDir string
+
+	// runContainer runs the one-off container and reports its exit code
+	runContainer func(config OneOffConfig, baseEnv map[string]string, out io.Writer, timeout time.Duration) (int, bool, error)
+}
+
+// NewCronRunner creates a new CronRunner
+func NewCronRunner(baseDir string) *CronRunner {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &CronRunner{baseDir: baseDir, runContainer: runCronContainer}
+}
+
+// Run runs the job once. A run that starts while the previous one is still
+// going is recorded as skipped and returns ErrCronJobRunning.
+func (r *CronRunner) Run(appName string, app *App, job CronJob, trigger string, out io.Writer) (CronRun, error) {
+	cronDir := r.cronDir(appName)
+
+	if err := os.MkdirAll(filepath.Join(cronDir, "logs", job.Name), 0755); err != nil {
+		return CronRun{}, fmt.Errorf("failed to create cron directory: %v", err)
+	}
+
+	started := time.Now()
+	run := CronRun{Job: job.Name, Trigger: trigger, StartedAt: started.Format(time.RFC3339)}
+
+	lock, err := os.OpenFile(filepath.Join(cronDir, job.Name+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+
+	if err != nil {
+		return run, fmt.Errorf("failed to open cron lock: %v", err)
+	}
+
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		run.Status = CronStatusSkipped
+		run.FinishedAt = run.StartedAt
+		run.Duration = "0s"
+
+		return run, errors.Join(ErrCronJobRunning, r.record(appName, run))
+	}
+
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	config := NewOneOffConfig(appName, app, job.Command)
+	config.Name = fmt.Sprintf("%s-%s-%s-%d", appName, CronProcessType, job.Name, started.Unix())
+	config.Labels.Process = CronProcessType
+	run.Container = config.Name
+
+	run.LogFile = filepath.Join(cronDir, "logs", job.Name, started.Format("20060102-150405")+".log")
+	logFile, err := os.Create(run.LogFile)
+
+	if err != nil {
+		return run, fmt.Errorf("failed to create cron log: %v", err)
+	}
+
+	code, timedOut, runErr := r.runContainer(config, AppBaseEnv(app), io.MultiWriter(logFile, out), job.TimeoutDuration())
+	logFile.Close()
+
+	run.ExitCode = code
+	run.FinishedAt = time.Now().Format(time.RFC3339)
+	run.Duration = time.Since(started).Round(time.Second).String()
+
+	switch {
+	case timedOut:
+		run.Status = CronStatusTimeout
+		run.ExitCode = CronTimeoutExitCode
+	case runErr == nil && code == 0:
+		run.Status = CronStatusSucceeded
+	default:
+		run.Status = CronStatusFailed
+	}
+
+	if err := r.record(appName, run); err != nil {
+		return run, err
+	}
+
+	return run, runErr
+}
+
+// History returns the recorded runs of a job, newest first
+func (r *CronRunner) History(appName, jobName string) ([]CronRun, error) {
+	data, err := os.ReadFile(r.historyPath(appName, jobName))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cron history: %v", err)
+	}
+
+	var runs []CronRun
+
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse cron history: %v", err)
+	}
+
+	return runs, nil
+}
+
+// LastRun returns the most recent run of a job, if any
+func (r *CronRunner) LastRun(appName, jobName string) (*CronRun, error) {
+	runs, err := r.History(appName, jobName)
+
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+
+	return &runs[0], nil
+}
+
+// record prepends the run to the job's history, dropping the oldest runs and
+// their logs beyond CronHistoryLimit. Runs skipped while the job lock is held
+// are recorded too, so writers take the history lock.
+func (r *CronRunner) record(appName string, run CronRun) error {
+	lock, err := os.OpenFile(filepath.Join(r.cronDir(appName), run.Job+".history.lock"), os.O_CREATE|os.O_RDWR, 0644)
+
+	if err != nil {
+		return fmt.Errorf("failed to open cron history lock: %v", err)
+	}
+
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock cron history: %v", err)
+	}
+
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	runs, err := r.History(appName, run.Job)
+
+	if err != nil {
+		return err
+	}
+
+	runs = append([]CronRun{run}, runs...)
+
+	if len(runs) > CronHistoryLimit {
+		for _, old := range runs[CronHistoryLimit:] {
+			if old.LogFile != "" {
+				os.Remove(old.LogFile)
+			}
+		}
+
+		runs = runs[:CronHistoryLimit]
+	}
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("failed to encode cron history: %v", err)
+	}
+
+	path := r.historyPath(appName, run.Job)
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cron history: %v", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (r *CronRunner) cronDir(appName string) string {
+	return filepath.Join(r.baseDir, "apps", appName, "cron")
+}
+
+func (r *CronRunner) historyPath(appName, jobName string) string {
+	return filepath.Join(r.cronDir(appName), jobName+".history.json")
+}
+
+// runCronContainer runs the one-off container, removing it when the timeout
+// expires
+func runCronContainer(config OneOffConfig, baseEnv map[string]string, out io.Writer, timeout time.Duration) (int, bool, error) {
+	cmd, cleanup, err := PrepareOneOff(config, baseEnv)
+	defer cleanup()
+
+	if err != nil {
+		return 1, false, err
+	}
+
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err := cmd.Start(); err != nil {
+		return 1, false, fmt.Errorf("failed to start %s: %v", config.Name, err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		code, err := commandExitCode(err)
+		return code, false, err
+	case <-time.After(timeout):
+		fmt.Fprintf(out, "\n-----> Timed out after %s, removing %s\n", timeout, config.Name)
+		RemoveContainer(config.Name, true)
+		<-done
+
+		return CronTimeoutExitCode, true, nil
+	}
+}
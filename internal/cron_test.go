@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type CronTestSuite struct {
+	suite.Suite
+	tempDir string
+	runner  *CronRunner
+	app     *App
+	exit    int
+	timeout bool
+	started []OneOffConfig
+}
+
+func TestCronTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(CronTestSuite))
+}
+
+func (s *CronTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.exit = 0
+	s.timeout = false
+	s.started = nil
+
+	s.app = &App{Cron: []CronJob{{Name: "cleanup", Schedule: "*/5 * * * *", Command: "./app cleanup", Timeout: "10m"}}}
+
+	s.runner = NewCronRunner(s.tempDir)
+	s.runner.runContainer = func(config OneOffConfig, baseEnv map[string]string, out io.Writer, timeout time.Duration) (int, bool, error) {
+		s.started = append(s.started, config)
+		fmt.Fprintln(out, "cleaned up")
+		return s.exit, s.timeout, nil
+	}
+}
+
+func (s *CronTestSuite) TestValidateCronSchedule_WithValidSchedules() {
+	for _, schedule := range []string{"*/5 * * * *", "0 2 * * 1-5", "0,30 8-18/2 1 1,6 0", "@daily"} {
+		Expect(ValidateCronSchedule(schedule)).To(BeNil(), schedule)
+	}
+}
+
+func (s *CronTestSuite) TestValidateCronSchedule_WithInvalidSchedules() {
+	for _, schedule := range []string{"* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@sometimes", "a * * * *"} {
+		Expect(ValidateCronSchedule(schedule)).NotTo(BeNil(), schedule)
+	}
+}
+
+func (s *CronTestSuite) TestCronJobs_DefaultsNames() {
+	app := &App{Cron: []CronJob{{Schedule: "@hourly", Command: "a"}, {Name: "report", Schedule: "@daily", Command: "b"}}}
+
+	jobs := CronJobs(app)
+
+	Expect(jobs[0].Name).To(Equal("job-1"))
+	Expect(jobs[1].Name).To(Equal("report"))
+}
+
+func (s *CronTestSuite) TestTimeoutDuration() {
+	Expect(CronJob{Timeout: "10m"}.TimeoutDuration()).To(Equal(10 * time.Minute))
+	Expect(CronJob{}.TimeoutDuration()).To(Equal(DefaultCronTimeout))
+}
+
+func (s *CronTestSuite) TestRenderCrontab_ReplacesOnlyTheAppBlock() {
+	existing := "0 0 * * * /usr/local/bin/backup\n" +
+		"# BEGIN gokku cron api\n*/1 * * * * gokku cron:run old -a api --scheduled >/dev/null 2>&1\n# END gokku cron api\n" +
+		"# BEGIN gokku cron worker\n@daily gokku cron:run report -a worker --scheduled >/dev/null 2>&1\n# END gokku cron worker\n"
+
+	rendered := RenderCrontab(existing, "api", CronJobs(s.app), "/usr/local/bin/gokku")
+
+	Expect(rendered).To(Equal("0 0 * * * /usr/local/bin/backup\n" +
+		"# BEGIN gokku cron worker\n@daily gokku cron:run report -a worker --scheduled >/dev/null 2>&1\n# END gokku cron worker\n" +
+		"# BEGIN gokku cron api\n*/5 * * * * /usr/local/bin/gokku cron:run cleanup -a api --scheduled >/dev/null 2>&1\n# END gokku cron api\n"))
+}
+
+func (s *CronTestSuite) TestRenderCrontab_WithoutJobsRemovesTheBlock() {
+	existing := "# BEGIN gokku cron api\n@daily gokku cron:run old -a api --scheduled >/dev/null 2>&1\n# END gokku cron api\n"
+
+	Expect(RenderCrontab(existing, "api", nil, "gokku")).To(BeEmpty())
+}
+
+func (s *CronTestSuite) TestRun_RecordsSuccessfulRun() {
+	job, _ := FindCronJob(s.app, "cleanup")
+
+	run, err := s.runner.Run("api", s.app, job, CronTriggerManual, io.Discard)
+
+	Expect(err).To(BeNil())
+	Expect(run.Status).To(Equal(CronStatusSucceeded))
+	Expect(s.started).To(HaveLen(1))
+	Expect(s.started[0].Command).To(Equal("./app cleanup"))
+	Expect(s.started[0].Labels.Process).To(Equal(CronProcessType))
+	Expect(s.started[0].Labels.Role).To(Equal(RoleOneOff))
+
+	output, _ := os.ReadFile(run.LogFile)
+	Expect(string(output)).To(Equal("cleaned up\n"))
+
+	history, err := s.runner.History("api", "cleanup")
+	Expect(err).To(BeNil())
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Trigger).To(Equal(CronTriggerManual))
+}
+
+func (s *CronTestSuite) TestRun_RecordsFailureAndTimeout() {
+	job, _ := FindCronJob(s.app, "cleanup")
+
+	s.exit = 3
+	failed, _ := s.runner.Run("api", s.app, job, CronTriggerSchedule, io.Discard)
+
+	s.timeout = true
+	timedOut, _ := s.runner.Run("api", s.app, job, CronTriggerSchedule, io.Discard)
+
+	Expect(failed.Status).To(Equal(CronStatusFailed))
+	Expect(failed.ExitCode).To(Equal(3))
+	Expect(timedOut.Status).To(Equal(CronStatusTimeout))
+	Expect(timedOut.ExitCode).To(Equal(CronTimeoutExitCode))
+
+	last, _ := s.runner.LastRun("api", "cleanup")
+	Expect(last.Status).To(Equal(CronStatusTimeout))
+}
+
+func (s *CronTestSuite) TestRun_SkipsWhenPreviousRunIsInProgress() {
+	job, _ := FindCronJob(s.app, "cleanup")
+	cronDir := filepath.Join(s.tempDir, "apps", "api", "cron")
+	s.Require().NoError(os.MkdirAll(cronDir, 0755))
+
+	lock, err := os.OpenFile(filepath.Join(cronDir, "cleanup.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	s.Require().NoError(err)
+	defer lock.Close()
+	s.Require().NoError(syscall.Flock(int(lock.Fd()), syscall.LOCK_EX))
+
+	run, err := s.runner.Run("api", s.app, job, CronTriggerSchedule, io.Discard)
+
+	Expect(err).To(MatchError(ErrCronJobRunning))
+	Expect(run.Status).To(Equal(CronStatusSkipped))
+	Expect(s.started).To(BeEmpty())
+}
+
+func (s *CronTestSuite) TestRun_WaitsForHistoryWriter() {
+	job, _ := FindCronJob(s.app, "cleanup")
+	cronDir := filepath.Join(s.tempDir, "apps", "api", "cron")
+	s.Require().NoError(os.MkdirAll(cronDir, 0755))
+
+	lock, err := os.OpenFile(filepath.Join(cronDir, "cleanup.history.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	s.Require().NoError(err)
+	defer lock.Close()
+	s.Require().NoError(syscall.Flock(int(lock.Fd()), syscall.LOCK_EX))
+
+	recorded := make(chan struct{})
+
+	go func() {
+		s.runner.Run("api", s.app, job, CronTriggerManual, io.Discard)
+		close(recorded)
+	}()
+
+	Consistently(recorded, 100*time.Millisecond).ShouldNot(BeClosed())
+
+	s.Require().NoError(syscall.Flock(int(lock.Fd()), syscall.LOCK_UN))
+
+	Eventually(recorded).Should(BeClosed())
+
+	history, _ := s.runner.History("api", "cleanup")
+	Expect(history).To(HaveLen(1))
+}
+
+func (s *CronTestSuite) TestRun_KeepsLimitedHistory() {
+	job, _ := FindCronJob(s.app, "cleanup")
+
+	var first CronRun
+
+	for i := 0; i < CronHistoryLimit+2; i++ {
+		run, _ := s.runner.Run("api", s.app, job, CronTriggerSchedule, io.Discard)
+
+		if i == 0 {
+			first = run
+		}
+	}
+
+	history, _ := s.runner.History("api", "cleanup")
+
+	Expect(history).To(HaveLen(CronHistoryLimit))
+	Expect(first.LogFile).NotTo(BeAnExistingFile())
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// OverrideTag marks an app value that replaces the default instead of merging with it
+const OverrideTag = "!override"
+
+// ParseServerConfig parses gokku.yml content and deep-merges the top-level
+// defaults block into every app.
+//
+// Merge rules, with the app value winning:
+//
+//	maps      merged key by key (env, deployment, network, ...)
+//	lists     default items followed by app items (volumes, ports, ...)
+//	scalars   app value replaces the default
+//
+// Tagging an app value with !override replaces the default as a whole.
+func ParseServerConfig(data []byte) (*ServerConfig, error) {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var config ServerConfig
+
+	if len(root.Content) == 0 {
+		return &config, nil
+	}
+
+	applyDefaults(root.Content[0])
+
+	if err := root.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// applyDefaults replaces each app node with the app merged over the defaults
+func applyDefaults(doc *yaml.Node) {
+	defaults := resolveAlias(mappingValue(doc, "defaults"))
+	apps := resolveAlias(mappingValue(doc, "apps"))
+
+	if defaults == nil || apps == nil || defaults.Kind != yaml.MappingNode || apps.Kind != yaml.MappingNode {
+		return
+	}
+
+	// build_type is a server-wide setting, not an app field
+	appDefaults := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	expanded := expandMergeKeys(defaults)
+
+	for i := 0; i+1 < len(expanded.Content); i += 2 {
+		if expanded.Content[i].Value != "build_type" {
+			appDefaults.Content = append(appDefaults.Content, expanded.Content[i], expanded.Content[i+1])
+		}
+	}
+
+	for i := 1; i < len(apps.Content); i += 2 {
+		app := resolveAlias(apps.Content[i])
+
+		// An app declared without keys only inherits the defaults
+		if app.Kind == yaml.ScalarNode && app.Tag == "!!null" {
+			app = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: app.Line, Column: app.Column}
+		}
+
+		apps.Content[i] = mergeNodes(appDefaults, app)
+	}
+}
+
+// mergeNodes deep-merges override on top of base without mutating either
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	base = resolveAlias(base)
+	override = resolveAlias(override)
+
+	if override.Tag == OverrideTag {
+		replaced := *override
+		replaced.Tag = ""
+
+		return &replaced
+	}
+
+	switch {
+	case base.Kind == yaml.MappingNode && override.Kind == yaml.MappingNode:
+		base = expandMergeKeys(base)
+		override = expandMergeKeys(override)
+
+		merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: override.Line, Column: override.Column}
+		merged.Content = append(merged.Content, base.Content...)
+
+		for i := 0; i+1 < len(override.Content); i += 2 {
+			key, value := override.Content[i], override.Content[i+1]
+
+			if index := mappingIndex(merged, key.Value); index != -1 {
+				merged.Content[index+1] = mergeNodes(merged.Content[index+1], value)
+			} else {
+				merged.Content = append(merged.Content, key, value)
+			}
+		}
+
+		return merged
+
+	case base.Kind == yaml.SequenceNode && override.Kind == yaml.SequenceNode:
+		merged := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: override.Style, Line: override.Line, Column: override.Column}
+		merged.Content = append(merged.Content, base.Content...)
+		merged.Content = append(merged.Content, override.Content...)
+
+		return merged
+	}
+
+	return override
+}
+
+// expandMergeKeys inlines YAML "<<" merge keys so mappings can be merged by key
+func expandMergeKeys(node *yaml.Node) *yaml.Node {
+	if mappingIndex(node, "<<") == -1 {
+		return node
+	}
+
+	expanded := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: node.Line, Column: node.Column}
+	var sources []*yaml.Node
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+
+		if key.Value != "<<" {
+			expanded.Content = append(expanded.Content, key, node.Content[i+1])
+			continue
+		}
+
+		if value.Kind == yaml.SequenceNode {
+			for _, item := range value.Content {
+				sources = append(sources, resolveAlias(item))
+			}
+		} else {
+			sources = append(sources, value)
+		}
+	}
+
+	// Explicit keys win over merged ones, earlier sources win over later ones
+	for _, source := range sources {
+		source = expandMergeKeys(source)
+
+		for i := 0; i+1 < len(source.Content); i += 2 {
+			if mappingIndex(expanded, source.Content[i].Value) == -1 {
+				expanded.Content = append(expanded.Content, source.Content[i], source.Content[i+1])
+			}
+		}
+	}
+
+	return expanded
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	return node
+}
+
+func mappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if index := mappingIndex(node, key); index != -1 {
+		return node.Content[index+1]
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type DefaultsTestSuite struct {
+	suite.Suite
+}
+
+func TestDefaultsTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(DefaultsTestSuite))
+}
+
+const defaultsConfig = `
+defaults:
+  build_type: docker
+  lang: go
+  go_version: "1.25"
+  volumes:
+    - /shared:/shared
+  network:
+    mode: bridge
+  env:
+    LOG_LEVEL: info
+    REGION: us
+  deployment:
+    keep_releases: 5
+    restart_policy: always
+
+x-worker: &worker
+  command: ./worker
+
+apps:
+  api:
+    path: ./cmd/api
+    volumes:
+      - /api:/data
+    env:
+      LOG_LEVEL: debug
+    deployment:
+      keep_releases: 10
+  worker:
+    <<: *worker
+    path: ./cmd/worker
+    go_version: "1.24"
+    volumes: !override
+      - /worker:/data
+  bare:
+`
+
+func (s *DefaultsTestSuite) parse() *ServerConfig {
+	config, err := ParseServerConfig([]byte(defaultsConfig))
+	s.Require().NoError(err)
+
+	return config
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_InheritsScalars() {
+	config := s.parse()
+
+	Expect(config.Apps["api"].Lang).To(Equal("go"))
+	Expect(config.Apps["api"].GoVersion).To(Equal("1.25"))
+	Expect(config.Apps["worker"].GoVersion).To(Equal("1.24"))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_MergesMapsByKey() {
+	config := s.parse()
+
+	Expect(config.Apps["api"].Env).To(Equal(map[string]string{"LOG_LEVEL": "debug", "REGION": "us"}))
+	Expect(config.Apps["api"].Deployment.KeepReleases).To(Equal(10))
+	Expect(config.Apps["api"].Deployment.RestartPolicy).To(Equal("always"))
+	Expect(config.Apps["api"].Network.Mode).To(Equal("bridge"))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_AppendsLists() {
+	config := s.parse()
+
+	Expect(config.Apps["api"].Volumes).To(Equal([]string{"/shared:/shared", "/api:/data"}))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_WithOverrideTag() {
+	config := s.parse()
+
+	Expect(config.Apps["worker"].Volumes).To(Equal([]string{"/worker:/data"}))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_WithMergeKeys() {
+	config := s.parse()
+
+	Expect(config.Apps["worker"].Command).To(Equal("./worker"))
+	Expect(config.Apps["worker"].Lang).To(Equal("go"))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_WhenAppHasNoKeys() {
+	config := s.parse()
+
+	Expect(config.Apps["bare"].Lang).To(Equal("go"))
+	Expect(config.Apps["bare"].Volumes).To(Equal([]string{"/shared:/shared"}))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_KeepsDefaultsUnchanged() {
+	config := s.parse()
+
+	Expect(config.Defaults.BuildType).To(Equal("docker"))
+	Expect(config.Defaults.Volumes).To(Equal([]string{"/shared:/shared"}))
+	Expect(config.Defaults.Env).To(HaveLen(2))
+}
+
+func (s *DefaultsTestSuite) TestParseServerConfig_WithoutDefaults() {
+	config, err := ParseServerConfig([]byte("apps:\n  api:\n    path: ./api\n"))
+
+	Expect(err).To(BeNil())
+	Expect(config.Apps["api"].Path).To(Equal("./api"))
+}
+
+func (s *DefaultsTestSuite) TestValidateConfig_WithDefaultsAndAnchors() {
+	err := ValidateConfig([]byte(defaultsConfig))
+
+	// Only the bare app lacks a path, x-worker and !override are accepted
+	Expect(err).ToNot(BeNil())
+	Expect(err.(ValidationErrors)).To(HaveLen(1))
+	Expect(err.(ValidationErrors)[0].Path).To(Equal("apps.bare"))
+}
+
+func (s *DefaultsTestSuite) TestValidateConfig_WhenPathComesFromDefaults() {
+	config := "defaults:\n  image: nginx:latest\napps:\n  web:\n    ports:\n      - \"80:80\"\n"
+
+	Expect(ValidateConfig([]byte(config))).To(BeNil())
+}
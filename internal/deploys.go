@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ReleaseMetadataFile holds the outcome of the deploy that created a release
+	ReleaseMetadataFile = "release.json"
+
+	// DeployStatsFile holds the deploy counters of an app; unlike release
+	// metadata it survives release cleanup
+	DeployStatsFile = "deploys.json"
+
+	DeployStatusSucceeded = "succeeded"
+	DeployStatusFailed    = "failed"
+)
+
+// ReleaseMetadata describes the deploy that created a release
+type ReleaseMetadata struct {
+	Release    string  `json:"release"`
+	Revision   string  `json:"revision,omitempty"`
+	Status     string  `json:"status"`
+	StartedAt  string  `json:"started_at"`
+	FinishedAt string  `json:"finished_at"`
+	Duration   float64 `json:"duration_seconds"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// DeployStats are the deploy counters of an app
+type DeployStats struct {
+	Succeeded       int              `json:"succeeded"`
+	Failed          int              `json:"failed"`
+	DurationSeconds float64          `json:"duration_seconds_total"`
+	Last            *ReleaseMetadata `json:"last,omitempty"`
+	LastSucceeded   *ReleaseMetadata `json:"last_succeeded,omitempty"`
+}
+
+// RecordDeploy writes the release metadata of a finished deploy and adds
+// it to the app's deploy counters. deployErr is the deploy result.
+func RecordDeploy(baseDir, appName, release string, startedAt time.Time, deployErr error) (ReleaseMetadata, error) {
+	appDir := filepath.Join(baseDir, "apps", appName)
+	releaseDir := filepath.Join(appDir, "releases", release)
+	finishedAt := time.Now()
+
+	meta := ReleaseMetadata{
+		Release:    release,
+		Status:     DeployStatusSucceeded,
+		StartedAt:  startedAt.UTC().Format(time.RFC3339),
+		FinishedAt: finishedAt.UTC().Format(time.RFC3339),
+		Duration:   finishedAt.Sub(startedAt).Seconds(),
+	}
+
+	if deployErr != nil {
+		meta.Status = DeployStatusFailed
+		meta.Error = deployErr.Error()
+	}
+
+	if revision, err := os.ReadFile(filepath.Join(releaseDir, RevisionFile)); err == nil {
+		meta.Revision = strings.TrimSpace(string(revision))
+	}
+
+	// The release directory is missing when the deploy failed before creating it
+	if _, err := os.Stat(releaseDir); err == nil {
+		if err := writeJSONFile(filepath.Join(releaseDir, ReleaseMetadataFile), meta); err != nil {
+			return meta, fmt.Errorf("failed to write release metadata: %v", err)
+		}
+	}
+
+	stats, err := ReadDeployStats(baseDir, appName)
+
+	if err != nil {
+		return meta, err
+	}
+
+	if meta.Status == DeployStatusSucceeded {
+		stats.Succeeded++
+		stats.LastSucceeded = &meta
+	} else {
+		stats.Failed++
+	}
+
+	stats.DurationSeconds += meta.Duration
+	stats.Last = &meta
+
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		return meta, fmt.Errorf("failed to create app directory: %v", err)
+	}
+
+	if err := writeJSONFile(filepath.Join(appDir, DeployStatsFile), stats); err != nil {
+		return meta, fmt.Errorf("failed to write deploy stats: %v", err)
+	}
+
+	return meta, nil
+}
+
+// ReadDeployStats returns the deploy counters of an app, empty when it was never deployed
+func ReadDeployStats(baseDir, appName string) (DeployStats, error) {
+	var stats DeployStats
+
+	data, err := os.ReadFile(filepath.Join(baseDir, "apps", appName, DeployStatsFile))
+
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+
+	if err != nil {
+		return stats, fmt.Errorf("failed to read deploy stats: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("failed to parse deploy stats: %v", err)
+	}
+
+	return stats, nil
+}
+
+// ReadReleaseMetadata returns the metadata of a release
+func ReadReleaseMetadata(releaseDir string) (*ReleaseMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(releaseDir, ReleaseMetadataFile))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var meta ReleaseMetadata
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse release metadata: %v", err)
+	}
+
+	return &meta, nil
+}
+
+// writeJSONFile writes v through a temporary file so readers never see a partial file
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploysTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestDeploysTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(DeploysTestSuite))
+}
+
+func (s *DeploysTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *DeploysTestSuite) TestRecordDeploy_WritesReleaseMetadataAndStats() {
+	releaseDir := filepath.Join(s.tempDir, "apps", "api", "releases", "20240101-120000")
+	s.Require().NoError(os.MkdirAll(releaseDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(releaseDir, RevisionFile), []byte("abc123\n"), 0644))
+
+	meta, err := RecordDeploy(s.tempDir, "api", "20240101-120000", time.Now().Add(-30*time.Second), nil)
+
+	Expect(err).To(BeNil())
+	Expect(meta.Status).To(Equal(DeployStatusSucceeded))
+	Expect(meta.Revision).To(Equal("abc123"))
+	Expect(meta.Duration).To(BeNumerically("~", 30, 1))
+
+	saved, err := ReadReleaseMetadata(releaseDir)
+	Expect(err).To(BeNil())
+	Expect(*saved).To(Equal(meta))
+
+	stats, err := ReadDeployStats(s.tempDir, "api")
+	Expect(err).To(BeNil())
+	Expect(stats.Succeeded).To(Equal(1))
+	Expect(stats.LastSucceeded.Release).To(Equal("20240101-120000"))
+}
+
+func (s *DeploysTestSuite) TestRecordDeploy_CountsFailuresWithoutReleaseDir() {
+	_, err := RecordDeploy(s.tempDir, "api", "20240101-120000", time.Now(), nil)
+	s.Require().NoError(err)
+
+	meta, err := RecordDeploy(s.tempDir, "api", "20240101-130000", time.Now(), errors.New("build failed"))
+
+	Expect(err).To(BeNil())
+	Expect(meta.Status).To(Equal(DeployStatusFailed))
+	Expect(meta.Error).To(Equal("build failed"))
+
+	stats, _ := ReadDeployStats(s.tempDir, "api")
+	Expect(stats.Succeeded).To(Equal(1))
+	Expect(stats.Failed).To(Equal(1))
+	Expect(stats.Last.Release).To(Equal("20240101-130000"))
+	Expect(stats.LastSucceeded.Release).To(Equal("20240101-120000"))
+}
+
+func (s *DeploysTestSuite) TestReadDeployStats_WhenNeverDeployed() {
+	stats, err := ReadDeployStats(s.tempDir, "api")
+
+	Expect(err).To(BeNil())
+	Expect(stats).To(Equal(DeployStats{}))
+}
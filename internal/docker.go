@@ -24,8 +24,10 @@ type ContainerInfo struct {
 	ID      string `json:"ID"`
 	Names   string `json:"Names"`
 	Image   string `json:"Image"`
+	State   string `json:"State"`
 	Status  string `json:"Status"`
 	Ports   string `json:"Ports"`
+	Labels  string `json:"Labels"`
 	Command string `json:"Command"`
 	Created string `json:"CreatedAt"`
 }
@@ -40,6 +42,9 @@ type ContainerConfig struct {
 	Volumes       []string
 	WorkingDir    string
 	Command       []string
+	Env           map[string]string // Base env from gokku.yml, overridden by EnvFile
+	Labels        ContainerLabels
+	Logging       *LoggingConfig
 }
 
 type DeploymentConfig struct {
@@ -52,6 +57,24 @@ type DeploymentConfig struct {
 	NetworkMode   string
 	DockerPorts   []string
 	Volumes       []string
+	Env           map[string]string
+	Labels        ContainerLabels
+	Logging       *LoggingConfig
+}
+
+// containerLabels returns the deployment labels, defaulting to the app's web process
+func (config DeploymentConfig) containerLabels(role string) ContainerLabels {
+	labels := config.Labels
+
+	if labels.App == "" {
+		labels.App = config.AppName
+	}
+
+	if labels.Process == "" {
+		labels.Process = DefaultProcessType
+	}
+
+	return labels.WithRole(role)
 }
 
 // ListContainers returns list of containers in JSON format
@@ -93,7 +116,7 @@ func ContainerExists(name string) bool {
 	containers, err := ListContainers(true)
 	if err == nil {
 		for _, container := range containers {
-			if strings.Contains(container.Names, name) {
+			if container.HasName(name) {
 				return true
 			}
 		}
@@ -107,7 +130,7 @@ func ContainerExists(name string) bool {
 	}
 
 	names := strings.TrimSpace(string(output))
-	return names != "" && strings.Contains(names, name)
+	return names == name
 }
 
 // ContainerIsRunning checks if a container is running
@@ -117,7 +140,7 @@ func ContainerIsRunning(name string) bool {
 	containers, err := ListContainers(false)
 	if err == nil {
 		for _, container := range containers {
-			if strings.Contains(container.Names, name) {
+			if container.HasName(name) {
 				return true
 			}
 		}
@@ -131,7 +154,7 @@ func ContainerIsRunning(name string) bool {
 	}
 
 	names := strings.TrimSpace(string(output))
-	return names != "" && strings.Contains(names, name)
+	return names == name
 }
 
 // StopContainer stops a container
@@ -165,7 +188,7 @@ func CreateContainer(config ContainerConfig) error {
 	args := []string{"run", "-d", "--name", config.Name}
 
 	// Add Gokku labels to identify this container
-	for _, label := range GetGokkuLabels() {
+	for _, label := range config.Labels.List() {
 		args = append(args, "--label", label)
 	}
 
@@ -174,8 +197,14 @@ func CreateContainer(config ContainerConfig) error {
 		args = append(args, "--restart", config.RestartPolicy)
 	}
 
-	// Add network mode
+	// Add network mode, creating the app's private network on first use
 	if config.NetworkMode != "" {
+		if IsAppNetwork(config.Labels.App, config.NetworkMode) {
+			if err := EnsureNetwork(config.NetworkMode); err != nil {
+				return err
+			}
+		}
+
 		args = append(args, "--network", config.NetworkMode)
 	}
 
@@ -184,9 +213,18 @@ func CreateContainer(config ContainerConfig) error {
 		args = append(args, "-p", port)
 	}
 
-	// Add environment file
-	if config.EnvFile != "" {
-		args = append(args, "--env-file", config.EnvFile)
+	// Add environment, merging gokku.yml env with the env file and
+	// resolving variable references at create time
+	if config.EnvFile != "" || len(config.Env) > 0 {
+		resolvedEnvFile, err := WriteResolvedEnvFile(config.EnvFile, config.Env, config.NetworkMode)
+
+		if err != nil {
+			return fmt.Errorf("failed to resolve environment for %s: %v", config.Name, err)
+		}
+
+		defer os.Remove(resolvedEnvFile)
+
+		args = append(args, "--env-file", resolvedEnvFile)
 	}
 
 	// Add volumes
@@ -199,6 +237,9 @@ func CreateContainer(config ContainerConfig) error {
 		args = append(args, "-w", config.WorkingDir)
 	}
 
+	// Add log rotation
+	args = append(args, config.Logging.DockerArgs()...)
+
 	// Add ulimits
 	args = append(args, "--ulimit", "nofile=65536:65536")
 	args = append(args, "--ulimit", "nproc=4096:4096")
@@ -360,6 +401,9 @@ func StandardDeploy(config DeploymentConfig) error {
 		RestartPolicy: "no",
 		WorkingDir:    "/app",
 		Volumes:       []string{fmt.Sprintf("%s:/app", config.ReleaseDir)},
+		Env:           config.Env,
+		Labels:        config.containerLabels(RoleActive),
+		Logging:       config.Logging,
 	}
 
 	// Add custom volumes from gokku.yml
@@ -373,6 +417,10 @@ func StandardDeploy(config DeploymentConfig) error {
 		if len(config.DockerPorts) > 0 {
 			containerConfig.Ports = config.DockerPorts
 			fmt.Println("-----> Using ports from gokku.yml")
+		} else if autoPort, err := applyAutoPort(&containerConfig, config.AppName, containerConfig.Labels.Process, containerPort); err != nil {
+			return err
+		} else if autoPort {
+			fmt.Printf("-----> Using auto-assigned port %s\n", containerConfig.Ports[0])
 		} else if containerPort > 0 {
 			// Only auto-map if containerPort is set and ports are not explicitly empty
 			containerConfig.Ports = []string{fmt.Sprintf("%d:%d", containerPort, containerPort)}
@@ -498,6 +546,9 @@ func startGreenContainer(config DeploymentConfig, containerPort int) error {
 		RestartPolicy: "unless-stopped",
 		WorkingDir:    "/app",
 		Volumes:       []string{fmt.Sprintf("%s:/app", config.ReleaseDir)},
+		Env:           config.Env,
+		Labels:        config.containerLabels(RoleGreen),
+		Logging:       config.Logging,
 	}
 
 	// Add custom volumes from gokku.yml
@@ -510,7 +561,9 @@ func startGreenContainer(config DeploymentConfig, containerPort int) error {
 	if config.NetworkMode != "host" {
 		if len(config.DockerPorts) > 0 {
 			containerConfig.Ports = config.DockerPorts
-		} else {
+		} else if autoPort, err := applyAutoPort(&containerConfig, config.AppName, containerConfig.Labels.Process, containerPort); err != nil {
+			return err
+		} else if !autoPort {
 			containerConfig.Ports = []string{fmt.Sprintf("%d:%d", containerPort, containerPort)}
 		}
 	}
@@ -635,17 +688,25 @@ func RecreateActiveContainer(appName, envFile, appDir string) error {
 	image := strings.TrimSpace(string(output))
 	fmt.Printf("       Using image: %s\n", image)
 
-	// Get Docker configuration from gokku.yml (simplified for now)
-	networkMode := "bridge" // TODO: Get from gokku.yml
-
-	if appConfig.Network != nil && appConfig.Network.Mode != "" {
-		networkMode = appConfig.Network.Mode
-	}
+	networkMode := AppNetworkMode(appName, appConfig)
 
 	containerPort := GetContainerPort(envFile, 0)
 
 	fmt.Printf("       Network mode: %s\n", networkMode)
 
+	// Keep the release labels of the container being replaced
+	labels, err := InspectLabels(activeContainer)
+
+	if err != nil || labels.App == "" {
+		labels = NewReleaseLabels(appName, appConfig, appDir)
+	}
+
+	if activeContainer == appName {
+		labels = labels.WithRole(RoleActive)
+	} else {
+		labels = labels.WithRole(RoleGreen)
+	}
+
 	// Stop and remove old container
 	fmt.Println("       Stopping old container...")
 
@@ -660,6 +721,9 @@ func RecreateActiveContainer(appName, envFile, appDir string) error {
 		RestartPolicy: "always",
 		WorkingDir:    "/app",
 		Volumes:       []string{fmt.Sprintf("%s:/app", appDir)},
+		Env:           AppBaseEnv(appConfig),
+		Labels:        labels,
+		Logging:       appConfig.Logging,
 	}
 
 	// Add custom volumes from gokku.yml
@@ -673,6 +737,10 @@ func RecreateActiveContainer(appName, envFile, appDir string) error {
 		if len(appConfig.Ports) > 0 {
 			containerConfig.Ports = appConfig.Ports
 			fmt.Println("       Using ports from gokku.yml")
+		} else if autoPort, err := applyAutoPort(&containerConfig, appName, containerConfig.Labels.Process, containerPort); err != nil {
+			return err
+		} else if autoPort {
+			fmt.Printf("       Using auto-assigned port %s\n", containerConfig.Ports[0])
 		} else {
 			containerConfig.Ports = []string{fmt.Sprintf("%d:%d", containerPort, containerPort)}
 		}
@@ -0,0 +1,360 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// envReferencePattern matches ${KEY}, ${service:<name>:<KEY>} and ${secret:<NAME>}
+var envReferencePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// EnvResolver resolves variable references in environment values
+//
+// Supported references:
+//
+//	${OTHER_KEY}                     value of another variable in the same set
+//	${service:<service>:<KEY>}       config or env var exposed by a service
+//	${secret:<NAME>}                 content of /opt/gokku/secrets/<NAME>
+//
+// A literal "${" can be written as "$${". A ${NAME} that is not a variable
+// of the set is kept as written, so values holding shell snippets still
+// work, and only triggers a warning.
+type EnvResolver struct {
+	servicesDir string
+	pluginsDir  string
+	secretsDir  string
+
+	// network is the network of the container the env is for
+	network string
+
+	// warn reports references that are kept as written
+	warn func(format string, args ...any)
+
+	// servicePorts returns the port mappings of a service's containers
+	servicePorts func(serviceName string) string
+}
+
+// serviceRef is the subset of a service config.json needed for resolution
+type serviceRef struct {
+	Name     string            `json:"name"`
+	Plugin   string            `json:"plugin"`
+	Config   map[string]string `json:"config"`
+	Networks []string          `json:"networks"`
+
+	env map[string]string
+}
+
+// NewEnvResolver creates a new resolver rooted at baseDir
+func NewEnvResolver(baseDir string) *EnvResolver {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &EnvResolver{
+		servicesDir: filepath.Join(baseDir, "services"),
+		pluginsDir:  filepath.Join(baseDir, "plugins"),
+		secretsDir:  filepath.Join(baseDir, "secrets"),
+		warn: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+		},
+		servicePorts: ServiceContainerPorts,
+	}
+}
+
+// WithNetwork returns a resolver for a container on network. Services on
+// the same network resolve to their DNS alias and container port.
+func (r *EnvResolver) WithNetwork(network string) *EnvResolver {
+	resolver := *r
+	resolver.network = network
+
+	return &resolver
+}
+
+// Resolve returns a copy of vars with every reference replaced by its value
+func (r *EnvResolver) Resolve(vars map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(vars))
+	services := make(map[string]*serviceRef)
+
+	for key := range vars {
+		value, err := r.resolveKey(key, vars, resolved, services, []string{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		resolved[key] = value
+	}
+
+	return resolved, nil
+}
+
+// resolveKey resolves a single variable, tracking the chain to detect cycles
+func (r *EnvResolver) resolveKey(key string, vars, resolved map[string]string, services map[string]*serviceRef, chain []string) (string, error) {
+	if value, ok := resolved[key]; ok {
+		return value, nil
+	}
+
+	for _, seen := range chain {
+		if seen == key {
+			return "", fmt.Errorf("circular reference: %s -> %s", strings.Join(chain, " -> "), key)
+		}
+	}
+
+	raw, ok := vars[key]
+
+	if !ok {
+		return "", fmt.Errorf("undefined variable '%s'", key)
+	}
+
+	chain = append(chain, key)
+
+	// Protect escaped references before expanding
+	const escaped = "\x00GOKKU_ESCAPED\x00"
+	raw = strings.ReplaceAll(raw, "$${", escaped)
+
+	var resolveErr error
+
+	value := envReferencePattern.ReplaceAllStringFunc(raw, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+
+		ref := match[2 : len(match)-1]
+		var result string
+
+		switch {
+		case strings.HasPrefix(ref, "service:"):
+			result, resolveErr = r.resolveService(strings.TrimPrefix(ref, "service:"), services)
+		case strings.HasPrefix(ref, "secret:"):
+			result, resolveErr = r.resolveSecret(strings.TrimPrefix(ref, "secret:"))
+		default:
+			if _, ok := vars[ref]; !ok {
+				r.warn("%s references undefined variable '%s', keeping it as written", key, ref)
+				return match
+			}
+
+			result, resolveErr = r.resolveKey(ref, vars, resolved, services, chain)
+		}
+
+		return result
+	})
+
+	if resolveErr != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", key, resolveErr)
+	}
+
+	value = strings.ReplaceAll(value, escaped, "${")
+	resolved[key] = value
+
+	return value, nil
+}
+
+// resolveService resolves "<service>:<KEY>" against the service config
+func (r *EnvResolver) resolveService(ref string, cache map[string]*serviceRef) (string, error) {
+	parts := strings.SplitN(ref, ":", 2)
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid service reference '%s', expected service:<name>:<KEY>", ref)
+	}
+
+	serviceName, key := parts[0], parts[1]
+
+	service, ok := cache[serviceName]
+
+	if !ok {
+		configPath := filepath.Join(r.servicesDir, serviceName, "config.json")
+		data, err := os.ReadFile(configPath)
+
+		if err != nil {
+			return "", fmt.Errorf("service '%s' not found", serviceName)
+		}
+
+		service = &serviceRef{}
+
+		if err := json.Unmarshal(data, service); err != nil {
+			return "", fmt.Errorf("failed to parse config for service '%s': %v", serviceName, err)
+		}
+
+		if r.network != "" && slices.Contains(service.Networks, r.network) {
+			ports := ""
+
+			if service.Config[ServiceContainerPortKey] == "" {
+				ports = r.servicePorts(serviceName)
+			}
+
+			service.Config = NetworkServiceConfig(serviceName, service.Config, ports)
+		}
+
+		cache[serviceName] = service
+	}
+
+	if value, ok := service.Config[key]; ok {
+		return value, nil
+	}
+
+	if value, ok := service.Config[strings.ToLower(key)]; ok {
+		return value, nil
+	}
+
+	if service.env == nil {
+		env, err := ServiceEnv(r.pluginsDir, serviceName, service.Plugin, service.Config)
+
+		if err != nil {
+			return "", err
+		}
+
+		service.env = env
+	}
+
+	if value, ok := service.env[key]; ok {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("service '%s' does not expose '%s'", serviceName, key)
+}
+
+// resolveSecret reads a secret value from the secrets directory
+func (r *EnvResolver) resolveSecret(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid secret name '%s'", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.secretsDir, name))
+
+	if err != nil {
+		return "", fmt.Errorf("secret '%s' not found", name)
+	}
+
+	value := strings.TrimRight(string(data), "\r\n")
+
+	// Containers get their env from an env file, one KEY=VALUE per line
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("secret '%s' spans several lines, which an env file cannot hold; store it on one line, for example base64-encoded", name)
+	}
+
+	return value, nil
+}
+
+// Sources reported for effective env vars
+const (
+	EnvSourceApp    = "gokku.yml"
+	EnvSourceConfig = "config:set"
+)
+
+// EffectiveEnvVar is a variable of the merged app environment and where it came from
+type EffectiveEnvVar struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// MergeAppEnv merges the app environment layers by precedence, lowest first:
+// gokku.yml env, the default_env_vars of the app's environment, then
+// server-side config:set values
+func MergeAppEnv(app *App, configVars map[string]string) []EffectiveEnvVar {
+	return mergeAppEnv(app, environmentName(configVars, app), configVars)
+}
+
+// mergeAppEnv is MergeAppEnv for the named environment. Only that
+// environment's defaults apply, the one the gokku.env label names.
+func mergeAppEnv(app *App, environment string, configVars map[string]string) []EffectiveEnvVar {
+	merged := make(map[string]EffectiveEnvVar)
+
+	if app != nil {
+		for key, value := range app.Env {
+			merged[key] = EffectiveEnvVar{Key: key, Value: value, Source: EnvSourceApp}
+		}
+
+		for _, env := range app.Environments {
+			if env.Name != environment {
+				continue
+			}
+
+			source := fmt.Sprintf("%s (environments.%s)", EnvSourceApp, env.Name)
+
+			for key, value := range env.DefaultEnvVars {
+				merged[key] = EffectiveEnvVar{Key: key, Value: value, Source: source}
+			}
+		}
+	}
+
+	for key, value := range configVars {
+		merged[key] = EffectiveEnvVar{Key: key, Value: value, Source: EnvSourceConfig}
+	}
+
+	result := make([]EffectiveEnvVar, 0, len(merged))
+
+	for _, envVar := range merged {
+		result = append(result, envVar)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+// AppBaseEnv returns the env defined for the app in gokku.yml, before config:set values
+func AppBaseEnv(app *App) map[string]string {
+	base := make(map[string]string)
+	environment := DefaultEnvironment
+
+	if app != nil {
+		environment = appEnvironment(app.Name, app)
+	}
+
+	for _, envVar := range mergeAppEnv(app, environment, nil) {
+		base[envVar.Key] = envVar.Value
+	}
+
+	return base
+}
+
+// WriteResolvedEnvFile merges baseEnv with envFile, resolves references and writes the
+// result to a temporary file for a container on network. The caller must remove the
+// returned file once consumed.
+func WriteResolvedEnvFile(envFile string, baseEnv map[string]string, network string) (string, error) {
+	vars := make(map[string]string, len(baseEnv))
+
+	for key, value := range baseEnv {
+		vars[key] = value
+	}
+
+	dir := ""
+
+	if envFile != "" {
+		dir = filepath.Dir(envFile)
+
+		for key, value := range LoadEnvFile(envFile) {
+			vars[key] = value
+		}
+	}
+
+	resolved, err := NewEnvResolver("").WithNetwork(network).Resolve(vars)
+
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".env.resolved-*")
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create resolved env file: %v", err)
+	}
+
+	tmp.Close()
+
+	if err := SaveEnvFile(tmp.Name(), resolved); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write resolved env file: %v", err)
+	}
+
+	return tmp.Name(), nil
+}
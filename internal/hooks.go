@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plugin hook events
+const (
+	HookPreBuild     = "pre-build"
+	HookPostBuild    = "post-build"
+	HookPreRelease   = "pre-release"
+	HookPreDeploy    = "pre-deploy"
+	HookPostDeploy   = "post-deploy"
+	HookAppCreate    = "app-create"
+	HookAppDestroy   = "app-destroy"
+	HookConfigChange = "config-change"
+	HookServiceLink  = "service-link"
+)
+
+// Hook failure policies
+const (
+	HookOnFailureIgnore = "ignore"
+	HookOnFailureBlock  = "block"
+)
+
+// DefaultHookTimeout bounds a hook without a timeout in its plugin manifest
+const DefaultHookTimeout = time.Minute
+
+// HookEvents lists every event plugins can hook into, in deploy order
+var HookEvents = []string{
+	HookPreBuild,
+	HookPostBuild,
+	HookPreRelease,
+	HookPreDeploy,
+	HookPostDeploy,
+	HookAppCreate,
+	HookAppDestroy,
+	HookConfigChange,
+	HookServiceLink,
+}
+
+// HookPolicy is how a plugin's manifest configures one of its hooks
+type HookPolicy struct {
+	Timeout   string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	OnFailure string `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+}
+
+// HookPayload is written as JSON to the stdin of every hook
+type HookPayload struct {
+	Event      string   `json:"event"`
+	App        string   `json:"app,omitempty"`
+	Release    string   `json:"release,omitempty"`
+	ReleaseDir string   `json:"release_dir,omitempty"`
+	Service    string   `json:"service,omitempty"`
+	Action     string   `json:"action,omitempty"`
+	Keys       []string `json:"keys,omitempty"`
+	Timestamp  string   `json:"timestamp"`
+}
+
+// Hook is an executable hooks/<event> script of an installed plugin
+type Hook struct {
+	Plugin  string
+	Event   string
+	Path    string
+	Timeout time.Duration
+	Block   bool
+}
+
+// HookError reports a blocking hook that failed
+type HookError struct {
+	Hook Hook
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook of plugin '%s' failed: %v", e.Hook.Event, e.Hook.Plugin, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// HookDispatcher runs the hooks installed plugins ship for an event
+type HookDispatcher struct {
+	baseDir    string
+	pluginsDir string
+	out        io.Writer
+
+	run func(hook Hook, env []string, stdin []byte) error
+	now func() time.Time
+}
+
+// NewHookDispatcher creates a new HookDispatcher streaming hook output to out
+func NewHookDispatcher(baseDir string, out io.Writer) *HookDispatcher {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	d := &HookDispatcher{
+		baseDir:    baseDir,
+		pluginsDir: filepath.Join(baseDir, "plugins"),
+		out:        out,
+		now:        time.Now,
+	}
+
+	d.run = d.execute
+
+	return d
+}
+
+// RunHooks dispatches an event to the plugin hooks under /opt/gokku. Only a
+// failing blocking hook returns an error; other failures print a warning.
+func RunHooks(payload HookPayload) error {
+	return NewHookDispatcher("", os.Stdout).Dispatch(payload)
+}
+
+// Hooks returns the hooks installed for event, ordered by plugin name. A
+// plugin whose manifest cannot be loaded is skipped with a warning, so it
+// does not keep the hooks of the other plugins from running.
+func (d *HookDispatcher) Hooks(event string) ([]Hook, error) {
+	entries, err := os.ReadDir(d.pluginsDir)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %v", err)
+	}
+
+	var hooks []Hook
+
+	for _, entry := range entries {
+		// Hidden directories hold staged and previous plugin versions
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// Stat follows the links of plugins installed from a local directory
+		pluginDir := filepath.Join(d.pluginsDir, entry.Name())
+
+		if info, err := os.Stat(pluginDir); err != nil || !info.IsDir() {
+			continue
+		}
+		path := filepath.Join(pluginDir, "hooks", event)
+
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+
+		hook := Hook{Plugin: entry.Name(), Event: event, Path: path, Timeout: DefaultHookTimeout}
+
+		manifest, err := LoadPluginManifest(pluginDir)
+
+		if err != nil {
+			fmt.Fprintf(d.out, "Warning: skipping %s hook of plugin '%s': %v\n", event, entry.Name(), err)
+			continue
+		}
+
+		if manifest != nil {
+			if policy, ok := manifest.Hooks[event]; ok {
+				if timeout, err := time.ParseDuration(policy.Timeout); err == nil && timeout > 0 {
+					hook.Timeout = timeout
+				}
+
+				hook.Block = policy.OnFailure == HookOnFailureBlock
+			}
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].Plugin < hooks[j].Plugin
+	})
+
+	return hooks, nil
+}
+
+// Dispatch runs every hook for the payload event in turn. A failing
+// blocking hook stops the dispatch and is returned as a *HookError.
+func (d *HookDispatcher) Dispatch(payload HookPayload) error {
+	hooks, err := d.Hooks(payload.Event)
+
+	if err != nil {
+		fmt.Fprintf(d.out, "Warning: %v\n", err)
+		return nil
+	}
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	if payload.Timestamp == "" {
+		payload.Timestamp = d.now().UTC().Format(time.RFC3339)
+	}
+
+	stdin, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to encode hook payload: %v", err)
+	}
+
+	for _, hook := range hooks {
+		fmt.Fprintf(d.out, "-----> Running %s hook of %s\n", hook.Event, hook.Plugin)
+
+		if err := d.run(hook, d.hookEnv(hook, payload), stdin); err != nil {
+			if hook.Block {
+				return &HookError{Hook: hook, Err: err}
+			}
+
+			fmt.Fprintf(d.out, "Warning: %s hook of plugin '%s' failed: %v\n", hook.Event, hook.Plugin, err)
+		}
+	}
+
+	return nil
+}
+
+// hookEnv returns the environment documented for hooks
+func (d *HookDispatcher) hookEnv(hook Hook, payload HookPayload) []string {
+	env := append(os.Environ(),
+		"GOKKU_HOOK="+hook.Event,
+		"GOKKU_PLUGIN="+hook.Plugin,
+		"GOKKU_PLUGIN_DIR="+filepath.Dir(filepath.Dir(hook.Path)),
+		"GOKKU_BASE_DIR="+d.baseDir,
+		"GOKKU_APP="+payload.App,
+		"GOKKU_RELEASE="+payload.Release,
+		"GOKKU_RELEASE_DIR="+payload.ReleaseDir,
+		"GOKKU_SERVICE="+payload.Service,
+		"GOKKU_ACTION="+payload.Action,
+	)
+
+	return env
+}
+
+// execute runs a hook with bash from its plugin directory, killing it when
+// the timeout expires
+func (d *HookDispatcher) execute(hook Hook, env []string, stdin []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", hook.Path)
+	cmd.Dir = filepath.Dir(filepath.Dir(hook.Path))
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = d.out
+	cmd.Stderr = d.out
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+
+	return err
+}
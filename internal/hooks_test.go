@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type HooksTestSuite struct {
+	suite.Suite
+	baseDir string
+	out     *bytes.Buffer
+}
+
+func TestHooksTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(HooksTestSuite))
+}
+
+func (s *HooksTestSuite) SetupTest() {
+	s.baseDir = s.T().TempDir()
+	s.out = &bytes.Buffer{}
+}
+
+func (s *HooksTestSuite) writeHook(plugin, event, script string) {
+	dir := filepath.Join(s.baseDir, "plugins", plugin, "hooks")
+	s.Require().NoError(os.MkdirAll(dir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, event), []byte("#!/bin/bash\n"+script), 0755))
+}
+
+func (s *HooksTestSuite) writeManifest(plugin, manifest string) {
+	path := filepath.Join(s.baseDir, "plugins", plugin, PluginManifestFile)
+	s.Require().NoError(os.WriteFile(path, []byte(manifest), 0644))
+}
+
+func (s *HooksTestSuite) TestHooks_OrderedByPluginWithPolicy() {
+	s.writeHook("zeta", HookPreDeploy, "exit 0\n")
+	s.writeHook("alpha", HookPreDeploy, "exit 0\n")
+	s.writeHook(".previous", HookPreDeploy, "exit 0\n")
+	s.writeHook("alpha", HookPostDeploy, "exit 0\n")
+	s.writeManifest("zeta", "hooks:\n  pre-deploy:\n    timeout: 5s\n    on_failure: block\n")
+
+	hooks, err := NewHookDispatcher(s.baseDir, s.out).Hooks(HookPreDeploy)
+
+	Expect(err).To(BeNil())
+	Expect(hooks).To(HaveLen(2))
+	Expect(hooks[0].Plugin).To(Equal("alpha"))
+	Expect(hooks[0].Timeout).To(Equal(DefaultHookTimeout))
+	Expect(hooks[0].Block).To(BeFalse())
+	Expect(hooks[1].Plugin).To(Equal("zeta"))
+	Expect(hooks[1].Timeout).To(Equal(5 * time.Second))
+	Expect(hooks[1].Block).To(BeTrue())
+}
+
+func (s *HooksTestSuite) TestDispatch_SkipsPluginWithBrokenManifest() {
+	marker := filepath.Join(s.baseDir, "ran")
+	s.writeHook("alpha", HookPreDeploy, "exit 0\n")
+	s.writeManifest("alpha", "hooks: [\n")
+	s.writeHook("zeta", HookPreDeploy, "touch "+marker+"\nexit 1\n")
+	s.writeManifest("zeta", "hooks:\n  pre-deploy:\n    on_failure: block\n")
+
+	err := NewHookDispatcher(s.baseDir, s.out).Dispatch(HookPayload{Event: HookPreDeploy, App: "api"})
+
+	Expect(err).To(MatchError(ContainSubstring("pre-deploy hook of plugin 'zeta' failed")))
+	Expect(marker).To(BeAnExistingFile())
+	Expect(s.out.String()).To(ContainSubstring("Warning: skipping pre-deploy hook of plugin 'alpha'"))
+	Expect(s.out.String()).ToNot(ContainSubstring("Running pre-deploy hook of alpha"))
+}
+
+func (s *HooksTestSuite) TestDispatch_PassesPayloadAndEnv() {
+	s.writeHook("audit", HookServiceLink, "cat > payload.json\necho \"$GOKKU_HOOK $GOKKU_APP $GOKKU_SERVICE $GOKKU_ACTION\" > env.txt\n")
+
+	err := NewHookDispatcher(s.baseDir, s.out).Dispatch(HookPayload{
+		Event:   HookServiceLink,
+		App:     "api",
+		Service: "postgres-api",
+		Action:  "link",
+	})
+
+	Expect(err).To(BeNil())
+
+	pluginDir := filepath.Join(s.baseDir, "plugins", "audit")
+
+	env, err := os.ReadFile(filepath.Join(pluginDir, "env.txt"))
+	Expect(err).To(BeNil())
+	Expect(string(env)).To(Equal("service-link api postgres-api link\n"))
+
+	data, err := os.ReadFile(filepath.Join(pluginDir, "payload.json"))
+	Expect(err).To(BeNil())
+
+	var payload HookPayload
+	Expect(json.Unmarshal(data, &payload)).To(Succeed())
+	Expect(payload.App).To(Equal("api"))
+	Expect(payload.Service).To(Equal("postgres-api"))
+	Expect(payload.Timestamp).NotTo(BeEmpty())
+}
+
+func (s *HooksTestSuite) TestDispatch_IgnoresFailureByDefault() {
+	s.writeHook("alpha", HookPreBuild, "exit 3\n")
+	s.writeHook("beta", HookPreBuild, "echo beta ran\n")
+
+	err := NewHookDispatcher(s.baseDir, s.out).Dispatch(HookPayload{Event: HookPreBuild, App: "api"})
+
+	Expect(err).To(BeNil())
+	Expect(s.out.String()).To(ContainSubstring("Warning: pre-build hook of plugin 'alpha' failed: exit status 3"))
+	Expect(s.out.String()).To(ContainSubstring("beta ran"))
+}
+
+func (s *HooksTestSuite) TestDispatch_BlockingFailureStops() {
+	s.writeHook("alpha", HookPreBuild, "exit 3\n")
+	s.writeManifest("alpha", "hooks:\n  pre-build:\n    on_failure: block\n")
+	s.writeHook("beta", HookPreBuild, "echo beta ran\n")
+
+	err := NewHookDispatcher(s.baseDir, s.out).Dispatch(HookPayload{Event: HookPreBuild, App: "api"})
+
+	var hookErr *HookError
+	Expect(errors.As(err, &hookErr)).To(BeTrue())
+	Expect(hookErr.Hook.Plugin).To(Equal("alpha"))
+	Expect(err).To(MatchError("pre-build hook of plugin 'alpha' failed: exit status 3"))
+	Expect(s.out.String()).NotTo(ContainSubstring("beta ran"))
+}
+
+func (s *HooksTestSuite) TestDispatch_KillsHookOnTimeout() {
+	s.writeHook("slow", HookPostDeploy, "sleep 10\n")
+	s.writeManifest("slow", "hooks:\n  post-deploy:\n    timeout: 200ms\n    on_failure: block\n")
+
+	started := time.Now()
+	err := NewHookDispatcher(s.baseDir, s.out).Dispatch(HookPayload{Event: HookPostDeploy, App: "api"})
+
+	Expect(err).To(MatchError(ContainSubstring("timed out after 200ms")))
+	Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+}
+
+func (s *HooksTestSuite) TestManifest_RejectsInvalidHookPolicy() {
+	manifest := &PluginManifest{Hooks: map[string]HookPolicy{"pre-push": {}}}
+	Expect(manifest.Validate()).To(MatchError("hooks.pre-push: unknown hook event"))
+
+	manifest = &PluginManifest{Hooks: map[string]HookPolicy{HookPreBuild: {Timeout: "soon"}}}
+	Expect(manifest.Validate()).To(MatchError("hooks.pre-build: invalid timeout 'soon'"))
+
+	manifest = &PluginManifest{Hooks: map[string]HookPolicy{HookPreBuild: {OnFailure: "retry"}}}
+	Expect(manifest.Validate()).To(MatchError("hooks.pre-build: on_failure must be 'block' or 'ignore'"))
+}
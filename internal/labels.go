@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Labels set on every container and image gokku creates
+const (
+	LabelApp     = "gokku.app"
+	LabelProcess = "gokku.process"
+	LabelRelease = "gokku.release"
+	LabelEnv     = "gokku.env"
+	LabelRole    = "gokku.role"
+	LabelSHA     = "gokku.sha"
+)
+
+// LabelService marks the containers of a service. Plugins set it when they
+// create a service's containers, and gokku finds them by it.
+const LabelService = "gokku.service"
+
+// Values for the gokku.role label. Docker labels are immutable, so a green
+// container that gets promoted keeps gokku.role=green until it is recreated.
+const (
+	RoleActive = "active"
+	RoleGreen  = "green"
+	RoleOneOff = "one-off"
+)
+
+// OneOffProcessType is the gokku.process of containers started by gokku run
+const OneOffProcessType = "run"
+
+const (
+	// RevisionFile holds the git commit a release was built from
+	RevisionFile = "REVISION"
+
+	// DefaultEnvironment is used when an app does not name its environment
+	DefaultEnvironment = "production"
+)
+
+// legacyProcessPattern matches containers named <app>-<process>-<number>
+var legacyProcessPattern = regexp.MustCompile(`^(.+)-([a-z0-9_]+)-(\d+)$`)
+
+// ContainerLabels describes what a container or image belongs to
+type ContainerLabels struct {
+	App     string
+	Process string
+	Release string
+	Env     string
+	Role    string
+	SHA     string
+}
+
+// NewReleaseLabels builds the labels for a release of an app
+func NewReleaseLabels(appName string, app *App, releaseDir string) ContainerLabels {
+	labels := ContainerLabels{
+		App:     appName,
+		Process: DefaultProcessType,
+		Env:     appEnvironment(appName, app),
+	}
+
+	if releaseDir != "" {
+		// Resolve the current symlink to the release it points at
+		if resolved, err := filepath.EvalSymlinks(releaseDir); err == nil {
+			releaseDir = resolved
+		}
+
+		labels.Release = filepath.Base(releaseDir)
+
+		if content, err := os.ReadFile(filepath.Join(releaseDir, RevisionFile)); err == nil {
+			labels.SHA = strings.TrimSpace(string(content))
+		}
+	}
+
+	return labels
+}
+
+// LabelsFromMap reads gokku labels from a Docker label map
+func LabelsFromMap(m map[string]string) ContainerLabels {
+	return ContainerLabels{
+		App:     m[LabelApp],
+		Process: m[LabelProcess],
+		Release: m[LabelRelease],
+		Env:     m[LabelEnv],
+		Role:    m[LabelRole],
+		SHA:     m[LabelSHA],
+	}
+}
+
+// WithRole returns a copy of the labels with the given role
+func (l ContainerLabels) WithRole(role string) ContainerLabels {
+	l.Role = role
+	return l
+}
+
+// List returns the labels as key=value pairs, including the createdby label
+func (l ContainerLabels) List() []string {
+	labels := GetGokkuLabels()
+
+	for _, pair := range [][2]string{
+		{LabelApp, l.App},
+		{LabelProcess, l.Process},
+		{LabelRelease, l.Release},
+		{LabelEnv, l.Env},
+		{LabelRole, l.Role},
+		{LabelSHA, l.SHA},
+	} {
+		if pair[1] != "" {
+			labels = append(labels, fmt.Sprintf("%s=%s", pair[0], pair[1]))
+		}
+	}
+
+	return labels
+}
+
+// ImageLabels returns the labels that apply to an image. Process and role
+// only make sense for containers.
+func (l ContainerLabels) ImageLabels() []string {
+	l.Process = ""
+	l.Role = ""
+	return l.List()
+}
+
+// ParseLabels parses the comma separated Labels field of docker ps
+func ParseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+
+		if found && key != "" {
+			labels[key] = value
+		}
+	}
+
+	return labels
+}
+
+// Label returns the value of a container label
+func (c ContainerInfo) Label(key string) string {
+	return ParseLabels(c.Labels)[key]
+}
+
+// HasName reports whether name is exactly one of the container's names
+func (c ContainerInfo) HasName(name string) bool {
+	for _, n := range strings.Split(c.Names, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(n), "/") == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BelongsTo reports whether the container belongs to the app. Containers
+// created before gokku.app existed fall back to exact name matching.
+func (c ContainerInfo) BelongsTo(appName string) bool {
+	if app := c.Label(LabelApp); app != "" {
+		return app == appName
+	}
+
+	if c.HasName(appName) || c.HasName(appName+"-green") {
+		return true
+	}
+
+	matches := legacyProcessPattern.FindStringSubmatch(c.Names)
+
+	return matches != nil && matches[1] == appName
+}
+
+// ProcessType returns the process the container runs
+func (c ContainerInfo) ProcessType() string {
+	if process := c.Label(LabelProcess); process != "" {
+		return process
+	}
+
+	if matches := legacyProcessPattern.FindStringSubmatch(c.Names); matches != nil {
+		return matches[2]
+	}
+
+	return DefaultProcessType
+}
+
+// InspectLabels returns the gokku labels of an existing container
+func InspectLabels(name string) (ContainerLabels, error) {
+	cmd := exec.Command("docker", "inspect", name, "--format", "{{json .Config.Labels}}")
+	output, err := cmd.Output()
+
+	if err != nil {
+		return ContainerLabels{}, fmt.Errorf("failed to inspect labels of %s: %v", name, err)
+	}
+
+	var labels map[string]string
+
+	if err := json.Unmarshal(output, &labels); err != nil {
+		return ContainerLabels{}, fmt.Errorf("failed to parse labels of %s: %v", name, err)
+	}
+
+	return LabelsFromMap(labels), nil
+}
+
+// appEnvironment resolves the environment name: GOKKU_ENV from the app's
+// env, then the first configured environment, then production
+func appEnvironment(appName string, app *App) string {
+	envFile := filepath.Join("/opt/gokku/apps", appName, "shared", ".env")
+
+	return environmentName(LoadEnvFile(envFile), app)
+}
+
+// environmentName is appEnvironment over the app's config:set values
+func environmentName(configVars map[string]string, app *App) string {
+	if env := configVars["GOKKU_ENV"]; env != "" {
+		return env
+	}
+
+	if app != nil {
+		if env := app.Env["GOKKU_ENV"]; env != "" {
+			return env
+		}
+
+		if len(app.Environments) > 0 && app.Environments[0].Name != "" {
+			return app.Environments[0].Name
+		}
+	}
+
+	return DefaultEnvironment
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type LabelsTestSuite struct {
+	suite.Suite
+}
+
+func TestLabelsTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(LabelsTestSuite))
+}
+
+func (s *LabelsTestSuite) TestList_SkipsEmptyLabels() {
+	labels := ContainerLabels{App: "api", Process: "web", Role: RoleActive}
+
+	Expect(labels.List()).To(Equal([]string{
+		"createdby=gokku",
+		"gokku.app=api",
+		"gokku.process=web",
+		"gokku.role=active",
+	}))
+}
+
+func (s *LabelsTestSuite) TestImageLabels_OmitsContainerOnlyLabels() {
+	labels := ContainerLabels{App: "api", Process: "web", Release: "20250101-120000", Role: RoleGreen, SHA: "abc123"}
+
+	Expect(labels.ImageLabels()).To(Equal([]string{
+		"createdby=gokku",
+		"gokku.app=api",
+		"gokku.release=20250101-120000",
+		"gokku.sha=abc123",
+	}))
+}
+
+func (s *LabelsTestSuite) TestNewReleaseLabels_ReadsRevisionThroughSymlink() {
+	tempDir := s.T().TempDir()
+	releaseDir := filepath.Join(tempDir, "releases", "20250101-120000")
+	current := filepath.Join(tempDir, "current")
+
+	s.Require().NoError(os.MkdirAll(releaseDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(releaseDir, RevisionFile), []byte("abc123\n"), 0644))
+	s.Require().NoError(os.Symlink(releaseDir, current))
+
+	app := &App{Environments: []Environment{{Name: "staging"}}}
+	labels := NewReleaseLabels("labels-test-app", app, current)
+
+	Expect(labels.App).To(Equal("labels-test-app"))
+	Expect(labels.Process).To(Equal(DefaultProcessType))
+	Expect(labels.Release).To(Equal("20250101-120000"))
+	Expect(labels.Env).To(Equal("staging"))
+	Expect(labels.SHA).To(Equal("abc123"))
+}
+
+func (s *LabelsTestSuite) TestNewReleaseLabels_DefaultsEnvironment() {
+	labels := NewReleaseLabels("labels-test-app", &App{}, "")
+
+	Expect(labels.Env).To(Equal(DefaultEnvironment))
+	Expect(labels.Release).To(BeEmpty())
+}
+
+func (s *LabelsTestSuite) TestParseLabels() {
+	labels := ParseLabels("createdby=gokku,gokku.app=api,empty=")
+
+	Expect(labels).To(Equal(map[string]string{
+		"createdby": "gokku",
+		"gokku.app": "api",
+		"empty":     "",
+	}))
+}
+
+func (s *LabelsTestSuite) TestHasName_MatchesExactly() {
+	c := ContainerInfo{Names: "api-worker"}
+
+	Expect(c.HasName("api-worker")).To(BeTrue())
+	Expect(c.HasName("api")).To(BeFalse())
+}
+
+func (s *LabelsTestSuite) TestBelongsTo_UsesAppLabel() {
+	api := ContainerInfo{Names: "api-worker-1", Labels: "gokku.app=api-worker"}
+
+	Expect(api.BelongsTo("api-worker")).To(BeTrue())
+	Expect(api.BelongsTo("api")).To(BeFalse())
+}
+
+func (s *LabelsTestSuite) TestBelongsTo_WithUnlabelledContainers() {
+	Expect(ContainerInfo{Names: "api"}.BelongsTo("api")).To(BeTrue())
+	Expect(ContainerInfo{Names: "api-green"}.BelongsTo("api")).To(BeTrue())
+	Expect(ContainerInfo{Names: "api-web-2"}.BelongsTo("api")).To(BeTrue())
+	Expect(ContainerInfo{Names: "legacy-api"}.BelongsTo("api")).To(BeFalse())
+}
+
+func (s *LabelsTestSuite) TestProcessType() {
+	Expect(ContainerInfo{Names: "api", Labels: "gokku.process=worker"}.ProcessType()).To(Equal("worker"))
+	Expect(ContainerInfo{Names: "api-clock-1"}.ProcessType()).To(Equal("clock"))
+	Expect(ContainerInfo{Names: "api"}.ProcessType()).To(Equal(DefaultProcessType))
+}
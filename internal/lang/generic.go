@@ -50,14 +50,14 @@ func (l *Generic) Build(appName string, app *App, releaseDir string) error {
 		// Use custom Dockerfile path
 		cmd = exec.Command("docker", "build", "-f", dockerfilePath, "-t", imageTag, releaseDir)
 		// Add Gokku labels to image
-		for _, label := range GetGokkuLabels() {
+		for _, label := range NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 			cmd.Args = append(cmd.Args, "--label", label)
 		}
 	} else {
 		// Use default Dockerfile in release directory
 		cmd = exec.Command("docker", "build", "-t", imageTag, releaseDir)
 		// Add Gokku labels to image
-		for _, label := range GetGokkuLabels() {
+		for _, label := range NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 			cmd.Args = append(cmd.Args, "--label", label)
 		}
 	}
@@ -79,11 +79,7 @@ func (l *Generic) Deploy(appName string, app *App, releaseDir string) error {
 	// Get environment file
 	envFile := filepath.Join("/opt/gokku/apps", appName, "shared", ".env")
 
-	networkMode := "bridge"
-
-	if app.Network != nil && app.Network.Mode != "" {
-		networkMode = app.Network.Mode
-	}
+	networkMode := AppNetworkMode(appName, app)
 
 	// Deploy using Docker client
 	volumes := []string{}
@@ -101,6 +97,9 @@ func (l *Generic) Deploy(appName string, app *App, releaseDir string) error {
 		NetworkMode: networkMode,
 		DockerPorts: app.Ports,
 		Volumes:     volumes,
+		Env:         AppBaseEnv(app),
+		Labels:      NewReleaseLabels(appName, app, releaseDir),
+		Logging:     app.Logging,
 	})
 }
 
@@ -69,7 +69,7 @@ func (l *Golang) Build(appName string, app *App, releaseDir string) error {
 		}
 
 		// Add Gokku labels to image
-		for _, label := range GetGokkuLabels() {
+		for _, label := range NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 			cmd.Args = append(cmd.Args, "--label", label)
 		}
 
@@ -78,7 +78,7 @@ func (l *Golang) Build(appName string, app *App, releaseDir string) error {
 		// Use default Dockerfile in release directory
 		cmd = exec.Command("docker", "build", "--progress=plain", "-t", imageTag, releaseDir)
 		// Add Gokku labels to image
-		for _, label := range GetGokkuLabels() {
+		for _, label := range NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 			cmd.Args = append(cmd.Args, "--label", label)
 		}
 	}
@@ -103,11 +103,7 @@ func (l *Golang) Deploy(appName string, app *App, releaseDir string) error {
 	// Get environment file
 	envFile := filepath.Join("/opt/gokku/apps", appName, "shared", ".env")
 
-	networkMode := "bridge"
-
-	if app.Network != nil && app.Network.Mode != "" {
-		networkMode = app.Network.Mode
-	}
+	networkMode := AppNetworkMode(appName, app)
 
 	// Create deployment config
 	volumes := []string{}
@@ -125,6 +121,9 @@ func (l *Golang) Deploy(appName string, app *App, releaseDir string) error {
 		NetworkMode: networkMode,
 		DockerPorts: app.Ports,
 		Volumes:     volumes,
+		Env:         AppBaseEnv(app),
+		Labels:      NewReleaseLabels(appName, app, releaseDir),
+		Logging:     app.Logging,
 	})
 }
 
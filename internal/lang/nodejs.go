@@ -57,14 +57,14 @@ func (l *Nodejs) Build(appName string, app *App, releaseDir string) error {
 		fmt.Printf("-----> Using custom Dockerfile: %s\n", dockerfilePath)
 		cmd = exec.Command("docker", "build", "-f", dockerfilePath, "-t", imageTag, releaseDir)
 		// Add Gokku labels to image
-		for _, label := range GetGokkuLabels() {
+		for _, label := range NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 			cmd.Args = append(cmd.Args, "--label", label)
 		}
 	} else {
 		// Use default Dockerfile in release directory
 		cmd = exec.Command("docker", "build", "-t", imageTag, releaseDir)
 		// Add Gokku labels to image
-		for _, label := range GetGokkuLabels() {
+		for _, label := range NewReleaseLabels(appName, app, releaseDir).ImageLabels() {
 			cmd.Args = append(cmd.Args, "--label", label)
 		}
 	}
@@ -86,11 +86,7 @@ func (l *Nodejs) Deploy(appName string, app *App, releaseDir string) error {
 	// Get environment file
 	envFile := filepath.Join("/opt/gokku/apps", appName, "shared", ".env")
 
-	networkMode := "bridge"
-
-	if app.Network != nil && app.Network.Mode != "" {
-		networkMode = app.Network.Mode
-	}
+	networkMode := AppNetworkMode(appName, app)
 
 	// Create deployment config
 	volumes := []string{}
@@ -108,6 +104,9 @@ func (l *Nodejs) Deploy(appName string, app *App, releaseDir string) error {
 		NetworkMode: networkMode,
 		DockerPorts: app.Ports,
 		Volumes:     volumes,
+		Env:         AppBaseEnv(app),
+		Labels:      NewReleaseLabels(appName, app, releaseDir),
+		Logging:     app.Logging,
 	})
 }
 
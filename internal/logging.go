@@ -0,0 +1,466 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLogSinkPath      = "/var/log/gokku"
+	DefaultLogBatchSize     = 100
+	DefaultLogFlushInterval = time.Second
+	DefaultLogPollInterval  = 10 * time.Second
+	logSinkRetries          = 5
+)
+
+// DockerArgs returns the docker run flags that rotate the container's logs
+func (l *LoggingConfig) DockerArgs() []string {
+	if l == nil || (l.MaxSize == "" && l.MaxFile == 0) {
+		return nil
+	}
+
+	args := []string{"--log-driver", "json-file"}
+
+	if l.MaxSize != "" {
+		args = append(args, "--log-opt", "max-size="+l.MaxSize)
+	}
+
+	if l.MaxFile > 0 {
+		args = append(args, "--log-opt", "max-file="+strconv.Itoa(l.MaxFile))
+	}
+
+	return args
+}
+
+// Sinks returns the forwarder sinks of the app, if any
+func (l *LoggingConfig) Sinks() []LogSink {
+	if l == nil {
+		return nil
+	}
+
+	return l.Forward
+}
+
+// FlushDuration returns the sink flush interval, defaulting to one second
+func (s LogSink) FlushDuration() time.Duration {
+	if d, err := time.ParseDuration(s.FlushInterval); err == nil && d > 0 {
+		return d
+	}
+
+	return DefaultLogFlushInterval
+}
+
+// logSinkWorker batches lines for a sink and retries failed batches
+type logSinkWorker struct {
+	name       string
+	sink       logSink
+	lines      chan LogLine
+	done       chan struct{}
+	batchSize  int
+	interval   time.Duration
+	retryDelay time.Duration
+	out        io.Writer
+
+	mu      sync.RWMutex // held for reading while sending, for writing while stopping
+	stopped bool
+}
+
+func newLogSinkWorker(config LogSink, sink logSink, out io.Writer) *logSinkWorker {
+	batchSize := config.BatchSize
+
+	if batchSize <= 0 {
+		batchSize = DefaultLogBatchSize
+	}
+
+	return &logSinkWorker{
+		name:       config.Type,
+		sink:       sink,
+		lines:      make(chan LogLine, batchSize*10),
+		done:       make(chan struct{}),
+		batchSize:  batchSize,
+		interval:   config.FlushDuration(),
+		retryDelay: time.Second,
+		out:        out,
+	}
+}
+
+func (w *logSinkWorker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var batch []LogLine
+
+	for {
+		select {
+		case line, ok := <-w.lines:
+			if !ok {
+				w.flush(batch)
+				w.sink.Close()
+				return
+			}
+
+			batch = append(batch, line)
+
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+// flush sends a batch, retrying with exponential backoff before dropping it
+func (w *logSinkWorker) flush(batch []LogLine) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+
+	for attempt := 0; attempt < logSinkRetries; attempt++ {
+		if err = w.sink.Send(batch); err == nil {
+			return
+		}
+
+		time.Sleep(w.retryDelay << attempt)
+	}
+
+	fmt.Fprintf(w.out, "Warning: dropped %d lines for %s sink: %v\n", len(batch), w.name, err)
+}
+
+// send queues a line unless the worker was stopped
+func (w *logSinkWorker) send(line LogLine) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.stopped {
+		w.lines <- line
+	}
+}
+
+// stop flushes pending lines and closes the sink
+func (w *logSinkWorker) stop() {
+	w.mu.Lock()
+
+	if !w.stopped {
+		w.stopped = true
+		close(w.lines)
+	}
+
+	w.mu.Unlock()
+	<-w.done
+}
+
+// LogForwarder tails the logs of labelled app containers and ships them to
+// the sinks configured under apps[].logging.forward
+type LogForwarder struct {
+	baseDir  string
+	appName  string
+	interval time.Duration
+	out      io.Writer
+
+	listContainers func() ([]ContainerInfo, error)
+	loadApp        func(appName string) (*App, error)
+	newSink        func(config LogSink) (logSink, error)
+	tail           func(appName string, c ContainerInfo, opts LogOptions, lines chan<- LogLine) error
+
+	mu      sync.Mutex
+	lines   chan LogLine
+	tailing map[string]bool                      // container IDs being tailed
+	cursors map[string]time.Time                 // last line shipped per container name and stream
+	workers map[string]map[string]*logSinkWorker // app -> sink key -> worker
+}
+
+// NewLogForwarder creates a forwarder for all apps, or only appName when set
+func NewLogForwarder(baseDir, appName string, out io.Writer) *LogForwarder {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &LogForwarder{
+		baseDir:  baseDir,
+		appName:  appName,
+		interval: DefaultLogPollInterval,
+		out:      out,
+
+		listContainers: func() ([]ContainerInfo, error) { return ListContainers(false) },
+		loadApp: func(appName string) (*App, error) {
+			return LoadAppConfigFromFile(filepath.Join(baseDir, "apps", appName, "gokku.yml"), appName)
+		},
+		newSink: newLogSink,
+		tail:    readContainerLogs,
+
+		lines:   make(chan LogLine, 1000),
+		tailing: make(map[string]bool),
+		cursors: make(map[string]time.Time),
+		workers: make(map[string]map[string]*logSinkWorker),
+	}
+}
+
+// Run forwards logs until ctx is cancelled. Containers running when it starts
+// only have their new lines shipped; containers started later are shipped
+// from their first line.
+func (f *LogForwarder) Run(ctx context.Context) error {
+	go f.dispatch()
+
+	startedAt := time.Now().UTC()
+
+	if err := f.Sync(startedAt); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.stop()
+			return nil
+		case <-ticker.C:
+			if err := f.Sync(time.Time{}); err != nil {
+				fmt.Fprintf(f.out, "Warning: %v\n", err)
+			}
+		}
+	}
+}
+
+// Sync starts tailing containers that are not tailed yet and updates the
+// sinks of their apps. New containers are read from since, or from their
+// first line when since is zero.
+func (f *LogForwarder) Sync(since time.Time) error {
+	containers, err := f.listContainers()
+
+	if err != nil {
+		return err
+	}
+
+	byApp := make(map[string][]ContainerInfo)
+
+	for _, c := range containers {
+		app := c.Label(LabelApp)
+
+		if app == "" || (f.appName != "" && app != f.appName) {
+			continue
+		}
+
+		byApp[app] = append(byApp[app], c)
+	}
+
+	apps := make([]string, 0, len(byApp))
+
+	for app := range byApp {
+		apps = append(apps, app)
+	}
+
+	sort.Strings(apps)
+
+	for _, app := range apps {
+		config, err := f.loadApp(app)
+
+		if err != nil {
+			continue
+		}
+
+		if !f.setSinks(app, config.Logging.Sinks()) {
+			continue
+		}
+
+		for _, c := range byApp[app] {
+			f.follow(app, c, since)
+		}
+	}
+
+	return nil
+}
+
+// setSinks starts workers for new sinks of the app and stops removed ones.
+// It reports whether the app has any sink.
+func (f *LogForwarder) setSinks(app string, sinks []LogSink) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current := f.workers[app]
+	next := make(map[string]*logSinkWorker)
+
+	for _, config := range sinks {
+		key := fmt.Sprintf("%+v", config)
+
+		if worker, ok := current[key]; ok {
+			next[key] = worker
+			continue
+		}
+
+		sink, err := f.newSink(config)
+
+		if err != nil {
+			fmt.Fprintf(f.out, "Warning: %s: %v\n", app, err)
+			continue
+		}
+
+		worker := newLogSinkWorker(config, sink, f.out)
+		go worker.run()
+
+		next[key] = worker
+		fmt.Fprintf(f.out, "-----> Forwarding %s logs to %s\n", app, describeLogSink(config))
+	}
+
+	for key, worker := range current {
+		if _, ok := next[key]; !ok {
+			go worker.stop()
+		}
+	}
+
+	f.workers[app] = next
+
+	return len(next) > 0
+}
+
+// follow starts tailing a container unless it is already tailed
+func (f *LogForwarder) follow(app string, c ContainerInfo, since time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.tailing[c.ID] {
+		return
+	}
+
+	f.tailing[c.ID] = true
+
+	opts := LogOptions{Tail: "all", Follow: true}
+
+	if cursor, ok := f.resumeCursor(c.Names); ok {
+		opts.Since = cursor.Format(time.RFC3339Nano)
+	} else if !since.IsZero() {
+		opts.Since = since.Format(time.RFC3339Nano)
+
+		for _, stream := range logStreams {
+			f.cursors[logCursorKey(c.Names, stream)] = since
+		}
+	}
+
+	go func() {
+		if err := f.tail(app, c, opts, f.lines); err != nil {
+			fmt.Fprintf(f.out, "Warning: %v\n", err)
+		}
+
+		f.mu.Lock()
+		delete(f.tailing, c.ID)
+		f.mu.Unlock()
+	}()
+}
+
+// resumeCursor returns where tailing a container again starts: the older of
+// its stream cursors, so neither stream loses lines. Lines the other stream
+// already shipped are skipped by dispatchLine.
+func (f *LogForwarder) resumeCursor(container string) (time.Time, bool) {
+	var resume time.Time
+	found := false
+
+	for _, stream := range logStreams {
+		cursor, ok := f.cursors[logCursorKey(container, stream)]
+
+		if ok && (!found || cursor.Before(resume)) {
+			resume, found = cursor, true
+		}
+	}
+
+	return resume, found
+}
+
+// logStreams are the streams docker logs reads from a container
+var logStreams = []string{"stdout", "stderr"}
+
+// logCursorKey keys the cursors by container and stream. Both streams are
+// read concurrently, so their lines are not ordered against each other.
+func logCursorKey(container, stream string) string {
+	return container + "/" + stream
+}
+
+// dispatch hands lines to the sinks of their app, skipping lines already
+// shipped when a container is tailed again
+func (f *LogForwarder) dispatch() {
+	for line := range f.lines {
+		f.dispatchLine(line)
+	}
+}
+
+// dispatchLine sends outside the lock, so a slow sink holds up only the
+// dispatch and not the containers being followed or the sinks being set
+func (f *LogForwarder) dispatchLine(line LogLine) {
+	f.mu.Lock()
+
+	key := logCursorKey(line.Container, line.Stream)
+	cursor, seen := f.cursors[key]
+
+	if seen && !line.time.IsZero() && !line.time.After(cursor) {
+		f.mu.Unlock()
+		return
+	}
+
+	if !line.time.IsZero() {
+		f.cursors[key] = line.time
+	}
+
+	workers := make([]*logSinkWorker, 0, len(f.workers[line.App]))
+
+	for _, worker := range f.workers[line.App] {
+		workers = append(workers, worker)
+	}
+
+	f.mu.Unlock()
+
+	for _, worker := range workers {
+		worker.send(line)
+	}
+}
+
+// stop flushes and closes every sink
+func (f *LogForwarder) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for app, workers := range f.workers {
+		for _, worker := range workers {
+			worker.stop()
+		}
+
+		delete(f.workers, app)
+	}
+}
+
+func describeLogSink(config LogSink) string {
+	switch config.Type {
+	case "file":
+		path := config.Path
+
+		if path == "" {
+			path = DefaultLogSinkPath
+		}
+
+		return "file " + path
+	case "syslog":
+		protocol := config.Protocol
+
+		if protocol == "" {
+			protocol = "udp"
+		}
+
+		return fmt.Sprintf("syslog %s://%s", protocol, config.Address)
+	default:
+		return config.Type + " " + config.URL
+	}
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(LoggingTestSuite))
+}
+
+func (s *LoggingTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+// fakeLogSink records batches, failing the first `failures` sends
+type fakeLogSink struct {
+	mu       sync.Mutex
+	batches  [][]LogLine
+	failures int
+	closed   bool
+}
+
+func (f *fakeLogSink) Send(lines []LogLine) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("unavailable")
+	}
+
+	f.batches = append(f.batches, append([]LogLine{}, lines...))
+	return nil
+}
+
+func (f *fakeLogSink) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeLogSink) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var messages []string
+
+	for _, batch := range f.batches {
+		for _, line := range batch {
+			messages = append(messages, line.Message)
+		}
+	}
+
+	return messages
+}
+
+func (s *LoggingTestSuite) TestDockerArgs_WithRotation() {
+	Expect((*LoggingConfig)(nil).DockerArgs()).To(BeEmpty())
+	Expect((&LoggingConfig{}).DockerArgs()).To(BeEmpty())
+	Expect((&LoggingConfig{MaxSize: "10m", MaxFile: 3}).DockerArgs()).To(Equal([]string{
+		"--log-driver", "json-file", "--log-opt", "max-size=10m", "--log-opt", "max-file=3",
+	}))
+}
+
+func (s *LoggingTestSuite) TestSinkWorker_BatchesAndRetries() {
+	sink := &fakeLogSink{failures: 2}
+	worker := newLogSinkWorker(LogSink{Type: "http", BatchSize: 2, FlushInterval: "1h"}, sink, io.Discard)
+	worker.retryDelay = time.Millisecond
+
+	go worker.run()
+
+	for _, message := range []string{"one", "two", "three"} {
+		worker.lines <- LogLine{Message: message}
+	}
+
+	worker.stop()
+
+	Expect(sink.batches).To(HaveLen(2))
+	Expect(sink.batches[0]).To(HaveLen(2))
+	Expect(sink.messages()).To(Equal([]string{"one", "two", "three"}))
+	Expect(sink.closed).To(BeTrue())
+}
+
+func (s *LoggingTestSuite) TestFileSink_WritesPerProcess() {
+	sink, err := newLogSink(LogSink{Type: "file", Path: s.tempDir})
+	s.Require().NoError(err)
+
+	err = sink.Send([]LogLine{
+		{Timestamp: "2024-01-01T12:00:00Z", App: "api", Container: "api", Process: "web", Stream: "stdout", Message: "GET /"},
+		{Timestamp: "2024-01-01T12:00:01Z", App: "api", Container: "api-worker-1", Process: "worker", Stream: "stderr", Message: "boom"},
+	})
+	sink.Close()
+
+	Expect(err).To(BeNil())
+
+	web, _ := os.ReadFile(filepath.Join(s.tempDir, "api", "web.log"))
+	worker, _ := os.ReadFile(filepath.Join(s.tempDir, "api", "worker.log"))
+
+	Expect(string(web)).To(Equal("2024-01-01T12:00:00Z api[stdout]: GET /\n"))
+	Expect(string(worker)).To(Equal("2024-01-01T12:00:01Z api-worker-1[stderr]: boom\n"))
+}
+
+func (s *LoggingTestSuite) TestFormatSyslogMessage() {
+	line := LogLine{Timestamp: "2024-01-01T12:00:00Z", App: "api", Container: "api-worker-1", Process: "worker", Stream: "stderr", Message: "boom"}
+
+	Expect(FormatSyslogMessage(line, "web-1")).To(Equal("<131>1 2024-01-01T12:00:00Z web-1 api api-worker-1 worker - boom"))
+	Expect(FormatSyslogMessage(LogLine{Stream: "stdout", Message: "hi"}, "")).To(Equal("<134>1 - - - - - - hi"))
+}
+
+func (s *LoggingTestSuite) TestSyslogSink_SendsOverUDP() {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	sink, err := newLogSink(LogSink{Type: "syslog", Address: conn.LocalAddr().String()})
+	s.Require().NoError(err)
+	defer sink.Close()
+
+	s.Require().NoError(sink.Send([]LogLine{{App: "api", Stream: "stdout", Message: "hello"}}))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+
+	Expect(err).To(BeNil())
+	Expect(string(buf[:n])).To(HaveSuffix(" api - - - hello"))
+}
+
+func (s *LoggingTestSuite) TestLokiSink_PostsStreams() {
+	var body map[string][]LokiStream
+	var auth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := newLogSink(LogSink{Type: "loki", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	s.Require().NoError(err)
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	err = sink.Send([]LogLine{
+		{App: "api", Container: "api", Process: "web", Stream: "stdout", Message: "one", time: ts},
+		{App: "api", Container: "api", Process: "web", Stream: "stderr", Message: "two", time: ts},
+		{App: "api", Container: "api", Process: "web", Stream: "stdout", Message: "three", time: ts},
+	})
+
+	Expect(err).To(BeNil())
+	Expect(auth).To(Equal("Bearer token"))
+	Expect(body["streams"]).To(HaveLen(2))
+	Expect(body["streams"][0].Stream["stream"]).To(Equal("stdout"))
+	Expect(body["streams"][0].Values).To(Equal([][2]string{{"1704110400000000000", "one"}, {"1704110400000000000", "three"}}))
+}
+
+func (s *LoggingTestSuite) TestHTTPSink_FailsOnErrorStatus() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink, _ := newLogSink(LogSink{Type: "http", URL: server.URL})
+
+	Expect(sink.Send([]LogLine{{Message: "x"}})).To(MatchError(ContainSubstring("503")))
+}
+
+func (s *LoggingTestSuite) TestForwarder_TailsContainersOfAppsWithSinks() {
+	sink := &fakeLogSink{}
+	var tailed []string
+	var mu sync.Mutex
+
+	forwarder := NewLogForwarder(s.tempDir, "", io.Discard)
+	forwarder.listContainers = func() ([]ContainerInfo, error) {
+		return []ContainerInfo{
+			{ID: "1", Names: "api", Labels: "gokku.app=api,gokku.process=web"},
+			{ID: "2", Names: "worker", Labels: "gokku.app=worker,gokku.process=web"},
+			{ID: "3", Names: "legacy"},
+		}, nil
+	}
+	forwarder.loadApp = func(appName string) (*App, error) {
+		if appName == "api" {
+			return &App{Logging: &LoggingConfig{Forward: []LogSink{{Type: "http", URL: "http://logs", FlushInterval: "1ms"}}}}, nil
+		}
+
+		return &App{}, nil
+	}
+	forwarder.newSink = func(config LogSink) (logSink, error) { return sink, nil }
+	forwarder.tail = func(appName string, c ContainerInfo, opts LogOptions, lines chan<- LogLine) error {
+		mu.Lock()
+		tailed = append(tailed, c.Names+" since="+opts.Since)
+		mu.Unlock()
+
+		lines <- LogLine{App: appName, Container: c.Names, Message: "hello"}
+		return nil
+	}
+
+	go forwarder.dispatch()
+
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(forwarder.Sync(since))
+
+	Eventually(sink.messages).Should(Equal([]string{"hello"}))
+
+	mu.Lock()
+	Expect(tailed).To(Equal([]string{"api since=2024-01-01T12:00:00Z"}))
+	mu.Unlock()
+
+	forwarder.stop()
+}
+
+func (s *LoggingTestSuite) TestForwarder_SkipsLinesAlreadyShipped() {
+	sink := &fakeLogSink{}
+	forwarder := NewLogForwarder(s.tempDir, "api", io.Discard)
+	worker := newLogSinkWorker(LogSink{Type: "http", FlushInterval: "1ms"}, sink, io.Discard)
+	forwarder.workers["api"] = map[string]*logSinkWorker{"http": worker}
+
+	go worker.run()
+
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Message: "one", time: first})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Message: "one", time: first})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Message: "two", time: first.Add(time.Second)})
+
+	forwarder.stop()
+
+	Expect(sink.messages()).To(Equal([]string{"one", "two"}))
+}
+
+func (s *LoggingTestSuite) TestForwarder_SendsOutsideTheLock() {
+	sink := &fakeLogSink{}
+	forwarder := NewLogForwarder(s.tempDir, "api", io.Discard)
+	worker := newLogSinkWorker(LogSink{Type: "http", BatchSize: 1, FlushInterval: "1ms"}, sink, io.Discard)
+	forwarder.workers["api"] = map[string]*logSinkWorker{"http": worker}
+
+	// The worker is not running yet, so the last line waits for room
+	dispatched := make(chan struct{})
+
+	go func() {
+		for i := 0; i <= cap(worker.lines); i++ {
+			forwarder.dispatchLine(LogLine{App: "api", Container: "api", Message: "line"})
+		}
+
+		close(dispatched)
+	}()
+
+	Eventually(func() int { return len(worker.lines) }).Should(Equal(cap(worker.lines)))
+
+	locked := make(chan struct{})
+
+	go func() {
+		forwarder.setSinks("worker", nil)
+		close(locked)
+	}()
+
+	Eventually(locked).Should(BeClosed())
+	Expect(dispatched).NotTo(BeClosed())
+
+	go worker.run()
+
+	Eventually(dispatched).Should(BeClosed())
+	forwarder.stop()
+
+	Expect(sink.messages()).To(HaveLen(cap(worker.lines) + 1))
+}
+
+func (s *LoggingTestSuite) TestForwarder_KeepsInterleavedStreams() {
+	sink := &fakeLogSink{}
+	forwarder := NewLogForwarder(s.tempDir, "api", io.Discard)
+	worker := newLogSinkWorker(LogSink{Type: "http", FlushInterval: "1ms"}, sink, io.Discard)
+	forwarder.workers["api"] = map[string]*logSinkWorker{"http": worker}
+
+	go worker.run()
+
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// stdout and stderr are read concurrently, so an older line of one
+	// stream can arrive after a newer line of the other
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stdout", Message: "out 3", time: first.Add(3 * time.Second)})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stderr", Message: "err 1", time: first.Add(time.Second)})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stdout", Message: "out 2", time: first.Add(2 * time.Second)})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stderr", Message: "err 4", time: first.Add(4 * time.Second)})
+
+	// Tailing again resumes from the older stream and skips shipped lines
+	resume, ok := forwarder.resumeCursor("api")
+	Expect(ok).To(BeTrue())
+	Expect(resume).To(Equal(first.Add(3 * time.Second)))
+
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stdout", Message: "out 3", time: first.Add(3 * time.Second)})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stderr", Message: "err 4", time: first.Add(4 * time.Second)})
+	forwarder.dispatchLine(LogLine{App: "api", Container: "api", Stream: "stdout", Message: "out 5", time: first.Add(5 * time.Second)})
+
+	forwarder.stop()
+
+	Expect(sink.messages()).To(Equal([]string{"out 3", "err 1", "err 4", "out 5"}))
+}
@@ -0,0 +1,384 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogTail is the number of lines shown per container when --tail is not given
+const DefaultLogTail = "500"
+
+// logColors are the ANSI colours used for container prefixes, in order
+var logColors = []string{"36", "33", "32", "35", "34", "31"}
+
+// LogOptions holds the gokku logs flags
+type LogOptions struct {
+	Tail    string // Lines per container, or "all"
+	Since   string // Passed to docker logs --since
+	Until   string // Passed to docker logs --until
+	Grep    string // Regular expression lines must match
+	Process string // Only containers running this process type
+	Release string // Only containers from this release
+	JSON    bool   // Print one JSON object per line
+	Follow  bool   // Keep streaming new lines
+	Color   bool   // Colour container prefixes
+}
+
+// LogLine is a single line read from a container
+type LogLine struct {
+	Timestamp string `json:"timestamp"`
+	App       string `json:"app"`
+	Container string `json:"container"`
+	Process   string `json:"process"`
+	Release   string `json:"release,omitempty"`
+	Stream    string `json:"stream"`
+	Message   string `json:"message"`
+
+	time time.Time
+}
+
+// ParseLogOptions parses the gokku logs flags
+func ParseLogOptions(args []string) (LogOptions, error) {
+	opts := LogOptions{Tail: DefaultLogTail}
+
+	values := map[string]*string{
+		"--tail":    &opts.Tail,
+		"-n":        &opts.Tail,
+		"--since":   &opts.Since,
+		"--until":   &opts.Until,
+		"--grep":    &opts.Grep,
+		"--process": &opts.Process,
+		"-p":        &opts.Process,
+		"--release": &opts.Release,
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "-f" || arg == "--follow" {
+			opts.Follow = true
+			continue
+		}
+
+		if arg == "--json" {
+			opts.JSON = true
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		target, ok := values[name]
+
+		if !ok {
+			return opts, fmt.Errorf("unknown option '%s'", arg)
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", name)
+			}
+
+			i++
+			value = args[i]
+		}
+
+		*target = value
+	}
+
+	return opts, opts.validate()
+}
+
+func (o LogOptions) validate() error {
+	if o.Tail != "all" {
+		if n, err := strconv.Atoi(o.Tail); err != nil || n < 0 {
+			return fmt.Errorf("invalid --tail '%s': must be a number or 'all'", o.Tail)
+		}
+	}
+
+	if _, err := regexp.Compile(o.Grep); err != nil {
+		return fmt.Errorf("invalid --grep '%s': %v", o.Grep, err)
+	}
+
+	return nil
+}
+
+// Flags renders the options back into command line flags for remote execution
+func (o LogOptions) Flags() string {
+	var flags []string
+
+	if o.Tail != DefaultLogTail {
+		flags = append(flags, "--tail", ShellQuote(o.Tail))
+	}
+
+	for _, flag := range []struct{ name, value string }{
+		{"--since", o.Since},
+		{"--until", o.Until},
+		{"--grep", o.Grep},
+		{"--process", o.Process},
+		{"--release", o.Release},
+	} {
+		if flag.value != "" {
+			flags = append(flags, flag.name, ShellQuote(flag.value))
+		}
+	}
+
+	if o.JSON {
+		flags = append(flags, "--json")
+	}
+
+	if o.Follow {
+		flags = append(flags, "-f")
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// DockerArgs returns the docker logs arguments for a container
+func (o LogOptions) DockerArgs(container string) []string {
+	args := []string{"logs", "--timestamps", "--tail", o.Tail}
+
+	if o.Since != "" {
+		args = append(args, "--since", o.Since)
+	}
+
+	if o.Until != "" {
+		args = append(args, "--until", o.Until)
+	}
+
+	if o.Follow {
+		args = append(args, "-f")
+	}
+
+	return append(args, container)
+}
+
+// SelectLogContainers returns the app's containers matching the process and
+// release filters, sorted by name
+func SelectLogContainers(containers []ContainerInfo, appName string, opts LogOptions) []ContainerInfo {
+	var selected []ContainerInfo
+
+	for _, c := range containers {
+		if !c.BelongsTo(appName) {
+			continue
+		}
+
+		if opts.Process != "" && c.ProcessType() != opts.Process {
+			continue
+		}
+
+		if opts.Release != "" && c.Label(LabelRelease) != opts.Release {
+			continue
+		}
+
+		selected = append(selected, c)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Names < selected[j].Names
+	})
+
+	return selected
+}
+
+// ParseLogLine splits a line written by docker logs --timestamps
+func ParseLogLine(raw string) (time.Time, string) {
+	ts, message, found := strings.Cut(raw, " ")
+
+	t, err := time.Parse(time.RFC3339Nano, ts)
+
+	if !found || err != nil {
+		return time.Time{}, raw
+	}
+
+	return t, message
+}
+
+// LogPrinter formats log lines from one or more containers
+type LogPrinter struct {
+	opts     LogOptions
+	grep     *regexp.Regexp
+	prefixes map[string]string
+}
+
+// NewLogPrinter creates a printer for the given containers. Lines are
+// prefixed with the container name when there is more than one.
+func NewLogPrinter(containers []ContainerInfo, opts LogOptions) *LogPrinter {
+	printer := &LogPrinter{opts: opts, prefixes: map[string]string{}}
+
+	if opts.Grep != "" {
+		printer.grep = regexp.MustCompile(opts.Grep)
+	}
+
+	if len(containers) < 2 {
+		return printer
+	}
+
+	width := 0
+
+	for _, c := range containers {
+		width = max(width, len(c.Names))
+	}
+
+	for i, c := range containers {
+		prefix := fmt.Sprintf("%-*s |", width, c.Names)
+
+		if opts.Color {
+			prefix = fmt.Sprintf("\033[%sm%s\033[0m", logColors[i%len(logColors)], prefix)
+		}
+
+		printer.prefixes[c.Names] = prefix
+	}
+
+	return printer
+}
+
+// Match reports whether the line passes the --grep filter
+func (p *LogPrinter) Match(line LogLine) bool {
+	return p.grep == nil || p.grep.MatchString(line.Message)
+}
+
+// Format renders a line as text or JSON
+func (p *LogPrinter) Format(line LogLine) string {
+	if p.opts.JSON {
+		data, _ := json.Marshal(line)
+		return string(data)
+	}
+
+	if prefix, ok := p.prefixes[line.Container]; ok {
+		return prefix + " " + line.Message
+	}
+
+	return line.Message
+}
+
+// StreamLogs prints the logs of the containers to out. Without --follow the
+// lines of all containers are merged by timestamp; with it they are printed
+// as they arrive.
+func StreamLogs(appName string, containers []ContainerInfo, opts LogOptions, out io.Writer) error {
+	printer := NewLogPrinter(containers, opts)
+	lines := make(chan LogLine, 256)
+	errs := make(chan error, len(containers))
+
+	var wg sync.WaitGroup
+
+	for _, c := range containers {
+		wg.Add(1)
+
+		go func(c ContainerInfo) {
+			defer wg.Done()
+
+			if err := readContainerLogs(appName, c, opts, lines); err != nil {
+				errs <- err
+			}
+		}(c)
+	}
+
+	go func() {
+		wg.Wait()
+		close(lines)
+		close(errs)
+	}()
+
+	var collected []LogLine
+
+	for line := range lines {
+		if !printer.Match(line) {
+			continue
+		}
+
+		if opts.Follow {
+			fmt.Fprintln(out, printer.Format(line))
+			continue
+		}
+
+		collected = append(collected, line)
+	}
+
+	sort.SliceStable(collected, func(i, j int) bool {
+		return collected[i].time.Before(collected[j].time)
+	})
+
+	for _, line := range collected {
+		fmt.Fprintln(out, printer.Format(line))
+	}
+
+	if err, ok := <-errs; ok {
+		return err
+	}
+
+	return nil
+}
+
+// readContainerLogs runs docker logs for a container and sends its stdout and
+// stderr lines to lines
+func readContainerLogs(appName string, c ContainerInfo, opts LogOptions, lines chan<- LogLine) error {
+	cmd := exec.Command("docker", opts.DockerArgs(c.Names)...)
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return err
+	}
+
+	stderr, err := cmd.StderrPipe()
+
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read logs of %s: %v", c.Names, err)
+	}
+
+	base := LogLine{
+		App:       appName,
+		Container: c.Names,
+		Process:   c.ProcessType(),
+		Release:   c.Label(LabelRelease),
+	}
+
+	var wg sync.WaitGroup
+
+	for stream, reader := range map[string]io.Reader{"stdout": stdout, "stderr": stderr} {
+		wg.Add(1)
+
+		go func(stream string, reader io.Reader) {
+			defer wg.Done()
+
+			scanner := bufio.NewScanner(reader)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+			for scanner.Scan() {
+				line := base
+				line.time, line.Message = ParseLogLine(scanner.Text())
+				line.Stream = stream
+
+				if !line.time.IsZero() {
+					line.Timestamp = line.time.Format(time.RFC3339Nano)
+				}
+
+				lines <- line
+			}
+		}(stream, reader)
+	}
+
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && (exitErr.ExitCode() == 130 || exitErr.ExitCode() == 143) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to read logs of %s: %v", c.Names, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type LogsTestSuite struct {
+	suite.Suite
+}
+
+func TestLogsTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(LogsTestSuite))
+}
+
+func (s *LogsTestSuite) TestParseLogOptions_Defaults() {
+	opts, err := ParseLogOptions(nil)
+
+	Expect(err).To(BeNil())
+	Expect(opts.Tail).To(Equal(DefaultLogTail))
+	Expect(opts.Follow).To(BeFalse())
+	Expect(opts.Flags()).To(BeEmpty())
+}
+
+func (s *LogsTestSuite) TestParseLogOptions_WithAllFlags() {
+	opts, err := ParseLogOptions([]string{
+		"-f", "--tail", "100", "--since=10m", "--until", "2024-01-01T00:00:00Z",
+		"--grep", "GET /health", "--process", "worker", "--release=20240101120000", "--json",
+	})
+
+	Expect(err).To(BeNil())
+	Expect(opts).To(Equal(LogOptions{
+		Tail:    "100",
+		Since:   "10m",
+		Until:   "2024-01-01T00:00:00Z",
+		Grep:    "GET /health",
+		Process: "worker",
+		Release: "20240101120000",
+		JSON:    true,
+		Follow:  true,
+	}))
+	Expect(opts.Flags()).To(Equal("--tail 100 --since 10m --until 2024-01-01T00:00:00Z --grep 'GET /health' --process worker --release 20240101120000 --json -f"))
+}
+
+func (s *LogsTestSuite) TestParseLogOptions_WithInvalidFlags() {
+	for _, args := range [][]string{{"--tail", "ten"}, {"--tail", "-1"}, {"--grep", "("}, {"--since"}, {"--colour"}} {
+		_, err := ParseLogOptions(args)
+		Expect(err).NotTo(BeNil(), args)
+	}
+}
+
+func (s *LogsTestSuite) TestDockerArgs() {
+	opts := LogOptions{Tail: "all", Since: "1h", Follow: true}
+
+	Expect(opts.DockerArgs("api")).To(Equal([]string{"logs", "--timestamps", "--tail", "all", "--since", "1h", "-f", "api"}))
+}
+
+func (s *LogsTestSuite) TestSelectLogContainers_FiltersByAppProcessAndRelease() {
+	containers := []ContainerInfo{
+		{Names: "api-worker-1", Labels: "gokku.app=api,gokku.process=worker,gokku.release=2"},
+		{Names: "api", Labels: "gokku.app=api,gokku.process=web,gokku.release=2"},
+		{Names: "api-green", Labels: "gokku.app=api,gokku.process=web,gokku.release=1"},
+		{Names: "api-v2", Labels: "gokku.app=api-v2,gokku.process=web"},
+		{Names: "api-web-2"},
+	}
+
+	names := func(cs []ContainerInfo) []string {
+		var result []string
+		for _, c := range cs {
+			result = append(result, c.Names)
+		}
+		return result
+	}
+
+	Expect(names(SelectLogContainers(containers, "api", LogOptions{}))).To(Equal([]string{"api", "api-green", "api-web-2", "api-worker-1"}))
+	Expect(names(SelectLogContainers(containers, "api", LogOptions{Process: "web"}))).To(Equal([]string{"api", "api-green", "api-web-2"}))
+	Expect(names(SelectLogContainers(containers, "api", LogOptions{Release: "2"}))).To(Equal([]string{"api", "api-worker-1"}))
+}
+
+func (s *LogsTestSuite) TestParseLogLine() {
+	ts, message := ParseLogLine("2024-01-01T12:00:00.123456789Z GET / 200")
+
+	Expect(ts).To(Equal(time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.UTC)))
+	Expect(message).To(Equal("GET / 200"))
+
+	ts, message = ParseLogLine("no timestamp here")
+
+	Expect(ts.IsZero()).To(BeTrue())
+	Expect(message).To(Equal("no timestamp here"))
+}
+
+func (s *LogsTestSuite) TestLogPrinter_PrefixesOnlyWhenMultiplexing() {
+	single := NewLogPrinter([]ContainerInfo{{Names: "api"}}, LogOptions{})
+	Expect(single.Format(LogLine{Container: "api", Message: "hello"})).To(Equal("hello"))
+
+	multi := NewLogPrinter([]ContainerInfo{{Names: "api"}, {Names: "api-worker-1"}}, LogOptions{})
+	Expect(multi.Format(LogLine{Container: "api", Message: "hello"})).To(Equal("api          | hello"))
+
+	colored := NewLogPrinter([]ContainerInfo{{Names: "api"}, {Names: "api-worker-1"}}, LogOptions{Color: true})
+	Expect(colored.Format(LogLine{Container: "api-worker-1", Message: "hi"})).To(Equal("\033[33mapi-worker-1 |\033[0m hi"))
+}
+
+func (s *LogsTestSuite) TestLogPrinter_JSONAndGrep() {
+	printer := NewLogPrinter(nil, LogOptions{JSON: true, Grep: "^GET"})
+	line := LogLine{Timestamp: "2024-01-01T12:00:00Z", App: "api", Container: "api", Process: "web", Stream: "stdout", Message: "GET /"}
+
+	Expect(printer.Match(line)).To(BeTrue())
+	Expect(printer.Match(LogLine{Message: "POST /"})).To(BeFalse())
+	Expect(printer.Format(line)).To(Equal(`{"timestamp":"2024-01-01T12:00:00Z","app":"api","container":"api","process":"web","stream":"stdout","message":"GET /"}`))
+}
@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// logSink ships batches of log lines to a destination
+type logSink interface {
+	Send(lines []LogLine) error
+	Close() error
+}
+
+func newLogSink(config LogSink) (logSink, error) {
+	switch config.Type {
+	case "file":
+		dir := config.Path
+
+		if dir == "" {
+			dir = DefaultLogSinkPath
+		}
+
+		return &fileLogSink{dir: dir, files: make(map[string]*os.File)}, nil
+	case "syslog":
+		protocol := config.Protocol
+
+		if protocol == "" {
+			protocol = "udp"
+		}
+
+		if config.Address == "" {
+			return nil, fmt.Errorf("syslog sink requires an address")
+		}
+
+		hostname, _ := os.Hostname()
+
+		return &syslogLogSink{network: protocol, address: config.Address, hostname: hostname}, nil
+	case "http", "loki":
+		if config.URL == "" {
+			return nil, fmt.Errorf("%s sink requires a url", config.Type)
+		}
+
+		return &httpLogSink{
+			url:     config.URL,
+			headers: config.Headers,
+			loki:    config.Type == "loki",
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown log sink type '%s'", config.Type)
+}
+
+// fileLogSink appends lines to <dir>/<app>/<process>.log
+type fileLogSink struct {
+	dir   string
+	files map[string]*os.File
+}
+
+func (s *fileLogSink) Send(lines []LogLine) error {
+	for _, line := range lines {
+		path := filepath.Join(s.dir, line.App, line.Process+".log")
+		file, ok := s.files[path]
+
+		if !ok {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create log directory: %v", err)
+			}
+
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+
+			if err != nil {
+				return fmt.Errorf("failed to open log file: %v", err)
+			}
+
+			file = f
+			s.files[path] = f
+		}
+
+		if _, err := fmt.Fprintf(file, "%s %s[%s]: %s\n", line.Timestamp, line.Container, line.Stream, line.Message); err != nil {
+			file.Close()
+			delete(s.files, path)
+			return fmt.Errorf("failed to write log file: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *fileLogSink) Close() error {
+	for path, file := range s.files {
+		file.Close()
+		delete(s.files, path)
+	}
+
+	return nil
+}
+
+// syslogLogSink sends RFC 5424 messages over UDP or TCP
+type syslogLogSink struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+}
+
+func (s *syslogLogSink) Send(lines []LogLine) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog %s: %v", s.address, err)
+		}
+
+		s.conn = conn
+	}
+
+	for _, line := range lines {
+		message := FormatSyslogMessage(line, s.hostname)
+
+		// TCP uses octet counting framing (RFC 6587)
+		if s.network == "tcp" {
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+
+		if _, err := s.conn.Write([]byte(message)); err != nil {
+			s.Close()
+			return fmt.Errorf("failed to write to syslog %s: %v", s.address, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *syslogLogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
+
+// FormatSyslogMessage renders a line as an RFC 5424 message with facility
+// local0: stdout lines are informational, stderr lines are errors
+func FormatSyslogMessage(line LogLine, hostname string) string {
+	priority := 16*8 + 6
+
+	if line.Stream == "stderr" {
+		priority = 16*8 + 3
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+		priority,
+		syslogField(line.Timestamp),
+		syslogField(hostname),
+		syslogField(line.App),
+		syslogField(line.Container),
+		syslogField(line.Process),
+		line.Message,
+	)
+}
+
+// syslogField returns the nil value "-" for empty header fields
+func syslogField(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// httpLogSink posts batches as a JSON array, or as a Loki push request
+type httpLogSink struct {
+	url     string
+	headers map[string]string
+	loki    bool
+	client  *http.Client
+}
+
+func (s *httpLogSink) Send(lines []LogLine) error {
+	var body interface{} = lines
+
+	if s.loki {
+		body = LokiPushRequest(lines)
+	}
+
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("failed to post logs to %s: %v", s.url, err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to post logs to %s: %s", s.url, resp.Status)
+	}
+
+	return nil
+}
+
+func (s *httpLogSink) Close() error {
+	return nil
+}
+
+// LokiStream is a stream of a Loki push request
+type LokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// LokiPushRequest groups lines into Loki streams labelled by app, process,
+// container and stream, keeping their order
+func LokiPushRequest(lines []LogLine) map[string][]LokiStream {
+	var streams []LokiStream
+	index := make(map[string]int)
+
+	for _, line := range lines {
+		key := line.App + "\x00" + line.Process + "\x00" + line.Container + "\x00" + line.Stream
+
+		i, ok := index[key]
+
+		if !ok {
+			i = len(streams)
+			index[key] = i
+			streams = append(streams, LokiStream{Stream: map[string]string{
+				"app":       line.App,
+				"process":   line.Process,
+				"container": line.Container,
+				"stream":    line.Stream,
+			}})
+		}
+
+		ts := line.time
+
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+
+		streams[i].Values = append(streams[i].Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), line.Message})
+	}
+
+	return map[string][]LokiStream{"streams": streams}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// AppNetworkPrefix names the private network gokku creates for each app.
+// Linked services join it, so the app reaches them by name without the
+// services publishing ports on the host.
+const AppNetworkPrefix = "gokku-"
+
+// AppNetworkName returns the name of the app's private network
+func AppNetworkName(appName string) string {
+	return AppNetworkPrefix + appName
+}
+
+// IsAppNetwork reports whether a network mode is the app's private network.
+// A user network that merely starts with the prefix is not, so gokku does
+// not create it.
+func IsAppNetwork(appName, mode string) bool {
+	return appName != "" && mode == AppNetworkName(appName)
+}
+
+// AppNetworkMode returns the network the app's containers run on:
+// network.mode from gokku.yml, or the app's private network
+func AppNetworkMode(appName string, app *App) string {
+	if app != nil && app.Network != nil && app.Network.Mode != "" {
+		return app.Network.Mode
+	}
+
+	return AppNetworkName(appName)
+}
+
+// EnsureNetwork creates a user-defined bridge network unless it exists
+func EnsureNetwork(name string) error {
+	if exec.Command("docker", "network", "inspect", name).Run() == nil {
+		return nil
+	}
+
+	output, err := exec.Command("docker", "network", "create", "--driver", "bridge", "--label", "gokku.network="+name, name).CombinedOutput()
+
+	if err != nil && !strings.Contains(string(output), "already exists") {
+		return fmt.Errorf("failed to create network %s: %v, output: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// ConnectNetwork attaches a container to a network, reachable by the given
+// DNS aliases. Connecting a container that is already attached is a no-op.
+func ConnectNetwork(network, container string, aliases ...string) error {
+	args := []string{"network", "connect"}
+
+	for _, alias := range aliases {
+		args = append(args, "--alias", alias)
+	}
+
+	output, err := exec.Command("docker", append(args, network, container)...).CombinedOutput()
+
+	if err != nil && !strings.Contains(string(output), "already exists") {
+		return fmt.Errorf("failed to connect %s to network %s: %v, output: %s", container, network, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// DisconnectNetwork detaches a container from a network it may not be on
+func DisconnectNetwork(network, container string) error {
+	output, err := exec.Command("docker", "network", "disconnect", network, container).CombinedOutput()
+
+	if err != nil && !strings.Contains(string(output), "is not connected") && !strings.Contains(string(output), "not found") {
+		return fmt.Errorf("failed to disconnect %s from network %s: %v, output: %s", container, network, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// RemoveNetwork removes a network unless it is already gone
+func RemoveNetwork(name string) error {
+	output, err := exec.Command("docker", "network", "rm", name).CombinedOutput()
+
+	if err != nil && !strings.Contains(string(output), "not found") {
+		return fmt.Errorf("failed to remove network %s: %v, output: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
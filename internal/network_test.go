@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type NetworkTestSuite struct {
+	suite.Suite
+}
+
+func TestNetworkTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(NetworkTestSuite))
+}
+
+func (s *NetworkTestSuite) TestAppNetworkMode_DefaultsToAppNetwork() {
+	Expect(AppNetworkMode("api", &App{})).To(Equal("gokku-api"))
+	Expect(AppNetworkMode("api", nil)).To(Equal("gokku-api"))
+	Expect(IsAppNetwork("api", AppNetworkMode("api", &App{}))).To(BeTrue())
+}
+
+func (s *NetworkTestSuite) TestAppNetworkMode_WithConfiguredMode() {
+	app := &App{Network: &NetworkConfig{Mode: "host"}}
+
+	Expect(AppNetworkMode("api", app)).To(Equal("host"))
+	Expect(IsAppNetwork("api", "host")).To(BeFalse())
+}
+
+func (s *NetworkTestSuite) TestIsAppNetwork_OnlyMatchesTheAppNetwork() {
+	Expect(IsAppNetwork("api", "gokku-api")).To(BeTrue())
+	Expect(IsAppNetwork("api", "gokku-shared")).To(BeFalse())
+	Expect(IsAppNetwork("api", "gokku-api-staging")).To(BeFalse())
+	Expect(IsAppNetwork("", "gokku-")).To(BeFalse())
+}
+
+func (s *NetworkTestSuite) TestNetworkServiceConfig() {
+	config := map[string]string{"host": "localhost", "port": "15432", "container_port": "5432"}
+
+	Expect(NetworkServiceConfig("pg", config, "")).To(Equal(map[string]string{
+		"host": "pg", "port": "5432", "container_port": "5432",
+	}))
+	Expect(config["host"]).To(Equal("localhost"))
+
+	config = map[string]string{"host": "localhost", "port": "16379"}
+
+	Expect(NetworkServiceConfig("redis", config, "0.0.0.0:16379->6379/tcp, :::16379->6379/tcp")).To(Equal(map[string]string{
+		"host": "redis", "port": "6379",
+	}))
+
+	// Without a known container port the alias would pair with the host port
+	Expect(NetworkServiceConfig("redis", config, "")).To(Equal(config))
+	Expect(NetworkServiceConfig("redis", config, "0.0.0.0:16380->6379/tcp")).To(Equal(config))
+}
+
+func (s *NetworkTestSuite) TestMappedContainerPort() {
+	ports := "0.0.0.0:15432->5432/tcp, :::15432->5432/tcp, 9000/tcp"
+
+	Expect(MappedContainerPort(ports, "15432")).To(Equal("5432"))
+	Expect(MappedContainerPort(ports, "9000")).To(Equal(""))
+	Expect(MappedContainerPort(ports, "")).To(Equal(""))
+	Expect(MappedContainerPort("127.0.0.1:8080->80/tcp", "8080")).To(Equal("80"))
+}
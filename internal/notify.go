@@ -0,0 +1,735 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EventDeployStarted   = "deploy.started"
+	EventDeploySucceeded = "deploy.succeeded"
+	EventDeployFailed    = "deploy.failed"
+	EventRollback        = "rollback"
+	EventConfigChanged   = "config.changed"
+	EventCrashLoop       = "container.crash_loop"
+
+	// NotificationsFile holds the server-wide targets notified for every app
+	NotificationsFile = "notifications.yml"
+
+	// NotificationMaxAttempts is how many times a delivery is tried before it
+	// is moved to the failed directory
+	NotificationMaxAttempts = 8
+
+	// NotificationWait is how long Notify waits for deliveries before it
+	// leaves the rest in the queue for notifications:flush
+	NotificationWait = 5 * time.Second
+
+	// NotificationFlushSchedule is the crontab schedule of
+	// notifications:flush, installed once a delivery is left in the queue
+	NotificationFlushSchedule = "* * * * *"
+)
+
+// NotificationEvents lists the events targets can subscribe to
+var NotificationEvents = []string{
+	EventDeployStarted,
+	EventDeploySucceeded,
+	EventDeployFailed,
+	EventRollback,
+	EventConfigChanged,
+	EventCrashLoop,
+}
+
+// NotificationEvent is the payload sent to notification targets
+type NotificationEvent struct {
+	ID        string            `json:"id"`
+	Event     string            `json:"event"`
+	App       string            `json:"app"`
+	Release   string            `json:"release,omitempty"`
+	Revision  string            `json:"revision,omitempty"`
+	Message   string            `json:"message"`
+	Host      string            `json:"host,omitempty"`
+	Timestamp string            `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// NewNotificationEvent creates an event of appName happening now
+func NewNotificationEvent(event, appName, message string) NotificationEvent {
+	now := time.Now().UTC()
+	hostname, _ := os.Hostname()
+
+	return NotificationEvent{
+		ID:        now.Format("20060102150405") + "-" + randomHex(4),
+		Event:     event,
+		App:       appName,
+		Message:   message,
+		Host:      hostname,
+		Timestamp: now.Format(time.RFC3339),
+	}
+}
+
+// Summary renders the event as a single line for chat and email targets
+func (e NotificationEvent) Summary() string {
+	summary := fmt.Sprintf("[%s] %s: %s", e.Event, e.App, e.Message)
+
+	if e.Release != "" {
+		release := e.Release
+
+		if e.Revision != "" {
+			release += ", " + shortRevision(e.Revision)
+		}
+
+		summary += " (release " + release + ")"
+	}
+
+	if e.Host != "" {
+		summary += " on " + e.Host
+	}
+
+	return summary
+}
+
+// Wants reports whether the target subscribed to event
+func (t NotificationTarget) Wants(event string) bool {
+	return len(t.Events) == 0 || contains(t.Events, event)
+}
+
+// Destination describes where the target delivers, without credentials
+func (t NotificationTarget) Destination() string {
+	if t.Type == "email" {
+		return strings.Join(t.To, ", ") + " via " + t.Host
+	}
+
+	u, err := url.Parse(t.URL)
+
+	if err != nil || u.Host == "" {
+		return t.URL
+	}
+
+	// Slack and similar services put the token in the path
+	return u.Scheme + "://" + u.Host + "/..."
+}
+
+// NotificationDelivery is a queued delivery of an event to a target
+type NotificationDelivery struct {
+	ID          string             `json:"id"`
+	Event       NotificationEvent  `json:"event"`
+	Target      NotificationTarget `json:"target"`
+	Attempts    int                `json:"attempts"`
+	NextAttempt string             `json:"next_attempt,omitempty"`
+	LastError   string             `json:"last_error,omitempty"`
+}
+
+// NotificationFlushResult counts the outcome of a queue flush
+type NotificationFlushResult struct {
+	Delivered int
+	Pending   int
+	Failed    int
+}
+
+// Notifier delivers events to the targets of an app and of the server,
+// queueing failed deliveries under <baseDir>/notifications for retries
+type Notifier struct {
+	baseDir string
+	out     io.Writer
+
+	loadApp func(appName string) (*App, error)
+	deliver func(delivery NotificationDelivery) error
+	now     func() time.Time
+	wait    time.Duration
+
+	// scheduleFlush installs the crontab entry that retries the queue
+	scheduleFlush func() error
+}
+
+// NewNotifier creates a new Notifier; warnings are written to out
+func NewNotifier(baseDir string, out io.Writer) *Notifier {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	n := &Notifier{
+		baseDir: baseDir,
+		out:     out,
+		loadApp: func(appName string) (*App, error) {
+			return LoadAppConfigFromFile(filepath.Join(baseDir, "apps", appName, "gokku.yml"), appName)
+		},
+		now:  time.Now,
+		wait: NotificationWait,
+		scheduleFlush: func() error {
+			return InstallNotificationFlush(filepath.Join(baseDir, "notifications", "flush.log"))
+		},
+	}
+
+	n.deliver = n.send
+
+	return n
+}
+
+// Notify sends an event of appName from /opt/gokku, printing failures as
+// warnings so they never fail the caller's operation
+func Notify(appName, event, release, message string) {
+	e := NewNotificationEvent(event, appName, message)
+	e.Release = release
+
+	if err := NewNotifier("/opt/gokku", os.Stdout).Notify(e); err != nil {
+		fmt.Printf("Warning: Failed to send %s notification: %v\n", event, err)
+	}
+}
+
+// Targets returns the app's targets followed by the server-wide ones
+func (n *Notifier) Targets(appName string) ([]NotificationTarget, error) {
+	var targets []NotificationTarget
+
+	// The app config is missing until its first deploy
+	if app, err := n.loadApp(appName); err == nil {
+		targets = append(targets, app.Notifications...)
+	}
+
+	serverTargets, err := n.ServerTargets()
+
+	if err != nil {
+		return targets, err
+	}
+
+	return append(targets, serverTargets...), nil
+}
+
+// ServerTargets returns the targets of <baseDir>/notifications.yml
+func (n *Notifier) ServerTargets() ([]NotificationTarget, error) {
+	data, err := os.ReadFile(filepath.Join(n.baseDir, NotificationsFile))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", NotificationsFile, err)
+	}
+
+	var config struct {
+		Notifications []NotificationTarget `yaml:"notifications"`
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", NotificationsFile, err)
+	}
+
+	return config.Notifications, nil
+}
+
+// Notify queues the event for every target subscribed to it and flushes
+// the queue, so due deliveries from earlier events are retried as well. It
+// waits at most NotificationWait for the flush: slow targets cannot hold up
+// a deploy. What is left stays queued, and the notifications:flush crontab
+// entry is installed so it is retried without another event.
+func (n *Notifier) Notify(event NotificationEvent) error {
+	targets, err := n.Targets(event.App)
+
+	if err != nil {
+		return err
+	}
+
+	if event.Release != "" && event.Revision == "" {
+		revision, err := os.ReadFile(filepath.Join(n.baseDir, "apps", event.App, "releases", event.Release, RevisionFile))
+
+		if err == nil {
+			event.Revision = strings.TrimSpace(string(revision))
+		}
+	}
+
+	var errs []error
+
+	for i, target := range targets {
+		if !target.Wants(event.Event) {
+			continue
+		}
+
+		delivery := NotificationDelivery{
+			ID:     event.ID + "-" + strconv.Itoa(i),
+			Event:  event,
+			Target: target,
+		}
+
+		// Only Flush delivers, under the queue lock, so concurrent flushes never send twice
+		if err := n.enqueue(delivery); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	flushed := make(chan error, 1)
+
+	go func() {
+		_, err := n.Flush()
+		flushed <- err
+	}()
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			errs = append(errs, err)
+		}
+	case <-time.After(n.wait):
+		fmt.Fprintf(n.out, "Warning: %s notification not delivered after %s, it stays queued for gokku notifications:flush\n", event.Event, n.wait)
+	}
+
+	if queued, err := n.Queued(); err == nil && len(queued) > 0 {
+		if err := n.scheduleFlush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Test sends a test event of appName to every target, ignoring their event
+// filters, and returns the result per target
+func (n *Notifier) Test(appName string) ([]NotificationTarget, []error, error) {
+	targets, err := n.Targets(appName)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event := NewNotificationEvent("test", appName, "test notification from gokku")
+	results := make([]error, len(targets))
+
+	for i, target := range targets {
+		results[i] = n.deliver(NotificationDelivery{
+			ID:       event.ID + "-" + strconv.Itoa(i),
+			Event:    event,
+			Target:   target,
+			Attempts: 1,
+		})
+	}
+
+	return targets, results, nil
+}
+
+// Flush retries the queued deliveries that are due. When another gokku
+// process is flushing the queue, it waits for it to finish and then picks
+// up what that flush left.
+func (n *Notifier) Flush() (NotificationFlushResult, error) {
+	var result NotificationFlushResult
+
+	if _, err := os.Stat(n.queueDir()); os.IsNotExist(err) {
+		return result, nil
+	}
+
+	lock, err := os.OpenFile(filepath.Join(n.baseDir, "notifications", "queue.lock"), os.O_CREATE|os.O_RDWR, 0644)
+
+	if err != nil {
+		return result, fmt.Errorf("failed to open notification lock: %v", err)
+	}
+
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return result, fmt.Errorf("failed to lock notification queue: %v", err)
+	}
+
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	// Read under the lock, so deliveries queued while waiting are included
+	entries, err := os.ReadDir(n.queueDir())
+
+	if err != nil {
+		return result, fmt.Errorf("failed to read notification queue: %v", err)
+	}
+
+	// Delivery IDs start with the event time, so names sort oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(n.queueDir(), entry.Name())
+		delivery, err := readNotificationDelivery(path)
+
+		if err != nil {
+			fmt.Fprintf(n.out, "Warning: %v\n", err)
+			continue
+		}
+
+		if next, err := time.Parse(time.RFC3339, delivery.NextAttempt); err == nil && n.now().Before(next) {
+			result.Pending++
+			continue
+		}
+
+		delivery.Attempts++
+		deliverErr := n.deliver(delivery)
+
+		switch {
+		case deliverErr == nil:
+			os.Remove(path)
+			result.Delivered++
+		case delivery.Attempts >= NotificationMaxAttempts:
+			delivery.LastError = deliverErr.Error()
+			fmt.Fprintf(n.out, "Warning: giving up on %s notification to %s after %d attempts: %v\n", delivery.Event.Event, delivery.Target.Destination(), delivery.Attempts, deliverErr)
+
+			if err := n.moveToFailed(path, delivery); err != nil {
+				return result, err
+			}
+
+			result.Failed++
+		default:
+			if delivery.Attempts == 1 {
+				fmt.Fprintf(n.out, "Warning: %s notification to %s failed, will retry: %v\n", delivery.Event.Event, delivery.Target.Destination(), deliverErr)
+			}
+
+			delivery.LastError = deliverErr.Error()
+			delivery.NextAttempt = n.now().Add(notificationBackoff(delivery.Attempts)).UTC().Format(time.RFC3339)
+
+			if err := writeNotificationDelivery(path, delivery); err != nil {
+				return result, err
+			}
+
+			result.Pending++
+		}
+	}
+
+	return result, nil
+}
+
+// InstallNotificationFlush adds the notifications:flush entry to the
+// crontab, logging to logFile
+func InstallNotificationFlush(logFile string) error {
+	return InstallCrontab(true, func(existing, gokkuBin string) string {
+		entry := fmt.Sprintf("%s %s notifications:flush >> %s 2>&1", NotificationFlushSchedule, gokkuBin, ShellQuote(logFile))
+		return ReplaceCrontabBlock(existing, "notifications", []string{entry})
+	})
+}
+
+// Queued returns the deliveries waiting for a retry, oldest first
+func (n *Notifier) Queued() ([]NotificationDelivery, error) {
+	return readNotificationDeliveries(n.queueDir())
+}
+
+// Failed returns the deliveries that ran out of attempts, oldest first
+func (n *Notifier) Failed() ([]NotificationDelivery, error) {
+	return readNotificationDeliveries(n.failedDir())
+}
+
+func (n *Notifier) queueDir() string {
+	return filepath.Join(n.baseDir, "notifications", "queue")
+}
+
+func (n *Notifier) failedDir() string {
+	return filepath.Join(n.baseDir, "notifications", "failed")
+}
+
+func (n *Notifier) enqueue(delivery NotificationDelivery) error {
+	if err := os.MkdirAll(n.queueDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create notification queue: %v", err)
+	}
+
+	return writeNotificationDelivery(filepath.Join(n.queueDir(), delivery.ID+".json"), delivery)
+}
+
+func (n *Notifier) moveToFailed(path string, delivery NotificationDelivery) error {
+	if err := os.MkdirAll(n.failedDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create failed notifications directory: %v", err)
+	}
+
+	if err := writeNotificationDelivery(filepath.Join(n.failedDir(), delivery.ID+".json"), delivery); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// send delivers to the target after resolving its ${secret:...} references
+func (n *Notifier) send(delivery NotificationDelivery) error {
+	target := delivery.Target
+
+	resolved, err := NewEnvResolver(n.baseDir).Resolve(map[string]string{
+		"secret":   target.Secret,
+		"password": target.Password,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	target.Secret = resolved["secret"]
+	target.Password = resolved["password"]
+
+	switch target.Type {
+	case "slack":
+		return postNotification(target, delivery, map[string]string{"text": delivery.Event.Summary()})
+	case "webhook":
+		return postNotification(target, delivery, delivery.Event)
+	case "email":
+		return sendNotificationEmail(target, delivery)
+	}
+
+	return fmt.Errorf("unknown notification type '%s'", target.Type)
+}
+
+// SignPayload returns the X-Gokku-Signature-256 value of body: the hex
+// HMAC-SHA256 of the body keyed by secret, prefixed with "sha256="
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postNotification posts payload as JSON, signed when the target has a secret
+func postNotification(target NotificationTarget, delivery NotificationDelivery, payload interface{}) error {
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gokku")
+	req.Header.Set("X-Gokku-Event", delivery.Event.Event)
+	req.Header.Set("X-Gokku-Delivery", delivery.ID)
+
+	if target.Secret != "" {
+		req.Header.Set("X-Gokku-Signature-256", SignPayload(target.Secret, body))
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %v", target.Destination(), err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to post to %s: %s", target.Destination(), resp.Status)
+	}
+
+	return nil
+}
+
+// NotificationEmail renders the email sent for a delivery
+func NotificationEmail(target NotificationTarget, delivery NotificationDelivery) ([]byte, error) {
+	details, err := json.MarshalIndent(delivery.Event, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", target.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(target.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [gokku] %s: %s\r\n", delivery.Event.App, delivery.Event.Event)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@gokku>\r\n", delivery.ID)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "X-Gokku-Event: %s\r\n", delivery.Event.Event)
+	fmt.Fprintf(&msg, "X-Gokku-Delivery: %s\r\n", delivery.ID)
+
+	// The signature covers the JSON event at the end of the body
+	if target.Secret != "" {
+		fmt.Fprintf(&msg, "X-Gokku-Signature-256: %s\r\n", SignPayload(target.Secret, details))
+	}
+
+	fmt.Fprintf(&msg, "\r\n%s\r\n\r\n", delivery.Event.Summary())
+	msg.Write(details)
+
+	return msg.Bytes(), nil
+}
+
+// sendNotificationEmail sends the delivery over SMTP, upgrading to TLS when
+// the server offers STARTTLS
+func sendNotificationEmail(target NotificationTarget, delivery NotificationDelivery) error {
+	port := target.Port
+
+	if port == 0 {
+		port = 587
+	}
+
+	msg, err := NotificationEmail(target, delivery)
+
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(target.Host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	client, err := smtp.NewClient(conn, target.Host)
+
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: target.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS with %s: %v", address, err)
+		}
+	}
+
+	if target.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", target.Username, target.Password, target.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate with %s: %v", address, err)
+		}
+	}
+
+	if err := client.Mail(target.From); err != nil {
+		return fmt.Errorf("failed to send email via %s: %v", address, err)
+	}
+
+	for _, to := range target.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to send email to %s: %v", to, err)
+		}
+	}
+
+	w, err := client.Data()
+
+	if err != nil {
+		return fmt.Errorf("failed to send email via %s: %v", address, err)
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send email via %s: %v", address, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email via %s: %v", address, err)
+	}
+
+	return client.Quit()
+}
+
+// notificationBackoff is the wait before the next attempt: 30s doubling up to 1h
+func notificationBackoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+
+	return delay
+}
+
+func readNotificationDelivery(path string) (NotificationDelivery, error) {
+	var delivery NotificationDelivery
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return delivery, fmt.Errorf("failed to read queued notification: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		return delivery, fmt.Errorf("failed to parse queued notification %s: %v", filepath.Base(path), err)
+	}
+
+	return delivery, nil
+}
+
+func readNotificationDeliveries(dir string) ([]NotificationDelivery, error) {
+	entries, err := os.ReadDir(dir)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+
+	var deliveries []NotificationDelivery
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		if delivery, err := readNotificationDelivery(filepath.Join(dir, entry.Name())); err == nil {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID < deliveries[j].ID
+	})
+
+	return deliveries, nil
+}
+
+// writeNotificationDelivery writes through a temporary file readable by root
+// only, since targets may hold SMTP passwords
+func writeNotificationDelivery(path string, delivery NotificationDelivery) error {
+	data, err := json.MarshalIndent(delivery, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %v", err)
+	}
+
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to queue notification: %v", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 7 {
+		return revision[:7]
+	}
+
+	return revision
+}
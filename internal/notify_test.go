@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotifyTestSuite struct {
+	suite.Suite
+	tempDir        string
+	flushSchedules int
+}
+
+func TestNotifyTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(NotifyTestSuite))
+}
+
+func (s *NotifyTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.flushSchedules = 0
+}
+
+func (s *NotifyTestSuite) newNotifier(targets ...NotificationTarget) *Notifier {
+	notifier := NewNotifier(s.tempDir, io.Discard)
+	notifier.loadApp = func(appName string) (*App, error) {
+		return &App{Name: appName, Notifications: targets}, nil
+	}
+	notifier.scheduleFlush = func() error {
+		s.flushSchedules++
+		return nil
+	}
+
+	return notifier
+}
+
+func (s *NotifyTestSuite) TestSignPayload() {
+	Expect(SignPayload("secret", []byte(`{"event":"rollback"}`))).To(Equal("sha256=eea581b4b5010bdeee5eb993329db5f0e6cc811b12bbbd810aef055925d54ce4"))
+	Expect(SignPayload("secret", []byte("a"))).NotTo(Equal(SignPayload("other", []byte("a"))))
+}
+
+func (s *NotifyTestSuite) TestWebhook_SendsSignedEvent() {
+	var body []byte
+	var headers http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	secretsDir := filepath.Join(s.tempDir, "secrets")
+	s.Require().NoError(os.MkdirAll(secretsDir, 0700))
+	s.Require().NoError(os.WriteFile(filepath.Join(secretsDir, "HOOK_KEY"), []byte("s3cret\n"), 0600))
+
+	notifier := s.newNotifier(NotificationTarget{Type: "webhook", URL: server.URL, Secret: "${secret:HOOK_KEY}"})
+	event := NewNotificationEvent(EventDeploySucceeded, "api", "deploy succeeded")
+
+	Expect(notifier.Notify(event)).To(Succeed())
+
+	var received NotificationEvent
+	s.Require().NoError(json.Unmarshal(body, &received))
+
+	Expect(received.Event).To(Equal(EventDeploySucceeded))
+	Expect(received.App).To(Equal("api"))
+	Expect(headers.Get("X-Gokku-Event")).To(Equal(EventDeploySucceeded))
+	Expect(headers.Get("X-Gokku-Delivery")).To(Equal(event.ID + "-0"))
+	Expect(headers.Get("X-Gokku-Signature-256")).To(Equal(SignPayload("s3cret", body)))
+}
+
+func (s *NotifyTestSuite) TestSlack_SendsSummaryText() {
+	var payload map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	notifier := s.newNotifier(NotificationTarget{Type: "slack", URL: server.URL})
+	event := NotificationEvent{ID: "1", Event: EventRollback, App: "api", Release: "20240101-120000", Revision: "abcdef123456", Message: "rolled back", Host: "web-1"}
+
+	Expect(notifier.Notify(event)).To(Succeed())
+	Expect(payload["text"]).To(Equal("[rollback] api: rolled back (release 20240101-120000, abcdef1) on web-1"))
+}
+
+func (s *NotifyTestSuite) TestNotify_SkipsUnsubscribedTargets() {
+	var sent []string
+
+	notifier := s.newNotifier(
+		NotificationTarget{Type: "webhook", URL: "http://a", Events: []string{EventDeployFailed}},
+		NotificationTarget{Type: "webhook", URL: "http://b"},
+	)
+	notifier.deliver = func(delivery NotificationDelivery) error {
+		sent = append(sent, delivery.Target.URL)
+		return nil
+	}
+
+	Expect(notifier.Notify(NewNotificationEvent(EventDeployStarted, "api", "deploy started"))).To(Succeed())
+	Expect(sent).To(Equal([]string{"http://b"}))
+	Expect(s.flushSchedules).To(BeZero())
+}
+
+func (s *NotifyTestSuite) TestFlush_WaitsForConcurrentFlush() {
+	var sent []string
+
+	notifier := s.newNotifier(NotificationTarget{Type: "webhook", URL: "http://hook"})
+	notifier.deliver = func(delivery NotificationDelivery) error {
+		sent = append(sent, delivery.ID)
+		return nil
+	}
+
+	s.Require().NoError(os.MkdirAll(notifier.queueDir(), 0700))
+
+	// Another process holds the queue lock
+	lock, err := os.OpenFile(filepath.Join(s.tempDir, "notifications", "queue.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	s.Require().NoError(err)
+	defer lock.Close()
+	s.Require().NoError(syscall.Flock(int(lock.Fd()), syscall.LOCK_EX))
+
+	event := NewNotificationEvent(EventDeploySucceeded, "api", "deploy succeeded")
+	s.Require().NoError(notifier.enqueue(NotificationDelivery{ID: event.ID + "-0", Event: event, Target: NotificationTarget{Type: "webhook", URL: "http://hook"}}))
+
+	flushed := make(chan NotificationFlushResult, 1)
+
+	go func() {
+		result, _ := notifier.Flush()
+		flushed <- result
+	}()
+
+	Consistently(flushed, 100*time.Millisecond).ShouldNot(Receive())
+
+	s.Require().NoError(syscall.Flock(int(lock.Fd()), syscall.LOCK_UN))
+
+	Eventually(flushed).Should(Receive(Equal(NotificationFlushResult{Delivered: 1})))
+	Expect(sent).To(Equal([]string{event.ID + "-0"}))
+}
+
+func (s *NotifyTestSuite) TestNotify_LeavesSlowDeliveriesQueued() {
+	release := make(chan struct{})
+
+	notifier := s.newNotifier(NotificationTarget{Type: "webhook", URL: "http://slow"})
+	notifier.wait = 10 * time.Millisecond
+	notifier.deliver = func(delivery NotificationDelivery) error {
+		<-release
+		return nil
+	}
+
+	started := time.Now()
+
+	Expect(notifier.Notify(NewNotificationEvent(EventDeployStarted, "api", "deploy started"))).To(Succeed())
+	Expect(time.Since(started)).To(BeNumerically("<", time.Second))
+
+	queued, _ := notifier.Queued()
+	Expect(queued).To(HaveLen(1))
+	Expect(queued[0].Target.URL).To(Equal("http://slow"))
+
+	// The flush carries on in the background
+	close(release)
+	Eventually(func() int {
+		queued, _ := notifier.Queued()
+		return len(queued)
+	}).Should(BeZero())
+}
+
+func (s *NotifyTestSuite) TestTargets_IncludeServerWideTargets() {
+	config := "notifications:\n  - type: slack\n    url: https://hooks.example.com/T1\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, NotificationsFile), []byte(config), 0644))
+
+	notifier := s.newNotifier(NotificationTarget{Type: "webhook", URL: "https://example.com/hook"})
+	targets, err := notifier.Targets("api")
+
+	Expect(err).To(BeNil())
+	Expect(targets).To(HaveLen(2))
+	Expect(targets[1].Type).To(Equal("slack"))
+	Expect(targets[1].Destination()).To(Equal("https://hooks.example.com/..."))
+}
+
+func (s *NotifyTestSuite) TestFailedDeliveries_AreRetriedFromTheQueue() {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	failures := 2
+	attempts := 0
+
+	notifier := s.newNotifier(NotificationTarget{Type: "webhook", URL: "http://hook"})
+	notifier.now = func() time.Time { return now }
+	notifier.deliver = func(delivery NotificationDelivery) error {
+		attempts++
+
+		if failures > 0 {
+			failures--
+			return errors.New("unavailable")
+		}
+
+		return nil
+	}
+
+	Expect(notifier.Notify(NewNotificationEvent(EventDeployFailed, "api", "deploy failed"))).To(Succeed())
+
+	queued, _ := notifier.Queued()
+	Expect(queued).To(HaveLen(1))
+	Expect(queued[0].Attempts).To(Equal(1))
+	Expect(queued[0].LastError).To(Equal("unavailable"))
+	Expect(s.flushSchedules).To(Equal(1))
+
+	// Not due yet
+	result, err := notifier.Flush()
+	Expect(err).To(BeNil())
+	Expect(result).To(Equal(NotificationFlushResult{Pending: 1}))
+	Expect(attempts).To(Equal(1))
+
+	now = now.Add(notificationBackoff(1))
+	result, _ = notifier.Flush()
+	Expect(result).To(Equal(NotificationFlushResult{Pending: 1}))
+
+	now = now.Add(notificationBackoff(2))
+	result, _ = notifier.Flush()
+	Expect(result).To(Equal(NotificationFlushResult{Delivered: 1}))
+
+	queued, _ = notifier.Queued()
+	Expect(queued).To(BeEmpty())
+	Expect(attempts).To(Equal(3))
+}
+
+func (s *NotifyTestSuite) TestFailedDeliveries_GiveUpAfterMaxAttempts() {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	notifier := s.newNotifier(NotificationTarget{Type: "webhook", URL: "http://hook"})
+	notifier.now = func() time.Time { return now }
+	notifier.deliver = func(delivery NotificationDelivery) error {
+		return errors.New("unavailable")
+	}
+
+	notifier.Notify(NewNotificationEvent(EventDeployFailed, "api", "deploy failed"))
+
+	for i := 1; i < NotificationMaxAttempts; i++ {
+		now = now.Add(time.Hour)
+		notifier.Flush()
+	}
+
+	queued, _ := notifier.Queued()
+	failed, _ := notifier.Failed()
+
+	Expect(queued).To(BeEmpty())
+	Expect(failed).To(HaveLen(1))
+	Expect(failed[0].Attempts).To(Equal(NotificationMaxAttempts))
+}
+
+func (s *NotifyTestSuite) TestNotificationBackoff() {
+	Expect(notificationBackoff(1)).To(Equal(30 * time.Second))
+	Expect(notificationBackoff(2)).To(Equal(time.Minute))
+	Expect(notificationBackoff(20)).To(Equal(time.Hour))
+}
+
+func (s *NotifyTestSuite) TestNotificationEmail_IsSigned() {
+	target := NotificationTarget{Type: "email", Host: "smtp.example.com", From: "gokku@example.com", To: []string{"ops@example.com"}, Secret: "key"}
+	delivery := NotificationDelivery{ID: "1-0", Event: NotificationEvent{ID: "1", Event: EventDeployFailed, App: "api", Message: "deploy failed"}}
+
+	msg, err := NotificationEmail(target, delivery)
+
+	Expect(err).To(BeNil())
+	Expect(string(msg)).To(ContainSubstring("Subject: [gokku] api: deploy.failed\r\n"))
+	Expect(string(msg)).To(ContainSubstring("X-Gokku-Signature-256: sha256="))
+	Expect(string(msg)).To(ContainSubstring("[deploy.failed] api: deploy failed"))
+}
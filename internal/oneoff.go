@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RunSize is a resource limit preset for one-off containers
+type RunSize struct {
+	Memory string
+	CPUs   string
+}
+
+// RunSizes are the named presets accepted by gokku run --size
+var RunSizes = map[string]RunSize{
+	"small":  {Memory: "512m", CPUs: "0.5"},
+	"medium": {Memory: "1g", CPUs: "1"},
+	"large":  {Memory: "2g", CPUs: "2"},
+	"xlarge": {Memory: "4g", CPUs: "4"},
+}
+
+var (
+	memoryPattern = regexp.MustCompile(`^\d+[bkmg]?$`)
+	cpusPattern   = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+	safeShellPattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
+// ParseRunSize parses a preset name or an explicit <memory>[:<cpus>] limit
+func ParseRunSize(size string) (RunSize, error) {
+	if preset, ok := RunSizes[size]; ok {
+		return preset, nil
+	}
+
+	memory, cpus, _ := strings.Cut(strings.ToLower(size), ":")
+
+	if !memoryPattern.MatchString(memory) {
+		return RunSize{}, fmt.Errorf("invalid size '%s': use small, medium, large, xlarge or <memory>[:<cpus>] like 1g:2", size)
+	}
+
+	if cpus != "" && !cpusPattern.MatchString(cpus) {
+		return RunSize{}, fmt.Errorf("invalid cpus '%s' in size '%s'", cpus, size)
+	}
+
+	return RunSize{Memory: memory, CPUs: cpus}, nil
+}
+
+// OneOffConfig describes a one-off container started by gokku run
+type OneOffConfig struct {
+	Name        string
+	Image       string
+	Command     string
+	EnvFile     string
+	NetworkMode string
+	Volumes     []string
+	WorkingDir  string
+	Labels      ContainerLabels
+	Detached    bool
+	TTY         bool
+	Size        *RunSize
+	Logging     *LoggingConfig
+}
+
+// NewOneOffConfig builds a one-off container for the app's current release,
+// with the same env, volumes and network as the web container
+func NewOneOffConfig(appName string, app *App, command string) OneOffConfig {
+	appDir := filepath.Join("/opt/gokku/apps", appName)
+	releaseDir := filepath.Join(appDir, "current")
+
+	networkMode := AppNetworkMode(appName, app)
+
+	volumes := []string{
+		fmt.Sprintf("%s:/app", releaseDir),
+		fmt.Sprintf("/opt/gokku/volumes/%s:/app/shared", appName),
+	}
+
+	volumes = append(volumes, app.Volumes...)
+
+	labels := NewReleaseLabels(appName, app, releaseDir)
+	labels.Process = OneOffProcessType
+
+	return OneOffConfig{
+		Name:        fmt.Sprintf("%s-%s-%d", appName, OneOffProcessType, time.Now().Unix()),
+		Image:       currentImage(appName),
+		Command:     command,
+		EnvFile:     filepath.Join(appDir, "shared", ".env"),
+		NetworkMode: networkMode,
+		Volumes:     volumes,
+		WorkingDir:  "/app",
+		Labels:      labels.WithRole(RoleOneOff),
+		Logging:     app.Logging,
+	}
+}
+
+// Args returns the docker run arguments for the one-off container, up to
+// and including the image. envFile is the resolved env file, if any.
+func (c OneOffConfig) Args(envFile string) []string {
+	args := []string{"run", "--name", c.Name}
+
+	if c.Detached {
+		args = append(args, "-d")
+	} else {
+		args = append(args, "--rm", "-i")
+
+		if c.TTY {
+			args = append(args, "-t")
+		}
+	}
+
+	for _, label := range c.Labels.List() {
+		args = append(args, "--label", label)
+	}
+
+	if c.NetworkMode != "" {
+		args = append(args, "--network", c.NetworkMode)
+	}
+
+	if envFile != "" {
+		args = append(args, "--env-file", envFile)
+	}
+
+	for _, volume := range c.Volumes {
+		args = append(args, "-v", volume)
+	}
+
+	if c.WorkingDir != "" {
+		args = append(args, "-w", c.WorkingDir)
+	}
+
+	args = append(args, c.Logging.DockerArgs()...)
+
+	if c.Size != nil {
+		args = append(args, "--memory", c.Size.Memory)
+
+		if c.Size.CPUs != "" {
+			args = append(args, "--cpus", c.Size.CPUs)
+		}
+	}
+
+	return append(args, c.Image)
+}
+
+// ShellCommand returns the docker run command line. The app command is
+// appended unquoted so the shell parses it as it did with docker exec.
+func (c OneOffConfig) ShellCommand(envFile string) string {
+	args := c.Args(envFile)
+	quoted := make([]string, len(args))
+
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+
+	return fmt.Sprintf("docker %s %s", strings.Join(quoted, " "), c.Command)
+}
+
+// PrepareOneOff resolves the app env and returns the docker run command for
+// the one-off container. cleanup removes the resolved env file.
+func PrepareOneOff(config OneOffConfig, baseEnv map[string]string) (*exec.Cmd, func(), error) {
+	var envFile string
+
+	cleanup := func() {}
+
+	if IsAppNetwork(config.Labels.App, config.NetworkMode) {
+		if err := EnsureNetwork(config.NetworkMode); err != nil {
+			return nil, cleanup, err
+		}
+	}
+
+	if fileExists(config.EnvFile) || len(baseEnv) > 0 {
+		resolved, err := WriteResolvedEnvFile(config.EnvFile, baseEnv, config.NetworkMode)
+
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("failed to resolve environment for %s: %v", config.Name, err)
+		}
+
+		envFile = resolved
+		cleanup = func() { os.Remove(resolved) }
+	}
+
+	return exec.Command("bash", "-c", config.ShellCommand(envFile)), cleanup, nil
+}
+
+// RunOneOff starts the one-off container and returns the command's exit code
+func RunOneOff(config OneOffConfig, baseEnv map[string]string) (int, error) {
+	cmd, cleanup, err := PrepareOneOff(config, baseEnv)
+	defer cleanup()
+
+	if err != nil {
+		return 1, err
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return commandExitCode(cmd.Run())
+}
+
+// commandExitCode turns the result of running a command into its exit code
+func commandExitCode(err error) (int, error) {
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+
+	if err != nil {
+		return 1, fmt.Errorf("failed to run one-off container: %v", err)
+	}
+
+	return 0, nil
+}
+
+// IsTerminal reports whether f is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ShellQuote quotes s for POSIX shells when it contains special characters
+func ShellQuote(s string) string {
+	if s != "" && safeShellPattern.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// currentImage returns the image of the app's active container, falling back
+// to the latest build
+func currentImage(appName string) string {
+	for _, name := range []string{appName, appName + "-green"} {
+		output, err := exec.Command("docker", "inspect", name, "--format", "{{.Config.Image}}").Output()
+
+		if err == nil && strings.TrimSpace(string(output)) != "" {
+			return strings.TrimSpace(string(output))
+		}
+	}
+
+	return fmt.Sprintf("%s:latest", appName)
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type OneOffTestSuite struct {
+	suite.Suite
+}
+
+func TestOneOffTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(OneOffTestSuite))
+}
+
+func (s *OneOffTestSuite) TestParseRunSize_WithPreset() {
+	size, err := ParseRunSize("large")
+
+	Expect(err).To(BeNil())
+	Expect(size).To(Equal(RunSize{Memory: "2g", CPUs: "2"}))
+}
+
+func (s *OneOffTestSuite) TestParseRunSize_WithExplicitLimits() {
+	size, err := ParseRunSize("1536M:1.5")
+
+	Expect(err).To(BeNil())
+	Expect(size).To(Equal(RunSize{Memory: "1536m", CPUs: "1.5"}))
+}
+
+func (s *OneOffTestSuite) TestParseRunSize_WithMemoryOnly() {
+	size, err := ParseRunSize("512m")
+
+	Expect(err).To(BeNil())
+	Expect(size.CPUs).To(BeEmpty())
+}
+
+func (s *OneOffTestSuite) TestParseRunSize_WithInvalidSize() {
+	_, err := ParseRunSize("huge")
+	Expect(err).NotTo(BeNil())
+
+	_, err = ParseRunSize("1g:two")
+	Expect(err).NotTo(BeNil())
+}
+
+func (s *OneOffTestSuite) TestArgs_WithInteractiveRun() {
+	config := OneOffConfig{
+		Name:        "api-run-1",
+		Image:       "api:latest",
+		Command:     "rails console",
+		NetworkMode: "bridge",
+		Volumes:     []string{"/opt/gokku/apps/api/current:/app"},
+		WorkingDir:  "/app",
+		Labels:      ContainerLabels{App: "api", Process: OneOffProcessType, Role: RoleOneOff},
+		TTY:         true,
+	}
+
+	Expect(config.Args("/tmp/env")).To(Equal([]string{
+		"run", "--name", "api-run-1", "--rm", "-i", "-t",
+		"--label", "createdby=gokku",
+		"--label", "gokku.app=api",
+		"--label", "gokku.process=run",
+		"--label", "gokku.role=one-off",
+		"--network", "bridge",
+		"--env-file", "/tmp/env",
+		"-v", "/opt/gokku/apps/api/current:/app",
+		"-w", "/app",
+		"api:latest",
+	}))
+}
+
+func (s *OneOffTestSuite) TestArgs_WithDetachedRunAndSize() {
+	config := OneOffConfig{
+		Name:     "api-run-1",
+		Image:    "api:latest",
+		Command:  "rake reindex",
+		Detached: true,
+		TTY:      true,
+		Size:     &RunSize{Memory: "1g", CPUs: "1"},
+	}
+
+	args := config.Args("")
+
+	Expect(args).To(ContainElement("-d"))
+	Expect(args).NotTo(ContainElement("--rm"))
+	Expect(args).NotTo(ContainElement("-t"))
+	Expect(args).NotTo(ContainElement("--env-file"))
+	Expect(args[len(args)-5:]).To(Equal([]string{"--memory", "1g", "--cpus", "1", "api:latest"}))
+}
+
+func (s *OneOffTestSuite) TestShellCommand_LeavesCommandToTheShell() {
+	config := OneOffConfig{
+		Name:    "api-run-1",
+		Image:   "api:latest",
+		Command: `rails runner "User.cleanup"`,
+		Labels:  ContainerLabels{App: "api", Env: "it's"},
+	}
+
+	Expect(config.ShellCommand("")).To(Equal(
+		`docker run --name api-run-1 --rm -i --label createdby=gokku --label gokku.app=api --label 'gokku.env=it'\''s' api:latest rails runner "User.cleanup"`,
+	))
+}
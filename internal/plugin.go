@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokku/pluginsdk"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the running gokku version, set by the CLI at startup
+var Version = "dev"
+
+// PluginManifestFile is the manifest a plugin ships at its root
+const PluginManifestFile = "plugin.yml"
+
+// PluginManifestFiles are the manifest names a plugin may ship at its root,
+// in lookup order. JSON is valid YAML, so both parse the same way.
+var PluginManifestFiles = []string{PluginManifestFile, "plugin.json"}
+
+// PluginManifest describes a plugin, its requirements and what it provides
+type PluginManifest struct {
+	Name         string                `yaml:"name" json:"name"`
+	Version      string                `yaml:"version" json:"version"`
+	Description  string                `yaml:"description,omitempty" json:"description,omitempty"`
+	Gokku        string                `yaml:"gokku,omitempty" json:"gokku,omitempty"`
+	Requires     []string              `yaml:"requires,omitempty" json:"requires,omitempty"`
+	Dependencies map[string]string     `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+	Commands     []PluginCommand       `yaml:"commands,omitempty" json:"commands,omitempty"`
+	Env          map[string]string     `yaml:"env,omitempty" json:"env,omitempty"`
+	Hooks        map[string]HookPolicy `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+	Protocol     string                `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	Executable   string                `yaml:"executable,omitempty" json:"executable,omitempty"`
+	Timeout      string                `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// DefaultVersion is the service version services:upgrade moves to when
+	// none is given, usually the latest the plugin supports
+	DefaultVersion string `yaml:"default_version,omitempty" json:"default_version,omitempty"`
+}
+
+// PluginCommand is a command a plugin provides as gokku <plugin>:<name>
+type PluginCommand struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// constraintOpSpacing matches the space allowed between an operator and its version
+var constraintOpSpacing = regexp.MustCompile(`([<>=!]=?)\s+`)
+
+// versionPattern matches versions like 1.2, 1.2.3 or v1.2.3-beta.1
+var versionPattern = regexp.MustCompile(`^v?\d+(\.\d+){0,2}([-+][0-9A-Za-z.-]+)?$`)
+
+// LoadPluginManifest reads the manifest of the plugin in pluginDir. It
+// returns nil when the plugin ships none.
+func LoadPluginManifest(pluginDir string) (*PluginManifest, error) {
+	for _, name := range PluginManifestFiles {
+		data, err := os.ReadFile(filepath.Join(pluginDir, name))
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+
+		var manifest PluginManifest
+
+		if err := yaml.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+
+		if err := manifest.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+
+		return &manifest, nil
+	}
+
+	return nil, nil
+}
+
+// Validate checks the manifest fields that gokku interprets
+func (m *PluginManifest) Validate() error {
+	if m.Version != "" && !versionPattern.MatchString(m.Version) {
+		return fmt.Errorf("version '%s' is not a semantic version", m.Version)
+	}
+
+	if _, err := ParseVersionConstraint(m.Gokku); err != nil {
+		return fmt.Errorf("gokku: %v", err)
+	}
+
+	for name, constraint := range m.Dependencies {
+		if _, err := ParseVersionConstraint(constraint); err != nil {
+			return fmt.Errorf("dependencies.%s: %v", name, err)
+		}
+	}
+
+	for i, command := range m.Commands {
+		if command.Name == "" {
+			return fmt.Errorf("commands.%d: name is required", i)
+		}
+	}
+
+	if m.Protocol != "" && m.Protocol != PluginProtocolScript && m.Protocol != pluginsdk.Protocol {
+		return fmt.Errorf("protocol must be '%s' or '%s'", PluginProtocolScript, pluginsdk.Protocol)
+	}
+
+	if m.Executable != "" && (filepath.IsAbs(m.Executable) || !filepath.IsLocal(m.Executable)) {
+		return fmt.Errorf("executable '%s' must be a path inside the plugin", m.Executable)
+	}
+
+	if m.Timeout != "" {
+		if timeout, err := time.ParseDuration(m.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout '%s'", m.Timeout)
+		}
+	}
+
+	for event, policy := range m.Hooks {
+		if !slices.Contains(HookEvents, event) {
+			return fmt.Errorf("hooks.%s: unknown hook event", event)
+		}
+
+		if policy.Timeout != "" {
+			if timeout, err := time.ParseDuration(policy.Timeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("hooks.%s: invalid timeout '%s'", event, policy.Timeout)
+			}
+		}
+
+		if policy.OnFailure != "" && policy.OnFailure != HookOnFailureBlock && policy.OnFailure != HookOnFailureIgnore {
+			return fmt.Errorf("hooks.%s: on_failure must be '%s' or '%s'", event, HookOnFailureBlock, HookOnFailureIgnore)
+		}
+	}
+
+	return nil
+}
+
+// VersionConstraint is a set of comparisons a version must all satisfy
+type VersionConstraint []versionComparison
+
+type versionComparison struct {
+	op      string
+	version string
+}
+
+// ParseVersionConstraint parses constraints such as ">=1.0.100",
+// ">=1.0 <2.0" or "1.2.3". Comparisons are separated by spaces or commas;
+// an empty constraint matches every version.
+func ParseVersionConstraint(constraint string) (VersionConstraint, error) {
+	var result VersionConstraint
+
+	constraint = strings.TrimSpace(constraint)
+	normalized := constraintOpSpacing.ReplaceAllString(constraint, "$1")
+
+	for _, part := range strings.FieldsFunc(normalized, func(r rune) bool { return r == ' ' || r == ',' }) {
+		op := "="
+
+		for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = strings.TrimPrefix(part, candidate)
+				break
+			}
+		}
+
+		if !versionPattern.MatchString(part) {
+			return nil, fmt.Errorf("invalid version constraint '%s'", constraint)
+		}
+
+		result = append(result, versionComparison{op: op, version: part})
+	}
+
+	return result, nil
+}
+
+// Allows reports whether version satisfies every comparison. Development
+// builds and unversioned plugins satisfy any constraint.
+func (c VersionConstraint) Allows(version string) bool {
+	if version == "" || version == "dev" {
+		return true
+	}
+
+	for _, comparison := range c {
+		cmp := CompareVersions(version, comparison.version)
+
+		switch comparison.op {
+		case ">=":
+			if cmp < 0 {
+				return false
+			}
+		case "<=":
+			if cmp > 0 {
+				return false
+			}
+		case ">":
+			if cmp <= 0 {
+				return false
+			}
+		case "<":
+			if cmp >= 0 {
+				return false
+			}
+		case "!=":
+			if cmp == 0 {
+				return false
+			}
+		default:
+			if cmp != 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// CompareVersions compares the numeric parts of two versions, returning -1,
+// 0 or 1. Missing parts count as zero and pre-release suffixes are ignored.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func versionParts(version string) [3]int {
+	var parts [3]int
+
+	version = strings.TrimPrefix(version, "v")
+
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	for i, part := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(part)
+	}
+
+	return parts
+}
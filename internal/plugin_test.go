@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type PluginManifestTestSuite struct {
+	suite.Suite
+	pluginDir string
+}
+
+func TestPluginManifestTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(PluginManifestTestSuite))
+}
+
+func (s *PluginManifestTestSuite) SetupTest() {
+	s.pluginDir = s.T().TempDir()
+}
+
+func (s *PluginManifestTestSuite) TestLoadPluginManifest_FromYAML() {
+	manifest := `
+name: postgres
+version: 1.4.0
+description: PostgreSQL databases
+gokku: ">= 1.0.100, <2"
+requires: [docker]
+dependencies:
+  nginx: ">=1.0"
+commands:
+  - name: backup
+    description: Dump the database
+env:
+  DATABASE_URL: postgres://{{user}}@{{host}}/{{database}}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(s.pluginDir, PluginManifestFile), []byte(manifest), 0644))
+
+	loaded, err := LoadPluginManifest(s.pluginDir)
+
+	Expect(err).To(BeNil())
+	Expect(loaded.Version).To(Equal("1.4.0"))
+	Expect(loaded.Requires).To(Equal([]string{"docker"}))
+	Expect(loaded.Dependencies).To(HaveKeyWithValue("nginx", ">=1.0"))
+	Expect(loaded.Commands).To(Equal([]PluginCommand{{Name: "backup", Description: "Dump the database"}}))
+	Expect(loaded.Env).To(HaveKey("DATABASE_URL"))
+}
+
+func (s *PluginManifestTestSuite) TestLoadPluginManifest_FromJSON() {
+	manifest := `{"name": "redis", "version": "v2.0.1", "gokku": ">=1.0"}`
+	s.Require().NoError(os.WriteFile(filepath.Join(s.pluginDir, "plugin.json"), []byte(manifest), 0644))
+
+	loaded, err := LoadPluginManifest(s.pluginDir)
+
+	Expect(err).To(BeNil())
+	Expect(loaded.Name).To(Equal("redis"))
+	Expect(loaded.Version).To(Equal("v2.0.1"))
+}
+
+func (s *PluginManifestTestSuite) TestLoadPluginManifest_WhenMissingOrInvalid() {
+	loaded, err := LoadPluginManifest(s.pluginDir)
+
+	Expect(err).To(BeNil())
+	Expect(loaded).To(BeNil())
+
+	s.Require().NoError(os.WriteFile(filepath.Join(s.pluginDir, PluginManifestFile), []byte("version: latest\n"), 0644))
+
+	_, err = LoadPluginManifest(s.pluginDir)
+	Expect(err).To(MatchError("invalid plugin.yml: version 'latest' is not a semantic version"))
+
+	s.Require().NoError(os.WriteFile(filepath.Join(s.pluginDir, PluginManifestFile), []byte("gokku: \"~> 1.0\"\n"), 0644))
+
+	_, err = LoadPluginManifest(s.pluginDir)
+	Expect(err).To(MatchError(ContainSubstring("invalid version constraint '~> 1.0'")))
+}
+
+func (s *PluginManifestTestSuite) TestVersionConstraint_Allows() {
+	constraint, err := ParseVersionConstraint(">=1.0.100 <2")
+
+	Expect(err).To(BeNil())
+	Expect(constraint.Allows("1.0.112")).To(BeTrue())
+	Expect(constraint.Allows("1.0.99")).To(BeFalse())
+	Expect(constraint.Allows("2.0.0")).To(BeFalse())
+	Expect(constraint.Allows("dev")).To(BeTrue())
+
+	exact, _ := ParseVersionConstraint("1.2")
+	Expect(exact.Allows("v1.2.0")).To(BeTrue())
+	Expect(exact.Allows("1.2.1")).To(BeFalse())
+
+	unconstrained, _ := ParseVersionConstraint("")
+	Expect(unconstrained.Allows("0.0.1")).To(BeTrue())
+}
+
+func (s *PluginManifestTestSuite) TestCompareVersions() {
+	Expect(CompareVersions("1.0.10", "1.0.9")).To(Equal(1))
+	Expect(CompareVersions("1.2", "1.2.0")).To(Equal(0))
+	Expect(CompareVersions("1.2.0-beta.1", "1.2.0")).To(Equal(0))
+	Expect(CompareVersions("0.9", "1.0")).To(Equal(-1))
+}
+
+func (s *PluginManifestTestSuite) TestValidate_Protocol() {
+	Expect((&PluginManifest{Protocol: "jsonrpc", Executable: "bin/plugin"}).Validate()).To(Succeed())
+	Expect((&PluginManifest{Protocol: "grpc"}).Validate()).To(MatchError("protocol must be 'script' or 'jsonrpc'"))
+	Expect((&PluginManifest{Protocol: "jsonrpc", Executable: "../gokku"}).Validate()).To(MatchError("executable '../gokku' must be a path inside the plugin"))
+	Expect((&PluginManifest{Protocol: "jsonrpc", Timeout: "soon"}).Validate()).To(MatchError("invalid timeout 'soon'"))
+}
+
+func (s *PluginManifestTestSuite) TestCallTimeout() {
+	Expect((*PluginManifest)(nil).CallTimeout()).To(Equal(DefaultPluginTimeout))
+	Expect((&PluginManifest{}).CallTimeout()).To(Equal(DefaultPluginTimeout))
+	Expect((&PluginManifest{Timeout: "30m"}).CallTimeout()).To(Equal(30 * time.Minute))
+}
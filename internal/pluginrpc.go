@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gokku/pluginsdk"
+)
+
+// PluginProtocolScript is the default protocol: bash scripts under bin/
+// and commands/. A jsonrpc plugin is one executable speaking pluginsdk.
+const PluginProtocolScript = "script"
+
+// DefaultPluginExecutable is the executable of a jsonrpc plugin whose
+// manifest names none
+const DefaultPluginExecutable = "plugin"
+
+// DefaultPluginTimeout bounds a JSON-RPC call, including the plugin's exit,
+// when the plugin manifest sets no timeout
+const DefaultPluginTimeout = 10 * time.Minute
+
+// UsesRPC reports whether the plugin speaks the JSON-RPC protocol
+func (m *PluginManifest) UsesRPC() bool {
+	return m != nil && m.Protocol == pluginsdk.Protocol
+}
+
+// ExecutablePath returns the path of the plugin's JSON-RPC executable
+func (m *PluginManifest) ExecutablePath(pluginDir string) string {
+	executable := DefaultPluginExecutable
+
+	if m != nil && m.Executable != "" {
+		executable = m.Executable
+	}
+
+	return filepath.Join(pluginDir, executable)
+}
+
+// CallTimeout returns how long a JSON-RPC call or the commands/env script of
+// the plugin may take
+func (m *PluginManifest) CallTimeout() time.Duration {
+	if m != nil {
+		if timeout, err := time.ParseDuration(m.Timeout); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+
+	return DefaultPluginTimeout
+}
+
+// CallPlugin calls a JSON-RPC method on the plugin in pluginDir. handled is
+// false when the plugin does not speak the protocol or does not implement
+// the method, so the caller can fall back to the plugin's scripts. The
+// plugin is killed when it runs past its timeout.
+func CallPlugin(pluginDir, method string, params any) (result *pluginsdk.Result, handled bool, err error) {
+	manifest, err := LoadPluginManifest(pluginDir)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !manifest.UsesRPC() {
+		return nil, false, nil
+	}
+
+	env := append(os.Environ(),
+		"GOKKU_PLUGIN="+filepath.Base(pluginDir),
+		"GOKKU_PLUGIN_DIR="+pluginDir,
+		"GOKKU_BASE_DIR="+filepath.Dir(filepath.Dir(pluginDir)),
+	)
+
+	timeout := manifest.CallTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := pluginsdk.Start(ctx, manifest.ExecutablePath(pluginDir), pluginDir, env, os.Stderr)
+
+	if err != nil {
+		return nil, true, fmt.Errorf("plugin '%s': %v", filepath.Base(pluginDir), err)
+	}
+
+	// The answer is all gokku needs; how the plugin exits afterwards is not
+	defer client.Close()
+
+	result = &pluginsdk.Result{}
+
+	if err := client.Call(method, params, result); err != nil {
+		if pluginsdk.IsMethodNotFound(err) {
+			return nil, false, nil
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, true, fmt.Errorf("plugin '%s' %s timed out after %s", filepath.Base(pluginDir), method, timeout)
+		}
+
+		return nil, true, fmt.Errorf("plugin '%s' %s failed: %v", filepath.Base(pluginDir), method, err)
+	}
+
+	return result, true, nil
+}
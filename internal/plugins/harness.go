@@ -0,0 +1,438 @@
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gokku/internal"
+	"gokku/internal/services"
+	"gokku/pluginsdk"
+)
+
+// dockerShim stands in for docker during plugins:test. It records every
+// call and keeps just enough container state for install, info and
+// uninstall scripts to behave as on a server.
+const dockerShim = `#!/bin/bash
+STATE="$GOKKU_DOCKER_STATE"
+mkdir -p "$STATE/containers" "$STATE/labels"
+printf '%s\n' "$*" >> "$STATE/calls.log"
+
+flag_value() {
+    local flag="$1"
+    shift
+    while [[ $# -gt 0 ]]; do
+        case "$1" in
+            "$flag") echo "$2"; return ;;
+            "$flag"=*) echo "${1#*=}"; return ;;
+        esac
+        shift
+    done
+}
+
+containers() {
+    for file in "$STATE"/containers/*; do
+        [[ -f "$file" ]] && basename "$file"
+    done
+}
+
+set_state() {
+    local state="$1"
+    shift
+    for name in "$@"; do
+        [[ "$name" == -* ]] && continue
+        [[ -f "$STATE/containers/$name" ]] && echo "$state" > "$STATE/containers/$name"
+    done
+}
+
+command="$1"
+shift
+
+case "$command" in
+    run|create)
+        name=$(flag_value --name "$@")
+        [[ -z "$name" ]] && name="container-$$"
+        if [[ -f "$STATE/containers/$name" ]]; then
+            echo "docker: Error response from daemon: Conflict. The container name \"/$name\" is already in use." >&2
+            exit 125
+        fi
+        [[ "$command" == run ]] && echo running > "$STATE/containers/$name" || echo created > "$STATE/containers/$name"
+        : > "$STATE/labels/$name"
+        while [[ $# -gt 0 ]]; do
+            [[ "$1" == --label || "$1" == -l ]] && echo "$2" >> "$STATE/labels/$name"
+            shift
+        done
+        echo "$name"
+        ;;
+    start|restart) set_state running "$@" ;;
+    stop|kill) set_state exited "$@" ;;
+    rm)
+        for name in "$@"; do
+            [[ "$name" == -* ]] && continue
+            rm -f "$STATE/containers/$name" "$STATE/labels/$name"
+        done
+        ;;
+    inspect)
+        name="${@: -1}"
+        if [[ ! -f "$STATE/containers/$name" ]]; then
+            echo "Error: No such object: $name" >&2
+            exit 1
+        fi
+        status=$(cat "$STATE/containers/$name")
+        format=$(flag_value --format "$@")
+        [[ -z "$format" ]] && format=$(flag_value -f "$@")
+        case "$format" in
+            *.Name*) echo "/$name" ;;
+            *State.Running*) [[ "$status" == running ]] && echo true || echo false ;;
+            "") echo "[{\"Name\": \"/$name\", \"State\": {\"Status\": \"$status\"}}]" ;;
+            *) echo "$status" ;;
+        esac
+        ;;
+    ps)
+        all=false
+        filter=""
+        format=""
+        while [[ $# -gt 0 ]]; do
+            case "$1" in
+                -a|--all|-aq|-qa) all=true ;;
+                -f|--filter) filter="$2"; shift ;;
+                --format) format="$2"; shift ;;
+            esac
+            shift
+        done
+        for name in $(containers); do
+            status=$(cat "$STATE/containers/$name")
+            [[ "$all" == false && "$status" != running ]] && continue
+            [[ "$filter" == name=* ]] && ! [[ "$name" =~ ${filter#name=} ]] && continue
+            [[ "$filter" == label=* ]] && ! grep -qxF "${filter#label=}" "$STATE/labels/$name" 2>/dev/null && continue
+            if [[ "$format" == json ]]; then
+                labels=$(paste -sd, "$STATE/labels/$name" 2>/dev/null)
+                printf '{"ID":"%s","Names":"%s","State":"%s","Status":"%s","Labels":"%s"}\n' "$name" "$name" "$status" "$status" "$labels"
+            else
+                echo "$name"
+            fi
+        done
+        ;;
+    logs)
+        name="${@: -1}"
+        [[ -f "$STATE/containers/$name" ]] || { echo "Error: No such container: $name" >&2; exit 1; }
+        ;;
+esac
+
+exit 0
+`
+
+// PluginTestStep is one check of a plugins:test run
+type PluginTestStep struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// PluginTestReport is the outcome of a plugins:test run
+type PluginTestReport struct {
+	Plugin      string
+	Service     string
+	BaseDir     string
+	Steps       []PluginTestStep
+	DockerCalls []string
+}
+
+// Passed reports whether every step succeeded
+func (r *PluginTestReport) Passed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PluginTestHarness runs a plugin against a temporary gokku base directory
+// with a fake docker on the PATH, so plugins can be tested without a server
+type PluginTestHarness struct {
+	pluginDir  string
+	pluginName string
+	baseDir    string
+	out        io.Writer
+}
+
+// NewPluginTestHarness prepares a temporary base directory for the plugin
+// in pluginDir
+func NewPluginTestHarness(pluginDir string, out io.Writer) (*PluginTestHarness, error) {
+	info, err := os.Stat(pluginDir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("plugin directory '%s' not found", pluginDir)
+	}
+
+	baseDir, err := os.MkdirTemp("", "gokku-plugin-test-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test base directory: %v", err)
+	}
+
+	pm := NewPluginManager(baseDir)
+
+	return &PluginTestHarness{
+		pluginDir:  pluginDir,
+		pluginName: pm.LocalPluginName(pluginDir),
+		baseDir:    baseDir,
+		out:        out,
+	}, nil
+}
+
+// BaseDir returns the temporary base directory the plugin runs against
+func (h *PluginTestHarness) BaseDir() string {
+	return h.baseDir
+}
+
+// Cleanup removes the temporary base directory
+func (h *PluginTestHarness) Cleanup() error {
+	return os.RemoveAll(h.baseDir)
+}
+
+// Run installs a service from the plugin, shows its info, checks gokku finds
+// its running containers, uninstalls it and runs the scripts in the
+// plugin's tests/ directory. Every step runs even when an earlier one
+// failed.
+func (h *PluginTestHarness) Run() (*PluginTestReport, error) {
+	report := &PluginTestReport{
+		Plugin:  h.pluginName,
+		Service: h.pluginName + "-test",
+		BaseDir: h.baseDir,
+	}
+
+	restore, err := h.setup()
+	if err != nil {
+		return nil, err
+	}
+
+	defer restore()
+
+	sm := services.NewServiceManager(h.baseDir)
+
+	h.step(report, "install", func() error {
+		if err := sm.CreateService(h.pluginName, report.Service, ""); err != nil {
+			return err
+		}
+
+		return h.checkServiceConfig(sm, report.Service)
+	})
+
+	h.step(report, "info", func() error {
+		return h.runCommand("info", report.Service)
+	})
+
+	h.step(report, "status", func() error {
+		status, err := sm.ServiceStatus(report.Service)
+		if err != nil {
+			return err
+		}
+
+		if !status.Running {
+			return fmt.Errorf("no running container found for the service (label it %s=%s)", internal.LabelService, report.Service)
+		}
+
+		return nil
+	})
+
+	h.step(report, "uninstall", func() error {
+		if err := sm.DestroyService(report.Service); err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(filepath.Join(h.baseDir, "services", report.Service)); err == nil {
+			return fmt.Errorf("service directory was not removed")
+		}
+
+		return nil
+	})
+
+	tests, err := h.testScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, script := range tests {
+		h.step(report, "tests/"+filepath.Base(script), func() error {
+			return h.runScript(script)
+		})
+	}
+
+	report.DockerCalls = h.dockerCalls()
+
+	return report, nil
+}
+
+// setup links the plugin into the base directory and points the
+// environment at it and the docker shim. The returned func restores the
+// environment.
+func (h *PluginTestHarness) setup() (func(), error) {
+	binDir := filepath.Join(h.baseDir, "bin")
+
+	for _, dir := range []string{binDir, filepath.Join(h.baseDir, "services"), filepath.Join(h.baseDir, "apps"), filepath.Join(h.baseDir, "scripts")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(binDir, "docker"), []byte(dockerShim), 0755); err != nil {
+		return nil, fmt.Errorf("failed to write docker shim: %v", err)
+	}
+
+	// Plugins source the helpers from the base directory when present
+	if helpers, err := os.ReadFile("/opt/gokku/scripts/plugin-helpers.sh"); err == nil {
+		os.WriteFile(filepath.Join(h.baseDir, "scripts", "plugin-helpers.sh"), helpers, 0755)
+	}
+
+	env := map[string]string{
+		"PATH":               binDir + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GOKKU_BASE_DIR":     h.baseDir,
+		"GOKKU_DOCKER_STATE": filepath.Join(h.baseDir, "docker"),
+		"GOKKU_TEST":         "1",
+	}
+
+	previous := map[string]*string{}
+
+	for key, value := range env {
+		if old, ok := os.LookupEnv(key); ok {
+			previous[key] = &old
+		} else {
+			previous[key] = nil
+		}
+
+		os.Setenv(key, value)
+	}
+
+	restore := func() {
+		for key, old := range previous {
+			if old == nil {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, *old)
+			}
+		}
+	}
+
+	absDir, err := filepath.Abs(h.pluginDir)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+
+	if _, err := NewPluginManager(h.baseDir).InstallLocalPlugin(absDir, h.pluginName); err != nil {
+		restore()
+		return nil, err
+	}
+
+	return restore, nil
+}
+
+// step runs fn as a named step and records its outcome
+func (h *PluginTestHarness) step(report *PluginTestReport, name string, fn func() error) {
+	fmt.Fprintf(h.out, "-----> %s\n", name)
+
+	started := time.Now()
+	err := fn()
+
+	report.Steps = append(report.Steps, PluginTestStep{Name: name, Err: err, Duration: time.Since(started)})
+}
+
+// checkServiceConfig checks install left a config.json gokku can read
+func (h *PluginTestHarness) checkServiceConfig(sm *services.ServiceManager, serviceName string) error {
+	service, err := sm.GetService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service config: %v", err)
+	}
+
+	if service.Plugin != h.pluginName {
+		return fmt.Errorf("service config names plugin '%s', expected '%s'", service.Plugin, h.pluginName)
+	}
+
+	if service.Name != serviceName {
+		return fmt.Errorf("service config names service '%s', expected '%s'", service.Name, serviceName)
+	}
+
+	return nil
+}
+
+// runCommand runs a plugin command the way gokku <plugin>:<command> does
+func (h *PluginTestHarness) runCommand(command string, args ...string) error {
+	pluginDir := filepath.Join(h.baseDir, "plugins", h.pluginName)
+
+	params := pluginsdk.CommandParams{Command: command, Args: args}
+
+	result, handled, err := internal.CallPlugin(pluginDir, pluginsdk.MethodCommand, params)
+	if err != nil {
+		return err
+	}
+
+	if handled {
+		if result.Output != "" {
+			fmt.Fprintln(h.out, strings.TrimRight(result.Output, "\n"))
+		}
+
+		return nil
+	}
+
+	for _, dir := range []string{"bin", "commands"} {
+		script := filepath.Join(pluginDir, dir, command)
+
+		if _, err := os.Stat(script); err == nil {
+			return h.runScript(script, args...)
+		}
+	}
+
+	return fmt.Errorf("command '%s' not found", command)
+}
+
+// runScript runs a plugin script with bash from the plugin directory
+func (h *PluginTestHarness) runScript(script string, args ...string) error {
+	cmd := exec.Command("bash", append([]string{script}, args...)...)
+	cmd.Dir = filepath.Join(h.baseDir, "plugins", h.pluginName)
+	cmd.Stdout = h.out
+	cmd.Stderr = h.out
+
+	return cmd.Run()
+}
+
+// testScripts returns the scripts in the plugin's tests/ directory
+func (h *PluginTestHarness) testScripts() ([]string, error) {
+	dir := filepath.Join(h.pluginDir, "tests")
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tests directory: %v", err)
+	}
+
+	var scripts []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			scripts = append(scripts, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	sort.Strings(scripts)
+
+	return scripts, nil
+}
+
+// dockerCalls returns the docker commands the plugin ran, in order
+func (h *PluginTestHarness) dockerCalls() []string {
+	data, err := os.ReadFile(filepath.Join(h.baseDir, "docker", "calls.log"))
+	if err != nil {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
@@ -0,0 +1,99 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gokku/internal"
+)
+
+// IsLocalPluginSource reports whether arg names a plugin directory on this
+// machine rather than a plugin name or git URL
+func IsLocalPluginSource(arg string) bool {
+	for _, prefix := range []string{"file://", "./", "../", "/", "~/"} {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+
+	return arg == "."
+}
+
+// LocalPluginPath resolves a local plugin source to an absolute path
+func LocalPluginPath(source string) (string, error) {
+	path := strings.TrimPrefix(source, "file://")
+
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		path = filepath.Join(home, path[2:])
+	}
+
+	return filepath.Abs(path)
+}
+
+// LocalPluginName returns the name a local plugin installs under: the name
+// in its manifest, or its directory name without the gokku- prefix
+func (pm *PluginManager) LocalPluginName(path string) string {
+	if manifest, err := internal.LoadPluginManifest(path); err == nil && manifest != nil && manifest.Name != "" {
+		return manifest.Name
+	}
+
+	return pm.ExtractPluginNameFromURL(path)
+}
+
+// InstallLocalPlugin links the plugin directory at path into the plugins
+// directory. Edits to the directory apply immediately, which makes it the
+// way to develop a plugin on the server.
+func (pm *PluginManager) InstallLocalPlugin(path, pluginName string) (PluginLockEntry, error) {
+	if pm.pluginExists(pluginName) {
+		return PluginLockEntry{}, fmt.Errorf("plugin '%s' already exists", pluginName)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return PluginLockEntry{}, fmt.Errorf("plugin directory '%s' not found", path)
+	}
+
+	if err := pm.CheckCompatibility(path); err != nil {
+		return PluginLockEntry{}, err
+	}
+
+	if err := pm.makeScriptsExecutable(path); err != nil {
+		return PluginLockEntry{}, fmt.Errorf("failed to make scripts executable: %v", err)
+	}
+
+	if err := os.MkdirAll(pm.pluginsDir, 0755); err != nil {
+		return PluginLockEntry{}, fmt.Errorf("failed to create plugins directory: %v", err)
+	}
+
+	if err := os.Symlink(path, filepath.Join(pm.pluginsDir, pluginName)); err != nil {
+		return PluginLockEntry{}, fmt.Errorf("failed to link plugin: %v", err)
+	}
+
+	entry := PluginLockEntry{
+		URL:         "file://" + path,
+		Linked:      true,
+		InstalledAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if manifest, err := internal.LoadPluginManifest(path); err == nil && manifest != nil {
+		entry.Version = manifest.Version
+	}
+
+	return entry, pm.updateLock(func(lock *PluginLock) {
+		lock.Plugins[pluginName] = entry
+	})
+}
+
+// IsLinked reports whether a plugin is a link to a local directory
+func (pm *PluginManager) IsLinked(pluginName string) bool {
+	info, err := os.Lstat(filepath.Join(pm.pluginsDir, pluginName))
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
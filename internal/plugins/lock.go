@@ -0,0 +1,309 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// LockFile records the exact source of every installed plugin
+	LockFile = "plugins.lock"
+
+	// stagingDir holds plugin checkouts until they are swapped into place
+	stagingDir = ".staging"
+
+	// previousDir keeps the version an update replaced, for plugins:rollback
+	previousDir = ".previous"
+)
+
+// commitPattern matches abbreviated or full git commit hashes
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// PluginLock is the content of plugins.lock
+type PluginLock struct {
+	Plugins map[string]PluginLockEntry `json:"plugins"`
+}
+
+// PluginLockEntry pins an installed plugin to a commit. Ref is what the user
+// asked for (a tag, branch or commit); empty follows the default branch.
+type PluginLockEntry struct {
+	URL         string           `json:"url"`
+	Ref         string           `json:"ref,omitempty"`
+	Commit      string           `json:"commit"`
+	Version     string           `json:"version,omitempty"`
+	Linked      bool             `json:"linked,omitempty"`
+	InstalledAt string           `json:"installed_at"`
+	Previous    *PluginLockEntry `json:"previous,omitempty"`
+}
+
+// ParsePluginRef splits "name@ref" into the plugin name and the ref
+func ParsePluginRef(arg string) (string, string) {
+	name, ref, _ := strings.Cut(arg, "@")
+	return name, ref
+}
+
+// LockPath returns the path of plugins.lock, next to the plugins directory
+func (pm *PluginManager) LockPath() string {
+	return filepath.Join(filepath.Dir(pm.pluginsDir), LockFile)
+}
+
+// ReadLock reads plugins.lock; a missing file is an empty lock
+func (pm *PluginManager) ReadLock() (PluginLock, error) {
+	lock := PluginLock{Plugins: map[string]PluginLockEntry{}}
+
+	data, err := os.ReadFile(pm.LockPath())
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+
+	if err != nil {
+		return lock, fmt.Errorf("failed to read %s: %v", LockFile, err)
+	}
+
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("failed to parse %s: %v", LockFile, err)
+	}
+
+	if lock.Plugins == nil {
+		lock.Plugins = map[string]PluginLockEntry{}
+	}
+
+	return lock, nil
+}
+
+// updateLock applies fn to the lock and writes it back atomically
+func (pm *PluginManager) updateLock(fn func(lock *PluginLock)) error {
+	lock, err := pm.ReadLock()
+	if err != nil {
+		return err
+	}
+
+	fn(&lock)
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := pm.LockPath() + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", LockFile, err)
+	}
+
+	return os.Rename(tmp, pm.LockPath())
+}
+
+// checkoutRepository clones gitURL at ref into targetDir and returns the
+// commit it checked out. Tags and branches are cloned shallow; commits need
+// the history to be found.
+func (pm *PluginManager) checkoutRepository(gitURL, ref, targetDir string) (string, error) {
+	var steps [][]string
+
+	switch {
+	case ref == "":
+		steps = [][]string{{"clone", "--depth", "1", gitURL, targetDir}}
+	case commitPattern.MatchString(ref):
+		steps = [][]string{
+			{"clone", "--no-checkout", gitURL, targetDir},
+			{"-C", targetDir, "checkout", "--quiet", ref},
+		}
+	default:
+		steps = [][]string{{"clone", "--depth", "1", "--branch", ref, gitURL, targetDir}}
+	}
+
+	for _, args := range steps {
+		output, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s failed: %v\nOutput: %s", args[0], err, string(output))
+		}
+	}
+
+	output, err := exec.Command("git", "-C", targetDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve checked out commit: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// stagePlugin checks out a plugin into the staging directory and prepares
+// it completely, so swapping it into place cannot leave a half-written plugin
+func (pm *PluginManager) stagePlugin(pluginName, gitURL, ref string) (string, PluginLockEntry, error) {
+	entry := PluginLockEntry{URL: gitURL, Ref: ref}
+
+	if err := os.MkdirAll(filepath.Join(pm.pluginsDir, stagingDir), 0755); err != nil {
+		return "", entry, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+
+	staged := filepath.Join(pm.pluginsDir, stagingDir, fmt.Sprintf("%s-%d", pluginName, time.Now().UnixNano()))
+
+	commit, err := pm.checkoutRepository(gitURL, ref, staged)
+	if err != nil {
+		os.RemoveAll(staged)
+		return "", entry, err
+	}
+
+	entry.Commit = commit
+
+	// Refuse plugins whose manifest this server cannot satisfy
+	if err := pm.CheckCompatibility(staged); err != nil {
+		os.RemoveAll(staged)
+		return "", entry, err
+	}
+
+	if err := pm.createPluginConfig(staged, pluginName, gitURL); err != nil {
+		os.RemoveAll(staged)
+		return "", entry, fmt.Errorf("failed to create plugin config: %v", err)
+	}
+
+	if err := pm.makeScriptsExecutable(staged); err != nil {
+		os.RemoveAll(staged)
+		return "", entry, fmt.Errorf("failed to make scripts executable: %v", err)
+	}
+
+	if config, err := pm.readPluginConfig(staged); err == nil {
+		entry.Version = config.Version
+	}
+
+	entry.InstalledAt = time.Now().UTC().Format(time.RFC3339)
+
+	return staged, entry, nil
+}
+
+// UpdatePlugin fetches a new version of a plugin into a staging directory
+// and swaps it into place. An empty ref keeps the pinned ref. The replaced
+// version is kept for RollbackPlugin.
+func (pm *PluginManager) UpdatePlugin(pluginName, ref string) (PluginLockEntry, error) {
+	if !pm.pluginExists(pluginName) {
+		return PluginLockEntry{}, fmt.Errorf("plugin '%s' not found", pluginName)
+	}
+
+	if pm.IsLinked(pluginName) {
+		return PluginLockEntry{}, fmt.Errorf("plugin '%s' is linked to a local directory, its changes apply without updating", pluginName)
+	}
+
+	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
+
+	config, err := pm.readPluginConfig(pluginDir)
+	if err != nil {
+		return PluginLockEntry{}, err
+	}
+
+	if config.URL == "" {
+		return PluginLockEntry{}, fmt.Errorf("plugin source URL not found in config.json")
+	}
+
+	lock, err := pm.ReadLock()
+	if err != nil {
+		return PluginLockEntry{}, err
+	}
+
+	current, locked := lock.Plugins[pluginName]
+
+	if ref == "" && locked {
+		ref = current.Ref
+	}
+
+	staged, entry, err := pm.stagePlugin(pluginName, config.URL, ref)
+	if err != nil {
+		return entry, err
+	}
+
+	// Already at that commit: only the pin may change
+	if locked && current.Commit == entry.Commit {
+		os.RemoveAll(staged)
+		current.Ref = ref
+
+		return current, pm.updateLock(func(lock *PluginLock) {
+			lock.Plugins[pluginName] = current
+		})
+	}
+
+	if err := pm.swapIn(pluginName, staged); err != nil {
+		return entry, err
+	}
+
+	if locked {
+		current.Previous = nil
+		entry.Previous = &current
+	}
+
+	return entry, pm.updateLock(func(lock *PluginLock) {
+		lock.Plugins[pluginName] = entry
+	})
+}
+
+// RollbackPlugin swaps a plugin back to the version its last update
+// replaced. Rolling back twice returns to the updated version.
+func (pm *PluginManager) RollbackPlugin(pluginName string) (PluginLockEntry, error) {
+	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
+	previous := filepath.Join(pm.pluginsDir, previousDir, pluginName)
+
+	if _, err := os.Stat(previous); err != nil {
+		return PluginLockEntry{}, fmt.Errorf("no previous version of plugin '%s' to roll back to", pluginName)
+	}
+
+	lock, err := pm.ReadLock()
+	if err != nil {
+		return PluginLockEntry{}, err
+	}
+
+	if err := pm.swapIn(pluginName, previous); err != nil {
+		return PluginLockEntry{}, err
+	}
+
+	current := lock.Plugins[pluginName]
+	entry := PluginLockEntry{URL: current.URL}
+
+	if current.Previous != nil {
+		entry = *current.Previous
+	} else if config, err := pm.readPluginConfig(pluginDir); err == nil {
+		entry.URL = config.URL
+		entry.Version = config.Version
+	}
+
+	current.Previous = nil
+	entry.Previous = &current
+
+	return entry, pm.updateLock(func(lock *PluginLock) {
+		lock.Plugins[pluginName] = entry
+	})
+}
+
+// swapIn moves dir into place as the plugin and keeps the replaced version
+// under .previous. Each step is a rename, and a failed second rename
+// restores the original plugin.
+func (pm *PluginManager) swapIn(pluginName, dir string) error {
+	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
+	previous := filepath.Join(pm.pluginsDir, previousDir, pluginName)
+	displaced := filepath.Join(pm.pluginsDir, stagingDir, fmt.Sprintf("%s-replaced-%d", pluginName, time.Now().UnixNano()))
+
+	for _, parent := range []string{filepath.Dir(previous), filepath.Dir(displaced)} {
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", parent, err)
+		}
+	}
+
+	if err := os.Rename(pluginDir, displaced); err != nil {
+		return fmt.Errorf("failed to move current version aside: %v", err)
+	}
+
+	if err := os.Rename(dir, pluginDir); err != nil {
+		os.Rename(displaced, pluginDir)
+		return fmt.Errorf("failed to swap in new version: %v", err)
+	}
+
+	os.RemoveAll(previous)
+
+	if err := os.Rename(displaced, previous); err != nil {
+		os.RemoveAll(displaced)
+	}
+
+	return nil
+}
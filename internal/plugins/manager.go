@@ -1,16 +1,27 @@
 package plugins
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+
+	"gokku/internal"
+)
+
+var (
+	gitURLPattern = regexp.MustCompile(`^(https?|git|ssh|git\+ssh|file)://[^\s]+$`)
+	scpURLPattern = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^\s]+$`)
 )
 
 // PluginManager manages plugins and their lifecycle
 type PluginManager struct {
 	pluginsDir string
+
+	lookPath func(file string) (string, error)
 }
 
 // GetPluginsDir returns the plugins directory
@@ -18,51 +29,26 @@ func (pm *PluginManager) GetPluginsDir() string {
 	return pm.pluginsDir
 }
 
-// NewPluginManager creates a new plugin manager
-func NewPluginManager() *PluginManager {
-	// For development, use local directory if /opt/gokku doesn't exist
-	pluginsDir := "/opt/gokku/plugins"
+// NewPluginManager creates a new plugin manager for the plugins under baseDir
+func NewPluginManager(baseDir string) *PluginManager {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
 
 	return &PluginManager{
-		pluginsDir: pluginsDir,
+		pluginsDir: filepath.Join(baseDir, "plugins"),
+		lookPath:   exec.LookPath,
 	}
 }
 
 // InstallOfficialPlugin installs an official plugin from gokku-vm organization
-func (pm *PluginManager) InstallOfficialPlugin(pluginName string) error {
-	// Check if plugin already exists
-	if pm.pluginExists(pluginName) {
-		return fmt.Errorf("plugin '%s' already exists", pluginName)
-	}
-
-	// Create plugin directory
-	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
-	if err := os.MkdirAll(pluginDir, 0755); err != nil {
-		return fmt.Errorf("failed to create plugin directory: %v", err)
-	}
-
+// at ref, a tag, branch or commit; empty installs the default branch
+func (pm *PluginManager) InstallOfficialPlugin(pluginName, ref string) (PluginLockEntry, error) {
 	// Official plugins are in gokku-vm organization with gokku- prefix
 	repoName := fmt.Sprintf("gokku-%s", pluginName)
 	gitURL := fmt.Sprintf("https://github.com/gokku-vm/%s", repoName)
 
-	// Clone repository
-	if err := pm.cloneRepository(gitURL, pluginDir); err != nil {
-		// Cleanup on error
-		os.RemoveAll(pluginDir)
-		return fmt.Errorf("failed to clone official plugin: %v", err)
-	}
-
-	// Create plugin config.json
-	if err := pm.createPluginConfig(pluginDir, pluginName, gitURL); err != nil {
-		return fmt.Errorf("failed to create plugin config: %v", err)
-	}
-
-	// Make scripts executable
-	if err := pm.makeScriptsExecutable(pluginDir); err != nil {
-		return fmt.Errorf("failed to make scripts executable: %v", err)
-	}
-
-	return nil
+	return pm.installPlugin(pluginName, gitURL, ref)
 }
 
 // ListPlugins returns a list of installed plugins
@@ -76,7 +62,11 @@ func (pm *PluginManager) ListPlugins() ([]string, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// Hidden directories hold staged and previous versions; links are
+		// local plugins in development
+		isPlugin := entry.IsDir() || entry.Type()&os.ModeSymlink != 0
+
+		if isPlugin && !strings.HasPrefix(entry.Name(), ".") {
 			plugins = append(plugins, entry.Name())
 		}
 	}
@@ -91,7 +81,15 @@ func (pm *PluginManager) RemovePlugin(pluginName string) error {
 	}
 
 	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
-	return os.RemoveAll(pluginDir)
+	if err := os.RemoveAll(pluginDir); err != nil {
+		return err
+	}
+
+	os.RemoveAll(filepath.Join(pm.pluginsDir, previousDir, pluginName))
+
+	return pm.updateLock(func(lock *PluginLock) {
+		delete(lock.Plugins, pluginName)
+	})
 }
 
 // GetPluginCommands returns available commands for a plugin
@@ -149,6 +147,13 @@ func (pm *PluginManager) makeScriptsExecutable(pluginDir string) error {
 		}
 	}
 
+	// Make the executable of a jsonrpc plugin runnable
+	if manifest, err := internal.LoadPluginManifest(pluginDir); err == nil && manifest.UsesRPC() {
+		if err := os.Chmod(manifest.ExecutablePath(pluginDir), 0755); err != nil {
+			return fmt.Errorf("failed to make plugin executable runnable: %v", err)
+		}
+	}
+
 	// Make all command scripts executable
 	commandsDir := filepath.Join(pluginDir, "commands")
 	if _, err := os.Stat(commandsDir); err == nil {
@@ -177,27 +182,11 @@ func (pm *PluginManager) pluginExists(pluginName string) bool {
 	return !os.IsNotExist(err)
 }
 
-// IsValidGitURL checks if the string is a valid Git URL
+// IsValidGitURL checks if the string is a Git URL git can clone: any
+// http(s), git, ssh or file URL, or scp-like user@host:path, so self-hosted
+// servers work as well as GitHub, GitLab and Bitbucket
 func (pm *PluginManager) IsValidGitURL(url string) bool {
-	// Check for common Git URL patterns
-	patterns := []string{
-		"https://github.com/",
-		"https://gitlab.com/",
-		"https://bitbucket.org/",
-		"git@github.com:",
-		"git@gitlab.com:",
-		"git@bitbucket.org:",
-		"git://",
-		"ssh://",
-	}
-
-	for _, pattern := range patterns {
-		if strings.HasPrefix(url, pattern) {
-			return true
-		}
-	}
-
-	return false
+	return gitURLPattern.MatchString(url) || scpURLPattern.MatchString(url)
 }
 
 // ExtractPluginNameFromURL extracts plugin name from Git URL
@@ -222,175 +211,49 @@ func (pm *PluginManager) ExtractPluginNameFromURL(url string) string {
 	return pluginName
 }
 
-// InstallPluginFromGit installs a plugin from Git repository
-func (pm *PluginManager) InstallPluginFromGit(gitURL, pluginName string) error {
+// InstallPluginFromGit installs a plugin from Git repository at ref
+func (pm *PluginManager) InstallPluginFromGit(gitURL, pluginName, ref string) (PluginLockEntry, error) {
+	return pm.installPlugin(pluginName, gitURL, ref)
+}
+
+// installPlugin stages a plugin, moves it into place and pins it in plugins.lock
+func (pm *PluginManager) installPlugin(pluginName, gitURL, ref string) (PluginLockEntry, error) {
 	// Check if plugin already exists
 	if pm.pluginExists(pluginName) {
-		return fmt.Errorf("plugin '%s' already exists", pluginName)
-	}
-
-	// Create plugin directory
-	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
-	if err := os.MkdirAll(pluginDir, 0755); err != nil {
-		return fmt.Errorf("failed to create plugin directory: %v", err)
+		return PluginLockEntry{}, fmt.Errorf("plugin '%s' already exists", pluginName)
 	}
 
-	// Clone repository
-	if err := pm.cloneRepository(gitURL, pluginDir); err != nil {
-		// Cleanup on error
-		os.RemoveAll(pluginDir)
-		return fmt.Errorf("failed to clone repository: %v", err)
-	}
-
-	// Create plugin config.json
-	if err := pm.createPluginConfig(pluginDir, pluginName, gitURL); err != nil {
-		return fmt.Errorf("failed to create plugin config: %v", err)
-	}
-
-	// Make scripts executable
-	if err := pm.makeScriptsExecutable(pluginDir); err != nil {
-		return fmt.Errorf("failed to make scripts executable: %v", err)
+	staged, entry, err := pm.stagePlugin(pluginName, gitURL, ref)
+	if err != nil {
+		return entry, err
 	}
 
-	return nil
-}
-
-// cloneRepository clones a Git repository
-func (pm *PluginManager) cloneRepository(gitURL, targetDir string) error {
-	// Use git clone command
-	cmd := exec.Command("git", "clone", "--depth", "1", gitURL, targetDir)
-
-	// Capture output for better error messages
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git clone failed: %v\nOutput: %s", err, string(output))
+	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
+	if err := os.Rename(staged, pluginDir); err != nil {
+		os.RemoveAll(staged)
+		return entry, fmt.Errorf("failed to move plugin into place: %v", err)
 	}
 
-	return nil
+	return entry, pm.updateLock(func(lock *PluginLock) {
+		lock.Plugins[pluginName] = entry
+	})
 }
 
 // createPluginConfig creates a config.json file for the plugin
 func (pm *PluginManager) createPluginConfig(pluginDir, pluginName, gitURL string) error {
-	configJSON := fmt.Sprintf(`{
-  "name": "%s",
-  "url": "%s"
-}`, pluginName, gitURL)
-
-	configPath := filepath.Join(pluginDir, "config.json")
-	return os.WriteFile(configPath, []byte(configJSON), 0644)
-}
+	config := PluginConfig{Name: pluginName, URL: gitURL}
 
-// UpdatePlugin updates a plugin by forcing an update without removing the directory
-func (pm *PluginManager) UpdatePlugin(pluginName string) error {
-	// Check if plugin exists
-	if !pm.pluginExists(pluginName) {
-		return fmt.Errorf("plugin '%s' not found", pluginName)
+	if manifest, err := internal.LoadPluginManifest(pluginDir); err == nil && manifest != nil {
+		config.Version = manifest.Version
 	}
 
-	pluginDir := filepath.Join(pm.pluginsDir, pluginName)
-	configPath := filepath.Join(pluginDir, "config.json")
-
-	// Read plugin config to get source URL
-	configData, err := os.ReadFile(configPath)
+	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to read plugin config: %v", err)
-	}
-
-	// Extract URL from config (simple parsing)
-	configStr := string(configData)
-	var gitURL string
-
-	// Find URL in config.json
-	lines := strings.Split(configStr, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, `"url":`) {
-			// Extract URL from line like: "url": "https://github.com/user/repo"
-			parts := strings.Split(line, `"url": "`)
-			if len(parts) > 1 {
-				gitURL = strings.TrimSuffix(strings.TrimSpace(parts[1]), `"`)
-				break
-			}
-		}
-	}
-
-	if gitURL == "" {
-		return fmt.Errorf("plugin source URL not found in config.json")
-	}
-
-	// Clone to temporary directory and overlay files (works for both git and non-git)
-	tempDir := pluginDir + ".tmp"
-	defer os.RemoveAll(tempDir)
-
-	// Clone to temp directory
-	if err := pm.cloneRepository(gitURL, tempDir); err != nil {
-		return fmt.Errorf("failed to clone repository: %v", err)
-	}
-
-	// Remove .git directory from temp to avoid conflicts
-	gitDir := filepath.Join(tempDir, ".git")
-	os.RemoveAll(gitDir)
-
-	// Copy all files from temp to plugin directory, overwriting existing
-	if err := pm.overlayDirectory(tempDir, pluginDir); err != nil {
-		return fmt.Errorf("failed to overlay files: %v", err)
-	}
-
-	// Recreate plugin config.json (overwrite)
-	if err := pm.createPluginConfig(pluginDir, pluginName, gitURL); err != nil {
-		return fmt.Errorf("failed to create plugin config: %v", err)
-	}
-
-	// Make scripts executable
-	if err := pm.makeScriptsExecutable(pluginDir); err != nil {
-		return fmt.Errorf("failed to make scripts executable: %v", err)
+		return err
 	}
 
-	return nil
-}
-
-// overlayDirectory copies all files and directories recursively from source to destination, overwriting existing files
-func (pm *PluginManager) overlayDirectory(srcDir, dstDir string) error {
-	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return fmt.Errorf("error accessing path %s: %v", path, err)
-		}
-
-		// Calculate relative path from source
-		relPath, err := filepath.Rel(srcDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to calculate relative path: %v", err)
-		}
-
-		// Skip if it's the source directory itself
-		if relPath == "." {
-			return nil
-		}
-
-		dstPath := filepath.Join(dstDir, relPath)
-
-		if info.IsDir() {
-			// Create directory with same permissions, including all parent directories
-			return os.MkdirAll(dstPath, info.Mode())
-		}
-
-		// Ensure parent directory exists
-		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
-			return fmt.Errorf("failed to create parent directory for %s: %v", dstPath, err)
-		}
-
-		// Read source file
-		srcData, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read source file %s: %v", path, err)
-		}
-
-		// Write file, overwriting if exists, preserving original permissions
-		if err := os.WriteFile(dstPath, srcData, info.Mode()); err != nil {
-			return fmt.Errorf("failed to write file %s: %v", dstPath, err)
-		}
-
-		return nil
-	})
+	configPath := filepath.Join(pluginDir, "config.json")
+	return os.WriteFile(configPath, data, 0644)
 }
 
 func (pm *PluginManager) afterInstallation(pluginDir string) error {
@@ -1,10 +1,15 @@
 package plugins
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"gokku/internal"
+
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/suite"
 )
@@ -20,7 +25,7 @@ func TestPluginManagerTestSuite(t *testing.T) {
 }
 
 func (s *PluginManagerTestSuite) SetupTest() {
-	s.manager = NewPluginManager()
+	s.manager = NewPluginManager("")
 
 	tempDir, err := os.MkdirTemp("", "gokku-test-*")
 	s.Require().NoError(err)
@@ -55,3 +60,308 @@ func (s *PluginManagerTestSuite) TestListPlugins_WhenPluginsAreInstalled() {
 	Expect(plugins).To(ContainElement("nginx"))
 	Expect(plugins).To(ContainElement("letsencrypt"))
 }
+
+func (s *PluginManagerTestSuite) writeManifest(pluginName, manifest string) string {
+	pluginDir := filepath.Join(s.manager.pluginsDir, pluginName)
+	s.Require().NoError(os.MkdirAll(pluginDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(pluginDir, "plugin.yml"), []byte(manifest), 0644))
+
+	return pluginDir
+}
+
+func (s *PluginManagerTestSuite) TestCheckCompatibility_ReportsAllProblems() {
+	internal.Version = "1.0.50"
+	defer func() { internal.Version = "dev" }()
+
+	s.manager.lookPath = func(file string) (string, error) {
+		if file == "docker" {
+			return "/usr/bin/docker", nil
+		}
+
+		return "", errors.New("not found")
+	}
+
+	s.writeManifest("nginx", "name: nginx\nversion: 1.1.0\n")
+	pluginDir := s.writeManifest("letsencrypt", `
+name: letsencrypt
+version: 2.0.0
+gokku: ">=1.0.100"
+requires: [docker, certbot]
+dependencies:
+  nginx: ">=2.0"
+  cron: ""
+`)
+
+	err := s.manager.CheckCompatibility(pluginDir)
+
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("requires gokku >=1.0.100 (running 1.0.50)"))
+	Expect(err.Error()).To(ContainSubstring("requires 'certbot' on the host"))
+	Expect(err.Error()).To(ContainSubstring("depends on plugin 'nginx' >=2.0 (installed 1.1.0)"))
+	Expect(err.Error()).To(ContainSubstring("depends on plugin 'cron', install it first"))
+	Expect(err.Error()).ToNot(ContainSubstring("docker"))
+}
+
+func (s *PluginManagerTestSuite) TestCheckCompatibility_WithoutManifest() {
+	pluginDir := filepath.Join(s.manager.pluginsDir, "legacy")
+	s.Require().NoError(os.MkdirAll(pluginDir, 0755))
+
+	Expect(s.manager.CheckCompatibility(pluginDir)).To(Succeed())
+}
+
+func (s *PluginManagerTestSuite) TestPluginConfig_RoundTripsWithManifestVersion() {
+	pluginDir := s.writeManifest("redis", "name: redis\nversion: 3.2.1\ndescription: Redis key-value store\n")
+
+	s.Require().NoError(s.manager.createPluginConfig(pluginDir, "redis", "https://github.com/gokku-vm/gokku-redis"))
+
+	config, err := s.manager.readPluginConfig(pluginDir)
+
+	Expect(err).To(BeNil())
+	Expect(config).To(Equal(PluginConfig{Name: "redis", URL: "https://github.com/gokku-vm/gokku-redis", Version: "3.2.1"}))
+
+	infos, err := s.manager.ListPluginInfo()
+
+	Expect(err).To(BeNil())
+	Expect(infos).To(Equal([]PluginInfo{{Name: "redis", Version: "3.2.1", Description: "Redis key-value store", URL: "https://github.com/gokku-vm/gokku-redis"}}))
+}
+
+// newPluginRepo creates a git repository with a v1.0.0 and a v2.0.0 tag
+func (s *PluginManagerTestSuite) newPluginRepo() string {
+	repo := s.T().TempDir()
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		output, err := cmd.CombinedOutput()
+		s.Require().NoError(err, string(output))
+	}
+
+	git("init", "--quiet")
+
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		manifest := "name: echo\nversion: " + version + "\n"
+		s.Require().NoError(os.WriteFile(filepath.Join(repo, "plugin.yml"), []byte(manifest), 0644))
+
+		if version == "1.0.0" {
+			s.Require().NoError(os.WriteFile(filepath.Join(repo, "legacy"), []byte("removed in 2.0.0"), 0644))
+		} else {
+			s.Require().NoError(os.Remove(filepath.Join(repo, "legacy")))
+		}
+
+		git("add", "-A")
+		git("commit", "--quiet", "-m", version)
+		git("tag", "v"+version)
+	}
+
+	return repo
+}
+
+func (s *PluginManagerTestSuite) TestInstallUpdateAndRollback_WithPinnedRefs() {
+	repo := s.newPluginRepo()
+	pluginDir := filepath.Join(s.manager.pluginsDir, "echo")
+
+	installed, err := s.manager.InstallPluginFromGit(repo, "echo", "v1.0.0")
+
+	Expect(err).To(BeNil())
+	Expect(installed.Version).To(Equal("1.0.0"))
+	Expect(installed.Commit).To(HaveLen(40))
+	Expect(filepath.Join(pluginDir, "legacy")).To(BeAnExistingFile())
+
+	updated, err := s.manager.UpdatePlugin("echo", "v2.0.0")
+
+	Expect(err).To(BeNil())
+	Expect(updated.Version).To(Equal("2.0.0"))
+	Expect(updated.Previous.Commit).To(Equal(installed.Commit))
+	Expect(filepath.Join(pluginDir, "legacy")).ToNot(BeAnExistingFile())
+
+	// Updating again follows the pin and changes nothing
+	again, err := s.manager.UpdatePlugin("echo", "")
+
+	Expect(err).To(BeNil())
+	Expect(again.Commit).To(Equal(updated.Commit))
+
+	rolledBack, err := s.manager.RollbackPlugin("echo")
+
+	Expect(err).To(BeNil())
+	Expect(rolledBack.Version).To(Equal("1.0.0"))
+	Expect(rolledBack.Ref).To(Equal("v1.0.0"))
+	Expect(filepath.Join(pluginDir, "legacy")).To(BeAnExistingFile())
+
+	lock, err := s.manager.ReadLock()
+
+	Expect(err).To(BeNil())
+	Expect(lock.Plugins["echo"].Commit).To(Equal(installed.Commit))
+	Expect(lock.Plugins["echo"].Previous.Commit).To(Equal(updated.Commit))
+
+	plugins, _ := s.manager.ListPlugins()
+	Expect(plugins).To(Equal([]string{"echo"}))
+}
+
+func (s *PluginManagerTestSuite) TestInstallPlugin_AtCommit() {
+	repo := s.newPluginRepo()
+
+	output, err := exec.Command("git", "-C", repo, "rev-parse", "v1.0.0").Output()
+	s.Require().NoError(err)
+
+	commit := strings.TrimSpace(string(output))
+	entry, err := s.manager.InstallPluginFromGit(repo, "echo", commit[:10])
+
+	Expect(err).To(BeNil())
+	Expect(entry.Commit).To(Equal(commit))
+	Expect(entry.Version).To(Equal("1.0.0"))
+}
+
+func (s *PluginManagerTestSuite) TestRemovePlugin_DropsLockEntry() {
+	repo := s.newPluginRepo()
+
+	_, err := s.manager.InstallPluginFromGit(repo, "echo", "")
+	s.Require().NoError(err)
+
+	Expect(s.manager.RemovePlugin("echo")).To(Succeed())
+
+	lock, err := s.manager.ReadLock()
+
+	Expect(err).To(BeNil())
+	Expect(lock.Plugins).To(BeEmpty())
+}
+
+func (s *PluginManagerTestSuite) TestRollbackPlugin_WithoutPreviousVersion() {
+	_, err := s.manager.RollbackPlugin("echo")
+
+	Expect(err).To(MatchError("no previous version of plugin 'echo' to roll back to"))
+}
+
+func (s *PluginManagerTestSuite) TestParsePluginRef() {
+	name, ref := ParsePluginRef("postgres@v1.2.0")
+	Expect(name).To(Equal("postgres"))
+	Expect(ref).To(Equal("v1.2.0"))
+
+	name, ref = ParsePluginRef("redis")
+	Expect(name).To(Equal("redis"))
+	Expect(ref).To(BeEmpty())
+}
+
+func (s *PluginManagerTestSuite) TestIsValidGitURL_AcceptsAnyGitServer() {
+	Expect(s.manager.IsValidGitURL("https://git.example.com/ops/gokku-redis.git")).To(BeTrue())
+	Expect(s.manager.IsValidGitURL("git@gitea.internal:ops/gokku-redis.git")).To(BeTrue())
+	Expect(s.manager.IsValidGitURL("ssh://git@gitea.internal:2222/ops/gokku-redis.git")).To(BeTrue())
+	Expect(s.manager.IsValidGitURL("file:///srv/git/gokku-redis.git")).To(BeTrue())
+	Expect(s.manager.IsValidGitURL("gokku-redis")).To(BeFalse())
+	Expect(s.manager.IsValidGitURL("https://")).To(BeFalse())
+}
+
+func (s *PluginManagerTestSuite) TestScaffoldPlugin() {
+	dir := filepath.Join(s.T().TempDir(), "gokku-cache")
+
+	files, err := ScaffoldPlugin(dir, "cache")
+
+	Expect(err).To(BeNil())
+	Expect(files).To(ContainElements("plugin.yml", "install", "uninstall", "commands/help", "commands/info", "commands/logs", "tests/lifecycle"))
+
+	manifest, err := internal.LoadPluginManifest(dir)
+	Expect(err).To(BeNil())
+	Expect(manifest.Name).To(Equal("cache"))
+
+	info, err := os.Stat(filepath.Join(dir, "tests", "lifecycle"))
+	Expect(err).To(BeNil())
+	Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+	help, err := os.ReadFile(filepath.Join(dir, "commands", "help"))
+	Expect(err).To(BeNil())
+	Expect(string(help)).To(ContainSubstring("gokku cache:info <service>"))
+
+	_, err = ScaffoldPlugin(dir, "cache")
+	Expect(err).To(MatchError(ContainSubstring("already exists and is not empty")))
+
+	_, err = ScaffoldPlugin(s.T().TempDir(), "My Cache")
+	Expect(err).To(MatchError(ContainSubstring("invalid plugin name 'My Cache'")))
+}
+
+func (s *PluginManagerTestSuite) TestInstallLocalPlugin_LinksDirectory() {
+	source := filepath.Join(s.T().TempDir(), "gokku-cache")
+	_, err := ScaffoldPlugin(source, "cache")
+	s.Require().NoError(err)
+
+	Expect(IsLocalPluginSource("./gokku-cache")).To(BeTrue())
+	Expect(IsLocalPluginSource("file://" + source)).To(BeTrue())
+	Expect(IsLocalPluginSource("cache")).To(BeFalse())
+	Expect(s.manager.LocalPluginName(source)).To(Equal("cache"))
+
+	s.manager.lookPath = func(file string) (string, error) {
+		return "/usr/bin/" + file, nil
+	}
+
+	entry, err := s.manager.InstallLocalPlugin(source, "cache")
+
+	Expect(err).To(BeNil())
+	Expect(entry.Linked).To(BeTrue())
+	Expect(entry.URL).To(Equal("file://" + source))
+	Expect(entry.Version).To(Equal("0.1.0"))
+	Expect(s.manager.IsLinked("cache")).To(BeTrue())
+
+	plugins, err := s.manager.ListPlugins()
+	Expect(err).To(BeNil())
+	Expect(plugins).To(ContainElement("cache"))
+
+	// Edits to the source apply without reinstalling
+	s.Require().NoError(os.WriteFile(filepath.Join(source, "commands", "backup"), []byte("#!/bin/bash\n"), 0755))
+	Expect(s.manager.CommandExists("cache", "backup")).To(BeTrue())
+
+	_, err = s.manager.UpdatePlugin("cache", "")
+	Expect(err).To(MatchError(ContainSubstring("is linked to a local directory")))
+
+	// Removing unlinks the plugin and leaves the source alone
+	Expect(s.manager.RemovePlugin("cache")).To(Succeed())
+	Expect(s.manager.PluginExists("cache")).To(BeFalse())
+	Expect(filepath.Join(source, "plugin.yml")).To(BeAnExistingFile())
+}
+
+func (s *PluginManagerTestSuite) TestPluginTestHarness_RunsScaffoldedPlugin() {
+	source := filepath.Join(s.T().TempDir(), "gokku-cache")
+	_, err := ScaffoldPlugin(source, "cache")
+	s.Require().NoError(err)
+
+	var out strings.Builder
+
+	harness, err := NewPluginTestHarness(source, &out)
+	s.Require().NoError(err)
+	defer harness.Cleanup()
+
+	report, err := harness.Run()
+
+	Expect(err).To(BeNil())
+	Expect(report.Passed()).To(BeTrue(), out.String())
+	Expect(report.Plugin).To(Equal("cache"))
+
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+	}
+
+	Expect(names).To(Equal([]string{"install", "info", "status", "uninstall", "tests/lifecycle"}))
+	Expect(report.DockerCalls).To(ContainElement("run -d --name cache-test --label gokku.service=cache-test --restart unless-stopped alpine:3 sleep infinity"))
+	Expect(out.String()).To(ContainSubstring("Status: running"))
+	Expect(os.Getenv("GOKKU_BASE_DIR")).NotTo(Equal(harness.BaseDir()))
+}
+
+func (s *PluginManagerTestSuite) TestPluginTestHarness_ReportsFailingSteps() {
+	source := filepath.Join(s.T().TempDir(), "gokku-broken")
+	_, err := ScaffoldPlugin(source, "broken")
+	s.Require().NoError(err)
+
+	s.Require().NoError(os.WriteFile(filepath.Join(source, "commands", "info"), []byte("#!/bin/bash\nexit 2\n"), 0755))
+	s.Require().NoError(os.RemoveAll(filepath.Join(source, "tests")))
+
+	harness, err := NewPluginTestHarness(source, &strings.Builder{})
+	s.Require().NoError(err)
+	defer harness.Cleanup()
+
+	report, err := harness.Run()
+
+	Expect(err).To(BeNil())
+	Expect(report.Passed()).To(BeFalse())
+	Expect(report.Steps).To(HaveLen(4))
+	Expect(report.Steps[1].Err).To(MatchError("exit status 2"))
+	Expect(report.Steps[2].Err).To(BeNil())
+	Expect(report.Steps[3].Err).To(BeNil())
+}
@@ -0,0 +1,144 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gokku/internal"
+)
+
+// PluginConfig is the config.json gokku writes next to an installed plugin
+type PluginConfig struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Version string `json:"version,omitempty"`
+}
+
+// PluginInfo is an installed plugin as shown by plugins:list
+type PluginInfo struct {
+	Name        string
+	Version     string
+	Description string
+	URL         string
+	Ref         string
+	Commit      string
+	Linked      bool
+}
+
+// GetManifest returns the manifest of an installed plugin, or nil when it ships none
+func (pm *PluginManager) GetManifest(pluginName string) (*internal.PluginManifest, error) {
+	return internal.LoadPluginManifest(filepath.Join(pm.pluginsDir, pluginName))
+}
+
+// ListPluginInfo returns the installed plugins with their manifest details
+func (pm *PluginManager) ListPluginInfo() ([]PluginInfo, error) {
+	names, err := pm.ListPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := pm.ReadLock()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]PluginInfo, 0, len(names))
+
+	for _, name := range names {
+		entry := lock.Plugins[name]
+		info := PluginInfo{Name: name, Ref: entry.Ref, Commit: entry.Commit, Linked: pm.IsLinked(name)}
+
+		if config, err := pm.readPluginConfig(filepath.Join(pm.pluginsDir, name)); err == nil {
+			info.URL = config.URL
+			info.Version = config.Version
+		}
+
+		manifest, err := pm.GetManifest(name)
+		if err != nil {
+			info.Description = err.Error()
+		} else if manifest != nil {
+			info.Version = manifest.Version
+			info.Description = manifest.Description
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// CheckCompatibility checks the plugin in pluginDir can run on this server:
+// the gokku version is in range, required binaries are on the PATH and the
+// plugins it depends on are installed in a matching version. All problems
+// are reported at once.
+func (pm *PluginManager) CheckCompatibility(pluginDir string) error {
+	manifest, err := internal.LoadPluginManifest(pluginDir)
+	if err != nil {
+		return err
+	}
+
+	if manifest == nil {
+		return nil
+	}
+
+	var problems []string
+
+	constraint, _ := internal.ParseVersionConstraint(manifest.Gokku)
+	if !constraint.Allows(internal.Version) {
+		problems = append(problems, fmt.Sprintf("requires gokku %s (running %s)", manifest.Gokku, internal.Version))
+	}
+
+	for _, binary := range manifest.Requires {
+		if _, err := pm.lookPath(binary); err != nil {
+			problems = append(problems, fmt.Sprintf("requires '%s' on the host", binary))
+		}
+	}
+
+	for name, versions := range manifest.Dependencies {
+		if !pm.pluginExists(name) {
+			problems = append(problems, fmt.Sprintf("depends on plugin '%s', install it first", name))
+			continue
+		}
+
+		dependency, err := pm.GetManifest(name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("depends on plugin '%s': %v", name, err))
+			continue
+		}
+
+		installed := ""
+		if dependency != nil {
+			installed = dependency.Version
+		}
+
+		constraint, _ := internal.ParseVersionConstraint(versions)
+		if !constraint.Allows(installed) {
+			problems = append(problems, fmt.Sprintf("depends on plugin '%s' %s (installed %s)", name, versions, installed))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("plugin is not compatible: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// readPluginConfig reads the config.json of the plugin in pluginDir
+func (pm *PluginManager) readPluginConfig(pluginDir string) (PluginConfig, error) {
+	var config PluginConfig
+
+	data, err := os.ReadFile(filepath.Join(pluginDir, "config.json"))
+	if err != nil {
+		return config, fmt.Errorf("failed to read plugin config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse plugin config: %v", err)
+	}
+
+	return config, nil
+}
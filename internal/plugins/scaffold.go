@@ -0,0 +1,182 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pluginNamePattern matches the names gokku <plugin>:<command> can route to
+var pluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// scaffoldFile is a file plugins:new writes, with {{name}} replaced by the
+// plugin name
+type scaffoldFile struct {
+	path       string
+	executable bool
+	content    string
+}
+
+// scriptPrelude loads the plugin helpers in every scaffolded script
+const scriptPrelude = `#!/bin/bash
+set -euo pipefail
+
+HELPERS="${GOKKU_BASE_DIR:-/opt/gokku}/scripts/plugin-helpers.sh"
+[[ -f "$HELPERS" ]] && source "$HELPERS"
+`
+
+var scaffoldFiles = []scaffoldFile{
+	{path: "plugin.yml", content: `name: {{name}}
+version: 0.1.0
+description: {{name}} service for gokku apps
+requires: [docker]
+commands:
+  - name: info
+    description: Show service information
+  - name: logs
+    description: Show service logs
+`},
+	{path: "README.md", content: "# gokku-{{name}}\n\n" +
+		"A [gokku](https://github.com/thadeu/gokku) plugin providing {{name}} services.\n\n" +
+		"## Install\n\n" +
+		"```bash\n" +
+		"gokku plugins:add ./gokku-{{name}}        # link while developing\n" +
+		"gokku plugins:add {{name}} <git-url>      # install a published version\n" +
+		"```\n\n" +
+		"## Usage\n\n" +
+		"```bash\n" +
+		"gokku services:create {{name}} --name {{name}}-main\n" +
+		"gokku {{name}}:info {{name}}-main\n" +
+		"gokku {{name}}:logs {{name}}-main\n" +
+		"```\n\n" +
+		"## Test\n\n" +
+		"```bash\n" +
+		"tests/lifecycle\n" +
+		"```\n"},
+	{path: "install", executable: true, content: scriptPrelude + `
+SERVICE_NAME="$1"
+
+echo "-----> Installing {{name}} service: $SERVICE_NAME"
+
+# Replace with the image and options of your service. The gokku.service
+# label lets services:status, start, stop and restart find the container.
+docker run -d --name "$SERVICE_NAME" --label gokku.service="$SERVICE_NAME" --restart unless-stopped alpine:3 sleep infinity >/dev/null
+
+echo "-----> {{name}} service installed successfully"
+`},
+	{path: "uninstall", executable: true, content: scriptPrelude + `
+SERVICE_NAME="$1"
+
+echo "-----> Uninstalling {{name}} service: $SERVICE_NAME"
+
+docker rm -f "$SERVICE_NAME" >/dev/null 2>&1 || true
+
+echo "-----> {{name}} service uninstalled successfully"
+`},
+	{path: "commands/name", executable: true, content: `#!/bin/bash
+echo "{{name}}"
+`},
+	{path: "commands/help", executable: true, content: `#!/bin/bash
+cat << EOF
+{{name}} plugin for Gokku
+
+Commands:
+  gokku {{name}}:info <service>    Show service information
+  gokku {{name}}:logs <service>    Show service logs
+EOF
+`},
+	{path: "commands/info", executable: true, content: scriptPrelude + `
+SERVICE_NAME="$1"
+
+echo "{{name}} Service: $SERVICE_NAME"
+echo "================================"
+
+if ! docker inspect "$SERVICE_NAME" >/dev/null 2>&1; then
+    echo "Status: NOT FOUND"
+    exit 0
+fi
+
+echo "Status: $(docker inspect --format '{{.State.Status}}' "$SERVICE_NAME")"
+`},
+	{path: "commands/logs", executable: true, content: scriptPrelude + `
+SERVICE_NAME="$1"
+
+if ! docker inspect "$SERVICE_NAME" >/dev/null 2>&1; then
+    echo "Service '$SERVICE_NAME' not found"
+    exit 1
+fi
+
+docker logs --tail 100 "$SERVICE_NAME"
+`},
+	{path: "tests/lifecycle", executable: true, content: `#!/bin/bash
+# Installs a service, checks its commands and uninstalls it again.
+# Exits non-zero on the first failing step.
+set -euo pipefail
+
+PLUGIN_DIR="$(cd "$(dirname "$0")/.." && pwd)"
+SERVICE_NAME="{{name}}-test-$$"
+
+step() {
+    echo "-----> $1"
+    shift
+    "$@"
+}
+
+trap 'bash "$PLUGIN_DIR/uninstall" "$SERVICE_NAME" >/dev/null 2>&1 || true' EXIT
+
+step "install" bash "$PLUGIN_DIR/install" "$SERVICE_NAME"
+step "info" bash "$PLUGIN_DIR/commands/info" "$SERVICE_NAME"
+step "logs" bash "$PLUGIN_DIR/commands/logs" "$SERVICE_NAME"
+step "uninstall" bash "$PLUGIN_DIR/uninstall" "$SERVICE_NAME"
+
+echo "✓ lifecycle passed"
+`},
+}
+
+// ValidatePluginName checks name can be installed and routed to
+func ValidatePluginName(name string) error {
+	if !pluginNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid plugin name '%s': use lowercase letters, digits and dashes", name)
+	}
+
+	return nil
+}
+
+// ScaffoldPlugin writes a new plugin following the plugin contract into
+// dir, which must not exist or be empty. It returns the files it wrote.
+func ScaffoldPlugin(dir, name string) ([]string, error) {
+	if err := ValidatePluginName(name); err != nil {
+		return nil, err
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("directory '%s' already exists and is not empty", dir)
+	}
+
+	var written []string
+
+	for _, file := range scaffoldFiles {
+		path := filepath.Join(dir, file.path)
+		mode := os.FileMode(0644)
+
+		if file.executable {
+			mode = 0755
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return written, fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+
+		content := strings.ReplaceAll(file.content, "{{name}}", name)
+
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			return written, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+
+		written = append(written, file.path)
+	}
+
+	return written, nil
+}
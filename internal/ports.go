@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"gokku/internal/containers"
+)
+
+// Port strategies accepted by port_strategy in gokku.yml
+const (
+	PortStrategyManual = "manual"
+	PortStrategyAuto   = "auto"
+)
+
+// DefaultProcessType is the process name used for the main app container
+const DefaultProcessType = "web"
+
+// applyAutoPort maps a host port allocated to the app's process when the app
+// uses port_strategy: auto. It reports whether the strategy applied.
+//
+// When the app has no PORT of its own, the allocated port is used inside the
+// container too and exposed to the app as PORT.
+func applyAutoPort(containerConfig *ContainerConfig, appName, process string, containerPort int) (bool, error) {
+	serverConfig, err := LoadServerConfigByApp(appName)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to load port strategy for %s: %v", appName, err)
+	}
+
+	if serverConfig.PortStrategy != PortStrategyAuto {
+		return false, nil
+	}
+
+	if process == "" {
+		process = DefaultProcessType
+	}
+
+	hostPort, err := containers.NewPortLedger("").Allocate(appName, process, serverConfig.BasePort)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to allocate port for %s %s: %v", appName, process, err)
+	}
+
+	if containerPort == 0 {
+		if port, err := strconv.Atoi(containerConfig.Env["PORT"]); err == nil {
+			containerPort = port
+		}
+	}
+
+	if containerPort == 0 {
+		containerPort = hostPort
+
+		env := make(map[string]string, len(containerConfig.Env)+1)
+
+		for key, value := range containerConfig.Env {
+			env[key] = value
+		}
+
+		env["PORT"] = strconv.Itoa(hostPort)
+		containerConfig.Env = env
+	}
+
+	containerConfig.Ports = []string{fmt.Sprintf("%d:%d", hostPort, containerPort)}
+
+	return true, nil
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the $id of the published gokku.yml schema
+const SchemaID = "https://github.com/thadeu/gokku/raw/main/gokku.schema.json"
+
+// JSONSchema is the subset of JSON Schema (draft 2020-12) used to describe gokku.yml
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Maximum              *int                   `json:"maximum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	PatternProperties    map[string]*JSONSchema `json:"patternProperties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// schemaConstraint holds validation rules for a field, keyed by "Type.Field"
+type schemaConstraint struct {
+	enum     []string
+	pattern  string
+	minimum  *int
+	maximum  *int
+	required bool
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// schemaConstraints are the rules shared by the schema and ValidateConfig
+var schemaConstraints = map[string]schemaConstraint{
+	"ServerConfig.Apps":         {required: true},
+	"ServerConfig.PortStrategy": {enum: SupportedPortStrategies},
+	"ServerConfig.BasePort":     {minimum: intPtr(1), maximum: intPtr(65535)},
+	"App.Lang":                  {enum: SupportedLangs},
+	"Deployment.KeepReleases":   {minimum: intPtr(0)},
+	"Deployment.KeepImages":     {minimum: intPtr(0)},
+	"Deployment.RestartDelay":   {minimum: intPtr(0)},
+	"Deployment.RestartPolicy":  {pattern: `^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`},
+	"NetworkConfig.Mode":        {pattern: `^(bridge|host|none|container:.+|[a-zA-Z0-9][a-zA-Z0-9_.-]*)$`},
+	"Environment.Name":          {required: true, pattern: environmentNamePattern.String()},
+	"CronJob.Name":              {pattern: environmentNamePattern.String()},
+	"CronJob.Schedule":          {required: true},
+	"CronJob.Command":           {required: true},
+	"CronJob.Timeout":           {pattern: `^([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+$`},
+	"LoggingConfig.MaxSize":     {pattern: logSizePattern.String()},
+	"LoggingConfig.MaxFile":     {minimum: intPtr(1)},
+	"LogSink.Type":              {required: true, enum: SupportedLogSinks},
+	"LogSink.Protocol":          {enum: []string{"udp", "tcp"}},
+	"LogSink.BatchSize":         {minimum: intPtr(1)},
+	"LogSink.FlushInterval":     {pattern: `^([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+$`},
+	"DeployWatch.Window":        {pattern: `^(0|([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+)$`},
+	"DeployWatch.MaxRestarts":   {minimum: intPtr(1)},
+	"NotificationTarget.Type":   {required: true, enum: SupportedNotificationTypes},
+	"NotificationTarget.Port":   {minimum: intPtr(1), maximum: intPtr(65535)},
+}
+
+// GenerateSchema builds the JSON Schema for gokku.yml from the config types
+func GenerateSchema() *JSONSchema {
+	defs := make(map[string]*JSONSchema)
+	root := structSchema(reflect.TypeOf(ServerConfig{}), defs)
+
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.ID = SchemaID
+	root.Title = "gokku.yml"
+	root.Description = "Gokku application configuration"
+	root.Defs = defs
+
+	// Top-level x- keys are free-form, e.g. to hold YAML anchors
+	root.PatternProperties = map[string]*JSONSchema{"^x-": {}}
+
+	// Every app needs either a path to build or an image to run
+	defs["App"].AnyOf = []*JSONSchema{
+		{Required: []string{"path"}},
+		{Required: []string{"image"}},
+	}
+
+	return root
+}
+
+// MarshalSchema returns the indented JSON for the gokku.yml schema
+func MarshalSchema() ([]byte, error) {
+	data, err := json.MarshalIndent(GenerateSchema(), "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// structSchema describes a struct's yaml fields, registering nested structs in defs
+func structSchema(t reflect.Type, defs map[string]*JSONSchema) *JSONSchema {
+	schema := &JSONSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*JSONSchema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		options := strings.Split(field.Tag.Get("yaml"), ",")
+		name := options[0]
+
+		// Inline structs contribute their fields directly
+		if field.Anonymous && len(options) > 1 && options[1] == "inline" {
+			for property, inlined := range structSchema(field.Type, defs).Properties {
+				schema.Properties[property] = inlined
+			}
+
+			continue
+		}
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := typeSchema(field.Type, defs)
+		property.Description = field.Tag.Get("doc")
+
+		if constraint, ok := schemaConstraints[t.Name()+"."+field.Name]; ok {
+			property.Enum = constraint.enum
+			property.Pattern = constraint.pattern
+			property.Minimum = constraint.minimum
+			property.Maximum = constraint.maximum
+
+			if constraint.required {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// typeSchema maps a Go type to its schema
+func typeSchema(t reflect.Type, defs map[string]*JSONSchema) *JSONSchema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), defs)
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Slice:
+		return &JSONSchema{Type: "array", Items: typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			defs[t.Name()] = nil
+			defs[t.Name()] = structSchema(t, defs)
+		}
+
+		return &JSONSchema{Ref: "#/$defs/" + t.Name()}
+	}
+
+	return &JSONSchema{}
+}
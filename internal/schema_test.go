@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaTestSuite struct {
+	suite.Suite
+}
+
+func TestSchemaTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(SchemaTestSuite))
+}
+
+func (s *SchemaTestSuite) TestPublishedSchema_IsInSync() {
+	expected, err := MarshalSchema()
+	Expect(err).To(BeNil())
+
+	published, err := os.ReadFile("../gokku.schema.json")
+	Expect(err).To(BeNil())
+
+	Expect(string(published)).To(Equal(string(expected)), "gokku.schema.json is stale, run: go run ./cmd/cli tool schema > gokku.schema.json")
+}
+
+func (s *SchemaTestSuite) TestGenerateSchema_DescribesEveryField() {
+	schema := GenerateSchema()
+
+	for name, def := range schema.Defs {
+		for property, field := range def.Properties {
+			Expect(field.Description).ToNot(BeEmpty(), "%s.%s has no doc tag", name, property)
+		}
+	}
+}
+
+func (s *SchemaTestSuite) TestGenerateSchema_RejectsUnknownKeys() {
+	schema := GenerateSchema()
+
+	Expect(schema.AdditionalProperties).To(Equal(false))
+	Expect(schema.Defs["App"].AdditionalProperties).To(Equal(false))
+	Expect(schema.Defs["Deployment"].AdditionalProperties).To(Equal(false))
+}
+
+func (s *SchemaTestSuite) TestGenerateSchema_WithEnumsAndRequired() {
+	schema := GenerateSchema()
+
+	Expect(schema.Required).To(Equal([]string{"apps"}))
+	Expect(schema.Defs["App"].Properties["lang"].Enum).To(Equal(SupportedLangs))
+	Expect(schema.Defs["Environment"].Required).To(Equal([]string{"name"}))
+	Expect(schema.Defs["App"].AnyOf).To(HaveLen(2))
+}
+
+func (s *SchemaTestSuite) TestSchemaConstraints_ReferenceExistingFields() {
+	types := map[string]reflect.Type{
+		"ServerConfig":       reflect.TypeOf(ServerConfig{}),
+		"App":                reflect.TypeOf(App{}),
+		"CronJob":            reflect.TypeOf(CronJob{}),
+		"Deployment":         reflect.TypeOf(Deployment{}),
+		"DeployWatch":        reflect.TypeOf(DeployWatch{}),
+		"Environment":        reflect.TypeOf(Environment{}),
+		"LoggingConfig":      reflect.TypeOf(LoggingConfig{}),
+		"LogSink":            reflect.TypeOf(LogSink{}),
+		"NetworkConfig":      reflect.TypeOf(NetworkConfig{}),
+		"NotificationTarget": reflect.TypeOf(NotificationTarget{}),
+	}
+
+	for key := range schemaConstraints {
+		typeName, fieldName, _ := strings.Cut(key, ".")
+
+		t, ok := types[typeName]
+		Expect(ok).To(BeTrue(), "unknown type in constraint %s", key)
+
+		_, ok = t.FieldByName(fieldName)
+		Expect(ok).To(BeTrue(), "unknown field in constraint %s", key)
+	}
+}
@@ -0,0 +1,355 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gokku/pluginsdk"
+)
+
+// PluginEnvCommand prints the env vars of a service as KEY=VALUE lines
+const PluginEnvCommand = "env"
+
+// ServiceContainerPortKey is the service config key of the port the service
+// listens on inside its container. Apps on the service's network connect to
+// it there instead of to the port published on the host.
+const ServiceContainerPortKey = "container_port"
+
+// envTemplatePattern matches {{key}} placeholders in env templates
+var envTemplatePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// envAliasPattern is what a link alias must look like once upper-cased
+var envAliasPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// ServiceEnvContract declares the env vars a plugin's services expose to
+// linked apps, as templates over the service config. Plugins ship it in the
+// env section of their manifest:
+//
+//	env:
+//	  DATABASE_URL: postgres://{{user}}:{{password}}@{{host}}:{{port}}/{{database}}
+//	  POSTGRES_HOST: "{{host}}"
+//
+// {{name}} is the service name and {{host}} defaults to localhost. For apps
+// on the service's network {{host}} is the service's DNS alias, see
+// NetworkServiceConfig.
+type ServiceEnvContract struct {
+	Env map[string]string `yaml:"env"`
+}
+
+// builtinEnvContracts keep postgres and redis plugins that predate
+// plugin.yml working
+var builtinEnvContracts = map[string]ServiceEnvContract{
+	"postgres": {Env: map[string]string{
+		"DATABASE_URL":      "postgres://{{user}}:{{password}}@{{host}}:{{port}}/{{database}}",
+		"POSTGRES_HOST":     "{{host}}",
+		"POSTGRES_PORT":     "{{port}}",
+		"POSTGRES_USER":     "{{user}}",
+		"POSTGRES_PASSWORD": "{{password}}",
+		"POSTGRES_DB":       "{{database}}",
+	}},
+	"redis": {Env: map[string]string{
+		"REDIS_URL":      "redis://:{{password}}@{{host}}:{{port}}",
+		"REDIS_HOST":     "{{host}}",
+		"REDIS_PORT":     "{{port}}",
+		"REDIS_PASSWORD": "{{password}}",
+	}},
+}
+
+// LoadServiceEnvContract reads the env contract from the manifest of a
+// plugin, falling back to the built-in contract. It returns nil when the
+// plugin declares no env vars.
+func LoadServiceEnvContract(pluginsDir, pluginName string) (*ServiceEnvContract, error) {
+	manifest, err := LoadPluginManifest(filepath.Join(pluginsDir, pluginName))
+
+	if err != nil {
+		return nil, fmt.Errorf("plugin '%s': %v", pluginName, err)
+	}
+
+	if manifest != nil && len(manifest.Env) > 0 {
+		return &ServiceEnvContract{Env: manifest.Env}, nil
+	}
+
+	if contract, ok := builtinEnvContracts[pluginName]; ok {
+		return &contract, nil
+	}
+
+	return nil, nil
+}
+
+// Expand renders the contract for a service. Variables whose template
+// references a config key the service does not have yet are left out.
+func (c *ServiceEnvContract) Expand(serviceName string, config map[string]string) map[string]string {
+	envVars := make(map[string]string)
+
+	if c == nil {
+		return envVars
+	}
+
+	values := map[string]string{"name": serviceName, "host": "localhost"}
+
+	for key, value := range config {
+		values[key] = value
+	}
+
+	for key, template := range c.Env {
+		complete := true
+
+		value := envTemplatePattern.ReplaceAllStringFunc(template, func(match string) string {
+			placeholder := envTemplatePattern.FindStringSubmatch(match)[1]
+			value, ok := values[placeholder]
+
+			if !ok || value == "" {
+				complete = false
+			}
+
+			return value
+		})
+
+		if complete {
+			envVars[key] = value
+		}
+	}
+
+	return envVars
+}
+
+// NetworkServiceConfig returns the service config as an app on the
+// service's network sees it: host is the service's DNS alias, its name, and
+// port the one it listens on inside its container. That port is the
+// container_port the plugin records or else the one the published port maps
+// to in ports, the service containers' docker ps Ports column. When neither
+// is known host and port stay as they are, since the alias is useless
+// without the matching port.
+func NetworkServiceConfig(serviceName string, config map[string]string, ports string) map[string]string {
+	networked := make(map[string]string, len(config)+1)
+
+	for key, value := range config {
+		networked[key] = value
+	}
+
+	port := config[ServiceContainerPortKey]
+
+	if port == "" {
+		port = MappedContainerPort(ports, config["port"])
+	}
+
+	if port != "" {
+		networked["host"] = serviceName
+		networked["port"] = port
+	}
+
+	return networked
+}
+
+// MappedContainerPort returns the container port a host port is published
+// from in a docker ps Ports column such as
+// "0.0.0.0:15432->5432/tcp, :::15432->5432/tcp", or "" when it is not there
+func MappedContainerPort(ports, hostPort string) string {
+	if hostPort == "" {
+		return ""
+	}
+
+	for _, mapping := range strings.Split(ports, ",") {
+		published, target, ok := strings.Cut(strings.TrimSpace(mapping), "->")
+
+		if !ok {
+			continue
+		}
+
+		if published[strings.LastIndex(published, ":")+1:] == hostPort {
+			port, _, _ := strings.Cut(target, "/")
+			return port
+		}
+	}
+
+	return ""
+}
+
+// ServiceContainerPorts returns the docker ps Ports column of the running
+// containers of a service, joined with commas
+func ServiceContainerPorts(serviceName string) string {
+	output, err := exec.Command("docker", "ps", "--filter", "label="+LabelService+"="+serviceName, "--format", "{{.Ports}}").Output()
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.Join(strings.Split(strings.TrimSpace(string(output)), "\n"), ", ")
+}
+
+// ServiceEnv returns the env vars a service exposes to linked apps. A
+// jsonrpc plugin's env method comes first, then a commands/env script, then
+// the plugin.yml contract.
+func ServiceEnv(pluginsDir, serviceName, pluginName string, config map[string]string) (map[string]string, error) {
+	params := pluginsdk.ServiceParams{Service: serviceName, Config: config}
+	result, handled, err := CallPlugin(filepath.Join(pluginsDir, pluginName), pluginsdk.MethodEnv, params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if handled {
+		return result.Env, nil
+	}
+
+	script := filepath.Join(pluginsDir, pluginName, "commands", PluginEnvCommand)
+
+	if _, err := os.Stat(script); err == nil {
+		output, err := runEnvCommand(filepath.Join(pluginsDir, pluginName), script, serviceName)
+
+		if err != nil {
+			return nil, fmt.Errorf("plugin '%s' env command failed: %v", pluginName, err)
+		}
+
+		return ParseEnvLines(string(output)), nil
+	}
+
+	contract, err := LoadServiceEnvContract(pluginsDir, pluginName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return contract.Expand(serviceName, config), nil
+}
+
+// runEnvCommand runs a plugin's commands/env script, killing it when it runs
+// past the plugin's timeout
+func runEnvCommand(pluginDir, script, serviceName string) ([]byte, error) {
+	manifest, err := LoadPluginManifest(pluginDir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := manifest.CallTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", script, serviceName)
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.Output()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+
+	return output, err
+}
+
+// ParseEnvLines parses KEY=VALUE lines, skipping blanks and comments
+func ParseEnvLines(content string) map[string]string {
+	envVars := make(map[string]string)
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+
+		if ok && key != "" {
+			envVars[key] = value
+		}
+	}
+
+	return envVars
+}
+
+// ServiceEnvRef is the reference written into an app env for a linked service var
+func ServiceEnvRef(serviceName, key string) string {
+	return fmt.Sprintf("${service:%s:%s}", serviceName, key)
+}
+
+// LinkedEnvName returns the app env name for a service var, prefixed by the
+// link alias when there is one: alias "analytics" turns DATABASE_URL into
+// ANALYTICS_DATABASE_URL.
+func LinkedEnvName(alias, key string) string {
+	if alias == "" {
+		return key
+	}
+
+	return strings.ToUpper(alias) + "_" + key
+}
+
+// ValidateLinkAlias checks an alias can prefix env var names
+func ValidateLinkAlias(alias string) error {
+	if alias != "" && !envAliasPattern.MatchString(strings.ToUpper(alias)) {
+		return fmt.Errorf("invalid alias '%s': use letters, digits and underscores, starting with a letter", alias)
+	}
+
+	return nil
+}
+
+// LinkServiceEnv adds references to the service vars keys into the app env
+// vars and returns the names it wrote. It refuses to take over a var that
+// already references another service, which is what an alias is for.
+func LinkServiceEnv(vars map[string]string, serviceName, alias string, keys []string) ([]string, error) {
+	if err := ValidateLinkAlias(alias); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	names := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		name := LinkedEnvName(alias, key)
+
+		if owner := serviceRefOwner(vars[name]); owner != "" && owner != serviceName {
+			return nil, fmt.Errorf("%s is already provided by service '%s', link with --alias to use both", name, owner)
+		}
+
+		names = append(names, name)
+	}
+
+	for i, key := range keys {
+		vars[names[i]] = ServiceEnvRef(serviceName, key)
+	}
+
+	return names, nil
+}
+
+// UnlinkServiceEnv removes every var referencing the service from the app
+// env vars, whatever alias it was linked under, and returns the removed names
+func UnlinkServiceEnv(vars map[string]string, serviceName string) []string {
+	var removed []string
+
+	for name, value := range vars {
+		if serviceRefOwner(value) == serviceName {
+			delete(vars, name)
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(removed)
+
+	return removed
+}
+
+// serviceRefOwner returns the service a value written by LinkServiceEnv
+// points at, or "" for any other value
+func serviceRefOwner(value string) string {
+	if !strings.HasPrefix(value, "${service:") || !strings.HasSuffix(value, "}") {
+		return ""
+	}
+
+	ref := strings.TrimSuffix(strings.TrimPrefix(value, "${service:"), "}")
+	serviceName, key, ok := strings.Cut(ref, ":")
+
+	if !ok || key == "" || strings.ContainsAny(ref, "${}") {
+		return ""
+	}
+
+	return serviceName
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceEnvTestSuite struct {
+	suite.Suite
+	pluginsDir string
+}
+
+func TestServiceEnvTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(ServiceEnvTestSuite))
+}
+
+func (s *ServiceEnvTestSuite) SetupTest() {
+	s.pluginsDir = s.T().TempDir()
+}
+
+func (s *ServiceEnvTestSuite) TestLoadServiceEnvContract_PrefersPluginManifest() {
+	pluginDir := filepath.Join(s.pluginsDir, "postgres")
+	s.Require().NoError(os.MkdirAll(pluginDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(pluginDir, PluginManifestFile), []byte("env:\n  PGURL: \"{{port}}\"\n"), 0644))
+
+	contract, err := LoadServiceEnvContract(s.pluginsDir, "postgres")
+
+	Expect(err).To(BeNil())
+	Expect(contract.Env).To(Equal(map[string]string{"PGURL": "{{port}}"}))
+
+	contract, err = LoadServiceEnvContract(s.pluginsDir, "redis")
+
+	Expect(err).To(BeNil())
+	Expect(contract.Env).To(HaveKey("REDIS_URL"))
+
+	contract, err = LoadServiceEnvContract(s.pluginsDir, "nginx")
+
+	Expect(err).To(BeNil())
+	Expect(contract).To(BeNil())
+}
+
+func (s *ServiceEnvTestSuite) TestExpand_SkipsIncompleteTemplates() {
+	contract := &ServiceEnvContract{Env: map[string]string{
+		"URL":  "amqp://{{ user }}:{{password}}@{{host}}:{{port}}/{{name}}",
+		"USER": "{{user}}",
+	}}
+
+	Expect(contract.Expand("queue", map[string]string{"user": "app", "port": "5672"})).To(Equal(map[string]string{"USER": "app"}))
+
+	envVars := contract.Expand("queue", map[string]string{"user": "app", "password": "pw", "port": "5672", "host": "10.0.0.2"})
+	Expect(envVars["URL"]).To(Equal("amqp://app:pw@10.0.0.2:5672/queue"))
+}
+
+func (s *ServiceEnvTestSuite) TestLinkServiceEnv_WithAlias() {
+	vars := map[string]string{"PORT": "8080"}
+
+	names, err := LinkServiceEnv(vars, "pg-main", "", []string{"DATABASE_URL", "POSTGRES_DB"})
+
+	Expect(err).To(BeNil())
+	Expect(names).To(Equal([]string{"DATABASE_URL", "POSTGRES_DB"}))
+
+	_, err = LinkServiceEnv(vars, "pg-analytics", "", []string{"DATABASE_URL"})
+	Expect(err).To(MatchError(ContainSubstring("DATABASE_URL is already provided by service 'pg-main'")))
+
+	names, err = LinkServiceEnv(vars, "pg-analytics", "analytics", []string{"DATABASE_URL"})
+
+	Expect(err).To(BeNil())
+	Expect(names).To(Equal([]string{"ANALYTICS_DATABASE_URL"}))
+	Expect(vars["ANALYTICS_DATABASE_URL"]).To(Equal("${service:pg-analytics:DATABASE_URL}"))
+	Expect(vars["DATABASE_URL"]).To(Equal("${service:pg-main:DATABASE_URL}"))
+
+	_, err = LinkServiceEnv(vars, "pg-analytics", "1st", []string{"DATABASE_URL"})
+	Expect(err).To(MatchError(ContainSubstring("invalid alias '1st'")))
+}
+
+func (s *ServiceEnvTestSuite) TestUnlinkServiceEnv_RemovesOnlyItsReferences() {
+	vars := map[string]string{
+		"DATABASE_URL":           "${service:pg-main:DATABASE_URL}",
+		"ANALYTICS_DATABASE_URL": "${service:pg-analytics:DATABASE_URL}",
+		"REPORTS_DB":             "${service:pg-analytics:POSTGRES_DB}",
+		"CUSTOM":                 "${service:pg-analytics:POSTGRES_DB}?sslmode=require",
+	}
+
+	Expect(UnlinkServiceEnv(vars, "pg-analytics")).To(Equal([]string{"ANALYTICS_DATABASE_URL", "REPORTS_DB"}))
+	Expect(vars).To(HaveLen(2))
+	Expect(vars).To(HaveKey("DATABASE_URL"))
+	Expect(vars).To(HaveKey("CUSTOM"))
+}
+
+func (s *ServiceEnvTestSuite) TestParseEnvLines() {
+	envVars := ParseEnvLines("# generated\nexport A=1\nB=x=y\n\nbroken\n")
+
+	Expect(envVars).To(Equal(map[string]string{"A": "1", "B": "x=y"}))
+}
+
+func (s *ServiceEnvTestSuite) TestServiceEnv_FromJSONRPCPlugin() {
+	pluginDir := filepath.Join(s.pluginsDir, "redis")
+	s.Require().NoError(os.MkdirAll(pluginDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(pluginDir, PluginManifestFile), []byte("protocol: jsonrpc\nexecutable: bin/redis\nenv:\n  UNUSED: x\n"), 0644))
+
+	// Answers the first request, whatever it is, with a fixed env
+	script := "#!/bin/bash\nread -r request\n" +
+		`echo '{"jsonrpc":"2.0","id":1,"result":{"env":{"REDIS_URL":"redis://redis-cache:6379"}}}'` + "\n"
+
+	s.Require().NoError(os.MkdirAll(filepath.Join(pluginDir, "bin"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(pluginDir, "bin", "redis"), []byte(script), 0755))
+
+	env, err := ServiceEnv(s.pluginsDir, "redis-cache", "redis", nil)
+
+	Expect(err).To(BeNil())
+	Expect(env).To(Equal(map[string]string{"REDIS_URL": "redis://redis-cache:6379"}))
+}
+
+func (s *ServiceEnvTestSuite) TestServiceEnv_KillsEnvCommandPastTimeout() {
+	pluginDir := filepath.Join(s.pluginsDir, "postgres")
+	s.Require().NoError(os.MkdirAll(filepath.Join(pluginDir, "commands"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(pluginDir, PluginManifestFile), []byte("timeout: 100ms\n"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(pluginDir, "commands", PluginEnvCommand), []byte("echo A=1\nsleep 5\n"), 0755))
+
+	started := time.Now()
+	_, err := ServiceEnv(s.pluginsDir, "pg-main", "postgres", nil)
+
+	Expect(err).To(MatchError(ContainSubstring("timed out after 100ms")))
+	Expect(time.Since(started)).To(BeNumerically("<", 3*time.Second))
+}
+
+func (s *ServiceEnvTestSuite) TestCallPlugin_NotHandledForScriptPlugins() {
+	pluginDir := filepath.Join(s.pluginsDir, "postgres")
+	s.Require().NoError(os.MkdirAll(pluginDir, 0755))
+
+	result, handled, err := CallPlugin(pluginDir, "env", nil)
+
+	Expect(err).To(BeNil())
+	Expect(handled).To(BeFalse())
+	Expect(result).To(BeNil())
+}
@@ -151,7 +151,7 @@ func (s *AppsService) getAppContainers(appName string) ([]internal.ContainerInfo
 
 	var appContainers []internal.ContainerInfo
 	for _, c := range containers {
-		if containsContainerName(c.Names, appName) {
+		if containsContainerName(c, appName) {
 			appContainers = append(appContainers, c)
 		}
 	}
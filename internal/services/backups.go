@@ -0,0 +1,681 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokku/internal"
+	"gokku/pluginsdk"
+)
+
+// BackupIDFormat is the time layout of backup IDs, which sort by age. The
+// milliseconds keep backups taken within the same second apart; IDs of
+// backups from older gokku versions have none.
+const BackupIDFormat = "20060102-150405.000"
+
+var backupIDPattern = regexp.MustCompile(`^\d{8}-\d{6}(\.\d{3})?$`)
+
+// backupTime returns when the backup with id was taken. Parsing accepts
+// the milliseconds without the layout naming them, so old IDs parse too.
+func backupTime(id string) time.Time {
+	created, _ := time.Parse("20060102-150405", id)
+	return created
+}
+
+// BackupPolicy is a service's backup schedule, retention and storage
+type BackupPolicy struct {
+	// Schedule is a cron expression or macro like @daily; empty means
+	// backups only run on demand
+	Schedule string `json:"schedule,omitempty"`
+
+	// Keep is how many backups are kept, 0 keeps all of them
+	Keep int `json:"keep,omitempty"`
+
+	// Target is s3://bucket/prefix, or empty for the local backups directory
+	Target string `json:"target,omitempty"`
+
+	// Endpoint is the URL of an S3-compatible server such as MinIO or R2
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Validate checks the policy can be scheduled and stored
+func (p BackupPolicy) Validate() error {
+	if p.Schedule != "" {
+		if err := internal.ValidateCronSchedule(p.Schedule); err != nil {
+			return err
+		}
+	}
+
+	if p.Keep < 0 {
+		return fmt.Errorf("keep must be 0 or more")
+	}
+
+	if p.Target != "" {
+		bucket, _ := splitS3Target(p.Target)
+
+		if !strings.HasPrefix(p.Target, "s3://") || bucket == "" {
+			return fmt.Errorf("invalid target '%s' (expected s3://bucket/prefix)", p.Target)
+		}
+	}
+
+	if p.Endpoint != "" {
+		if p.Target == "" {
+			return fmt.Errorf("endpoint requires an s3:// target")
+		}
+
+		if !strings.HasPrefix(p.Endpoint, "http://") && !strings.HasPrefix(p.Endpoint, "https://") {
+			return fmt.Errorf("invalid endpoint '%s' (expected an http(s) URL)", p.Endpoint)
+		}
+	}
+
+	return nil
+}
+
+// Backup is one stored backup of a service
+type Backup struct {
+	ID        string
+	Service   string
+	CreatedAt time.Time
+	Size      int64
+	Location  string
+}
+
+// backupStore keeps the compressed backups of services
+type backupStore interface {
+	// Put moves the compressed backup at path into the store
+	Put(serviceName, id, path string) (string, error)
+	Open(serviceName, id string) (io.ReadCloser, error)
+	List(serviceName string) ([]Backup, error)
+	Delete(serviceName, id string) error
+}
+
+// BackupManager dumps services through their plugin's backup command and
+// keeps the compressed dumps under backups/<service> or in an S3 bucket
+type BackupManager struct {
+	baseDir  string
+	services *ServiceManager
+
+	// aws runs the aws CLI for S3 targets
+	aws func(args ...string) ([]byte, error)
+
+	// installSchedule writes a service's backup schedule to the crontab,
+	// removing it when schedule is empty
+	installSchedule func(serviceName, schedule, logFile string) error
+
+	now func() time.Time
+}
+
+// NewBackupManager creates a new BackupManager
+func NewBackupManager(baseDir string) *BackupManager {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &BackupManager{
+		baseDir:         baseDir,
+		services:        NewServiceManager(baseDir),
+		aws:             runAWS,
+		installSchedule: InstallBackupSchedule,
+		now:             time.Now,
+	}
+}
+
+// Policy returns the service's backup policy
+func (bm *BackupManager) Policy(serviceName string) (BackupPolicy, error) {
+	service, err := bm.services.GetService(serviceName)
+	if err != nil {
+		return BackupPolicy{}, fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	if service.Backup == nil {
+		return BackupPolicy{}, nil
+	}
+
+	return *service.Backup, nil
+}
+
+// SetPolicy saves the service's backup policy and installs its schedule
+func (bm *BackupManager) SetPolicy(serviceName string, policy BackupPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	service, err := bm.services.GetService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	if err := bm.installSchedule(serviceName, policy.Schedule, filepath.Join(bm.backupDir(serviceName), "backup.log")); err != nil {
+		return err
+	}
+
+	service.Backup = &policy
+
+	return bm.services.saveServiceConfig(serviceName, service)
+}
+
+// Backup dumps the service with its plugin, stores the compressed dump and
+// drops the backups the policy no longer keeps
+func (bm *BackupManager) Backup(serviceName string, out io.Writer) (Backup, error) {
+	service, err := bm.services.GetService(serviceName)
+	if err != nil {
+		return Backup{}, fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	policy := BackupPolicy{}
+	if service.Backup != nil {
+		policy = *service.Backup
+	}
+
+	dir := bm.backupDir(serviceName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Backup{}, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	created := bm.now().UTC().Truncate(time.Millisecond)
+	id := created.Format(BackupIDFormat)
+
+	dump, err := os.CreateTemp(dir, ".dump-*")
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to create dump file: %v", err)
+	}
+
+	dump.Close()
+	defer os.Remove(dump.Name())
+
+	if err := bm.runPlugin(service, pluginsdk.MethodBackup, dump.Name(), out); err != nil {
+		return Backup{}, err
+	}
+
+	compressed := filepath.Join(dir, "."+id+".gz")
+	defer os.Remove(compressed)
+
+	size, err := compressFile(dump.Name(), compressed)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	store := bm.store(policy)
+
+	location, err := store.Put(serviceName, id, compressed)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	backup := Backup{ID: id, Service: serviceName, CreatedAt: created, Size: size, Location: location}
+
+	if err := bm.prune(store, serviceName, policy.Keep, out); err != nil {
+		return backup, err
+	}
+
+	return backup, nil
+}
+
+// List returns the service's backups, newest first
+func (bm *BackupManager) List(serviceName string) ([]Backup, error) {
+	policy, err := bm.Policy(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := bm.store(policy).List(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+
+	return backups, nil
+}
+
+// Restore loads the backup into the service with its plugin's restore command
+func (bm *BackupManager) Restore(serviceName, id string, out io.Writer) error {
+	if !backupIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid backup id '%s'", id)
+	}
+
+	service, err := bm.services.GetService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	policy := BackupPolicy{}
+	if service.Backup != nil {
+		policy = *service.Backup
+	}
+
+	reader, err := bm.store(policy).Open(serviceName, id)
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	dir := bm.backupDir(serviceName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	dump, err := os.CreateTemp(dir, ".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create dump file: %v", err)
+	}
+
+	defer os.Remove(dump.Name())
+
+	err = decompress(reader, dump)
+	dump.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to decompress backup '%s': %v", id, err)
+	}
+
+	return bm.runPlugin(service, pluginsdk.MethodRestore, dump.Name(), out)
+}
+
+// runPlugin runs the plugin's backup or restore on the uncompressed dump at
+// path. jsonrpc plugins get the path; a backup script writes the dump to
+// stdout and a restore script reads it from stdin.
+func (bm *BackupManager) runPlugin(service Service, method, path string, out io.Writer) error {
+	pluginDir := filepath.Join(bm.baseDir, "plugins", service.Plugin)
+	params := pluginsdk.ServiceParams{Service: service.Name, Version: service.Config["version"], Config: service.Config, Path: path}
+
+	result, handled, err := internal.CallPlugin(pluginDir, method, params)
+	if err != nil {
+		return err
+	}
+
+	if handled {
+		if result.Output != "" {
+			fmt.Fprintln(out, strings.TrimRight(result.Output, "\n"))
+		}
+
+		return nil
+	}
+
+	script := pluginScript(pluginDir, method)
+	if script == "" {
+		return fmt.Errorf("plugin '%s' has no %s command", service.Plugin, method)
+	}
+
+	mode := os.O_RDONLY
+	if method == pluginsdk.MethodBackup {
+		mode = os.O_WRONLY | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, mode, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	cmd := exec.Command("bash", script, service.Name)
+	cmd.Dir = pluginDir
+	cmd.Stderr = out
+
+	if method == pluginsdk.MethodBackup {
+		cmd.Stdout = file
+	} else {
+		cmd.Stdin = file
+		cmd.Stdout = out
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin '%s' %s failed: %v", service.Plugin, method, err)
+	}
+
+	return nil
+}
+
+// prune deletes the oldest backups beyond keep
+func (bm *BackupManager) prune(store backupStore, serviceName string, keep int, out io.Writer) error {
+	if keep == 0 {
+		return nil
+	}
+
+	backups, err := store.List(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= keep {
+		return nil
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+
+	for _, backup := range backups[keep:] {
+		fmt.Fprintf(out, "-----> Removing old backup %s\n", backup.ID)
+
+		if err := store.Delete(serviceName, backup.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// store returns where the policy keeps backups
+func (bm *BackupManager) store(policy BackupPolicy) backupStore {
+	if policy.Target != "" {
+		bucket, prefix := splitS3Target(policy.Target)
+		return &s3BackupStore{bucket: bucket, prefix: prefix, endpoint: policy.Endpoint, aws: bm.aws}
+	}
+
+	return &localBackupStore{dir: filepath.Join(bm.baseDir, "backups")}
+}
+
+func (bm *BackupManager) backupDir(serviceName string) string {
+	return filepath.Join(bm.baseDir, "backups", serviceName)
+}
+
+// InstallBackupSchedule writes the service's backup schedule to the crontab
+// of the current user. Each run appends its output to logFile.
+func InstallBackupSchedule(serviceName, schedule, logFile string) error {
+	return internal.InstallCrontab(schedule != "", func(existing, gokkuBin string) string {
+		var entries []string
+
+		if schedule != "" {
+			entries = append(entries, fmt.Sprintf("%s %s services:backup %s >> %s 2>&1",
+				schedule, gokkuBin, serviceName, internal.ShellQuote(logFile)))
+		}
+
+		return internal.ReplaceCrontabBlock(existing, "backup "+serviceName, entries)
+	})
+}
+
+// compressFile gzips src into dst and returns the size of dst
+func compressFile(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+
+	defer in.Close()
+
+	if info, err := in.Stat(); err == nil && info.Size() == 0 {
+		return 0, fmt.Errorf("backup command produced no data")
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create backup file: %v", err)
+	}
+
+	defer out.Close()
+
+	writer := gzip.NewWriter(out)
+
+	if _, err := io.Copy(writer, in); err != nil {
+		return 0, fmt.Errorf("failed to compress backup: %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to compress backup: %v", err)
+	}
+
+	info, err := out.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+func decompress(r io.Reader, w io.Writer) error {
+	reader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// localBackupStore keeps backups as <dir>/<service>/<id>.gz
+type localBackupStore struct {
+	dir string
+}
+
+func (s *localBackupStore) Put(serviceName, id, path string) (string, error) {
+	target := s.path(serviceName, id)
+
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	// Unlike a rename, a link fails instead of replacing an existing backup
+	if err := os.Link(path, target); os.IsExist(err) {
+		return "", fmt.Errorf("backup '%s' already exists", id)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to store backup: %v", err)
+	}
+
+	os.Remove(path)
+
+	return target, nil
+}
+
+func (s *localBackupStore) Open(serviceName, id string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(serviceName, id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("backup '%s' not found", id)
+	}
+
+	return file, err
+}
+
+func (s *localBackupStore) List(serviceName string) ([]Backup, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, serviceName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backups: %v", err)
+	}
+
+	var backups []Backup
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".gz")
+		if !ok || !backupIDPattern.MatchString(id) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			ID:        id,
+			Service:   serviceName,
+			CreatedAt: backupTime(id),
+			Size:      info.Size(),
+			Location:  s.path(serviceName, id),
+		})
+	}
+
+	return backups, nil
+}
+
+func (s *localBackupStore) Delete(serviceName, id string) error {
+	if err := os.Remove(s.path(serviceName, id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove backup '%s': %v", id, err)
+	}
+
+	return nil
+}
+
+func (s *localBackupStore) path(serviceName, id string) string {
+	return filepath.Join(s.dir, serviceName, id+".gz")
+}
+
+// s3BackupStore keeps backups as s3://<bucket>/<prefix>/<service>/<id>.gz
+// through the aws CLI, which reads credentials from its usual environment
+// variables and config files
+type s3BackupStore struct {
+	bucket   string
+	prefix   string
+	endpoint string
+	aws      func(args ...string) ([]byte, error)
+}
+
+func (s *s3BackupStore) Put(serviceName, id, path string) (string, error) {
+	url := s.url(serviceName, id+".gz")
+
+	// ls lists every key starting with the URL and fails when there is none
+	if output, err := s.run("s3", "ls", url); err == nil {
+		for _, backup := range parseS3Listing(string(output), serviceName, "") {
+			if backup.ID == id {
+				return "", fmt.Errorf("backup '%s' already exists", id)
+			}
+		}
+	}
+
+	if _, err := s.run("s3", "cp", "--only-show-errors", path, url); err != nil {
+		return "", fmt.Errorf("failed to upload backup: %v", err)
+	}
+
+	return url, nil
+}
+
+func (s *s3BackupStore) Open(serviceName, id string) (io.ReadCloser, error) {
+	file, err := os.CreateTemp("", "gokku-backup-*.gz")
+	if err != nil {
+		return nil, err
+	}
+
+	file.Close()
+
+	if _, err := s.run("s3", "cp", "--only-show-errors", s.url(serviceName, id+".gz"), file.Name()); err != nil {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to download backup '%s': %v", id, err)
+	}
+
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &tempFileReader{File: reader}, nil
+}
+
+func (s *s3BackupStore) List(serviceName string) ([]Backup, error) {
+	output, err := s.run("s3", "ls", s.url(serviceName, ""))
+	if err != nil {
+		// ls fails on a prefix with no objects yet
+		if strings.Contains(err.Error(), "exit status 1") {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to list backups: %v", err)
+	}
+
+	return parseS3Listing(string(output), serviceName, s.url(serviceName, "")), nil
+}
+
+func (s *s3BackupStore) Delete(serviceName, id string) error {
+	if _, err := s.run("s3", "rm", "--only-show-errors", s.url(serviceName, id+".gz")); err != nil {
+		return fmt.Errorf("failed to remove backup '%s': %v", id, err)
+	}
+
+	return nil
+}
+
+func (s *s3BackupStore) run(args ...string) ([]byte, error) {
+	if s.endpoint != "" {
+		args = append(args, "--endpoint-url", s.endpoint)
+	}
+
+	return s.aws(args...)
+}
+
+func (s *s3BackupStore) url(serviceName, file string) string {
+	parts := []string{s.bucket}
+
+	if s.prefix != "" {
+		parts = append(parts, s.prefix)
+	}
+
+	return "s3://" + strings.Join(append(parts, serviceName, file), "/")
+}
+
+// parseS3Listing reads the backups out of `aws s3 ls` lines like
+// "2024-05-01 03:00:02      48213 20240501-030000.gz"
+func parseS3Listing(output, serviceName, baseURL string) []Backup {
+	var backups []Backup
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+
+		id, ok := strings.CutSuffix(fields[3], ".gz")
+		if !ok || !backupIDPattern.MatchString(id) {
+			continue
+		}
+
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+
+		backups = append(backups, Backup{
+			ID:        id,
+			Service:   serviceName,
+			CreatedAt: backupTime(id),
+			Size:      size,
+			Location:  baseURL + fields[3],
+		})
+	}
+
+	return backups
+}
+
+// splitS3Target splits s3://bucket/some/prefix into its bucket and prefix
+func splitS3Target(target string) (string, string) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(target, "s3://"), "/")
+	return bucket, strings.Trim(prefix, "/")
+}
+
+// tempFileReader removes its file once read
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	r.File.Close()
+	return os.Remove(r.File.Name())
+}
+
+func runAWS(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("aws", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return output, fmt.Errorf("aws %s: %v, output: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
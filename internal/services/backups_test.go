@@ -0,0 +1,181 @@
+package services
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBackupManagerTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(BackupManagerTestSuite))
+}
+
+type BackupManagerTestSuite struct {
+	suite.Suite
+	tempDir   string
+	manager   *BackupManager
+	clock     time.Time
+	schedules map[string]string
+	awsCalls  []string
+}
+
+func (s *BackupManagerTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "gokku-backups-test-*")
+	s.Require().NoError(err)
+
+	// The fake plugin dumps a fixed text and restores into restored.sql
+	commandsDir := filepath.Join(s.tempDir, "plugins", "postgres", "commands")
+	s.Require().NoError(os.MkdirAll(commandsDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(commandsDir, "backup"), []byte("#!/bin/bash\necho \"dump of $1\"\n"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(commandsDir, "restore"),
+		[]byte("#!/bin/bash\ncat > \"$(dirname \"$0\")/../restored.sql\"\n"), 0755))
+
+	s.manager = NewBackupManager(s.tempDir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "services", "postgres-api"), 0755))
+	s.Require().NoError(s.manager.services.saveServiceConfig("postgres-api", Service{Name: "postgres-api", Plugin: "postgres"}))
+
+	s.clock = time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	s.manager.now = func() time.Time {
+		s.clock = s.clock.Add(time.Hour)
+		return s.clock
+	}
+
+	s.schedules = map[string]string{}
+	s.manager.installSchedule = func(serviceName, schedule, logFile string) error {
+		s.schedules[serviceName] = schedule
+		return nil
+	}
+
+	s.awsCalls = nil
+	s.manager.aws = func(args ...string) ([]byte, error) {
+		s.awsCalls = append(s.awsCalls, strings.Join(args, " "))
+		return []byte("2024-05-01 04:00:02        48 20240501-040000.gz\n"), nil
+	}
+}
+
+func (s *BackupManagerTestSuite) TearDownTest() {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+}
+
+func (s *BackupManagerTestSuite) TestBackupAndRestore() {
+	backup, err := s.manager.Backup("postgres-api", io.Discard)
+
+	Expect(err).To(BeNil())
+	Expect(backup.ID).To(Equal("20240501-040000.000"))
+	Expect(backup.Location).To(Equal(filepath.Join(s.tempDir, "backups", "postgres-api", "20240501-040000.000.gz")))
+	Expect(backup.Location).To(BeAnExistingFile())
+
+	backups, err := s.manager.List("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(backups).To(HaveLen(1))
+	Expect(backups[0].Size).To(Equal(backup.Size))
+
+	Expect(s.manager.Restore("postgres-api", backup.ID, io.Discard)).To(Succeed())
+
+	restored, err := os.ReadFile(filepath.Join(s.tempDir, "plugins", "postgres", "restored.sql"))
+	Expect(err).To(BeNil())
+	Expect(string(restored)).To(Equal("dump of postgres-api\n"))
+}
+
+func (s *BackupManagerTestSuite) TestBackup_KeepsNewest() {
+	Expect(s.manager.SetPolicy("postgres-api", BackupPolicy{Keep: 2})).To(Succeed())
+
+	for range 3 {
+		_, err := s.manager.Backup("postgres-api", io.Discard)
+		Expect(err).To(BeNil())
+	}
+
+	backups, err := s.manager.List("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(backups).To(HaveLen(2))
+	Expect(backups[0].ID).To(Equal("20240501-060000.000"))
+	Expect(backups[1].ID).To(Equal("20240501-050000.000"))
+}
+
+func (s *BackupManagerTestSuite) TestBackup_SameSecondGetsDistinctIDs() {
+	s.manager.now = func() time.Time {
+		s.clock = s.clock.Add(250 * time.Millisecond)
+		return s.clock
+	}
+
+	first, err := s.manager.Backup("postgres-api", io.Discard)
+	Expect(err).To(BeNil())
+	second, err := s.manager.Backup("postgres-api", io.Discard)
+	Expect(err).To(BeNil())
+
+	Expect(first.ID).To(Equal("20240501-030000.250"))
+	Expect(second.ID).To(Equal("20240501-030000.500"))
+	Expect(second.CreatedAt).To(Equal(s.clock))
+
+	// A backup taken at the very same time is refused instead of replacing it
+	s.manager.now = func() time.Time { return s.clock }
+
+	_, err = s.manager.Backup("postgres-api", io.Discard)
+	Expect(err).To(MatchError("backup '20240501-030000.500' already exists"))
+
+	backups, err := s.manager.List("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(backups).To(HaveLen(2))
+}
+
+func (s *BackupManagerTestSuite) TestBackup_WithoutBackupCommand() {
+	s.Require().NoError(os.Remove(filepath.Join(s.tempDir, "plugins", "postgres", "commands", "backup")))
+
+	_, err := s.manager.Backup("postgres-api", io.Discard)
+
+	Expect(err).To(MatchError("plugin 'postgres' has no backup command"))
+}
+
+func (s *BackupManagerTestSuite) TestBackup_ToS3Target() {
+	policy := BackupPolicy{Target: "s3://backups/gokku/", Endpoint: "https://minio.example.com"}
+	Expect(s.manager.SetPolicy("postgres-api", policy)).To(Succeed())
+
+	backup, err := s.manager.Backup("postgres-api", io.Discard)
+
+	Expect(err).To(BeNil())
+	Expect(backup.Location).To(Equal("s3://backups/gokku/postgres-api/20240501-040000.000.gz"))
+	Expect(s.awsCalls).To(HaveLen(2))
+	Expect(s.awsCalls[0]).To(Equal("s3 ls s3://backups/gokku/postgres-api/20240501-040000.000.gz --endpoint-url https://minio.example.com"))
+	Expect(s.awsCalls[1]).To(HavePrefix("s3 cp --only-show-errors "))
+	Expect(s.awsCalls[1]).To(HaveSuffix(" s3://backups/gokku/postgres-api/20240501-040000.000.gz --endpoint-url https://minio.example.com"))
+
+	backups, err := s.manager.List("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(backups).To(HaveLen(1))
+	Expect(backups[0].Size).To(Equal(int64(48)))
+	Expect(backups[0].Location).To(Equal("s3://backups/gokku/postgres-api/20240501-040000.gz"))
+}
+
+func (s *BackupManagerTestSuite) TestSetPolicy_InstallsSchedule() {
+	Expect(s.manager.SetPolicy("postgres-api", BackupPolicy{Schedule: "0 3 * * *", Keep: 7})).To(Succeed())
+
+	Expect(s.schedules).To(HaveKeyWithValue("postgres-api", "0 3 * * *"))
+
+	policy, err := s.manager.Policy("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(policy).To(Equal(BackupPolicy{Schedule: "0 3 * * *", Keep: 7}))
+}
+
+func (s *BackupManagerTestSuite) TestSetPolicy_Invalid() {
+	Expect(s.manager.SetPolicy("postgres-api", BackupPolicy{Schedule: "0 25 * * *"})).ToNot(Succeed())
+	Expect(s.manager.SetPolicy("postgres-api", BackupPolicy{Keep: -1})).To(MatchError("keep must be 0 or more"))
+	Expect(s.manager.SetPolicy("postgres-api", BackupPolicy{Target: "gs://bucket"})).To(MatchError("invalid target 'gs://bucket' (expected s3://bucket/prefix)"))
+	Expect(s.manager.SetPolicy("postgres-api", BackupPolicy{Endpoint: "https://minio"})).To(MatchError("endpoint requires an s3:// target"))
+	Expect(s.schedules).To(BeEmpty())
+}
+
+func (s *BackupManagerTestSuite) TestRestore_RejectsInvalidID() {
+	Expect(s.manager.Restore("postgres-api", "../../etc/passwd", io.Discard)).To(MatchError("invalid backup id '../../etc/passwd'"))
+	Expect(s.manager.Restore("postgres-api", "20240101-000000", io.Discard)).To(MatchError("backup '20240101-000000' not found"))
+	Expect(s.manager.Restore("postgres-api", "20240101-000000.123", io.Discard)).To(MatchError("backup '20240101-000000.123' not found"))
+}
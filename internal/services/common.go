@@ -25,6 +25,32 @@ type ContainerFilter struct {
 	All         bool
 }
 
+// Reconcile action kinds
+const (
+	ReconcileStatus     = "status"     // registry status drifted from Docker
+	ReconcileUnregister = "unregister" // registry entry without a container
+	ReconcileRegister   = "register"   // app container missing from the registry
+	ReconcileRemove     = "remove"     // stopped container not owned by any app
+	ReconcileOrphan     = "orphan"     // running container not owned by any app, left alone
+)
+
+// ReconcileOptions controls what the reconciler is allowed to change
+type ReconcileOptions struct {
+	DryRun bool // Report actions without applying them
+	Prune  bool // Remove stopped containers not owned by any app
+}
+
+// ReconcileAction describes a single difference between the registry and Docker
+type ReconcileAction struct {
+	Kind        string
+	AppName     string
+	ProcessType string
+	Number      int
+	Container   string
+	From        string
+	To          string
+}
+
 // ConfigOperation represents a configuration operation
 type ConfigOperation struct {
 	Type  string // "set", "get", "list", "unset"
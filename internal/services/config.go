@@ -74,6 +74,43 @@ func (s *ConfigService) UnsetEnvVar(appName string, keys []string) error {
 	return internal.SaveEnvFile(envFile, envVars)
 }
 
+// EffectiveEnvVars returns the merged app environment with the source of each value.
+// When resolve is true, variable references are replaced by their values.
+func (s *ConfigService) EffectiveEnvVars(appName string, resolve bool) ([]internal.EffectiveEnvVar, error) {
+	configPath := filepath.Join(s.baseDir, "apps", appName, "gokku.yml")
+
+	// The app may not have a gokku.yml yet, in which case only config:set values apply
+	app, err := internal.LoadAppConfigFromFile(configPath, appName)
+
+	if err != nil {
+		app = nil
+	}
+
+	envVars := internal.MergeAppEnv(app, s.ListEnvVars(appName))
+
+	if !resolve {
+		return envVars, nil
+	}
+
+	values := make(map[string]string, len(envVars))
+
+	for _, envVar := range envVars {
+		values[envVar.Key] = envVar.Value
+	}
+
+	resolved, err := internal.NewEnvResolver(s.baseDir).Resolve(values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range envVars {
+		envVars[i].Value = resolved[envVars[i].Key]
+	}
+
+	return envVars, nil
+}
+
 // ReloadApp restarts/recreates the app container to apply config changes
 func (s *ConfigService) ReloadApp(appName string) error {
 	envFile := s.getEnvFilePath(appName)
@@ -184,3 +184,38 @@ func (s *ConfigServiceTestSuite) TestGetEnvFilePath() {
 
 	Expect(actualPath).To(Equal(expectedPath))
 }
+
+func (s *ConfigServiceTestSuite) TestEffectiveEnvVars_MergesGokkuYmlAndConfig() {
+	gokkuYml := `apps:
+  test-app:
+    env:
+      HOST: example.com
+      PORT: "3000"
+      URL: "http://${HOST}:${PORT}"
+`
+	configPath := filepath.Join(s.tempDir, "apps", s.appName, "gokku.yml")
+	s.Require().NoError(os.WriteFile(configPath, []byte(gokkuYml), 0644))
+	s.Require().NoError(s.service.SetEnvVar(s.appName, []string{"PORT=8080"}))
+
+	envVars, err := s.service.EffectiveEnvVars(s.appName, true)
+	Expect(err).To(BeNil())
+	Expect(envVars).To(HaveLen(3))
+	Expect(envVars[1].Key).To(Equal("PORT"))
+	Expect(envVars[1].Value).To(Equal("8080"))
+	Expect(envVars[1].Source).To(Equal("config:set"))
+	Expect(envVars[2].Value).To(Equal("http://example.com:8080"))
+	Expect(envVars[2].Source).To(Equal("gokku.yml"))
+}
+
+func (s *ConfigServiceTestSuite) TestEffectiveEnvVars_Raw() {
+	s.Require().NoError(s.service.SetEnvVar(s.appName, []string{"URL=${MISSING}"}))
+
+	envVars, err := s.service.EffectiveEnvVars(s.appName, false)
+	Expect(err).To(BeNil())
+	Expect(envVars[0].Value).To(Equal("${MISSING}"))
+
+	// Undefined references are kept as written when resolving too
+	envVars, err = s.service.EffectiveEnvVars(s.appName, true)
+	Expect(err).To(BeNil())
+	Expect(envVars[0].Value).To(Equal("${MISSING}"))
+}
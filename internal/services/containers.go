@@ -3,7 +3,6 @@ package services
 import (
 	"fmt"
 	"os/exec"
-	"strings"
 
 	"gokku/internal"
 )
@@ -37,13 +36,13 @@ func (s *ContainerService) ListContainers(filter ContainerFilter) ([]internal.Co
 		match := true
 
 		if filter.AppName != "" {
-			if !containsContainerName(c.Names, filter.AppName) {
+			if !containsContainerName(c, filter.AppName) {
 				match = false
 			}
 		}
 
 		if filter.ProcessType != "" && match {
-			if !containsProcessType(c.Names, filter.ProcessType) {
+			if !containsProcessType(c, filter.ProcessType) {
 				match = false
 			}
 		}
@@ -91,7 +90,7 @@ func (s *ContainerService) GetContainerInfo(name string) (*internal.ContainerInf
 	}
 
 	for _, c := range containers {
-		if strings.Contains(c.Names, name) {
+		if c.HasName(name) {
 			return &c, nil
 		}
 	}
@@ -99,12 +98,14 @@ func (s *ContainerService) GetContainerInfo(name string) (*internal.ContainerInf
 	return nil, &ContainerNotFoundError{ContainerName: name}
 }
 
-// containsContainerName checks if container name contains the app name
-func containsContainerName(names, appName string) bool {
-	return strings.Contains(names, appName)
+// containsContainerName checks if the container belongs to the app, using
+// the gokku.app label
+func containsContainerName(c internal.ContainerInfo, appName string) bool {
+	return c.BelongsTo(appName)
 }
 
-// containsProcessType checks if container name contains the process type
-func containsProcessType(names, processType string) bool {
-	return strings.Contains(names, processType)
+// containsProcessType checks if the container runs the process type, using
+// the gokku.process label
+func containsProcessType(c internal.ContainerInfo, processType string) bool {
+	return c.ProcessType() == processType
 }
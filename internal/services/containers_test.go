@@ -3,6 +3,8 @@ package services
 import (
 	"testing"
 
+	"gokku/internal"
+
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/suite"
 )
@@ -36,46 +38,46 @@ func (s *ContainerServiceTestSuite) TestNewContainerService_WithCustomBaseDir()
 	Expect(service.baseDir).To(Equal(customDir))
 }
 
-func (s *ContainerServiceTestSuite) TestContainsContainerName_WhenNameMatches() {
-	names := "my-app-web-1"
-	appName := "my-app"
-	result := containsContainerName(names, appName)
+func (s *ContainerServiceTestSuite) TestContainsContainerName_WhenLabelMatches() {
+	c := internal.ContainerInfo{Names: "my-app-web-1", Labels: "createdby=gokku,gokku.app=my-app,gokku.process=web"}
+	result := containsContainerName(c, "my-app")
 	Expect(result).To(BeTrue())
 }
 
-func (s *ContainerServiceTestSuite) TestContainsContainerName_WhenNameDoesNotMatch() {
-	names := "other-app-web-1"
-	appName := "my-app"
-	result := containsContainerName(names, appName)
+func (s *ContainerServiceTestSuite) TestContainsContainerName_WhenLabelDoesNotMatch() {
+	c := internal.ContainerInfo{Names: "my-app-worker", Labels: "createdby=gokku,gokku.app=my-app-worker"}
+	result := containsContainerName(c, "my-app")
 	Expect(result).To(BeFalse())
 }
 
 func (s *ContainerServiceTestSuite) TestContainsContainerName_WhenNameIsSubstring() {
-	names := "my-app-production-web-1"
-	appName := "my-app"
-	result := containsContainerName(names, appName)
+	c := internal.ContainerInfo{Names: "legacy-my-app", Labels: "createdby=gokku"}
+	result := containsContainerName(c, "my-app")
+	Expect(result).To(BeFalse())
+}
+
+func (s *ContainerServiceTestSuite) TestContainsContainerName_WithUnlabelledContainer() {
+	c := internal.ContainerInfo{Names: "my-app", Labels: "createdby=gokku"}
+	result := containsContainerName(c, "my-app")
 	Expect(result).To(BeTrue())
 }
 
-func (s *ContainerServiceTestSuite) TestContainsProcessType_WhenTypeMatches() {
-	names := "my-app-web-1"
-	processType := "web"
-	result := containsProcessType(names, processType)
+func (s *ContainerServiceTestSuite) TestContainsProcessType_WhenLabelMatches() {
+	c := internal.ContainerInfo{Names: "my-app", Labels: "gokku.app=my-app,gokku.process=web"}
+	result := containsProcessType(c, "web")
 	Expect(result).To(BeTrue())
 }
 
-func (s *ContainerServiceTestSuite) TestContainsProcessType_WhenTypeDoesNotMatch() {
-	names := "my-app-worker-1"
-	processType := "web"
-	result := containsProcessType(names, processType)
+func (s *ContainerServiceTestSuite) TestContainsProcessType_WhenLabelDoesNotMatch() {
+	c := internal.ContainerInfo{Names: "my-app-web-1", Labels: "gokku.app=my-app,gokku.process=worker"}
+	result := containsProcessType(c, "web")
 	Expect(result).To(BeFalse())
 }
 
 func (s *ContainerServiceTestSuite) TestContainsProcessType_WhenTypeIsSubstring() {
-	names := "my-app-web-production-1"
-	processType := "web"
-	result := containsProcessType(names, processType)
-	Expect(result).To(BeTrue())
+	c := internal.ContainerInfo{Names: "my-app-webhooks-1"}
+	result := containsProcessType(c, "web")
+	Expect(result).To(BeFalse())
 }
 
 func (s *ContainerServiceTestSuite) TestListContainers_WithEmptyFilter() {
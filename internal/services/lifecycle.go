@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gokku/internal"
+	"gokku/pluginsdk"
+)
+
+// ServiceContainer is one of a service's containers as Docker reports it
+type ServiceContainer struct {
+	Name   string
+	Image  string
+	State  string
+	Status string
+}
+
+// ServiceStatus is a service's config together with the state of its
+// containers
+type ServiceStatus struct {
+	Service    string
+	Plugin     string
+	Version    string
+	Running    bool
+	Containers []ServiceContainer
+	LinkedApps []string
+	Networks   []string
+}
+
+// ServiceStatus reports the state of the service's containers from Docker
+// and records in config.json whether the service is running
+func (sm *ServiceManager) ServiceStatus(serviceName string) (ServiceStatus, error) {
+	service, err := sm.getServiceConfig(serviceName)
+	if err != nil {
+		return ServiceStatus{}, fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	containers, err := sm.serviceContainers(serviceName)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	status := ServiceStatus{
+		Service:    serviceName,
+		Plugin:     service.Plugin,
+		Version:    service.Config["version"],
+		LinkedApps: service.LinkedApps,
+		Networks:   service.Networks,
+	}
+
+	for _, c := range containers {
+		status.Containers = append(status.Containers, ServiceContainer{
+			Name:   containerName(c),
+			Image:  c.Image,
+			State:  c.State,
+			Status: c.Status,
+		})
+
+		if c.State == "running" {
+			status.Running = true
+		}
+	}
+
+	if service.Running != status.Running {
+		service.Running = status.Running
+
+		if err := sm.saveServiceConfig(serviceName, service); err != nil {
+			return status, fmt.Errorf("failed to update service config: %v", err)
+		}
+	}
+
+	return status, nil
+}
+
+// StartService starts the service's containers and attaches them to the
+// networks of its linked apps
+func (sm *ServiceManager) StartService(serviceName string, out io.Writer) error {
+	if err := sm.runLifecycle(serviceName, "start", out); err != nil {
+		return err
+	}
+
+	if err := sm.ConnectNetworks(serviceName); err != nil {
+		return err
+	}
+
+	return sm.refreshRunning(serviceName)
+}
+
+// StopService stops the service's containers
+func (sm *ServiceManager) StopService(serviceName string, out io.Writer) error {
+	if err := sm.runLifecycle(serviceName, "stop", out); err != nil {
+		return err
+	}
+
+	return sm.refreshRunning(serviceName)
+}
+
+// RestartService restarts the service's containers and attaches them to the
+// networks of its linked apps
+func (sm *ServiceManager) RestartService(serviceName string, out io.Writer) error {
+	if err := sm.runLifecycle(serviceName, "restart", out); err != nil {
+		return err
+	}
+
+	if err := sm.ConnectNetworks(serviceName); err != nil {
+		return err
+	}
+
+	return sm.refreshRunning(serviceName)
+}
+
+// DefaultUpgradeVersion returns the version services:upgrade moves a
+// service to when none is given: the default_version of its plugin manifest
+func (sm *ServiceManager) DefaultUpgradeVersion(serviceName string) (string, error) {
+	service, err := sm.getServiceConfig(serviceName)
+	if err != nil {
+		return "", fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	manifest, err := internal.LoadPluginManifest(filepath.Join(sm.pluginsDir, service.Plugin))
+	if err != nil {
+		return "", fmt.Errorf("plugin '%s': %v", service.Plugin, err)
+	}
+
+	if manifest == nil || manifest.DefaultVersion == "" {
+		return "", fmt.Errorf("plugin '%s' has no default_version, give the version to upgrade to", service.Plugin)
+	}
+
+	return manifest.DefaultVersion, nil
+}
+
+// UpgradeService moves a service to another version, or to the plugin's
+// default version when version is empty. Unless skipBackup is set, the
+// service is backed up first, so a failed upgrade can be undone with
+// services:restore. The plugin's upgrade step swaps the containers and
+// migrates the data.
+func (sm *ServiceManager) UpgradeService(serviceName, version string, skipBackup bool, out io.Writer) error {
+	service, err := sm.getServiceConfig(serviceName)
+	if err != nil {
+		return fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	if version == "" {
+		if version, err = sm.DefaultUpgradeVersion(serviceName); err != nil {
+			return err
+		}
+	}
+
+	current := service.Config["version"]
+	if version == current {
+		return fmt.Errorf("service '%s' is already at version %s", serviceName, version)
+	}
+
+	pluginDir := filepath.Join(sm.pluginsDir, service.Plugin)
+	script := pluginScript(pluginDir, pluginsdk.MethodUpgrade)
+
+	if script == "" && !pluginUsesRPC(pluginDir) {
+		return fmt.Errorf("plugin '%s' does not support upgrades", service.Plugin)
+	}
+
+	backupID := ""
+
+	if !skipBackup {
+		backupID, err = sm.backupBeforeUpgrade(service, out)
+		if err != nil {
+			return err
+		}
+	}
+
+	config := make(map[string]string, len(service.Config))
+	maps.Copy(config, service.Config)
+
+	params := pluginsdk.ServiceParams{Service: serviceName, Version: version, Config: config}
+
+	result, handled, err := internal.CallPlugin(pluginDir, pluginsdk.MethodUpgrade, params)
+	if err == nil && handled && result.Output != "" {
+		fmt.Fprintln(out, strings.TrimRight(result.Output, "\n"))
+	}
+
+	if err == nil && !handled {
+		if script == "" {
+			err = fmt.Errorf("plugin '%s' does not support upgrades", service.Plugin)
+		} else {
+			err = runPluginScript(pluginDir, script, out, serviceName, version, current)
+		}
+	}
+
+	if err != nil {
+		if backupID != "" {
+			fmt.Fprintf(out, "Restore the data from before the upgrade with: gokku services:restore %s %s\n", serviceName, backupID)
+		}
+
+		return fmt.Errorf("upgrade of %s to %s failed: %v", serviceName, version, err)
+	}
+
+	// The plugin may have changed the config while upgrading
+	if updated, err := sm.getServiceConfig(serviceName); err == nil {
+		service = updated
+	}
+
+	if service.Config == nil {
+		service.Config = make(map[string]string)
+	}
+
+	if result != nil {
+		maps.Copy(service.Config, result.Config)
+	}
+
+	service.Config["version"] = version
+
+	if err := sm.saveServiceConfig(serviceName, service); err != nil {
+		return fmt.Errorf("failed to update service config: %v", err)
+	}
+
+	if err := sm.ConnectNetworks(serviceName); err != nil {
+		return err
+	}
+
+	return sm.refreshRunning(serviceName)
+}
+
+// backupBeforeUpgrade backs the service up and returns the backup ID, or ""
+// when its plugin cannot take backups
+func (sm *ServiceManager) backupBeforeUpgrade(service Service, out io.Writer) (string, error) {
+	pluginDir := filepath.Join(sm.pluginsDir, service.Plugin)
+
+	if pluginScript(pluginDir, pluginsdk.MethodBackup) == "" && !pluginUsesRPC(pluginDir) {
+		fmt.Fprintf(out, "Warning: plugin '%s' has no backup command, upgrading without a backup\n", service.Plugin)
+		return "", nil
+	}
+
+	fmt.Fprintf(out, "-----> Backing up %s before the upgrade\n", service.Name)
+
+	bm := NewBackupManager(sm.baseDir)
+	bm.services = sm
+
+	backup, err := bm.Backup(service.Name, out)
+	if err != nil {
+		return "", fmt.Errorf("pre-upgrade backup failed, upgrade aborted: %v", err)
+	}
+
+	fmt.Fprintf(out, "       Backup %s stored at %s\n", backup.ID, backup.Location)
+
+	return backup.ID, nil
+}
+
+// runLifecycle runs the plugin's start, stop or restart command when it has
+// one, and otherwise the docker command of the same name on the service's
+// containers
+func (sm *ServiceManager) runLifecycle(serviceName, action string, out io.Writer) error {
+	service, err := sm.getServiceConfig(serviceName)
+	if err != nil {
+		return fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	pluginDir := filepath.Join(sm.pluginsDir, service.Plugin)
+
+	params := pluginsdk.CommandParams{Command: action, Args: []string{serviceName}}
+
+	result, handled, err := internal.CallPlugin(pluginDir, pluginsdk.MethodCommand, params)
+	if err != nil {
+		return err
+	}
+
+	if handled {
+		if result.Output != "" {
+			fmt.Fprintln(out, strings.TrimRight(result.Output, "\n"))
+		}
+
+		return nil
+	}
+
+	if script := pluginScript(pluginDir, action); script != "" {
+		return runPluginScript(pluginDir, script, out, serviceName)
+	}
+
+	containers, err := sm.findServiceContainers(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 {
+		return fmt.Errorf("no containers found for service '%s'", serviceName)
+	}
+
+	if _, err := sm.docker(append([]string{action}, containers...)...); err != nil {
+		return fmt.Errorf("failed to %s %s: %v", action, serviceName, err)
+	}
+
+	return nil
+}
+
+// refreshRunning records whether the service's containers are running
+func (sm *ServiceManager) refreshRunning(serviceName string) error {
+	_, err := sm.ServiceStatus(serviceName)
+	return err
+}
+
+// pluginScript returns the plugin's commands/ or bin/ script for a command,
+// or "" when it has none
+func pluginScript(pluginDir, command string) string {
+	for _, dir := range []string{"commands", "bin"} {
+		script := filepath.Join(pluginDir, dir, command)
+
+		if _, err := os.Stat(script); err == nil {
+			return script
+		}
+	}
+
+	return ""
+}
+
+// pluginUsesRPC reports whether the plugin speaks the JSON-RPC protocol
+func pluginUsesRPC(pluginDir string) bool {
+	manifest, err := internal.LoadPluginManifest(pluginDir)
+	return err == nil && manifest.UsesRPC()
+}
+
+// runPluginScript runs a plugin script with bash from the plugin directory
+func runPluginScript(pluginDir, script string, out io.Writer, args ...string) error {
+	cmd := exec.Command("bash", append([]string{script}, args...)...)
+	cmd.Dir = pluginDir
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	return cmd.Run()
+}
+
+func runDocker(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return output, fmt.Errorf("docker %s: %v, output: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
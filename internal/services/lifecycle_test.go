@@ -0,0 +1,242 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gokku/internal"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestServiceLifecycleTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(ServiceLifecycleTestSuite))
+}
+
+type ServiceLifecycleTestSuite struct {
+	suite.Suite
+	tempDir     string
+	manager     *ServiceManager
+	containers  []internal.ContainerInfo
+	dockerCalls []string
+}
+
+func (s *ServiceLifecycleTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "gokku-lifecycle-test-*")
+	s.Require().NoError(err)
+
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "plugins", "postgres", "commands"), 0755))
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "services", "postgres-api"), 0755))
+
+	s.manager = NewServiceManager(s.tempDir)
+	s.Require().NoError(s.manager.saveServiceConfig("postgres-api", Service{
+		Name:   "postgres-api",
+		Plugin: "postgres",
+		Config: map[string]string{"version": "14"},
+	}))
+
+	s.containers = []internal.ContainerInfo{
+		{ID: "a1", Names: "postgres-api", Image: "postgres:14", State: "running", Status: "Up 2 hours", Labels: "gokku.service=postgres-api"},
+		{ID: "b2", Names: "postgres-api-exporter", Image: "exporter:1", State: "exited", Status: "Exited (0)"},
+		{ID: "c3", Names: "postgres-api-v2", Image: "postgres:16", State: "running", Labels: "gokku.service=postgres-api-v2"},
+		{ID: "d4", Names: "redis-cache", Image: "redis:7", State: "running", Labels: "gokku.service=redis-cache"},
+	}
+
+	s.dockerCalls = nil
+	s.manager.docker = s.fakeDocker
+}
+
+func (s *ServiceLifecycleTestSuite) TearDownTest() {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+}
+
+// fakeDocker answers ps from s.containers and applies start, stop and
+// restart to them
+func (s *ServiceLifecycleTestSuite) fakeDocker(args ...string) ([]byte, error) {
+	s.dockerCalls = append(s.dockerCalls, strings.Join(args, " "))
+
+	if args[0] != "ps" {
+		state := map[string]string{"start": "running", "restart": "running", "stop": "exited"}[args[0]]
+
+		for i, c := range s.containers {
+			for _, name := range args[1:] {
+				if c.HasName(name) {
+					s.containers[i].State = state
+				}
+			}
+		}
+
+		return nil, nil
+	}
+
+	filter := args[len(args)-1]
+
+	var lines []string
+
+	for _, c := range s.containers {
+		if value, ok := strings.CutPrefix(filter, "label="); ok && c.Labels != value {
+			continue
+		}
+
+		if pattern, ok := strings.CutPrefix(filter, "name="); ok && !regexp.MustCompile(pattern).MatchString(c.Names) {
+			continue
+		}
+
+		line, _ := json.Marshal(c)
+		lines = append(lines, string(line))
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func (s *ServiceLifecycleTestSuite) writeScript(name, content string) {
+	path := filepath.Join(s.tempDir, "plugins", "postgres", "commands", name)
+	s.Require().NoError(os.WriteFile(path, []byte("#!/bin/bash\n"+content), 0755))
+}
+
+func (s *ServiceLifecycleTestSuite) TestServiceStatus_ReportsContainersFromDocker() {
+	status, err := s.manager.ServiceStatus("postgres-api")
+
+	Expect(err).To(BeNil())
+	Expect(status.Running).To(BeTrue())
+	Expect(status.Version).To(Equal("14"))
+	Expect(status.Containers).To(Equal([]ServiceContainer{
+		{Name: "postgres-api", Image: "postgres:14", State: "running", Status: "Up 2 hours"},
+		{Name: "postgres-api-exporter", Image: "exporter:1", State: "exited", Status: "Exited (0)"},
+	}))
+
+	service, err := s.manager.GetService("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(service.Running).To(BeTrue())
+}
+
+func (s *ServiceLifecycleTestSuite) TestServiceStatus_UnknownService() {
+	_, err := s.manager.ServiceStatus("missing")
+
+	Expect(err).To(MatchError("service 'missing' not found"))
+}
+
+func (s *ServiceLifecycleTestSuite) TestStopAndStartService_UseDocker() {
+	Expect(s.manager.StopService("postgres-api", &strings.Builder{})).To(Succeed())
+
+	Expect(s.dockerCalls).To(ContainElement("stop postgres-api postgres-api-exporter"))
+
+	service, err := s.manager.GetService("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(service.Running).To(BeFalse())
+
+	Expect(s.manager.StartService("postgres-api", &strings.Builder{})).To(Succeed())
+
+	Expect(s.dockerCalls).To(ContainElement("start postgres-api postgres-api-exporter"))
+
+	service, err = s.manager.GetService("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(service.Running).To(BeTrue())
+}
+
+func (s *ServiceLifecycleTestSuite) TestRestartService_PrefersPluginCommand() {
+	s.writeScript("restart", "echo \"restarting $1\"\n")
+
+	var out strings.Builder
+
+	Expect(s.manager.RestartService("postgres-api", &out)).To(Succeed())
+
+	Expect(out.String()).To(Equal("restarting postgres-api\n"))
+	Expect(s.dockerCalls).NotTo(ContainElement(HavePrefix("restart")))
+}
+
+func (s *ServiceLifecycleTestSuite) TestStartService_WithoutContainers() {
+	s.containers = nil
+
+	err := s.manager.StartService("postgres-api", &strings.Builder{})
+
+	Expect(err).To(MatchError("no containers found for service 'postgres-api'"))
+}
+
+func (s *ServiceLifecycleTestSuite) TestUpgradeService_BacksUpAndMigrates() {
+	s.writeScript("backup", "echo \"dump of $1\"\n")
+	s.writeScript("upgrade", "echo \"$@\" > \"$(dirname \"$0\")/../upgraded\"\n")
+
+	var out strings.Builder
+
+	Expect(s.manager.UpgradeService("postgres-api", "16", false, &out)).To(Succeed())
+
+	upgraded, err := os.ReadFile(filepath.Join(s.tempDir, "plugins", "postgres", "upgraded"))
+	Expect(err).To(BeNil())
+	Expect(string(upgraded)).To(Equal("postgres-api 16 14\n"))
+
+	service, err := s.manager.GetService("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(service.Config["version"]).To(Equal("16"))
+
+	backups, err := NewBackupManager(s.tempDir).List("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(backups).To(HaveLen(1))
+	Expect(out.String()).To(ContainSubstring("Backing up postgres-api before the upgrade"))
+}
+
+func (s *ServiceLifecycleTestSuite) TestUpgradeService_FailureKeepsVersion() {
+	s.writeScript("backup", "echo \"dump of $1\"\n")
+	s.writeScript("upgrade", "exit 3\n")
+
+	var out strings.Builder
+
+	err := s.manager.UpgradeService("postgres-api", "16", false, &out)
+
+	Expect(err).To(MatchError("upgrade of postgres-api to 16 failed: exit status 3"))
+	Expect(out.String()).To(MatchRegexp(`gokku services:restore postgres-api \d{8}-\d{6}\.\d{3}`))
+
+	service, err := s.manager.GetService("postgres-api")
+	Expect(err).To(BeNil())
+	Expect(service.Config["version"]).To(Equal("14"))
+}
+
+func (s *ServiceLifecycleTestSuite) TestUpgradeService_AbortsWhenBackupFails() {
+	s.writeScript("backup", "exit 1\n")
+	s.writeScript("upgrade", "touch \"$(dirname \"$0\")/../upgraded\"\n")
+
+	err := s.manager.UpgradeService("postgres-api", "16", false, &strings.Builder{})
+
+	Expect(err).To(MatchError(HavePrefix("pre-upgrade backup failed, upgrade aborted")))
+	Expect(filepath.Join(s.tempDir, "plugins", "postgres", "upgraded")).NotTo(BeAnExistingFile())
+}
+
+func (s *ServiceLifecycleTestSuite) TestUpgradeService_WithoutBackupCommand() {
+	s.writeScript("upgrade", "true\n")
+
+	var out strings.Builder
+
+	Expect(s.manager.UpgradeService("postgres-api", "16", false, &out)).To(Succeed())
+	Expect(out.String()).To(ContainSubstring("Warning: plugin 'postgres' has no backup command"))
+}
+
+func (s *ServiceLifecycleTestSuite) TestUpgradeService_DefaultsToPluginVersion() {
+	s.writeScript("upgrade", "echo \"$@\" > \"$(dirname \"$0\")/../upgraded\"\n")
+
+	_, err := s.manager.DefaultUpgradeVersion("postgres-api")
+	Expect(err).To(MatchError("plugin 'postgres' has no default_version, give the version to upgrade to"))
+
+	manifest := "name: postgres\nversion: 1.4.0\ndefault_version: \"17\"\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, "plugins", "postgres", "plugin.yml"), []byte(manifest), 0644))
+
+	Expect(s.manager.DefaultUpgradeVersion("postgres-api")).To(Equal("17"))
+	Expect(s.manager.UpgradeService("postgres-api", "", true, &strings.Builder{})).To(Succeed())
+
+	upgraded, err := os.ReadFile(filepath.Join(s.tempDir, "plugins", "postgres", "upgraded"))
+	Expect(err).To(BeNil())
+	Expect(string(upgraded)).To(Equal("postgres-api 17 14\n"))
+}
+
+func (s *ServiceLifecycleTestSuite) TestUpgradeService_Unsupported() {
+	Expect(s.manager.UpgradeService("postgres-api", "16", true, &strings.Builder{})).To(MatchError("plugin 'postgres' does not support upgrades"))
+	Expect(s.manager.UpgradeService("postgres-api", "14", true, &strings.Builder{})).To(MatchError("service 'postgres-api' is already at version 14"))
+}
@@ -6,16 +6,22 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"gokku/internal"
+	"gokku/pluginsdk"
 )
 
 // ServiceManager manages services and their lifecycle
 type ServiceManager struct {
+	baseDir     string
 	servicesDir string
 	pluginsDir  string
+
+	// docker runs a docker command and returns its stdout
+	docker func(args ...string) ([]byte, error)
 }
 
 // Service represents a service instance
@@ -27,13 +33,21 @@ type Service struct {
 	LinkedApps  []string          `json:"linked_apps"`
 	CreatedAt   string            `json:"created_at"`
 	Config      map[string]string `json:"config"`
+	Backup      *BackupPolicy     `json:"backup,omitempty"`
+	Networks    []string          `json:"networks,omitempty"`
 }
 
-// NewServiceManager creates a new service manager
-func NewServiceManager() *ServiceManager {
+// NewServiceManager creates a new service manager for the services under baseDir
+func NewServiceManager(baseDir string) *ServiceManager {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
 	return &ServiceManager{
-		servicesDir: "/opt/gokku/services",
-		pluginsDir:  "/opt/gokku/plugins",
+		baseDir:     baseDir,
+		servicesDir: filepath.Join(baseDir, "services"),
+		pluginsDir:  filepath.Join(baseDir, "plugins"),
+		docker:      runDocker,
 	}
 }
 
@@ -76,9 +90,32 @@ func (sm *ServiceManager) CreateService(pluginName, serviceName, version string)
 		return fmt.Errorf("failed to save service config: %v", err)
 	}
 
-	// Execute plugin install script
+	// jsonrpc plugins provision the service and report its config
+	params := pluginsdk.ServiceParams{Service: serviceName, Version: version, Config: service.Config}
+
+	result, handled, err := internal.CallPlugin(filepath.Join(sm.pluginsDir, pluginName), pluginsdk.MethodInstall, params)
+	if err != nil {
+		os.RemoveAll(serviceDir)
+		return err
+	}
+
+	if handled {
+		printPluginOutput(result)
+
+		for key, value := range result.Config {
+			service.Config[key] = value
+		}
+
+		return sm.saveServiceConfig(serviceName, service)
+	}
+
+	// Execute plugin install script, bin/install or the install PLUGINS.md documents
 	installScript := filepath.Join(sm.pluginsDir, pluginName, "bin", "install")
 
+	if _, err := os.Stat(installScript); err != nil {
+		installScript = filepath.Join(sm.pluginsDir, pluginName, "install")
+	}
+
 	if _, err := os.Stat(installScript); err == nil {
 		cmd := exec.Command("bash", installScript, serviceName, version)
 		cmd.Stdout = os.Stdout
@@ -93,33 +130,46 @@ func (sm *ServiceManager) CreateService(pluginName, serviceName, version string)
 	return nil
 }
 
-// LinkService links a service to an app
-func (sm *ServiceManager) LinkService(serviceName, appName, env string) error {
+// LinkService links a service to an app and returns the env vars it added.
+// With an alias the vars are prefixed, so two services of the same plugin
+// can be linked to one app.
+func (sm *ServiceManager) LinkService(serviceName, appName, env, alias string) ([]string, error) {
 	// Check if service exists
 	service, err := sm.getServiceConfig(serviceName)
 	if err != nil {
-		return fmt.Errorf("service '%s' not found: %v", serviceName, err)
+		return nil, fmt.Errorf("service '%s' not found: %v", serviceName, err)
 	}
 
 	// Check if app exists
 	if !sm.appExists(appName, env) {
-		return fmt.Errorf("app '%s' not found", appName)
+		return nil, fmt.Errorf("app '%s' not found", appName)
+	}
+
+	// Add environment variables to app
+	names, err := sm.addServiceEnvVars(service, appName, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add environment variables: %v", err)
+	}
+
+	// Put the service on the app's network, where its env resolves to its name
+	network := internal.AppNetworkName(appName)
+
+	if err := sm.connectNetwork(serviceName, network); err != nil {
+		fmt.Printf("Warning: %v, linked apps reach the service through the host\n", err)
+	} else if !slices.Contains(service.Networks, network) {
+		service.Networks = append(service.Networks, network)
 	}
 
 	// Update service config
 	if !sm.isAppLinked(service.LinkedApps, appName, env) {
 		service.LinkedApps = append(service.LinkedApps, appName)
-		if err := sm.saveServiceConfig(serviceName, service); err != nil {
-			return fmt.Errorf("failed to update service config: %v", err)
-		}
 	}
 
-	// Add environment variables to app
-	if err := sm.addServiceEnvVars(serviceName, appName, service.Plugin); err != nil {
-		return fmt.Errorf("failed to add environment variables: %v", err)
+	if err := sm.saveServiceConfig(serviceName, service); err != nil {
+		return nil, fmt.Errorf("failed to update service config: %v", err)
 	}
 
-	return nil
+	return names, nil
 }
 
 // UnlinkService unlinks a service from an app
@@ -131,7 +181,7 @@ func (sm *ServiceManager) UnlinkService(serviceName, appName, env string) error
 	}
 
 	// Remove environment variables from app
-	if err := sm.removeServiceEnvVars(serviceName, appName, service.Plugin); err != nil {
+	if err := sm.removeServiceEnvVars(serviceName, appName); err != nil {
 		return fmt.Errorf("failed to remove environment variables: %v", err)
 	}
 
@@ -144,6 +194,22 @@ func (sm *ServiceManager) UnlinkService(serviceName, appName, env string) error
 	}
 
 	service.LinkedApps = newLinkedApps
+
+	// Take the service off the app's network
+	network := internal.AppNetworkName(appName)
+
+	if slices.Contains(service.Networks, network) {
+		containers, _ := sm.findServiceContainers(serviceName)
+
+		for _, container := range containers {
+			if err := internal.DisconnectNetwork(network, container); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+
+		service.Networks = slices.DeleteFunc(service.Networks, func(n string) bool { return n == network })
+	}
+
 	if err := sm.saveServiceConfig(serviceName, service); err != nil {
 		return fmt.Errorf("failed to update service config: %v", err)
 	}
@@ -223,9 +289,18 @@ func (sm *ServiceManager) DestroyService(serviceName string) error {
 		}
 	}
 
+	params := pluginsdk.ServiceParams{Service: serviceName, Config: service.Config}
+
+	result, handled, err := internal.CallPlugin(filepath.Join(sm.pluginsDir, service.Plugin), pluginsdk.MethodUninstall, params)
+	if err != nil {
+		return err
+	}
+
+	printPluginOutput(result)
+
 	// Execute plugin uninstall script
 	uninstallScript := filepath.Join(sm.pluginsDir, service.Plugin, "uninstall")
-	if _, err := os.Stat(uninstallScript); err == nil {
+	if _, err := os.Stat(uninstallScript); err == nil && !handled {
 		fmt.Printf("-----> Executing plugin uninstall script\n")
 		cmd := exec.Command("bash", uninstallScript, serviceName)
 		cmd.Stdout = os.Stdout
@@ -235,6 +310,13 @@ func (sm *ServiceManager) DestroyService(serviceName string) error {
 		}
 	}
 
+	// Backups outlive the service, their schedule does not
+	if service.Backup != nil && service.Backup.Schedule != "" {
+		if err := InstallBackupSchedule(serviceName, "", ""); err != nil {
+			fmt.Printf("Warning: Failed to remove backup schedule: %v\n", err)
+		}
+	}
+
 	// Remove service directory
 	serviceDir := filepath.Join(sm.servicesDir, serviceName)
 	fmt.Printf("-----> Removing service directory\n")
@@ -245,6 +327,23 @@ func (sm *ServiceManager) DestroyService(serviceName string) error {
 	return nil
 }
 
+// ConnectNetworks attaches the service's containers to the networks of the
+// apps it is linked to again, as a recreated container loses them
+func (sm *ServiceManager) ConnectNetworks(serviceName string) error {
+	service, err := sm.getServiceConfig(serviceName)
+	if err != nil {
+		return fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	for _, network := range service.Networks {
+		if err := sm.connectNetwork(serviceName, network); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // UpdateServiceConfig updates service configuration
 func (sm *ServiceManager) UpdateServiceConfig(serviceName string, config map[string]string) error {
 	service, err := sm.getServiceConfig(serviceName)
@@ -257,6 +356,13 @@ func (sm *ServiceManager) UpdateServiceConfig(serviceName string, config map[str
 }
 
 // Helper methods
+
+// printPluginOutput prints the text a jsonrpc plugin returned
+func printPluginOutput(result *pluginsdk.Result) {
+	if result != nil && result.Output != "" {
+		fmt.Println(strings.TrimRight(result.Output, "\n"))
+	}
+}
 func (sm *ServiceManager) pluginExists(pluginName string) bool {
 	pluginPath := filepath.Join(sm.pluginsDir, pluginName)
 	_, err := os.Stat(pluginPath)
@@ -270,7 +376,7 @@ func (sm *ServiceManager) serviceExists(serviceName string) bool {
 }
 
 func (sm *ServiceManager) appExists(appName, env string) bool {
-	appPath := filepath.Join("/opt/gokku/apps", appName, env)
+	appPath := filepath.Join(sm.baseDir, "apps", appName, env)
 	_, err := os.Stat(appPath)
 	return !os.IsNotExist(err)
 }
@@ -296,6 +402,11 @@ func (sm *ServiceManager) getServiceConfig(serviceName string) (Service, error)
 		return Service{}, err
 	}
 
+	// Configs written by older plugins may omit the name
+	if service.Name == "" {
+		service.Name = serviceName
+	}
+
 	return service, nil
 }
 
@@ -308,195 +419,142 @@ func (sm *ServiceManager) isAppLinked(linkedApps []string, appName, env string)
 	return false
 }
 
-// addServiceEnvVars adds service environment variables to an app
-func (sm *ServiceManager) addServiceEnvVars(serviceName, appName, pluginName string) error {
-	// Get service configuration
-	service, err := sm.getServiceConfig(serviceName)
+// addServiceEnvVars adds references to the env vars the service exposes to an app
+func (sm *ServiceManager) addServiceEnvVars(service Service, appName, alias string) ([]string, error) {
+	envVars, err := sm.getServiceEnvVars(service)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Get environment variables based on plugin type
-	envVars := sm.getServiceEnvVars(serviceName, service.Config, pluginName)
 	if len(envVars) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(envVars))
+	for key := range envVars {
+		keys = append(keys, key)
 	}
 
 	// Get app env file path
-	envFile := filepath.Join("/opt/gokku/apps", appName, "shared", ".env")
+	envFile := filepath.Join(sm.baseDir, "apps", appName, "shared", ".env")
 
 	// Load existing env vars
 	existingVars := internal.LoadEnvFile(envFile)
 
-	// Add service env vars
-	for key, value := range envVars {
-		existingVars[key] = value
+	// Add service env vars as references so config changes on the service
+	// propagate to the app the next time its container is created
+	names, err := internal.LinkServiceEnv(existingVars, service.Name, alias, keys)
+	if err != nil {
+		return nil, err
 	}
 
 	// Save updated env vars
-	return internal.SaveEnvFile(envFile, existingVars)
+	return names, internal.SaveEnvFile(envFile, existingVars)
 }
 
-// removeServiceEnvVars removes service environment variables from an app
-func (sm *ServiceManager) removeServiceEnvVars(serviceName, appName, pluginName string) error {
-	// Get environment variable keys based on plugin type
-	envKeys := sm.getServiceEnvKeys(pluginName)
-	if len(envKeys) == 0 {
-		return nil
-	}
-
+// removeServiceEnvVars removes every env var referencing the service from an app
+func (sm *ServiceManager) removeServiceEnvVars(serviceName, appName string) error {
 	// Get app env file path
-	envFile := filepath.Join("/opt/gokku/apps", appName, "shared", ".env")
+	envFile := filepath.Join(sm.baseDir, "apps", appName, "shared", ".env")
 
 	// Load existing env vars
 	existingVars := internal.LoadEnvFile(envFile)
 
-	// Remove service env vars
-	for _, key := range envKeys {
-		delete(existingVars, key)
+	if len(internal.UnlinkServiceEnv(existingVars, serviceName)) == 0 {
+		return nil
 	}
 
 	// Save updated env vars
 	return internal.SaveEnvFile(envFile, existingVars)
 }
 
-// getServiceEnvVars returns environment variables for a service based on plugin type
-func (sm *ServiceManager) getServiceEnvVars(serviceName string, config map[string]string, pluginName string) map[string]string {
-	envVars := make(map[string]string)
-
-	switch pluginName {
-	case "postgres":
-		host := "localhost"
-		port := config["port"]
-		user := config["user"]
-		password := config["password"]
-		database := config["database"]
-
-		if port == "" || user == "" || password == "" || database == "" {
-			return envVars
-		}
-
-		envVars["DATABASE_URL"] = fmt.Sprintf("postgres://%s:%s@%s:%s/%s", user, password, host, port, database)
-		envVars["POSTGRES_HOST"] = host
-		envVars["POSTGRES_PORT"] = port
-		envVars["POSTGRES_USER"] = user
-		envVars["POSTGRES_PASSWORD"] = password
-		envVars["POSTGRES_DB"] = database
+// getServiceEnvVars returns the environment variables the service's plugin declares
+func (sm *ServiceManager) getServiceEnvVars(service Service) (map[string]string, error) {
+	return internal.ServiceEnv(sm.pluginsDir, service.Name, service.Plugin, service.Config)
+}
 
-	case "redis":
-		host := "localhost"
-		port := config["port"]
-		password := config["password"]
+// connectNetwork attaches the service's containers to network. The
+// container named after the service gets the service name as DNS alias.
+func (sm *ServiceManager) connectNetwork(serviceName, network string) error {
+	containers, _ := sm.findServiceContainers(serviceName)
 
-		if port == "" || password == "" {
-			return envVars
-		}
+	if len(containers) == 0 {
+		return fmt.Errorf("no containers found for service '%s'", serviceName)
+	}
 
-		envVars["REDIS_URL"] = fmt.Sprintf("redis://:%s@%s:%s", password, host, port)
-		envVars["REDIS_HOST"] = host
-		envVars["REDIS_PORT"] = port
-		envVars["REDIS_PASSWORD"] = password
+	if err := internal.EnsureNetwork(network); err != nil {
+		return err
 	}
 
-	return envVars
-}
+	for _, container := range containers {
+		var aliases []string
 
-// getServiceEnvKeys returns environment variable keys for a plugin type
-func (sm *ServiceManager) getServiceEnvKeys(pluginName string) []string {
-	switch pluginName {
-	case "postgres":
-		return []string{
-			"DATABASE_URL",
-			"POSTGRES_HOST",
-			"POSTGRES_PORT",
-			"POSTGRES_USER",
-			"POSTGRES_PASSWORD",
-			"POSTGRES_DB",
+		if container == serviceName || len(containers) == 1 {
+			aliases = []string{serviceName}
 		}
-	case "redis":
-		return []string{
-			"REDIS_URL",
-			"REDIS_HOST",
-			"REDIS_PORT",
-			"REDIS_PASSWORD",
+
+		if err := internal.ConnectNetwork(network, container, aliases...); err != nil {
+			return err
 		}
-	default:
-		return []string{}
 	}
+
+	return nil
 }
 
-// findServiceContainers finds all containers related to a service
+// findServiceContainers returns the names of a service's containers
 func (sm *ServiceManager) findServiceContainers(serviceName string) ([]string, error) {
-	var containers []string
-
-	// Find containers with exact name match
-	checkCmd := exec.Command("docker", "ps", "-aq", "-f", fmt.Sprintf("name=^%s$", serviceName))
-	output, err := checkCmd.Output()
-	if err == nil && len(strings.TrimSpace(string(output))) > 0 {
-		containerIDs := strings.Split(strings.TrimSpace(string(output)), "\n")
-		for _, id := range containerIDs {
-			if id != "" {
-				// Get container name from ID
-				nameCmd := exec.Command("docker", "inspect", "--format", "{{.Name}}", id)
-				nameOutput, err := nameCmd.Output()
-				if err == nil {
-					containerName := strings.TrimPrefix(strings.TrimSpace(string(nameOutput)), "/")
-					containers = append(containers, containerName)
-				}
-			}
-		}
+	containers, err := sm.serviceContainers(serviceName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Also find containers that start with the service name (for services with multiple containers)
-	patternCmd := exec.Command("docker", "ps", "-aq", "-f", fmt.Sprintf("name=^%s-", serviceName))
-	patternOutput, err := patternCmd.Output()
-	if err == nil && len(strings.TrimSpace(string(patternOutput))) > 0 {
-		containerIDs := strings.Split(strings.TrimSpace(string(patternOutput)), "\n")
-		for _, id := range containerIDs {
-			if id != "" {
-				// Get container name from ID
-				nameCmd := exec.Command("docker", "inspect", "--format", "{{.Name}}", id)
-				nameOutput, err := nameCmd.Output()
-				if err == nil {
-					containerName := strings.TrimPrefix(strings.TrimSpace(string(nameOutput)), "/")
-					// Avoid duplicates
-					found := false
-					for _, existing := range containers {
-						if existing == containerName {
-							found = true
-							break
-						}
-					}
-					if !found {
-						containers = append(containers, containerName)
-					}
-				}
-			}
-		}
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		names = append(names, containerName(c))
 	}
 
-	return containers, nil
+	return names, nil
 }
 
-// getServiceContainerInfo gets container information from Docker
-func (sm *ServiceManager) getServiceContainerInfo(serviceName string) (map[string]string, error) {
-	info := make(map[string]string)
+// serviceContainers finds the containers labelled gokku.service=<service>
+// and, for plugins that do not label them, those named <service> or
+// <service>-*, unless another service's label claims them
+func (sm *ServiceManager) serviceContainers(serviceName string) ([]internal.ContainerInfo, error) {
+	var containers []internal.ContainerInfo
+	seen := make(map[string]bool)
 
-	// Check if container exists
-	checkCmd := exec.Command("docker", "ps", "-aq", "-f", fmt.Sprintf("name=^%s$", serviceName))
-	output, err := checkCmd.Output()
-	if err != nil || len(strings.TrimSpace(string(output))) == 0 {
-		return info, fmt.Errorf("container not found")
+	filters := []string{
+		fmt.Sprintf("label=%s=%s", internal.LabelService, serviceName),
+		fmt.Sprintf("name=^%s$", serviceName),
+		fmt.Sprintf("name=^%s-", serviceName),
 	}
 
-	// Check if container is running
-	runningCmd := exec.Command("docker", "ps", "-q", "-f", fmt.Sprintf("name=^%s$", serviceName))
-	runningOutput, err := runningCmd.Output()
-	if err != nil || len(strings.TrimSpace(string(runningOutput))) == 0 {
-		info["running"] = "false"
-		return info, nil
+	for _, filter := range filters {
+		output, err := sm.docker("ps", "-a", "--format", "json", "--filter", filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers: %v", err)
+		}
+
+		for _, line := range strings.Split(string(output), "\n") {
+			var c internal.ContainerInfo
+			if json.Unmarshal([]byte(line), &c) != nil || seen[c.ID] {
+				continue
+			}
+
+			if label := c.Label(internal.LabelService); label != "" && label != serviceName {
+				continue
+			}
+
+			seen[c.ID] = true
+			containers = append(containers, c)
+		}
 	}
 
-	info["running"] = "true"
-	return info, nil
+	return containers, nil
+}
+
+// containerName returns the first name of a container
+func containerName(c internal.ContainerInfo) string {
+	name, _, _ := strings.Cut(c.Names, ",")
+	return strings.TrimPrefix(strings.TrimSpace(name), "/")
 }
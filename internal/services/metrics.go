@@ -0,0 +1,451 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokku/internal"
+)
+
+// DefaultMetricsAddress is where gokku metrics:serve listens by default
+const DefaultMetricsAddress = "127.0.0.1:9110"
+
+// ContainerStats are the resource usage figures of a running container
+type ContainerStats struct {
+	CPUPercent  float64
+	MemoryBytes float64
+	MemoryLimit float64
+}
+
+// MetricsCollector gathers container, deploy and service metrics in the
+// Prometheus text format
+type MetricsCollector struct {
+	baseDir string
+
+	listContainers func(all bool) ([]internal.ContainerInfo, error)
+	containerStats func() (map[string]ContainerStats, error)
+	restartCounts  func(names []string) (map[string]int, error)
+	listServices   func() ([]Service, error)
+	runningDocker  func() ([]internal.ContainerInfo, error)
+}
+
+// NewMetricsCollector creates a new MetricsCollector
+func NewMetricsCollector(baseDir string) *MetricsCollector {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &MetricsCollector{
+		baseDir:        baseDir,
+		listContainers: internal.ListContainers,
+		containerStats: dockerContainerStats,
+		restartCounts:  dockerRestartCounts,
+		listServices:   NewServiceManager(baseDir).ListServices,
+		runningDocker:  dockerRunningContainers,
+	}
+}
+
+// Handler serves the metrics on every request
+func (m *MetricsCollector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		if err := m.WriteMetrics(w); err != nil {
+			fmt.Fprintf(os.Stderr, "Error collecting metrics: %v\n", err)
+		}
+	})
+}
+
+// WriteMetrics writes all metrics to w. Sources that fail are skipped and
+// reported through gokku_scrape_error.
+func (m *MetricsCollector) WriteMetrics(w io.Writer) error {
+	out := newMetricsWriter(w)
+	var errs []string
+
+	if err := m.writeContainerMetrics(out); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if err := m.writeDeployMetrics(out); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if err := m.writeServiceMetrics(out); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	scrapeError := 0.0
+
+	if len(errs) > 0 {
+		scrapeError = 1
+	}
+
+	out.family("gokku_scrape_error", "gauge", "Whether collecting any metric source failed")
+	out.sample("gokku_scrape_error", nil, scrapeError)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (m *MetricsCollector) writeContainerMetrics(out *metricsWriter) error {
+	containers, err := m.listContainers(true)
+
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Names < containers[j].Names
+	})
+
+	names := make([]string, 0, len(containers))
+
+	for _, c := range containers {
+		names = append(names, c.Names)
+	}
+
+	// Stats and restart counts are best effort; the up metric is still useful without them
+	stats, statsErr := m.containerStats()
+	restarts, restartsErr := m.restartCounts(names)
+
+	out.family("gokku_container_up", "gauge", "Whether the container is running")
+
+	for _, c := range containers {
+		up := 0.0
+
+		if c.State == "running" {
+			up = 1
+		}
+
+		out.sample("gokku_container_up", containerLabels(c), up)
+	}
+
+	out.family("gokku_container_cpu_percent", "gauge", "CPU usage of the container in percent of one core")
+
+	for _, c := range containers {
+		if s, ok := stats[c.Names]; ok {
+			out.sample("gokku_container_cpu_percent", containerLabels(c), s.CPUPercent)
+		}
+	}
+
+	out.family("gokku_container_memory_bytes", "gauge", "Memory used by the container")
+
+	for _, c := range containers {
+		if s, ok := stats[c.Names]; ok {
+			out.sample("gokku_container_memory_bytes", containerLabels(c), s.MemoryBytes)
+		}
+	}
+
+	out.family("gokku_container_memory_limit_bytes", "gauge", "Memory limit of the container")
+
+	for _, c := range containers {
+		if s, ok := stats[c.Names]; ok {
+			out.sample("gokku_container_memory_limit_bytes", containerLabels(c), s.MemoryLimit)
+		}
+	}
+
+	out.family("gokku_container_restarts_total", "counter", "Times Docker restarted the container")
+
+	for _, c := range containers {
+		if count, ok := restarts[c.Names]; ok {
+			out.sample("gokku_container_restarts_total", containerLabels(c), float64(count))
+		}
+	}
+
+	if statsErr != nil {
+		return statsErr
+	}
+
+	return restartsErr
+}
+
+func (m *MetricsCollector) writeDeployMetrics(out *metricsWriter) error {
+	entries, err := os.ReadDir(filepath.Join(m.baseDir, "apps"))
+
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to list apps: %v", err)
+	}
+
+	stats := make(map[string]internal.DeployStats)
+	var apps []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		s, err := internal.ReadDeployStats(m.baseDir, entry.Name())
+
+		if err != nil {
+			continue
+		}
+
+		stats[entry.Name()] = s
+		apps = append(apps, entry.Name())
+	}
+
+	out.family("gokku_deploys_total", "counter", "Deploys by outcome")
+
+	for _, app := range apps {
+		out.sample("gokku_deploys_total", []string{"app", app, "status", internal.DeployStatusSucceeded}, float64(stats[app].Succeeded))
+		out.sample("gokku_deploys_total", []string{"app", app, "status", internal.DeployStatusFailed}, float64(stats[app].Failed))
+	}
+
+	out.family("gokku_deploy_duration_seconds", "summary", "Time taken by deploys")
+
+	for _, app := range apps {
+		s := stats[app]
+		out.sample("gokku_deploy_duration_seconds_sum", []string{"app", app}, s.DurationSeconds)
+		out.sample("gokku_deploy_duration_seconds_count", []string{"app", app}, float64(s.Succeeded+s.Failed))
+	}
+
+	out.family("gokku_deploy_last_duration_seconds", "gauge", "Time taken by the last deploy")
+
+	for _, app := range apps {
+		if last := stats[app].Last; last != nil {
+			out.sample("gokku_deploy_last_duration_seconds", []string{"app", app, "release", last.Release, "status", last.Status}, last.Duration)
+		}
+	}
+
+	out.family("gokku_deploy_last_success_timestamp_seconds", "gauge", "Unix time the last successful deploy finished")
+
+	for _, app := range apps {
+		if last := stats[app].LastSucceeded; last != nil {
+			if finished, err := time.Parse(time.RFC3339, last.FinishedAt); err == nil {
+				out.sample("gokku_deploy_last_success_timestamp_seconds", []string{"app", app, "release", last.Release}, float64(finished.Unix()))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MetricsCollector) writeServiceMetrics(out *metricsWriter) error {
+	services, err := m.listServices()
+
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to list services: %v", err)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	var running []internal.ContainerInfo
+
+	if len(services) > 0 {
+		running, err = m.runningDocker()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	out.family("gokku_service_up", "gauge", "Whether the service container is running")
+
+	for _, service := range services {
+		up := 0.0
+
+		if slices.ContainsFunc(running, func(c internal.ContainerInfo) bool {
+			return serviceOwnsContainer(service.Name, c)
+		}) {
+			up = 1
+		}
+
+		out.sample("gokku_service_up", []string{"service", service.Name, "plugin", service.Plugin}, up)
+	}
+
+	return nil
+}
+
+// serviceOwnsContainer matches a container the way serviceContainers finds
+// them: by its gokku.service label, or by name when it has none
+func serviceOwnsContainer(serviceName string, c internal.ContainerInfo) bool {
+	if label := c.Label(internal.LabelService); label != "" {
+		return label == serviceName
+	}
+
+	name := containerName(c)
+
+	return name == serviceName || strings.HasPrefix(name, serviceName+"-")
+}
+
+// containerLabels returns the metric labels of a container
+func containerLabels(c internal.ContainerInfo) []string {
+	return []string{
+		"container", c.Names,
+		"app", c.Label(internal.LabelApp),
+		"process", c.ProcessType(),
+		"release", c.Label(internal.LabelRelease),
+	}
+}
+
+// dockerRunningContainers lists every running container in one docker ps,
+// which is all a scrape needs to tell which services are up
+func dockerRunningContainers() ([]internal.ContainerInfo, error) {
+	output, err := exec.Command("docker", "ps", "--format", "json").Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running containers: %v", err)
+	}
+
+	var containers []internal.ContainerInfo
+
+	for _, line := range strings.Split(string(output), "\n") {
+		var c internal.ContainerInfo
+
+		if json.Unmarshal([]byte(line), &c) == nil {
+			containers = append(containers, c)
+		}
+	}
+
+	return containers, nil
+}
+
+// dockerContainerStats reads the usage of running containers from docker stats
+func dockerContainerStats() (map[string]ContainerStats, error) {
+	output, err := exec.Command("docker", "stats", "--no-stream", "--format", "{{json .}}").Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker stats: %v", err)
+	}
+
+	return ParseDockerStats(string(output)), nil
+}
+
+// ParseDockerStats parses docker stats --format '{{json .}}' output
+func ParseDockerStats(output string) map[string]ContainerStats {
+	stats := make(map[string]ContainerStats)
+
+	for _, line := range strings.Split(output, "\n") {
+		var row struct {
+			Name     string `json:"Name"`
+			CPUPerc  string `json:"CPUPerc"`
+			MemUsage string `json:"MemUsage"`
+		}
+
+		if strings.TrimSpace(line) == "" || json.Unmarshal([]byte(line), &row) != nil {
+			continue
+		}
+
+		var s ContainerStats
+
+		s.CPUPercent, _ = strconv.ParseFloat(strings.TrimSuffix(row.CPUPerc, "%"), 64)
+
+		used, limit, _ := strings.Cut(row.MemUsage, "/")
+		s.MemoryBytes = ParseByteSize(used)
+		s.MemoryLimit = ParseByteSize(limit)
+
+		stats[row.Name] = s
+	}
+
+	return stats
+}
+
+// byteUnits maps the units printed by docker stats to their size
+var byteUnits = map[string]float64{
+	"B":   1,
+	"kB":  1e3,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// ParseByteSize parses sizes like "12.5MiB" or "1GB", returning 0 when invalid
+func ParseByteSize(s string) float64 {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+
+	if i <= 0 {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(s[:i], 64)
+
+	if err != nil {
+		return 0
+	}
+
+	return value * byteUnits[strings.TrimSpace(s[i:])]
+}
+
+// dockerRestartCounts returns how many times Docker restarted each container
+func dockerRestartCounts(names []string) (map[string]int, error) {
+	counts := make(map[string]int)
+
+	if len(names) == 0 {
+		return counts, nil
+	}
+
+	args := append([]string{"inspect", "--format", "{{.Name}} {{.RestartCount}}"}, names...)
+	output, err := exec.Command("docker", args...).Output()
+
+	if err != nil {
+		return counts, fmt.Errorf("failed to read restart counts: %v", err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		name, count, ok := strings.Cut(strings.TrimSpace(line), " ")
+
+		if !ok {
+			continue
+		}
+
+		if n, err := strconv.Atoi(count); err == nil {
+			counts[strings.TrimPrefix(name, "/")] = n
+		}
+	}
+
+	return counts, nil
+}
+
+// labelValueEscaper escapes label values as the text format requires
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter writes the Prometheus text exposition format
+type metricsWriter struct {
+	w io.Writer
+}
+
+func newMetricsWriter(w io.Writer) *metricsWriter {
+	return &metricsWriter{w: w}
+}
+
+func (m *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a sample; labels are name/value pairs
+func (m *metricsWriter) sample(name string, labels []string, value float64) {
+	var pairs []string
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelValueEscaper.Replace(labels[i+1])))
+	}
+
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	fmt.Fprintf(m.w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gokku/internal"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestMetricsCollectorTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(MetricsCollectorTestSuite))
+}
+
+type MetricsCollectorTestSuite struct {
+	suite.Suite
+	tempDir   string
+	collector *MetricsCollector
+}
+
+func (s *MetricsCollectorTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+
+	s.collector = NewMetricsCollector(s.tempDir)
+	s.collector.listContainers = func(all bool) ([]internal.ContainerInfo, error) {
+		return []internal.ContainerInfo{
+			{Names: "api-worker-1", State: "exited", Labels: "gokku.app=api,gokku.process=worker,gokku.release=20240101-120000"},
+			{Names: "api", State: "running", Labels: "gokku.app=api,gokku.process=web,gokku.release=20240101-120000"},
+		}, nil
+	}
+	s.collector.containerStats = func() (map[string]ContainerStats, error) {
+		return map[string]ContainerStats{"api": {CPUPercent: 1.5, MemoryBytes: 1048576, MemoryLimit: 2147483648}}, nil
+	}
+	s.collector.restartCounts = func(names []string) (map[string]int, error) {
+		return map[string]int{"api": 0, "api-worker-1": 4}, nil
+	}
+	s.collector.listServices = func() ([]Service, error) {
+		return []Service{{Name: "redis-cache", Plugin: "redis"}, {Name: "postgres-api", Plugin: "postgres"}}, nil
+	}
+	s.collector.runningDocker = func() ([]internal.ContainerInfo, error) {
+		return []internal.ContainerInfo{
+			{Names: "api", State: "running"},
+			{Names: "pg-main", State: "running", Labels: "gokku.service=postgres-api"},
+			{Names: "redis-cache-old", State: "running", Labels: "gokku.service=redis-legacy"},
+		}, nil
+	}
+}
+
+func (s *MetricsCollectorTestSuite) TestWriteMetrics_ContainersAndServices() {
+	var out bytes.Buffer
+
+	Expect(s.collector.WriteMetrics(&out)).To(BeNil())
+
+	metrics := out.String()
+
+	Expect(metrics).To(ContainSubstring("# TYPE gokku_container_up gauge\n" +
+		`gokku_container_up{container="api",app="api",process="web",release="20240101-120000"} 1` + "\n" +
+		`gokku_container_up{container="api-worker-1",app="api",process="worker",release="20240101-120000"} 0` + "\n"))
+	Expect(metrics).To(ContainSubstring(`gokku_container_cpu_percent{container="api",app="api",process="web",release="20240101-120000"} 1.5`))
+	Expect(metrics).To(ContainSubstring(`gokku_container_memory_bytes{container="api",app="api",process="web",release="20240101-120000"} 1.048576e+06`))
+	Expect(metrics).To(ContainSubstring(`gokku_container_restarts_total{container="api-worker-1",app="api",process="worker",release="20240101-120000"} 4`))
+	Expect(metrics).To(ContainSubstring(`gokku_service_up{service="postgres-api",plugin="postgres"} 1`))
+	Expect(metrics).To(ContainSubstring(`gokku_service_up{service="redis-cache",plugin="redis"} 0`))
+	Expect(metrics).To(HaveSuffix("gokku_scrape_error 0\n"))
+}
+
+func (s *MetricsCollectorTestSuite) TestWriteMetrics_ListsServiceContainersOncePerScrape() {
+	calls := 0
+	s.collector.runningDocker = func() ([]internal.ContainerInfo, error) {
+		calls++
+		return []internal.ContainerInfo{{Names: "redis-cache-1", State: "running"}}, nil
+	}
+
+	var out bytes.Buffer
+
+	Expect(s.collector.WriteMetrics(&out)).To(BeNil())
+	Expect(calls).To(Equal(1))
+	Expect(out.String()).To(ContainSubstring(`gokku_service_up{service="redis-cache",plugin="redis"} 1`))
+	Expect(out.String()).To(ContainSubstring(`gokku_service_up{service="postgres-api",plugin="postgres"} 0`))
+}
+
+func (s *MetricsCollectorTestSuite) TestWriteMetrics_Deploys() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "apps", "api"), 0755))
+
+	_, err := internal.RecordDeploy(s.tempDir, "api", "20240101-120000", time.Now(), nil)
+	s.Require().NoError(err)
+	_, err = internal.RecordDeploy(s.tempDir, "api", "20240101-130000", time.Now(), errors.New("boom"))
+	s.Require().NoError(err)
+
+	var out bytes.Buffer
+
+	Expect(s.collector.WriteMetrics(&out)).To(BeNil())
+	Expect(out.String()).To(ContainSubstring(`gokku_deploys_total{app="api",status="succeeded"} 1`))
+	Expect(out.String()).To(ContainSubstring(`gokku_deploys_total{app="api",status="failed"} 1`))
+	Expect(out.String()).To(ContainSubstring(`gokku_deploy_duration_seconds_count{app="api"} 2`))
+	Expect(out.String()).To(ContainSubstring(`gokku_deploy_last_duration_seconds{app="api",release="20240101-130000",status="failed"}`))
+	Expect(out.String()).To(ContainSubstring(`gokku_deploy_last_success_timestamp_seconds{app="api",release="20240101-120000"}`))
+}
+
+func (s *MetricsCollectorTestSuite) TestWriteMetrics_ReportsScrapeErrors() {
+	s.collector.containerStats = func() (map[string]ContainerStats, error) {
+		return nil, errors.New("docker unavailable")
+	}
+
+	var out bytes.Buffer
+
+	Expect(s.collector.WriteMetrics(&out)).To(MatchError(ContainSubstring("docker unavailable")))
+	Expect(out.String()).To(ContainSubstring("gokku_container_up{"))
+	Expect(out.String()).To(HaveSuffix("gokku_scrape_error 1\n"))
+}
+
+func (s *MetricsCollectorTestSuite) TestParseDockerStats() {
+	output := `{"Name":"api","CPUPerc":"12.34%","MemUsage":"512MiB / 1.5GiB","MemPerc":"33.33%"}
+{"Name":"worker","CPUPerc":"0.00%","MemUsage":"10.5MB / 2GB"}
+`
+
+	stats := ParseDockerStats(output)
+
+	Expect(stats["api"]).To(Equal(ContainerStats{CPUPercent: 12.34, MemoryBytes: 512 << 20, MemoryLimit: 1.5 * (1 << 30)}))
+	Expect(stats["worker"]).To(Equal(ContainerStats{MemoryBytes: 10.5e6, MemoryLimit: 2e9}))
+}
+
+func (s *MetricsCollectorTestSuite) TestParseByteSize() {
+	Expect(ParseByteSize("0B")).To(Equal(0.0))
+	Expect(ParseByteSize(" 1.5KiB ")).To(Equal(1536.0))
+	Expect(ParseByteSize("abc")).To(Equal(0.0))
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gokku/internal"
+	"gokku/internal/containers"
+)
+
+// processContainerPattern matches containers named <app>-<process>-<number>
+var processContainerPattern = regexp.MustCompile(`^(.+)-([a-z0-9_]+)-(\d+)$`)
+
+// Reconciler keeps the container registry in sync with Docker
+type Reconciler struct {
+	baseDir  string
+	registry *containers.ContainerRegistry
+
+	listContainers  func(all bool) ([]internal.ContainerInfo, error)
+	removeContainer func(name string) error
+}
+
+// NewReconciler creates a new Reconciler
+func NewReconciler(baseDir string) *Reconciler {
+	if baseDir == "" {
+		baseDir = "/opt/gokku"
+	}
+
+	return &Reconciler{
+		baseDir:        baseDir,
+		registry:       containers.NewContainerRegistryAt(filepath.Join(baseDir, "apps")),
+		listContainers: internal.ListContainers,
+		removeContainer: func(name string) error {
+			return internal.RemoveContainer(name, true)
+		},
+	}
+}
+
+// Reconcile compares registry entries with labelled Docker containers and
+// fixes the registry. Stopped containers that no app owns are only removed
+// when opts.Prune is set.
+func (r *Reconciler) Reconcile(opts ReconcileOptions) ([]ReconcileAction, error) {
+	dockerContainers, err := r.listContainers(true)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	byName := make(map[string]internal.ContainerInfo, len(dockerContainers))
+
+	for _, c := range dockerContainers {
+		byName[c.Names] = c
+	}
+
+	apps := r.listApps()
+	registered := make(map[string]bool)
+
+	var actions []ReconcileAction
+
+	// Registry → Docker: drop entries without a container, fix drifted status
+	for _, appName := range apps {
+		entries, err := r.registry.GetAllContainers(appName)
+
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			registered[entry.Name] = true
+			c, exists := byName[entry.Name]
+
+			if !exists {
+				actions = append(actions, ReconcileAction{
+					Kind:        ReconcileUnregister,
+					AppName:     appName,
+					ProcessType: entry.ProcessType,
+					Number:      entry.Number,
+					Container:   entry.Name,
+					From:        entry.Status,
+				})
+
+				continue
+			}
+
+			if status := containerStatus(c); status != entry.Status {
+				actions = append(actions, ReconcileAction{
+					Kind:        ReconcileStatus,
+					AppName:     appName,
+					ProcessType: entry.ProcessType,
+					Number:      entry.Number,
+					Container:   entry.Name,
+					From:        entry.Status,
+					To:          status,
+				})
+			}
+		}
+	}
+
+	// Docker → Registry: adopt app containers, flag the rest as orphans
+	appSet := make(map[string]bool, len(apps))
+
+	for _, appName := range apps {
+		appSet[appName] = true
+	}
+
+	for _, c := range dockerContainers {
+		if registered[c.Names] {
+			continue
+		}
+
+		status := containerStatus(c)
+
+		if appName, processType, number, ok := ownerOf(c, appSet); ok {
+			actions = append(actions, ReconcileAction{
+				Kind:        ReconcileRegister,
+				AppName:     appName,
+				ProcessType: processType,
+				Number:      number,
+				Container:   c.Names,
+				To:          status,
+			})
+
+			continue
+		}
+
+		kind := ReconcileOrphan
+
+		if status != "running" && opts.Prune {
+			kind = ReconcileRemove
+		}
+
+		actions = append(actions, ReconcileAction{Kind: kind, Container: c.Names, From: status})
+	}
+
+	if opts.DryRun {
+		return actions, nil
+	}
+
+	for _, action := range actions {
+		if err := r.apply(action, byName[action.Container]); err != nil {
+			return actions, err
+		}
+	}
+
+	return actions, nil
+}
+
+func (r *Reconciler) apply(action ReconcileAction, c internal.ContainerInfo) error {
+	switch action.Kind {
+	case ReconcileUnregister:
+		return r.registry.RemoveContainerInfo(action.AppName, action.ProcessType, action.Number)
+	case ReconcileStatus:
+		return r.registry.UpdateContainerStatus(action.AppName, action.ProcessType, action.Number, action.To)
+	case ReconcileRegister:
+		info := containers.CreateContainerInfo(action.AppName, action.ProcessType, action.Number, 0, 0)
+		info.Name = action.Container
+		info.Status = action.To
+
+		if c.Created != "" {
+			info.CreatedAt = c.Created
+		}
+
+		return r.registry.SaveContainerInfo(info)
+	case ReconcileRemove:
+		return r.removeContainer(action.Container)
+	}
+
+	return nil
+}
+
+// listApps returns the app names that have a directory under baseDir/apps
+func (r *Reconciler) listApps() []string {
+	entries, err := os.ReadDir(filepath.Join(r.baseDir, "apps"))
+
+	if err != nil {
+		return nil
+	}
+
+	var apps []string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			apps = append(apps, entry.Name())
+		}
+	}
+
+	sort.Strings(apps)
+
+	return apps
+}
+
+// ownerOf resolves which app process a container belongs to. Labelled
+// containers use gokku.app and gokku.process; older ones fall back to the
+// name: <app> is the app's main web container, <app>-<process>-<n> a scaled
+// process. Green and one-off containers are never registered.
+func ownerOf(c internal.ContainerInfo, apps map[string]bool) (string, string, int, bool) {
+	name := c.Names
+
+	if appName := c.Label(internal.LabelApp); appName != "" {
+		role := c.Label(internal.LabelRole)
+
+		if !apps[appName] || role == internal.RoleGreen || role == internal.RoleOneOff {
+			return "", "", 0, false
+		}
+
+		number := 1
+
+		if matches := processContainerPattern.FindStringSubmatch(name); matches != nil {
+			number, _ = strconv.Atoi(matches[3])
+		}
+
+		return appName, c.ProcessType(), number, true
+	}
+
+	if apps[name] {
+		return name, internal.DefaultProcessType, 1, true
+	}
+
+	if matches := processContainerPattern.FindStringSubmatch(name); matches != nil && apps[matches[1]] {
+		number, _ := strconv.Atoi(matches[3])
+		return matches[1], matches[2], number, true
+	}
+
+	return "", "", 0, false
+}
+
+// containerStatus maps the Docker state to a registry status
+func containerStatus(c internal.ContainerInfo) string {
+	state := strings.ToLower(c.State)
+
+	if state == "" {
+		// Older Docker versions only report the human readable status
+		switch {
+		case strings.HasPrefix(c.Status, "Up") && strings.Contains(c.Status, "Paused"):
+			state = "paused"
+		case strings.HasPrefix(c.Status, "Up"):
+			state = "running"
+		case strings.HasPrefix(c.Status, "Restarting"):
+			state = "restarting"
+		default:
+			state = "exited"
+		}
+	}
+
+	switch state {
+	case "exited", "dead", "created":
+		return "stopped"
+	}
+
+	return state
+}
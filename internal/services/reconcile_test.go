@@ -0,0 +1,179 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gokku/internal"
+	"gokku/internal/containers"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestReconcilerTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(ReconcilerTestSuite))
+}
+
+type ReconcilerTestSuite struct {
+	suite.Suite
+	tempDir    string
+	reconciler *Reconciler
+	registry   *containers.ContainerRegistry
+	docker     []internal.ContainerInfo
+	removed    []string
+}
+
+func (s *ReconcilerTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "gokku-test-*")
+	s.Require().NoError(err)
+
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "apps", "api"), 0755))
+
+	s.docker = nil
+	s.removed = nil
+	s.registry = containers.NewContainerRegistryAt(filepath.Join(s.tempDir, "apps"))
+
+	s.reconciler = NewReconciler(s.tempDir)
+	s.reconciler.listContainers = func(all bool) ([]internal.ContainerInfo, error) {
+		return s.docker, nil
+	}
+	s.reconciler.removeContainer = func(name string) error {
+		s.removed = append(s.removed, name)
+		return nil
+	}
+}
+
+func (s *ReconcilerTestSuite) TearDownTest() {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+}
+
+func (s *ReconcilerTestSuite) register(name, processType string, number int, status string) {
+	info := containers.CreateContainerInfo("api", processType, number, 0, 0)
+	info.Name = name
+	info.Status = status
+	s.Require().NoError(s.registry.SaveContainerInfo(info))
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_WhenInSync() {
+	s.register("api", "web", 1, "running")
+	s.docker = []internal.ContainerInfo{{Names: "api", State: "running"}}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{})
+
+	Expect(err).To(BeNil())
+	Expect(actions).To(BeEmpty())
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_UpdatesDriftedStatus() {
+	s.register("api", "web", 1, "running")
+	s.docker = []internal.ContainerInfo{{Names: "api", State: "exited"}}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{})
+
+	Expect(err).To(BeNil())
+	Expect(actions).To(HaveLen(1))
+	Expect(actions[0].Kind).To(Equal(ReconcileStatus))
+	Expect(actions[0].To).To(Equal("stopped"))
+
+	info, _ := s.registry.GetContainerByNumber("api", "web", 1)
+	Expect(info.Status).To(Equal("stopped"))
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_UnregistersMissingContainers() {
+	s.register("api-worker-1", "worker", 1, "running")
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{})
+
+	Expect(err).To(BeNil())
+	Expect(actions[0].Kind).To(Equal(ReconcileUnregister))
+
+	entries, _ := s.registry.GetAllContainers("api")
+	Expect(entries).To(BeEmpty())
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_RegistersAppContainers() {
+	s.docker = []internal.ContainerInfo{
+		{Names: "api", State: "running"},
+		{Names: "api-worker-2", Status: "Exited (137) 5 minutes ago"},
+	}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{})
+
+	Expect(err).To(BeNil())
+	Expect(actions).To(HaveLen(2))
+
+	web, err := s.registry.GetContainerByNumber("api", "web", 1)
+	Expect(err).To(BeNil())
+	Expect(web.Name).To(Equal("api"))
+	Expect(web.Status).To(Equal("running"))
+
+	worker, err := s.registry.GetContainerByNumber("api", "worker", 2)
+	Expect(err).To(BeNil())
+	Expect(worker.Status).To(Equal("stopped"))
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_LeavesOrphansWithoutPrune() {
+	s.docker = []internal.ContainerInfo{{Names: "api-green", State: "exited"}}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{})
+
+	Expect(err).To(BeNil())
+	Expect(actions[0].Kind).To(Equal(ReconcileOrphan))
+	Expect(s.removed).To(BeEmpty())
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_PrunesStoppedOrphans() {
+	s.docker = []internal.ContainerInfo{
+		{Names: "api-green", State: "exited"},
+		{Names: "legacy", State: "running"},
+	}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{Prune: true})
+
+	Expect(err).To(BeNil())
+	Expect(actions).To(HaveLen(2))
+	Expect(s.removed).To(Equal([]string{"api-green"}))
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_WithDryRun() {
+	s.register("api", "web", 1, "running")
+	s.docker = []internal.ContainerInfo{
+		{Names: "api", State: "exited"},
+		{Names: "api-green", State: "exited"},
+	}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{DryRun: true, Prune: true})
+
+	Expect(err).To(BeNil())
+	Expect(actions).To(HaveLen(2))
+	Expect(s.removed).To(BeEmpty())
+
+	info, _ := s.registry.GetContainerByNumber("api", "web", 1)
+	Expect(info.Status).To(Equal("running"))
+}
+
+func (s *ReconcilerTestSuite) TestReconcile_RegistersByLabels() {
+	s.docker = []internal.ContainerInfo{
+		{Names: "api-worker-3", State: "running", Labels: "createdby=gokku,gokku.app=api,gokku.process=worker"},
+		{Names: "api-green", State: "running", Labels: "createdby=gokku,gokku.app=api,gokku.role=green"},
+		{Names: "api-admin", State: "running", Labels: "createdby=gokku,gokku.app=api-admin"},
+	}
+
+	actions, err := s.reconciler.Reconcile(ReconcileOptions{})
+
+	Expect(err).To(BeNil())
+	Expect(actions).To(HaveLen(3))
+	Expect(actions[0].Kind).To(Equal(ReconcileRegister))
+	Expect(actions[1].Kind).To(Equal(ReconcileOrphan))
+	Expect(actions[2].Kind).To(Equal(ReconcileOrphan))
+
+	worker, err := s.registry.GetContainerByNumber("api", "worker", 3)
+	Expect(err).To(BeNil())
+	Expect(worker.Name).To(Equal("api-worker-3"))
+}
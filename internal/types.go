@@ -3,45 +3,48 @@ package internal
 import (
 	"fmt"
 	"os"
-
-	"gopkg.in/yaml.v3"
 )
 
 // ServerConfig represents the gokku.yml configuration on the server
 type ServerConfig struct {
-	Apps         map[string]App `yaml:"apps"`
-	Defaults     *Defaults      `yaml:"defaults,omitempty"`
-	Docker       *Docker        `yaml:"docker,omitempty"`
-	Environments []Environment  `yaml:"environments,omitempty"`
+	Apps         map[string]App `yaml:"apps" doc:"Applications keyed by name"`
+	Defaults     *Defaults      `yaml:"defaults,omitempty" doc:"Defaults applied to every app"`
+	Docker       *Docker        `yaml:"docker,omitempty" doc:"Docker settings"`
+	Environments []Environment  `yaml:"environments,omitempty" doc:"Environments shared by all apps"`
+	PortStrategy string         `yaml:"port_strategy,omitempty" doc:"How host ports are assigned: manual uses apps[].ports, auto assigns sequential ports from base_port"`
+	BasePort     int            `yaml:"base_port,omitempty" doc:"First port assigned when port_strategy is auto"`
 }
 
 // Config represents the CLI configuration
 type Config struct {
-	Apps map[string]App `yaml:"apps"`
+	Apps map[string]App `yaml:"apps" doc:"Applications keyed by name"`
 }
 
 // App represents an application configuration
 type App struct {
-	Name         string            `yaml:"name,omitempty"`
-	Lang         string            `yaml:"lang,omitempty"`
-	Path         string            `yaml:"path,omitempty"`
-	WorkDir      string            `yaml:"workdir,omitempty"`
-	BinaryName   string            `yaml:"binary_name,omitempty"`
-	GoVersion    string            `yaml:"go_version,omitempty"`
-	Goos         string            `yaml:"goos,omitempty"`
-	Goarch       string            `yaml:"goarch,omitempty"`
-	CgoEnabled   *bool             `yaml:"cgo_enabled,omitempty"`
-	Dockerfile   string            `yaml:"dockerfile,omitempty"`
-	Image        string            `yaml:"image,omitempty"`
-	Entrypoint   string            `yaml:"entrypoint,omitempty"`
-	Command      string            `yaml:"command,omitempty"`
-	Env          map[string]string `yaml:"env,omitempty"`
-	Volumes      []string          `yaml:"volumes,omitempty"`
-	Security     string            `yaml:"security,omitempty"`
-	Deployment   *Deployment       `yaml:"deployment,omitempty"`
-	Network      *NetworkConfig    `yaml:"network"`
-	Ports        []string          `yaml:"ports"`
-	Environments []Environment     `yaml:"environments,omitempty"`
+	Name          string               `yaml:"name,omitempty" doc:"Application name (defaults to the apps key)"`
+	Lang          string               `yaml:"lang,omitempty" doc:"Programming language"`
+	Path          string               `yaml:"path,omitempty" doc:"Path to the app code, relative to the project root"`
+	WorkDir       string               `yaml:"workdir,omitempty" doc:"Working directory for the build"`
+	BinaryName    string               `yaml:"binary_name,omitempty" doc:"Output binary name (Go only)"`
+	GoVersion     string               `yaml:"go_version,omitempty" doc:"Go version (Go only)"`
+	Goos          string               `yaml:"goos,omitempty" doc:"Target OS (Go only)"`
+	Goarch        string               `yaml:"goarch,omitempty" doc:"Target architecture (Go only)"`
+	CgoEnabled    *bool                `yaml:"cgo_enabled,omitempty" doc:"Enable CGO (Go only)"`
+	Dockerfile    string               `yaml:"dockerfile,omitempty" doc:"Custom Dockerfile path"`
+	Image         string               `yaml:"image,omitempty" doc:"Base image for local builds or a pre-built registry image"`
+	Entrypoint    string               `yaml:"entrypoint,omitempty" doc:"Entrypoint file (non-Go apps)"`
+	Command       string               `yaml:"command,omitempty" doc:"Command to run in the container"`
+	Env           map[string]string    `yaml:"env,omitempty" doc:"Environment variables, overridden by config:set values"`
+	Volumes       []string             `yaml:"volumes,omitempty" doc:"Volume mappings as source:/target[:options]"`
+	Security      string               `yaml:"security,omitempty" doc:"Docker security options"`
+	Deployment    *Deployment          `yaml:"deployment,omitempty" doc:"Deployment settings"`
+	Network       *NetworkConfig       `yaml:"network" doc:"Container network settings"`
+	Ports         []string             `yaml:"ports" doc:"Port mappings as [ip:][host:]container[/proto]"`
+	Environments  []Environment        `yaml:"environments,omitempty" doc:"Deployment environments"`
+	Cron          []CronJob            `yaml:"cron,omitempty" doc:"Scheduled jobs, run as one-off containers from the current release"`
+	Logging       *LoggingConfig       `yaml:"logging,omitempty" doc:"Container log rotation and forwarding"`
+	Notifications []NotificationTarget `yaml:"notifications,omitempty" doc:"Targets notified of deploys, rollbacks, config changes and crash loops"`
 }
 
 // RemoteInfo contains information about remote connection
@@ -52,33 +55,83 @@ type RemoteInfo struct {
 }
 
 type NetworkConfig struct {
-	Mode string `yaml:"mode,omitempty"`
+	Mode string `yaml:"mode,omitempty" doc:"Docker network mode: bridge, host, none, container:<name> or a network name (defaults to the app's private gokku-<app> network)"`
 }
 
 // Deployment represents deployment configuration
 type Deployment struct {
-	KeepReleases  int      `yaml:"keep_releases,omitempty"`
-	KeepImages    int      `yaml:"keep_images,omitempty"`
-	RestartPolicy string   `yaml:"restart_policy,omitempty"`
-	RestartDelay  int      `yaml:"restart_delay,omitempty"`
-	PostDeploy    []string `yaml:"post_deploy,omitempty"`
+	KeepReleases  int          `yaml:"keep_releases,omitempty" doc:"Number of releases to keep"`
+	KeepImages    int          `yaml:"keep_images,omitempty" doc:"Number of Docker images to keep"`
+	RestartPolicy string       `yaml:"restart_policy,omitempty" doc:"Container restart policy"`
+	RestartDelay  int          `yaml:"restart_delay,omitempty" doc:"Delay between restarts in seconds"`
+	PostDeploy    []string     `yaml:"post_deploy,omitempty" doc:"Commands to run after a successful deployment"`
+	Watch         *DeployWatch `yaml:"watch,omitempty" doc:"Crash-loop detection after a deployment"`
+}
+
+// DeployWatch represents the crash-loop detection run after a deployment
+type DeployWatch struct {
+	Window      string `yaml:"window,omitempty" doc:"How long the new release is watched, e.g. 2m (defaults to 1m once watch is set, 0 disables)"`
+	MaxRestarts int    `yaml:"max_restarts,omitempty" doc:"Restarts within the window that count as a crash loop (defaults to 3)"`
+	Rollback    *bool  `yaml:"rollback,omitempty" doc:"Redeploy the previous release on a crash loop (defaults to true)"`
+}
+
+// CronJob represents a scheduled job of an app
+type CronJob struct {
+	Name     string `yaml:"name,omitempty" doc:"Job name used by cron:run (defaults to job-<n>)"`
+	Schedule string `yaml:"schedule" doc:"Cron schedule: five fields or @hourly, @daily, @weekly, @monthly, @yearly"`
+	Command  string `yaml:"command" doc:"Command to run in a one-off container"`
+	Timeout  string `yaml:"timeout,omitempty" doc:"Maximum run time as a duration, e.g. 10m (defaults to 1h)"`
+}
+
+// LoggingConfig represents container log rotation and forwarding
+type LoggingConfig struct {
+	MaxSize string    `yaml:"max_size,omitempty" doc:"Size at which a container log file is rotated, e.g. 10m"`
+	MaxFile int       `yaml:"max_file,omitempty" doc:"Number of rotated log files to keep per container"`
+	Forward []LogSink `yaml:"forward,omitempty" doc:"Sinks the gokku log forwarder ships the app's logs to"`
+}
+
+// LogSink represents a destination of the log forwarder
+type LogSink struct {
+	Type          string            `yaml:"type" doc:"Sink type: file, syslog, http or loki"`
+	Path          string            `yaml:"path,omitempty" doc:"Directory of file sinks (defaults to /var/log/gokku)"`
+	Address       string            `yaml:"address,omitempty" doc:"host:port of the syslog server"`
+	Protocol      string            `yaml:"protocol,omitempty" doc:"Syslog transport: udp or tcp (defaults to udp)"`
+	URL           string            `yaml:"url,omitempty" doc:"Endpoint of http and loki sinks"`
+	Headers       map[string]string `yaml:"headers,omitempty" doc:"Extra HTTP headers, e.g. Authorization"`
+	BatchSize     int               `yaml:"batch_size,omitempty" doc:"Maximum lines per batch (defaults to 100)"`
+	FlushInterval string            `yaml:"flush_interval,omitempty" doc:"Maximum time a line waits before its batch is sent (defaults to 1s)"`
+}
+
+// NotificationTarget represents a destination of event notifications
+type NotificationTarget struct {
+	Type     string   `yaml:"type" doc:"Target type: slack, webhook or email"`
+	URL      string   `yaml:"url,omitempty" doc:"Endpoint of slack and webhook targets"`
+	Secret   string   `yaml:"secret,omitempty" doc:"HMAC-SHA256 key used to sign payloads, e.g. ${secret:<NAME>}"`
+	Events   []string `yaml:"events,omitempty" doc:"Events sent to the target (defaults to all)"`
+	Host     string   `yaml:"host,omitempty" doc:"SMTP server of email targets"`
+	Port     int      `yaml:"port,omitempty" doc:"SMTP port (defaults to 587)"`
+	Username string   `yaml:"username,omitempty" doc:"SMTP username"`
+	Password string   `yaml:"password,omitempty" doc:"SMTP password, e.g. ${secret:<NAME>}"`
+	From     string   `yaml:"from,omitempty" doc:"Sender address of email targets"`
+	To       []string `yaml:"to,omitempty" doc:"Recipients of email targets"`
 }
 
 // Environment represents environment-specific app config
 type Environment struct {
-	Name           string            `yaml:"name"`
-	Branch         string            `yaml:"branch,omitempty"`
-	DefaultEnvVars map[string]string `yaml:"default_env_vars,omitempty"`
+	Name           string            `yaml:"name" doc:"Environment name"`
+	Branch         string            `yaml:"branch,omitempty" doc:"Git branch deployed to this environment"`
+	DefaultEnvVars map[string]string `yaml:"default_env_vars,omitempty" doc:"Environment variables for this environment"`
 }
 
-// Defaults represents default configurations
+// Defaults represents default configurations; app fields are deep-merged into every app
 type Defaults struct {
-	BuildType string `yaml:"build_type,omitempty"`
+	BuildType string `yaml:"build_type,omitempty" doc:"Default build type"`
+	App       `yaml:",inline"`
 }
 
 // Docker represents Docker-related configurations
 type Docker struct {
-	Registry []string `yaml:"registry,omitempty"`
+	Registry []string `yaml:"registry,omitempty" doc:"Custom Docker registries"`
 }
 
 // LoadServerConfig loads the server configuration from gokku.yml
@@ -96,26 +149,30 @@ func LoadServerConfigByApp(appName string) (*ServerConfig, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config ServerConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	config, err := ParseServerConfig(data)
+
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 func LoadAppConfig(appName string) (*App, error) {
-	filePath := fmt.Sprintf("/opt/gokku/apps/%s/gokku.yml", appName)
+	return LoadAppConfigFromFile(fmt.Sprintf("/opt/gokku/apps/%s/gokku.yml", appName), appName)
+}
 
+// LoadAppConfigFromFile loads an app configuration from a specific gokku.yml
+func LoadAppConfigFromFile(filePath, appName string) (*App, error) {
 	data, err := os.ReadFile(filePath)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	var serverConfig ServerConfig
+	serverConfig, err := ParseServerConfig(data)
 
-	if err := yaml.Unmarshal(data, &serverConfig); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
@@ -138,21 +195,13 @@ func (c *ServerConfig) GetApp(name string) (*App, error) {
 	return nil, fmt.Errorf("app '%s' not found", name)
 }
 
-// Validate validates the server configuration
+// Validate validates the server configuration values
 func (c *ServerConfig) Validate() error {
-	if len(c.Apps) == 0 {
-		return fmt.Errorf("no apps defined")
-	}
-
-	for appName, app := range c.Apps {
-		if appName == "" {
-			return fmt.Errorf("app name cannot be empty")
-		}
+	v := &configValidator{}
+	v.validate(c)
 
-		// Validate that either path or image is specified
-		if app.Path == "" && app.Image == "" {
-			return fmt.Errorf("app '%s' must specify either 'path' or 'image'", appName)
-		}
+	if len(v.errs) > 0 {
+		return v.errs
 	}
 
 	return nil
@@ -0,0 +1,646 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// SupportedLangs lists the values accepted by apps[].lang
+	SupportedLangs = []string{"go", "python", "nodejs", "ruby", "docker", "generic"}
+
+	// SupportedRestartPolicies lists the values accepted by deployment.restart_policy
+	SupportedRestartPolicies = []string{"no", "always", "on-failure", "unless-stopped"}
+
+	// SupportedPortStrategies lists the values accepted by port_strategy
+	SupportedPortStrategies = []string{"manual", "auto"}
+
+	// SupportedNetworkModes lists the built-in Docker network modes; user-defined network names are also accepted
+	SupportedNetworkModes = []string{"bridge", "host", "none"}
+
+	// SupportedLogSinks lists the values accepted by logging.forward[].type
+	SupportedLogSinks = []string{"file", "syslog", "http", "loki"}
+
+	// SupportedNotificationTypes lists the values accepted by notifications[].type
+	SupportedNotificationTypes = []string{"slack", "webhook", "email"}
+
+	environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	networkNamePattern     = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	logSizePattern         = regexp.MustCompile(`^[0-9]+[kmg]?$`)
+	yamlLinePattern        = regexp.MustCompile(`^line (\d+): (.*)$`)
+	unknownFieldPattern    = regexp.MustCompile(`^field (\S+) not found in type internal\.(\S+)$`)
+)
+
+// ValidationError describes a single problem found in gokku.yml
+type ValidationError struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	var prefix string
+
+	if e.Line > 0 {
+		prefix = fmt.Sprintf("line %d: ", e.Line)
+	}
+
+	if e.Path != "" {
+		return fmt.Sprintf("%s%s: %s", prefix, e.Path, e.Message)
+	}
+
+	return prefix + e.Message
+}
+
+// ValidationErrors is the list of problems found in gokku.yml
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// ValidateConfigFile strictly validates a gokku.yml file
+func ValidateConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return ValidateConfig(data)
+}
+
+// ValidateConfig strictly decodes gokku.yml content, rejecting unknown keys,
+// and validates its values. Errors carry the line number where possible.
+func ValidateConfig(data []byte) error {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return yamlErrors(err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var config ServerConfig
+
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		if errs := withoutExtensionKeys(yamlErrors(err)); len(errs) > 0 {
+			return errs
+		}
+	}
+
+	// Values are checked after defaults are merged into each app
+	resolved, err := ParseServerConfig(data)
+
+	if err != nil {
+		return yamlErrors(err)
+	}
+
+	v := &configValidator{root: &root}
+	v.validate(resolved)
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+
+	return nil
+}
+
+// yamlErrors converts yaml decoding errors into ValidationErrors
+func yamlErrors(err error) ValidationErrors {
+	var messages []string
+
+	var typeErr *yaml.TypeError
+
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	var errs ValidationErrors
+
+	for _, message := range messages {
+		if matches := yamlLinePattern.FindStringSubmatch(message); matches != nil {
+			line, _ := strconv.Atoi(matches[1])
+			message = matches[2]
+
+			if field := unknownFieldPattern.FindStringSubmatch(message); field != nil {
+				location := "in " + strings.ToLower(field[2])
+
+				if field[2] == "ServerConfig" {
+					location = "at top level"
+				}
+
+				message = fmt.Sprintf("unknown key '%s' %s", field[1], location)
+			}
+
+			errs = append(errs, ValidationError{Line: line, Message: message})
+		} else {
+			errs = append(errs, ValidationError{Message: message})
+		}
+	}
+
+	return errs
+}
+
+// withoutExtensionKeys drops unknown key errors for top-level x- keys, which hold YAML anchors
+func withoutExtensionKeys(errs ValidationErrors) ValidationErrors {
+	var filtered ValidationErrors
+
+	for _, err := range errs {
+		if strings.HasPrefix(err.Message, "unknown key 'x-") && strings.HasSuffix(err.Message, "at top level") {
+			continue
+		}
+
+		filtered = append(filtered, err)
+	}
+
+	return filtered
+}
+
+// configValidator collects semantic errors, locating them in the YAML tree when available
+type configValidator struct {
+	root *yaml.Node
+	errs ValidationErrors
+}
+
+func (v *configValidator) add(path []string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{
+		Line:    v.lineOf(path),
+		Path:    strings.Join(path, "."),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lineOf returns the line of the node at path, falling back to the closest parent
+func (v *configValidator) lineOf(path []string) int {
+	if v.root == nil {
+		return 0
+	}
+
+	node := v.root
+
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := node.Line
+
+	for _, segment := range path {
+		var next *yaml.Node
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(node.Content) {
+				next = node.Content[index]
+			}
+		}
+
+		if next == nil {
+			return line
+		}
+
+		node = next
+		line = node.Line
+	}
+
+	return line
+}
+
+func (v *configValidator) validate(config *ServerConfig) {
+	if len(config.Apps) == 0 {
+		v.add([]string{"apps"}, "no apps defined")
+	}
+
+	appNames := make([]string, 0, len(config.Apps))
+
+	for appName := range config.Apps {
+		appNames = append(appNames, appName)
+	}
+
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
+		v.validateApp(appName, config.Apps[appName])
+	}
+
+	v.validateEnvironments([]string{"environments"}, config.Environments)
+
+	if config.PortStrategy != "" {
+		v.checkConstraint([]string{"port_strategy"}, "ServerConfig.PortStrategy", config.PortStrategy)
+	}
+
+	if config.BasePort != 0 {
+		v.checkConstraint([]string{"base_port"}, "ServerConfig.BasePort", config.BasePort)
+	}
+}
+
+// checkConstraint applies the schema enum and range rules for a field
+func (v *configValidator) checkConstraint(path []string, field string, value interface{}) {
+	constraint, ok := schemaConstraints[field]
+
+	if !ok {
+		return
+	}
+
+	switch val := value.(type) {
+	case string:
+		if len(constraint.enum) > 0 && !contains(constraint.enum, val) {
+			v.add(path, "invalid value '%s' (expected one of: %s)", val, strings.Join(constraint.enum, ", "))
+		}
+	case int:
+		if constraint.minimum != nil && val < *constraint.minimum {
+			v.add(path, "must be at least %d", *constraint.minimum)
+		}
+
+		if constraint.maximum != nil && val > *constraint.maximum {
+			v.add(path, "must be at most %d", *constraint.maximum)
+		}
+	}
+}
+
+func (v *configValidator) validateApp(appName string, app App) {
+	path := []string{"apps", appName}
+
+	if appName == "" {
+		v.add(path, "app name cannot be empty")
+	}
+
+	if app.Path == "" && app.Image == "" {
+		v.add(path, "must specify either 'path' or 'image'")
+	}
+
+	if app.Lang != "" {
+		v.checkConstraint(append(path, "lang"), "App.Lang", app.Lang)
+	}
+
+	for i, port := range app.Ports {
+		if err := ValidatePortSpec(port); err != nil {
+			v.add(append(path, "ports", strconv.Itoa(i)), "%v", err)
+		}
+	}
+
+	for i, volume := range app.Volumes {
+		if err := ValidateVolumeSpec(volume); err != nil {
+			v.add(append(path, "volumes", strconv.Itoa(i)), "%v", err)
+		}
+	}
+
+	if app.Network != nil && app.Network.Mode != "" {
+		if err := ValidateNetworkMode(app.Network.Mode); err != nil {
+			v.add(append(path, "network", "mode"), "%v", err)
+		}
+	}
+
+	if app.Deployment != nil {
+		deploymentPath := append(path, "deployment")
+
+		if app.Deployment.RestartPolicy != "" {
+			if err := ValidateRestartPolicy(app.Deployment.RestartPolicy); err != nil {
+				v.add(append(deploymentPath, "restart_policy"), "%v", err)
+			}
+		}
+
+		v.checkConstraint(append(deploymentPath, "keep_releases"), "Deployment.KeepReleases", app.Deployment.KeepReleases)
+		v.checkConstraint(append(deploymentPath, "keep_images"), "Deployment.KeepImages", app.Deployment.KeepImages)
+		v.checkConstraint(append(deploymentPath, "restart_delay"), "Deployment.RestartDelay", app.Deployment.RestartDelay)
+
+		if watch := app.Deployment.Watch; watch != nil {
+			watchPath := append(deploymentPath, "watch")
+
+			if watch.Window != "" {
+				if window, err := time.ParseDuration(watch.Window); err != nil || window < 0 {
+					v.add(append(watchPath, "window"), "invalid window '%s' (use a duration like 90s or 2m, or 0 to disable)", watch.Window)
+				}
+			}
+
+			if watch.MaxRestarts != 0 {
+				v.checkConstraint(append(watchPath, "max_restarts"), "DeployWatch.MaxRestarts", watch.MaxRestarts)
+			}
+		}
+	}
+
+	v.validateEnvironments(append(path, "environments"), app.Environments)
+	v.validateCronJobs(append(path, "cron"), app.Cron)
+
+	if app.Logging != nil {
+		v.validateLogging(append(path, "logging"), app.Logging)
+	}
+
+	v.validateNotifications(append(path, "notifications"), app.Notifications)
+}
+
+func (v *configValidator) validateLogging(path []string, logging *LoggingConfig) {
+	if logging.MaxSize != "" && !logSizePattern.MatchString(logging.MaxSize) {
+		v.add(append(path, "max_size"), "invalid size '%s' (use a number with an optional k, m or g unit, e.g. 10m)", logging.MaxSize)
+	}
+
+	if logging.MaxFile != 0 {
+		v.checkConstraint(append(path, "max_file"), "LoggingConfig.MaxFile", logging.MaxFile)
+	}
+
+	for i, sink := range logging.Forward {
+		sinkPath := append(append([]string{}, path...), "forward", strconv.Itoa(i))
+
+		if sink.Type == "" {
+			v.add(sinkPath, "type is required")
+			continue
+		}
+
+		v.checkConstraint(append(sinkPath, "type"), "LogSink.Type", sink.Type)
+
+		switch sink.Type {
+		case "syslog":
+			if sink.Address == "" {
+				v.add(sinkPath, "address is required for syslog sinks")
+			} else if _, _, err := net.SplitHostPort(sink.Address); err != nil {
+				v.add(append(sinkPath, "address"), "invalid address '%s' (expected host:port)", sink.Address)
+			}
+
+			if sink.Protocol != "" {
+				v.checkConstraint(append(sinkPath, "protocol"), "LogSink.Protocol", sink.Protocol)
+			}
+		case "http", "loki":
+			if sink.URL == "" {
+				v.add(sinkPath, "url is required for %s sinks", sink.Type)
+			} else if u, err := url.Parse(sink.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				v.add(append(sinkPath, "url"), "invalid url '%s' (expected http:// or https://)", sink.URL)
+			}
+		}
+
+		if sink.BatchSize != 0 {
+			v.checkConstraint(append(sinkPath, "batch_size"), "LogSink.BatchSize", sink.BatchSize)
+		}
+
+		if sink.FlushInterval != "" {
+			if interval, err := time.ParseDuration(sink.FlushInterval); err != nil || interval <= 0 {
+				v.add(append(sinkPath, "flush_interval"), "invalid flush_interval '%s' (use a duration like 500ms or 5s)", sink.FlushInterval)
+			}
+		}
+	}
+}
+
+func (v *configValidator) validateNotifications(path []string, targets []NotificationTarget) {
+	for i, target := range targets {
+		targetPath := append(append([]string{}, path...), strconv.Itoa(i))
+
+		if target.Type == "" {
+			v.add(targetPath, "type is required")
+			continue
+		}
+
+		v.checkConstraint(append(targetPath, "type"), "NotificationTarget.Type", target.Type)
+
+		switch target.Type {
+		case "slack", "webhook":
+			if target.URL == "" {
+				v.add(targetPath, "url is required for %s targets", target.Type)
+			} else if u, err := url.Parse(target.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				v.add(append(targetPath, "url"), "invalid url '%s' (expected http:// or https://)", target.URL)
+			}
+		case "email":
+			if target.Host == "" {
+				v.add(targetPath, "host is required for email targets")
+			}
+
+			if target.From == "" {
+				v.add(targetPath, "from is required for email targets")
+			}
+
+			if len(target.To) == 0 {
+				v.add(targetPath, "to is required for email targets")
+			}
+
+			if target.Port != 0 {
+				v.checkConstraint(append(targetPath, "port"), "NotificationTarget.Port", target.Port)
+			}
+		}
+
+		for j, event := range target.Events {
+			if !contains(NotificationEvents, event) {
+				v.add(append(targetPath, "events", strconv.Itoa(j)), "unknown event '%s' (expected one of: %s)", event, strings.Join(NotificationEvents, ", "))
+			}
+		}
+	}
+}
+
+func (v *configValidator) validateCronJobs(path []string, jobs []CronJob) {
+	seen := make(map[string]bool)
+
+	for i, job := range jobs {
+		jobPath := append(append([]string{}, path...), strconv.Itoa(i))
+		name := CronJobName(job, i)
+
+		if !environmentNamePattern.MatchString(name) {
+			v.add(append(jobPath, "name"), "invalid job name '%s' (use lowercase letters, digits, '-' and '_')", name)
+		} else if seen[name] {
+			v.add(append(jobPath, "name"), "duplicate job '%s'", name)
+		}
+
+		seen[name] = true
+
+		if job.Schedule == "" {
+			v.add(jobPath, "schedule is required")
+		} else if err := ValidateCronSchedule(job.Schedule); err != nil {
+			v.add(append(jobPath, "schedule"), "%v", err)
+		}
+
+		if strings.TrimSpace(job.Command) == "" {
+			v.add(jobPath, "command is required")
+		}
+
+		if job.Timeout != "" {
+			if timeout, err := time.ParseDuration(job.Timeout); err != nil || timeout <= 0 {
+				v.add(append(jobPath, "timeout"), "invalid timeout '%s' (use a duration like 30s, 10m or 1h)", job.Timeout)
+			}
+		}
+	}
+}
+
+func (v *configValidator) validateEnvironments(path []string, environments []Environment) {
+	seen := make(map[string]bool)
+
+	for i, env := range environments {
+		envPath := append(append([]string{}, path...), strconv.Itoa(i), "name")
+
+		if env.Name == "" {
+			v.add(envPath, "environment name cannot be empty")
+			continue
+		}
+
+		if !environmentNamePattern.MatchString(env.Name) {
+			v.add(envPath, "invalid environment name '%s' (use lowercase letters, digits, '-' and '_')", env.Name)
+		}
+
+		if seen[env.Name] {
+			v.add(envPath, "duplicate environment '%s'", env.Name)
+		}
+
+		seen[env.Name] = true
+	}
+}
+
+// ValidatePortSpec validates a Docker port mapping: [ip:][host:]container[/proto]
+func ValidatePortSpec(spec string) error {
+	if spec == "" {
+		return fmt.Errorf("port cannot be empty")
+	}
+
+	mapping := spec
+
+	if idx := strings.LastIndex(spec, "/"); idx != -1 {
+		proto := spec[idx+1:]
+		mapping = spec[:idx]
+
+		if proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return fmt.Errorf("invalid protocol '%s' in port '%s' (expected tcp, udp or sctp)", proto, spec)
+		}
+	}
+
+	parts := strings.Split(mapping, ":")
+
+	if len(parts) > 3 {
+		return fmt.Errorf("invalid port '%s' (expected [ip:][host:]container[/proto])", spec)
+	}
+
+	// The IP part is only present in the three-part form
+	if len(parts) == 3 {
+		parts = parts[1:]
+	}
+
+	for i, part := range parts {
+		// Host port may be omitted (ip::container) to let Docker choose
+		if part == "" && i == 0 && len(parts) == 2 {
+			continue
+		}
+
+		if err := validatePortRange(part); err != nil {
+			return fmt.Errorf("invalid port '%s': %v", spec, err)
+		}
+	}
+
+	return nil
+}
+
+// validatePortRange validates a port number or a start-end range
+func validatePortRange(value string) error {
+	bounds := strings.SplitN(value, "-", 2)
+
+	for _, bound := range bounds {
+		port, err := strconv.Atoi(bound)
+
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("'%s' is not a valid port number", bound)
+		}
+	}
+
+	return nil
+}
+
+// ValidateVolumeSpec validates a Docker volume mapping: source:target[:options]
+func ValidateVolumeSpec(spec string) error {
+	parts := strings.Split(spec, ":")
+
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid volume '%s' (expected source:target[:options])", spec)
+	}
+
+	if parts[0] == "" {
+		return fmt.Errorf("invalid volume '%s': source cannot be empty", spec)
+	}
+
+	if !strings.HasPrefix(parts[1], "/") {
+		return fmt.Errorf("invalid volume '%s': target must be an absolute path", spec)
+	}
+
+	if len(parts) == 3 {
+		validOptions := []string{"ro", "rw", "z", "Z", "cached", "delegated", "consistent", "nocopy"}
+
+		for _, option := range strings.Split(parts[2], ",") {
+			if !contains(validOptions, option) {
+				return fmt.Errorf("invalid volume '%s': unknown option '%s'", spec, option)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateRestartPolicy validates a Docker restart policy, including on-failure:N
+func ValidateRestartPolicy(policy string) error {
+	name, retries, hasRetries := strings.Cut(policy, ":")
+
+	if !contains(SupportedRestartPolicies, name) {
+		return fmt.Errorf("invalid restart policy '%s' (expected one of: %s)", policy, strings.Join(SupportedRestartPolicies, ", "))
+	}
+
+	if hasRetries {
+		if name != "on-failure" {
+			return fmt.Errorf("invalid restart policy '%s': only on-failure accepts a retry count", policy)
+		}
+
+		if count, err := strconv.Atoi(retries); err != nil || count < 0 {
+			return fmt.Errorf("invalid restart policy '%s': retry count must be a positive number", policy)
+		}
+	}
+
+	return nil
+}
+
+// ValidateNetworkMode validates a Docker network mode or user-defined network name
+func ValidateNetworkMode(mode string) error {
+	if contains(SupportedNetworkModes, mode) {
+		return nil
+	}
+
+	if container, ok := strings.CutPrefix(mode, "container:"); ok {
+		if container == "" {
+			return fmt.Errorf("invalid network mode '%s': container name is required", mode)
+		}
+
+		return nil
+	}
+
+	if !networkNamePattern.MatchString(mode) {
+		return fmt.Errorf("invalid network mode '%s' (expected bridge, host, none, container:<name> or a network name)", mode)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,385 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidateConfigTestSuite struct {
+	suite.Suite
+}
+
+func TestValidateConfigTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(ValidateConfigTestSuite))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WithValidConfig() {
+	config := `
+port_strategy: manual
+apps:
+  api:
+    path: ./api
+    lang: go
+    ports:
+      - "8080:8080"
+      - "127.0.0.1:9090:9090/udp"
+    volumes:
+      - "/data:/app/data:ro"
+    network:
+      mode: bridge
+    deployment:
+      keep_releases: 3
+      restart_policy: on-failure:5
+    environments:
+      - name: production
+`
+
+	Expect(ValidateConfig([]byte(config))).To(BeNil())
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WhenKeyIsUnknown() {
+	config := `
+apps:
+  api:
+    path: ./api
+    deployment:
+      keep_relases: 3
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal("line 6: unknown key 'keep_relases' in deployment"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WhenTypeIsWrong() {
+	config := `
+apps:
+  api:
+    path: ./api
+    ports: "8080"
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("line 5:"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_ReportsAllErrorsWithLines() {
+	config := `
+apps:
+  api:
+    path: ./api
+    lang: rust
+    ports:
+      - "80:99999"
+    volumes:
+      - "data:relative"
+    network:
+      mode: "bad mode"
+    deployment:
+      restart_policy: sometimes
+    environments:
+      - name: Production
+      - name: Production
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+
+	errs, ok := err.(ValidationErrors)
+	Expect(ok).To(BeTrue())
+	Expect(errs).To(HaveLen(8))
+	Expect(errs[0].Line).To(Equal(5))
+	Expect(errs[0].Path).To(Equal("apps.api.lang"))
+	Expect(errs[1].Line).To(Equal(7))
+	Expect(errs[2].Line).To(Equal(9))
+	Expect(errs[3].Path).To(Equal("apps.api.network.mode"))
+	Expect(errs[4].Path).To(Equal("apps.api.deployment.restart_policy"))
+	Expect(errs[7].Message).To(ContainSubstring("duplicate environment"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WhenAppHasNoPathOrImage() {
+	err := ValidateConfig([]byte("apps:\n  api:\n    lang: go\n"))
+
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal("line 3: apps.api: must specify either 'path' or 'image'"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WhenNoAppsDefined() {
+	err := ValidateConfig([]byte("docker:\n  registry:\n    - example.com\n"))
+
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("no apps defined"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WithInvalidCronJobs() {
+	config := `
+apps:
+  api:
+    path: ./api
+    cron:
+      - schedule: "*/5 * * * *"
+        command: ./app cleanup
+        timeout: 10m
+      - name: report
+        schedule: "61 * * * *"
+        command: ./app report
+        timeout: soon
+      - name: report
+        schedule: "@daily"
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+
+	errs := err.(ValidationErrors)
+	Expect(errs).To(HaveLen(4))
+	Expect(errs[0].Path).To(Equal("apps.api.cron.1.schedule"))
+	Expect(errs[0].Line).To(Equal(10))
+	Expect(errs[1].Path).To(Equal("apps.api.cron.1.timeout"))
+	Expect(errs[2].Message).To(ContainSubstring("duplicate job 'report'"))
+	Expect(errs[3].Message).To(Equal("command is required"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WithInvalidLogging() {
+	config := `
+apps:
+  api:
+    path: ./api
+    logging:
+      max_size: 10mb
+      max_file: 3
+      forward:
+        - type: file
+        - type: syslog
+          address: logs.example.com
+          protocol: tls
+        - type: loki
+          url: loki:3100/push
+          flush_interval: 5s
+        - type: kafka
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+
+	errs := err.(ValidationErrors)
+	Expect(errs).To(HaveLen(5))
+	Expect(errs[0].Path).To(Equal("apps.api.logging.max_size"))
+	Expect(errs[0].Line).To(Equal(6))
+	Expect(errs[1].Path).To(Equal("apps.api.logging.forward.1.address"))
+	Expect(errs[2].Path).To(Equal("apps.api.logging.forward.1.protocol"))
+	Expect(errs[3].Path).To(Equal("apps.api.logging.forward.2.url"))
+	Expect(errs[4].Message).To(ContainSubstring("invalid value 'kafka'"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WithInvalidDeployWatch() {
+	config := `
+apps:
+  api:
+    path: ./api
+    deployment:
+      watch:
+        window: soon
+        max_restarts: 0
+  worker:
+    path: ./worker
+    deployment:
+      watch:
+        window: -1m
+        max_restarts: -2
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+
+	errs := err.(ValidationErrors)
+	Expect(errs).To(HaveLen(3))
+	Expect(errs[0].Path).To(Equal("apps.api.deployment.watch.window"))
+	Expect(errs[0].Line).To(Equal(7))
+	Expect(errs[1].Path).To(Equal("apps.worker.deployment.watch.window"))
+	Expect(errs[2].Path).To(Equal("apps.worker.deployment.watch.max_restarts"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_WithInvalidNotifications() {
+	config := `
+apps:
+  api:
+    path: ./api
+    notifications:
+      - type: slack
+        url: https://hooks.slack.com/services/T0/B0/X
+        events: [deploy.failed, rollback]
+      - type: webhook
+        url: example.com/hook
+        events: [deploy.finished]
+      - type: email
+        host: smtp.example.com
+      - type: pager
+`
+
+	err := ValidateConfig([]byte(config))
+
+	Expect(err).ToNot(BeNil())
+
+	errs := err.(ValidationErrors)
+	Expect(errs).To(HaveLen(5))
+	Expect(errs[0].Path).To(Equal("apps.api.notifications.1.url"))
+	Expect(errs[1].Path).To(Equal("apps.api.notifications.1.events.0"))
+	Expect(errs[1].Line).To(Equal(11))
+	Expect(errs[2].Message).To(Equal("from is required for email targets"))
+	Expect(errs[3].Message).To(Equal("to is required for email targets"))
+	Expect(errs[4].Message).To(ContainSubstring("invalid value 'pager'"))
+}
+
+func (s *ValidateConfigTestSuite) TestValidatePortSpec() {
+	Expect(ValidatePortSpec("8080")).To(BeNil())
+	Expect(ValidatePortSpec("80:8080")).To(BeNil())
+	Expect(ValidatePortSpec("0.0.0.0:80:8080/tcp")).To(BeNil())
+	Expect(ValidatePortSpec("127.0.0.1::8080")).To(BeNil())
+	Expect(ValidatePortSpec("8000-8010:8000-8010")).To(BeNil())
+	Expect(ValidatePortSpec("0:80")).ToNot(BeNil())
+	Expect(ValidatePortSpec("80:abc")).ToNot(BeNil())
+	Expect(ValidatePortSpec("80:80/http")).ToNot(BeNil())
+}
+
+func (s *ValidateConfigTestSuite) TestValidateVolumeSpec() {
+	Expect(ValidateVolumeSpec("/data:/app/data")).To(BeNil())
+	Expect(ValidateVolumeSpec("named:/app/data:ro,z")).To(BeNil())
+	Expect(ValidateVolumeSpec("/data")).ToNot(BeNil())
+	Expect(ValidateVolumeSpec("/data:app")).ToNot(BeNil())
+	Expect(ValidateVolumeSpec("/data:/app:rx")).ToNot(BeNil())
+}
+
+func (s *ValidateConfigTestSuite) TestValidateRestartPolicy() {
+	Expect(ValidateRestartPolicy("always")).To(BeNil())
+	Expect(ValidateRestartPolicy("on-failure:3")).To(BeNil())
+	Expect(ValidateRestartPolicy("always:3")).ToNot(BeNil())
+	Expect(ValidateRestartPolicy("on-failure:x")).ToNot(BeNil())
+	Expect(ValidateRestartPolicy("sometimes")).ToNot(BeNil())
+}
+
+func (s *ValidateConfigTestSuite) TestValidateNetworkMode() {
+	Expect(ValidateNetworkMode("host")).To(BeNil())
+	Expect(ValidateNetworkMode("container:db")).To(BeNil())
+	Expect(ValidateNetworkMode("my-network")).To(BeNil())
+	Expect(ValidateNetworkMode("container:")).ToNot(BeNil())
+	Expect(ValidateNetworkMode("bad mode")).ToNot(BeNil())
+}
+
+func (s *ValidateConfigTestSuite) TestServerConfigValidate_WithoutLineNumbers() {
+	config := &ServerConfig{Apps: map[string]App{"api": {Lang: "go"}}}
+
+	err := config.Validate()
+
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal("apps.api: must specify either 'path' or 'image'"))
+}
+
+// docYAMLBlockPattern matches the yaml code blocks of the markdown docs
+var docYAMLBlockPattern = regexp.MustCompile("(?s)```ya?ml\n(.*?)```")
+
+// otherFileComment marks an example of another file, e.g. "# prometheus.yml"
+var otherFileComment = regexp.MustCompile(`^#\s*\S+\.ya?ml\s*$`)
+
+// docExampleConfig turns a gokku.yml example from the docs into a complete
+// config. Top-level fragments get an app, app fragments are placed under
+// apps. ok is false for examples of other files.
+func docExampleConfig(block string) (config string, ok bool) {
+	first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(block), "\n", 2)[0])
+
+	if otherFileComment.MatchString(first) && !strings.Contains(first, "gokku.yml") {
+		return "", false
+	}
+
+	var top map[string]any
+	if yaml.Unmarshal([]byte(block), &top) != nil {
+		return block, true
+	}
+
+	if _, ok := top["apps"]; ok {
+		return block, true
+	}
+
+	serverKeys := map[string]bool{}
+	serverType := reflect.TypeOf(ServerConfig{})
+
+	for i := 0; i < serverType.NumField(); i++ {
+		serverKeys[strings.Split(serverType.Field(i).Tag.Get("yaml"), ",")[0]] = true
+	}
+
+	appFragment := false
+
+	for key := range top {
+		if !serverKeys[key] && !strings.HasPrefix(key, "x-") {
+			appFragment = true
+		}
+	}
+
+	if !appFragment {
+		return block + "\napps:\n  example:\n    path: .\n", true
+	}
+
+	wrapped := "apps:\n  example:\n"
+
+	for _, line := range strings.Split(block, "\n") {
+		wrapped += "    " + line + "\n"
+	}
+
+	if top["path"] == nil && top["image"] == nil {
+		wrapped += "    path: .\n"
+	}
+
+	return wrapped, true
+}
+
+func (s *ValidateConfigTestSuite) TestValidateConfig_DocumentationExamples() {
+	files := []string{"../README.md"}
+
+	err := filepath.WalkDir("../docs", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".md") {
+			files = append(files, path)
+		}
+
+		return err
+	})
+	s.Require().NoError(err)
+
+	examples := 0
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		s.Require().NoError(err)
+
+		for i, match := range docYAMLBlockPattern.FindAllStringSubmatch(string(data), -1) {
+			config, ok := docExampleConfig(match[1])
+			if !ok {
+				continue
+			}
+
+			examples++
+
+			Expect(ValidateConfig([]byte(config))).To(BeNil(), fmt.Sprintf("yaml example %d of %s:\n%s", i+1, file, match[1]))
+		}
+	}
+
+	Expect(examples).To(BeNumerically(">", 50))
+}
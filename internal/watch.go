@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWatchWindow is how long a new release is watched after a deploy
+	// when deployment.watch sets no window
+	DefaultWatchWindow = time.Minute
+
+	// DefaultWatchMaxRestarts is how many restarts within the window make a crash loop
+	DefaultWatchMaxRestarts = 3
+
+	// DefaultKeepImages is how many release images are kept for rollbacks
+	DefaultKeepImages = 5
+)
+
+// WatchConfig returns the crash-loop detection settings, defaulted when unset.
+// Watching is opt-in: without deployment.watch the window is 0.
+func (d *Deployment) WatchConfig() DeployWatch {
+	watch := DeployWatch{Window: "0"}
+
+	if d != nil && d.Watch != nil {
+		watch = *d.Watch
+	}
+
+	if watch.MaxRestarts == 0 {
+		watch.MaxRestarts = DefaultWatchMaxRestarts
+	}
+
+	return watch
+}
+
+// WindowDuration returns the watch window; zero disables watching
+func (w DeployWatch) WindowDuration() (time.Duration, error) {
+	if w.Window == "" {
+		return DefaultWatchWindow, nil
+	}
+
+	window, err := time.ParseDuration(w.Window)
+
+	if err != nil || window < 0 {
+		return 0, fmt.Errorf("invalid watch window '%s'", w.Window)
+	}
+
+	return window, nil
+}
+
+// RollbackEnabled reports whether a crash loop redeploys the previous release
+func (w DeployWatch) RollbackEnabled() bool {
+	return w.Rollback == nil || *w.Rollback
+}
+
+// ContainerState is the part of docker inspect the watcher looks at
+type ContainerState struct {
+	Status       string
+	Health       string
+	ExitCode     int
+	RestartCount int
+}
+
+// CrashLoopError reports a release that breached the watch thresholds
+type CrashLoopError struct {
+	Container string
+	Reason    string
+}
+
+func (e *CrashLoopError) Error() string {
+	return fmt.Sprintf("container %s %s", e.Container, e.Reason)
+}
+
+// ReleaseWatcher watches a freshly deployed container for crash loops
+type ReleaseWatcher struct {
+	out      io.Writer
+	interval time.Duration
+
+	inspect func(name string) (ContainerState, error)
+	sleep   func(d time.Duration)
+	now     func() time.Time
+}
+
+// NewReleaseWatcher creates a new ReleaseWatcher reporting progress to out
+func NewReleaseWatcher(out io.Writer) *ReleaseWatcher {
+	return &ReleaseWatcher{
+		out:      out,
+		interval: 2 * time.Second,
+		inspect:  InspectContainerState,
+		sleep:    time.Sleep,
+		now:      time.Now,
+	}
+}
+
+// Watch polls the container until the window ends. It returns a
+// *CrashLoopError when the container restarts MaxRestarts times, stops or
+// reports unhealthy within the window.
+func (w *ReleaseWatcher) Watch(container string, config DeployWatch) error {
+	window, err := config.WindowDuration()
+
+	if err != nil {
+		return err
+	}
+
+	started := w.now()
+
+	initial, err := w.inspect(container)
+
+	if err != nil {
+		return &CrashLoopError{Container: container, Reason: fmt.Sprintf("could not be inspected: %v", err)}
+	}
+
+	for {
+		state, err := w.inspect(container)
+
+		if err != nil {
+			return &CrashLoopError{Container: container, Reason: fmt.Sprintf("could not be inspected: %v", err)}
+		}
+
+		elapsed := w.now().Sub(started).Round(time.Second)
+		restarts := state.RestartCount - initial.RestartCount
+
+		switch {
+		case restarts >= config.MaxRestarts:
+			return &CrashLoopError{Container: container, Reason: fmt.Sprintf("restarted %d times in %s", restarts, elapsed)}
+		case state.Status == "exited" || state.Status == "dead":
+			return &CrashLoopError{Container: container, Reason: fmt.Sprintf("exited with code %d after %s", state.ExitCode, elapsed)}
+		case state.Health == "unhealthy":
+			return &CrashLoopError{Container: container, Reason: fmt.Sprintf("became unhealthy after %s", elapsed)}
+		}
+
+		if elapsed >= window {
+			return nil
+		}
+
+		if restarts > 0 {
+			fmt.Fprintf(w.out, "       %s restarted %d times (%s/%s)\n", container, restarts, elapsed, window)
+		}
+
+		w.sleep(w.interval)
+	}
+}
+
+// InspectContainerState reads the state and restart count of a container
+func InspectContainerState(name string) (ContainerState, error) {
+	output, err := exec.Command("docker", "inspect", "--format", "{{.RestartCount}} {{json .State}}", name).Output()
+
+	if err != nil {
+		return ContainerState{}, fmt.Errorf("failed to inspect %s: %v", name, err)
+	}
+
+	return ParseContainerState(string(output))
+}
+
+// ParseContainerState parses "{{.RestartCount}} {{json .State}}" output
+func ParseContainerState(output string) (ContainerState, error) {
+	count, stateJSON, ok := strings.Cut(strings.TrimSpace(output), " ")
+
+	if !ok {
+		return ContainerState{}, fmt.Errorf("unexpected docker inspect output: %s", output)
+	}
+
+	restarts, err := strconv.Atoi(count)
+
+	if err != nil {
+		return ContainerState{}, fmt.Errorf("unexpected restart count: %s", count)
+	}
+
+	var raw struct {
+		Status   string `json:"Status"`
+		ExitCode int    `json:"ExitCode"`
+		Health   *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	}
+
+	if err := json.Unmarshal([]byte(stateJSON), &raw); err != nil {
+		return ContainerState{}, fmt.Errorf("failed to parse container state: %v", err)
+	}
+
+	state := ContainerState{Status: raw.Status, ExitCode: raw.ExitCode, RestartCount: restarts}
+
+	if raw.Health != nil {
+		state.Health = raw.Health.Status
+	}
+
+	return state, nil
+}
+
+// ReleaseImage is the image tag a release is kept under for rollbacks
+func ReleaseImage(appName, release string) string {
+	return fmt.Sprintf("%s:release-%s", appName, release)
+}
+
+// TagReleaseImage tags the freshly built <app>:latest image as the release image
+func TagReleaseImage(appName, release string) error {
+	output, err := exec.Command("docker", "tag", appName+":latest", ReleaseImage(appName, release)).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("failed to tag release image: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// RestoreReleaseImage makes the image of a release <app>:latest again
+func RestoreReleaseImage(appName, release string) error {
+	output, err := exec.Command("docker", "tag", ReleaseImage(appName, release), appName+":latest").CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("image of release %s not found: %s", release, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// PruneReleaseImages removes all but the newest keep release images of an
+// app. Images still used by a container are left in place.
+func PruneReleaseImages(appName string, keep int) {
+	output, err := exec.Command("docker", "images", appName, "--format", "{{.Tag}}").Output()
+
+	if err != nil {
+		return
+	}
+
+	for _, tag := range StaleReleaseTags(strings.Split(string(output), "\n"), keep) {
+		exec.Command("docker", "rmi", appName+":"+tag).Run()
+	}
+}
+
+// StaleReleaseTags returns the release-* tags beyond the newest keep
+func StaleReleaseTags(tags []string, keep int) []string {
+	var releases []string
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+
+		if strings.HasPrefix(tag, "release-") {
+			releases = append(releases, tag)
+		}
+	}
+
+	if len(releases) <= keep {
+		return nil
+	}
+
+	// Release names are timestamps, so they sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(releases)))
+
+	return releases[keep:]
+}
+
+// PreviousRelease returns the newest release before current that did not
+// fail. Releases deployed before release metadata existed count as good.
+func PreviousRelease(baseDir, appName, current string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(baseDir, "apps", appName, "releases"))
+
+	if err != nil {
+		return "", fmt.Errorf("failed to list releases: %v", err)
+	}
+
+	var releases []string
+
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() < current {
+			releases = append(releases, entry.Name())
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(releases)))
+
+	for _, release := range releases {
+		meta, err := ReadReleaseMetadata(filepath.Join(baseDir, "apps", appName, "releases", release))
+
+		if err == nil && meta.Status != DeployStatusSucceeded {
+			continue
+		}
+
+		return release, nil
+	}
+
+	return "", fmt.Errorf("no previous successful release of %s", appName)
+}
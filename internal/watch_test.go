@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatchTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestWatchTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(WatchTestSuite))
+}
+
+func (s *WatchTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+// newWatcher returns a watcher replaying states, one per poll, on a fake clock
+func (s *WatchTestSuite) newWatcher(states ...ContainerState) *ReleaseWatcher {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	polls := 0
+
+	watcher := NewReleaseWatcher(io.Discard)
+	watcher.now = func() time.Time { return now }
+	watcher.sleep = func(d time.Duration) { now = now.Add(d) }
+	watcher.inspect = func(name string) (ContainerState, error) {
+		state := states[len(states)-1]
+
+		if polls < len(states) {
+			state = states[polls]
+		}
+
+		polls++
+		return state, nil
+	}
+
+	return watcher
+}
+
+func (s *WatchTestSuite) TestWatchConfig_Defaults() {
+	var deployment *Deployment
+
+	// Watching is opt-in
+	watch := deployment.WatchConfig()
+
+	Expect(watch.WindowDuration()).To(BeZero())
+	Expect((&Deployment{}).WatchConfig().WindowDuration()).To(BeZero())
+
+	watch = (&Deployment{Watch: &DeployWatch{}}).WatchConfig()
+
+	Expect(watch.WindowDuration()).To(Equal(DefaultWatchWindow))
+	Expect(watch.MaxRestarts).To(Equal(DefaultWatchMaxRestarts))
+	Expect(watch.RollbackEnabled()).To(BeTrue())
+
+	disabled := false
+	watch = (&Deployment{Watch: &DeployWatch{Window: "0", Rollback: &disabled}}).WatchConfig()
+
+	Expect(watch.WindowDuration()).To(BeZero())
+	Expect(watch.RollbackEnabled()).To(BeFalse())
+
+	_, err := DeployWatch{Window: "soon"}.WindowDuration()
+	Expect(err).To(MatchError("invalid watch window 'soon'"))
+	Expect(NewReleaseWatcher(io.Discard).Watch("api", DeployWatch{Window: "-1m"})).To(MatchError("invalid watch window '-1m'"))
+}
+
+func (s *WatchTestSuite) TestWatch_PassesWhenStable() {
+	running := ContainerState{Status: "running", RestartCount: 1}
+	watcher := s.newWatcher(running)
+
+	Expect(watcher.Watch("api", DeployWatch{Window: "10s", MaxRestarts: 3})).To(Succeed())
+}
+
+func (s *WatchTestSuite) TestWatch_DetectsRestarts() {
+	watcher := s.newWatcher(
+		ContainerState{Status: "running", RestartCount: 0},
+		ContainerState{Status: "running", RestartCount: 0},
+		ContainerState{Status: "restarting", RestartCount: 2},
+		ContainerState{Status: "running", RestartCount: 3},
+	)
+
+	err := watcher.Watch("api", DeployWatch{Window: "1m", MaxRestarts: 3})
+
+	var crashLoop *CrashLoopError
+	Expect(errors.As(err, &crashLoop)).To(BeTrue())
+	Expect(err.Error()).To(Equal("container api restarted 3 times in 4s"))
+}
+
+func (s *WatchTestSuite) TestWatch_DetectsExitAndUnhealthy() {
+	exited := s.newWatcher(ContainerState{Status: "running"}, ContainerState{Status: "exited", ExitCode: 2})
+	Expect(exited.Watch("api", DeployWatch{MaxRestarts: 3})).To(MatchError("container api exited with code 2 after 0s"))
+
+	unhealthy := s.newWatcher(ContainerState{Status: "running"}, ContainerState{Status: "running"}, ContainerState{Status: "running", Health: "unhealthy"})
+	Expect(unhealthy.Watch("api", DeployWatch{MaxRestarts: 3})).To(MatchError("container api became unhealthy after 2s"))
+}
+
+func (s *WatchTestSuite) TestParseContainerState() {
+	state, err := ParseContainerState(`4 {"Status":"running","ExitCode":0,"Health":{"Status":"starting"}}` + "\n")
+
+	Expect(err).To(BeNil())
+	Expect(state).To(Equal(ContainerState{Status: "running", Health: "starting", RestartCount: 4}))
+
+	_, err = ParseContainerState("oops")
+	Expect(err).ToNot(BeNil())
+}
+
+func (s *WatchTestSuite) TestStaleReleaseTags() {
+	tags := []string{"latest", "release-20240103-120000", "release-20240101-120000", "release-20240102-120000", ""}
+
+	Expect(StaleReleaseTags(tags, 2)).To(Equal([]string{"release-20240101-120000"}))
+	Expect(StaleReleaseTags(tags, 5)).To(BeEmpty())
+}
+
+func (s *WatchTestSuite) TestPreviousRelease_SkipsFailedReleases() {
+	releasesDir := filepath.Join(s.tempDir, "apps", "api", "releases")
+
+	for _, release := range []string{"20240101-120000", "20240102-120000", "20240103-120000", "20240104-120000"} {
+		s.Require().NoError(os.MkdirAll(filepath.Join(releasesDir, release), 0755))
+	}
+
+	s.Require().NoError(writeJSONFile(filepath.Join(releasesDir, "20240102-120000", ReleaseMetadataFile), ReleaseMetadata{Status: DeployStatusSucceeded}))
+	s.Require().NoError(writeJSONFile(filepath.Join(releasesDir, "20240103-120000", ReleaseMetadataFile), ReleaseMetadata{Status: DeployStatusFailed}))
+
+	Expect(PreviousRelease(s.tempDir, "api", "20240104-120000")).To(Equal("20240102-120000"))
+	Expect(PreviousRelease(s.tempDir, "api", "20240102-120000")).To(Equal("20240101-120000"))
+
+	_, err := PreviousRelease(s.tempDir, "api", "20240101-120000")
+	Expect(err).To(MatchError(ContainSubstring("no previous successful release")))
+}
@@ -0,0 +1,140 @@
+package pluginsdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Client calls methods on a plugin process
+type Client struct {
+	mu      sync.Mutex
+	nextID  int64
+	writer  io.Writer
+	scanner *bufio.Scanner
+
+	ctx   context.Context
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// NewClient creates a Client that writes requests to w and reads
+// responses from r
+func NewClient(r io.Reader, w io.Writer) *Client {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	return &Client{writer: w, scanner: scanner}
+}
+
+// Start launches the plugin executable at path in dir. The plugin's
+// stderr is streamed to stderr. When ctx is done the plugin is killed and
+// a pending Call returns an error.
+func Start(ctx context.Context, path, dir string, env []string, stderr io.Writer) (*Client, error) {
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %v", err)
+	}
+
+	// A child the plugin started may keep stdout open after the kill
+	context.AfterFunc(ctx, func() { stdout.Close() })
+
+	client := NewClient(stdout, stdin)
+	client.ctx = ctx
+	client.cmd = cmd
+	client.stdin = stdin
+
+	return client, nil
+}
+
+// Call sends a request and decodes the result into result. A plugin error
+// is returned as *Error.
+func (c *Client) Call(method string, params any, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+
+	req := Request{JSONRPC: "2.0", ID: c.nextID, Method: method}
+
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode params: %v", err)
+		}
+
+		req.Params = data
+	}
+
+	if err := json.NewEncoder(c.writer).Encode(req); err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+
+	if !c.scanner.Scan() {
+		if c.ctx != nil && c.ctx.Err() != nil {
+			return fmt.Errorf("plugin did not answer '%s': %v", method, c.ctx.Err())
+		}
+
+		if err := c.scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read response: %v", err)
+		}
+
+		return fmt.Errorf("plugin exited before answering '%s'", method)
+	}
+
+	var resp Response
+
+	if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+		return fmt.Errorf("invalid response from plugin: %v", err)
+	}
+
+	if resp.ID != req.ID {
+		return fmt.Errorf("plugin answered request %d, expected %d", resp.ID, req.ID)
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("invalid result from plugin: %v", err)
+	}
+
+	return nil
+}
+
+// Close closes the plugin's stdin and waits for it to exit, at most until
+// the context given to Start is done
+func (c *Client) Close() error {
+	if c.cmd == nil {
+		return nil
+	}
+
+	c.stdin.Close()
+
+	return c.cmd.Wait()
+}
@@ -0,0 +1,159 @@
+package pluginsdk
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxMessageSize bounds a single protocol message
+const maxMessageSize = 16 * 1024 * 1024
+
+// CommandFunc handles a plugin command. args are the words after the
+// command on the gokku command line, usually starting with the service.
+type CommandFunc func(args []string) (*Result, error)
+
+// ServiceFunc handles a service lifecycle method
+type ServiceFunc func(params ServiceParams) (*Result, error)
+
+// Plugin is a gokku plugin. Leave a handler nil and gokku falls back to the
+// plugin's scripts, or its plugin.yml env contract for Env.
+//
+//	func main() {
+//		plugin := &pluginsdk.Plugin{
+//			Commands: map[string]pluginsdk.CommandFunc{
+//				"info": func(args []string) (*pluginsdk.Result, error) {
+//					return &pluginsdk.Result{Status: "running"}, nil
+//				},
+//			},
+//		}
+//
+//		if err := plugin.Serve(); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+type Plugin struct {
+	Commands  map[string]CommandFunc
+	Install   ServiceFunc
+	Uninstall ServiceFunc
+	Env       ServiceFunc
+	Backup    ServiceFunc
+	Restore   ServiceFunc
+	Upgrade   ServiceFunc
+}
+
+// Serve answers requests on stdin and stdout until gokku closes stdin
+func (p *Plugin) Serve() error {
+	return p.ServeIO(os.Stdin, os.Stdout)
+}
+
+// ServeIO answers the requests read from r on w until r is exhausted
+func (p *Plugin) ServeIO(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	encoder := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		if err := encoder.Encode(p.handle(scanner.Bytes())); err != nil {
+			return fmt.Errorf("failed to write response: %v", err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handle decodes one request and dispatches it to its handler
+func (p *Plugin) handle(line []byte) Response {
+	var req Request
+
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(0, &Error{Code: CodeParseError, Message: err.Error()})
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+	}
+
+	result, err := p.dispatch(req)
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+
+		return errorResponse(req.ID, rpcErr)
+	}
+
+	if result == nil {
+		result = &Result{}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: CodeInternalError, Message: err.Error()})
+	}
+
+	return Response{JSONRPC: "2.0", ID: req.ID, Result: data}
+}
+
+func (p *Plugin) dispatch(req Request) (*Result, error) {
+	switch req.Method {
+	case MethodCommand:
+		var params CommandParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		command, ok := p.Commands[params.Command]
+		if !ok {
+			return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("unknown command '%s'", params.Command)}
+		}
+
+		return command(params.Args)
+	case MethodInstall, MethodUninstall, MethodEnv, MethodBackup, MethodRestore, MethodUpgrade:
+		handler := map[string]ServiceFunc{
+			MethodInstall:   p.Install,
+			MethodUninstall: p.Uninstall,
+			MethodEnv:       p.Env,
+			MethodBackup:    p.Backup,
+			MethodRestore:   p.Restore,
+			MethodUpgrade:   p.Upgrade,
+		}[req.Method]
+
+		if handler == nil {
+			break
+		}
+
+		var params ServiceParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		return handler(params)
+	}
+
+	return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method '%s' not implemented", req.Method)}
+}
+
+func decodeParams(raw json.RawMessage, params any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, params); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
+
+func errorResponse(id int64, err *Error) Response {
+	return Response{JSONRPC: "2.0", ID: id, Error: err}
+}
@@ -0,0 +1,136 @@
+package pluginsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+type PluginTestSuite struct {
+	suite.Suite
+	client *Client
+	done   chan error
+}
+
+func TestPluginTestSuite(t *testing.T) {
+	RegisterTestingT(t)
+	suite.Run(t, new(PluginTestSuite))
+}
+
+func (s *PluginTestSuite) SetupTest() {
+	plugin := &Plugin{
+		Commands: map[string]CommandFunc{
+			"info": func(args []string) (*Result, error) {
+				return &Result{
+					Status: "running",
+					Table:  &Table{Headers: []string{"SERVICE"}, Rows: [][]string{args}},
+				}, nil
+			},
+			"fail": func(args []string) (*Result, error) {
+				return nil, fmt.Errorf("container is gone")
+			},
+		},
+		Env: func(params ServiceParams) (*Result, error) {
+			return &Result{Env: map[string]string{"REDIS_URL": "redis://" + params.Service + ":6379"}}, nil
+		},
+	}
+
+	requests, pluginIn := io.Pipe()
+	pluginOut, responses := io.Pipe()
+
+	s.done = make(chan error, 1)
+
+	go func() {
+		s.done <- plugin.ServeIO(requests, responses)
+		responses.Close()
+	}()
+
+	s.client = NewClient(pluginOut, pluginIn)
+	s.T().Cleanup(func() { pluginIn.Close() })
+}
+
+func (s *PluginTestSuite) TestCall_Command() {
+	var result Result
+
+	err := s.client.Call(MethodCommand, CommandParams{Command: "info", Args: []string{"redis-cache"}}, &result)
+
+	Expect(err).To(BeNil())
+	Expect(result.Status).To(Equal("running"))
+	Expect(result.Table.Rows).To(Equal([][]string{{"redis-cache"}}))
+}
+
+func (s *PluginTestSuite) TestCall_Env() {
+	var result Result
+
+	err := s.client.Call(MethodEnv, ServiceParams{Service: "redis-cache"}, &result)
+
+	Expect(err).To(BeNil())
+	Expect(result.Env).To(HaveKeyWithValue("REDIS_URL", "redis://redis-cache:6379"))
+}
+
+func (s *PluginTestSuite) TestCall_MethodNotFound() {
+	err := s.client.Call(MethodInstall, ServiceParams{Service: "redis-cache"}, nil)
+	Expect(IsMethodNotFound(err)).To(BeTrue())
+
+	err = s.client.Call(MethodCommand, CommandParams{Command: "export"}, nil)
+	Expect(IsMethodNotFound(err)).To(BeTrue())
+	Expect(err).To(MatchError("unknown command 'export' (code -32601)"))
+}
+
+func (s *PluginTestSuite) TestCall_HandlerError() {
+	err := s.client.Call(MethodCommand, CommandParams{Command: "fail"}, nil)
+
+	Expect(err).To(MatchError("container is gone (code -32603)"))
+	Expect(IsMethodNotFound(err)).To(BeFalse())
+}
+
+func (s *PluginTestSuite) TestStart_KillsPluginWhenContextExpires() {
+	dir := s.T().TempDir()
+	path := filepath.Join(dir, "plugin")
+	s.Require().NoError(os.WriteFile(path, []byte("#!/bin/sh\nsleep 30\n"), 0755))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	client, err := Start(ctx, path, dir, nil, io.Discard)
+	s.Require().NoError(err)
+
+	started := time.Now()
+
+	Expect(client.Call(MethodEnv, ServiceParams{Service: "redis-cache"}, nil)).To(MatchError(ContainSubstring("plugin did not answer 'env'")))
+	Expect(client.Close()).ToNot(Succeed())
+	Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+}
+
+func (s *PluginTestSuite) TestServeIO_AnswersMalformedRequests() {
+	var out bytes.Buffer
+
+	input := strings.Join([]string{
+		`not json`,
+		`{"jsonrpc": "1.0", "id": 7, "method": "env"}`,
+		`{"jsonrpc": "2.0", "id": 8, "method": "command", "params": {"command": 3}}`,
+	}, "\n")
+
+	Expect((&Plugin{}).ServeIO(strings.NewReader(input), &out)).To(Succeed())
+
+	decoder := json.NewDecoder(&out)
+	codes := []int{}
+
+	for decoder.More() {
+		var resp Response
+		Expect(decoder.Decode(&resp)).To(Succeed())
+		codes = append(codes, resp.Error.Code)
+	}
+
+	Expect(codes).To(Equal([]int{CodeParseError, CodeInvalidRequest, CodeInvalidParams}))
+}
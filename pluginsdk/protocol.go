@@ -0,0 +1,125 @@
+// Package pluginsdk implements the gokku plugin protocol: JSON-RPC 2.0
+// messages, one per line, over the stdin and stdout of a plugin process.
+// Plugin authors build on Plugin and Serve; gokku itself uses Client.
+//
+// stdout is reserved for the protocol. Anything a plugin wants the user to
+// see while it works, such as progress lines, goes to stderr.
+package pluginsdk
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Protocol is the protocol name a plugin declares in its plugin.yml
+const Protocol = "jsonrpc"
+
+// Methods gokku calls on a plugin
+const (
+	// MethodCommand runs a command, as in gokku <plugin>:<command>
+	MethodCommand = "command"
+
+	// MethodInstall provisions a service created from the plugin
+	MethodInstall = "install"
+
+	// MethodUninstall tears down a destroyed service
+	MethodUninstall = "uninstall"
+
+	// MethodEnv returns the env vars a service exposes to linked apps
+	MethodEnv = "env"
+
+	// MethodBackup dumps a service's data to the file at ServiceParams.Path
+	MethodBackup = "backup"
+
+	// MethodRestore loads a dump from the file at ServiceParams.Path
+	MethodRestore = "restore"
+
+	// MethodUpgrade moves a service to ServiceParams.Version, migrating its
+	// data. Config holds the current version.
+	MethodUpgrade = "upgrade"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Request is a JSON-RPC request sent to a plugin
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a plugin's answer to a Request
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// IsMethodNotFound reports whether err is a plugin saying it does not
+// implement the method, so callers can fall back to another mechanism
+func IsMethodNotFound(err error) bool {
+	rpcErr, ok := err.(*Error)
+	return ok && rpcErr.Code == CodeMethodNotFound
+}
+
+// CommandParams are the params of MethodCommand
+type CommandParams struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// ServiceParams are the params of the service lifecycle methods
+type ServiceParams struct {
+	Service string            `json:"service"`
+	Version string            `json:"version,omitempty"`
+	Config  map[string]string `json:"config,omitempty"`
+
+	// Path is the uncompressed dump file of MethodBackup and MethodRestore
+	Path string `json:"path,omitempty"`
+}
+
+// Result is what every method returns. All fields are optional; gokku
+// prints Output, renders Table, and uses Env and Config where the method
+// calls for them.
+type Result struct {
+	// Output is text printed to the user as is
+	Output string `json:"output,omitempty"`
+
+	// Table is rendered as an ASCII table
+	Table *Table `json:"table,omitempty"`
+
+	// Env holds env vars, for MethodEnv or a command that exports them
+	Env map[string]string `json:"env,omitempty"`
+
+	// Config is merged into the service's config.json after MethodInstall
+	// and MethodUpgrade
+	Config map[string]string `json:"config,omitempty"`
+
+	// Status is a one-word state such as "running" or "stopped"
+	Status string `json:"status,omitempty"`
+}
+
+// Table is tabular output
+type Table struct {
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+}